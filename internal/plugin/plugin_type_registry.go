@@ -95,6 +95,12 @@ var pluginTypes = []pluginTypeMeta{
 		outputType: reflect.TypeFor[schema.OutputMessagePostRendererV1](),
 		configType: reflect.TypeFor[schema.ConfigPostRendererV1](),
 	},
+	{
+		pluginType: "storage/v1",
+		inputType:  reflect.TypeFor[schema.InputMessageStorageV1](),
+		outputType: reflect.TypeFor[schema.OutputMessageStorageV1](),
+		configType: reflect.TypeFor[schema.ConfigStorageV1](),
+	},
 }
 
 var pluginTypesIndex = func() map[string]*pluginTypeMeta {
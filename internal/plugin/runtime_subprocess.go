@@ -104,6 +104,8 @@ func (r *SubprocessPluginRuntime) Invoke(_ context.Context, input *Input) (*Outp
 		return r.runGetter(input)
 	case schema.InputMessagePostRendererV1:
 		return r.runPostrenderer(input)
+	case schema.InputMessageStorageV1:
+		return r.runStorage(input)
 	default:
 		return nil, fmt.Errorf("unsupported subprocess plugin type %q", r.metadata.Type)
 	}
@@ -0,0 +1,80 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+
+	"helm.sh/helm/v4/internal/plugin/schema"
+)
+
+// runStorage invokes a subprocess storage plugin. The input message is written
+// to the plugin's stdin as JSON, and the plugin is expected to write the JSON
+// encoded output message to stdout.
+func (r *SubprocessPluginRuntime) runStorage(input *Input) (*Output, error) {
+	msg, ok := input.Message.(schema.InputMessageStorageV1)
+	if !ok {
+		return nil, fmt.Errorf("plugin %q input message does not implement InputMessageStorageV1", r.metadata.Name)
+	}
+
+	env := parseEnv(os.Environ())
+	maps.Insert(env, maps.All(r.EnvVars))
+	maps.Insert(env, maps.All(parseEnv(input.Env)))
+	env["HELM_PLUGIN_NAME"] = r.metadata.Name
+	env["HELM_PLUGIN_DIR"] = r.pluginDir
+	env["HELM_STORAGE_OPERATION"] = string(msg.Operation)
+
+	command, args, err := PrepareCommands(r.RuntimeConfig.PlatformCommand, false, []string{}, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare plugin command: %w", err)
+	}
+
+	stdin, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal storage plugin input: %w", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.Command(command, args...)
+	cmd.Env = formatEnv(env)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if input.Stderr != nil {
+		cmd.Stderr = input.Stderr
+	}
+
+	slog.Debug("executing plugin command", slog.String("pluginName", r.metadata.Name), slog.String("command", cmd.String()))
+	if err := executeCmd(cmd, r.metadata.Name); err != nil {
+		return nil, err
+	}
+
+	var out schema.OutputMessageStorageV1
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal storage plugin %q output: %w", r.metadata.Name, err)
+	}
+
+	return &Output{
+		Message: out,
+	}, nil
+}
@@ -82,3 +82,37 @@ func TestSubprocessPluginRuntime(t *testing.T) {
 
 	assert.Nil(t, output)
 }
+
+func TestSubprocessPluginRuntimeStorage(t *testing.T) {
+	rc := RuntimeConfigSubprocess{
+		PlatformCommand: []PlatformCommand{
+			{Command: "sh", Args: []string{"-c", `cat >/dev/null; echo "{\"records\":[{\"key\":\"$HELM_STORAGE_OPERATION\",\"namespace\":\"default\"}]}"`}},
+		},
+	}
+	p := &SubprocessPluginRuntime{
+		metadata: Metadata{
+			Name:          "store",
+			Type:          "storage/v1",
+			APIVersion:    "v1",
+			Runtime:       "subprocess",
+			Config:        &schema.ConfigStorageV1{},
+			RuntimeConfig: &rc,
+		},
+		pluginDir:     t.TempDir(),
+		RuntimeConfig: rc,
+	}
+
+	output, err := p.Invoke(t.Context(), &Input{
+		Message: schema.InputMessageStorageV1{
+			Operation: schema.StorageOperationGetV1,
+			Namespace: "default",
+			Key:       "sh.helm.release.v1.foo.v1",
+		},
+	})
+	require.NoError(t, err)
+
+	msg, ok := output.Message.(schema.OutputMessageStorageV1)
+	require.True(t, ok, "expected OutputMessageStorageV1, got %T", output.Message)
+	require.Len(t, msg.Records, 1)
+	assert.Equal(t, "get", msg.Records[0].Key)
+}
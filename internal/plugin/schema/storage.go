@@ -0,0 +1,88 @@
+/*
+ Copyright The Helm Authors.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+ http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package schema
+
+// StorageOperationV1 is the storage driver operation a storage/v1 plugin is
+// asked to perform.
+type StorageOperationV1 string
+
+const (
+	// StorageOperationCreateV1 stores Record, failing with
+	// StorageErrorAlreadyExistsV1 if Key is already present in Namespace.
+	StorageOperationCreateV1 StorageOperationV1 = "create"
+	// StorageOperationUpdateV1 replaces the stored Record, failing with
+	// StorageErrorNotFoundV1 if Key is not present in Namespace.
+	StorageOperationUpdateV1 StorageOperationV1 = "update"
+	// StorageOperationDeleteV1 removes Key from Namespace and returns the
+	// removed record.
+	StorageOperationDeleteV1 StorageOperationV1 = "delete"
+	// StorageOperationGetV1 returns the record stored for Key in Namespace.
+	StorageOperationGetV1 StorageOperationV1 = "get"
+	// StorageOperationListV1 returns all records owned by helm in Namespace,
+	// or in all namespaces if Namespace is empty.
+	StorageOperationListV1 StorageOperationV1 = "list"
+	// StorageOperationQueryV1 returns the records in Namespace, or in all
+	// namespaces if Namespace is empty, whose labels match all of Labels.
+	StorageOperationQueryV1 StorageOperationV1 = "query"
+)
+
+const (
+	// StorageErrorNotFoundV1 is returned by a plugin when the requested
+	// release record does not exist.
+	StorageErrorNotFoundV1 = "NotFound"
+	// StorageErrorAlreadyExistsV1 is returned by a plugin when creating a
+	// release record that already exists.
+	StorageErrorAlreadyExistsV1 = "AlreadyExists"
+)
+
+// StorageRecordV1 is a single stored release.
+type StorageRecordV1 struct {
+	// Key is the storage key of the release, e.g. "sh.helm.release.v1.foo.v1"
+	Key string `json:"key"`
+	// Namespace is the namespace of the release
+	Namespace string `json:"namespace"`
+	// Labels are the release labels, including the system labels "name",
+	// "owner", "status", "version", "createdAt" and "modifiedAt"
+	Labels map[string]string `json:"labels,omitempty"`
+	// Body is the encoded release, opaque to the plugin
+	Body string `json:"body,omitempty"`
+}
+
+// InputMessageStorageV1 implements Input.Message
+type InputMessageStorageV1 struct {
+	Operation StorageOperationV1 `json:"operation"`
+	Namespace string             `json:"namespace"`
+	// Key is set for create, update, delete and get
+	Key string `json:"key,omitempty"`
+	// Record is set for create and update
+	Record *StorageRecordV1 `json:"record,omitempty"`
+	// Labels is set for query
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type OutputMessageStorageV1 struct {
+	// Records holds the result of delete and get (a single record), and of
+	// list and query
+	Records []StorageRecordV1 `json:"records,omitempty"`
+	// Error is either StorageErrorNotFoundV1, StorageErrorAlreadyExistsV1 or
+	// a free form message describing why the operation failed
+	Error string `json:"error,omitempty"`
+}
+
+// ConfigStorageV1 represents the configuration for storage plugins
+type ConfigStorageV1 struct{}
+
+func (c *ConfigStorageV1) Validate() error {
+	return nil
+}
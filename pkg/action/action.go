@@ -44,6 +44,7 @@ import (
 	chart "helm.sh/helm/v4/pkg/chart/v2"
 	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
 	"helm.sh/helm/v4/pkg/engine"
+	"helm.sh/helm/v4/pkg/helmpath"
 	"helm.sh/helm/v4/pkg/kube"
 	"helm.sh/helm/v4/pkg/postrenderer"
 	"helm.sh/helm/v4/pkg/registry"
@@ -94,6 +95,10 @@ type Configuration struct {
 	// Releases stores records of releases.
 	Releases *storage.Storage
 
+	// Locker, if set, serializes operations on the same release. Without a
+	// Locker concurrent operations are detected from pending release statuses.
+	Locker driver.Locker
+
 	// KubeClient is a Kubernetes API client.
 	KubeClient kube.Interface
 
@@ -109,13 +114,34 @@ type Configuration struct {
 	// HookOutputFunc called with container name and returns and expects writer that will receive the log output.
 	HookOutputFunc func(namespace, pod, container string) io.Writer
 
+	// HookParallelism is the maximum number of hooks of equal weight that are
+	// executed concurrently. If zero, up to 8 hooks are executed at a time.
+	// Set it to 1 to execute hooks one after another.
+	HookParallelism int
+
+	// EventSink, if set, receives progress events of install, upgrade and
+	// rollback actions. It must be safe for concurrent use.
+	EventSink EventSink
+
+	// PluginsDirectories are the directories searched for a storage driver
+	// plugin when Init is called with a driver name Helm does not provide.
+	PluginsDirectories []string
+
 	mutex sync.Mutex
+
+	// locks holds the release locks acquired through this configuration.
+	locks     map[string]*heldLock
+	locksLock sync.Mutex
+
+	// parent is the configuration this one was derived from to operate as a
+	// service account. Release locks are held through the parent.
+	parent *Configuration
 }
 
 const (
 	// filenameAnnotation is the annotation key used to store the original filename
 	// information in manifest annotations for post-rendering reconstruction.
-	filenameAnnotation = "postrenderer.helm.sh/postrender-filename"
+	filenameAnnotation = postrenderer.FilenameAnnotation
 )
 
 // annotateAndMerge combines multiple YAML files into a single stream of documents,
@@ -475,6 +501,10 @@ func GetVersionSet(client discovery.ServerResourcesInterface) (common.VersionSet
 
 // recordRelease with an update operation in case reuse has been set.
 func (cfg *Configuration) recordRelease(r *release.Release) {
+	if err := cfg.checkReleaseLock(r.Name); err != nil {
+		slog.Warn("not updating release", "name", r.Name, "revision", r.Version, slog.Any("error", err))
+		return
+	}
 	if err := cfg.Releases.Update(r); err != nil {
 		slog.Warn("failed to update release", "name", r.Name, "revision", r.Version, slog.Any("error", err))
 	}
@@ -490,13 +520,26 @@ func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namesp
 	}
 
 	var store *storage.Storage
+	var locker driver.Locker
 	switch helmDriver {
 	case "secret", "secrets", "":
+		enc, err := storageEncryption()
+		if err != nil {
+			return err
+		}
 		d := driver.NewSecrets(newSecretClient(lazyClient))
+		d.Encryption = enc
 		store = storage.Init(d)
+		locker = driver.NewLeases(newLeaseClient(lazyClient))
 	case "configmap", "configmaps":
+		enc, err := storageEncryption()
+		if err != nil {
+			return err
+		}
 		d := driver.NewConfigMaps(newConfigMapClient(lazyClient))
+		d.Encryption = enc
 		store = storage.Init(d)
+		locker = driver.NewLeases(newLeaseClient(lazyClient))
 	case "memory":
 		var d *driver.Memory
 		if cfg.Releases != nil {
@@ -521,18 +564,53 @@ func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namesp
 			return fmt.Errorf("unable to instantiate SQL driver: %w", err)
 		}
 		store = storage.Init(d)
+		locker = d
+	case "sqlite":
+		connectionString := os.Getenv("HELM_DRIVER_SQL_CONNECTION_STRING")
+		if connectionString == "" {
+			connectionString = helmpath.DataPath("releases.db")
+			if err := os.MkdirAll(filepath.Dir(connectionString), 0755); err != nil {
+				return fmt.Errorf("unable to create SQLite database directory: %w", err)
+			}
+		}
+		d, err := driver.NewSQLite(connectionString, namespace)
+		if err != nil {
+			return fmt.Errorf("unable to instantiate SQLite driver: %w", err)
+		}
+		store = storage.Init(d)
+		locker = d
 	default:
-		return fmt.Errorf("unknown driver %q", helmDriver)
+		// Any other driver name refers to a storage plugin
+		d, err := driver.NewPlugin(cfg.PluginsDirectories, helmDriver, namespace)
+		if err != nil {
+			return fmt.Errorf("unknown driver %q: %w", helmDriver, err)
+		}
+		store = storage.Init(d)
 	}
 
 	cfg.RESTClientGetter = getter
 	cfg.KubeClient = kc
 	cfg.Releases = store
+	cfg.Locker = locker
 	cfg.HookOutputFunc = func(_, _, _ string) io.Writer { return io.Discard }
 
 	return nil
 }
 
+// storageEncryption loads the keys used to encrypt release records at rest
+// from the environment. It returns nil if encryption is not configured.
+func storageEncryption() (*driver.Encryption, error) {
+	enc, err := driver.LoadEncryption(driver.EncryptionOptions{
+		KeyFile:           os.Getenv("HELM_DRIVER_ENCRYPTION_KEY_FILE"),
+		AgeRecipientsFile: os.Getenv("HELM_DRIVER_AGE_RECIPIENTS_FILE"),
+		AgeIdentityFile:   os.Getenv("HELM_DRIVER_AGE_IDENTITY_FILE"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to load release encryption keys: %w", err)
+	}
+	return enc, nil
+}
+
 // SetHookOutputFunc sets the HookOutputFunc on the Configuration.
 func (cfg *Configuration) SetHookOutputFunc(hookOutputFunc func(_, _, _ string) io.Writer) {
 	cfg.HookOutputFunc = hookOutputFunc
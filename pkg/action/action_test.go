@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -78,6 +80,14 @@ metadata:
 data:
   name: value`
 
+var manifestWithFailureHooks = `kind: ConfigMap
+metadata:
+  name: on-failure
+  annotations:
+    "helm.sh/hook": post-install-failure,post-upgrade-failure,post-rollback-failure
+data:
+  name: value`
+
 var manifestWithTestHook = `kind: Pod
   metadata:
 	name: finding-nemo,
@@ -358,6 +368,36 @@ func TestConfiguration_Init(t *testing.T) {
 	}
 }
 
+func TestConfiguration_InitStoragePlugin(t *testing.T) {
+	dir := t.TempDir()
+	pluginDir := filepath.Join(dir, "store")
+	require.NoError(t, os.MkdirAll(pluginDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "plugin.yaml"), []byte(`apiVersion: v1
+name: store
+type: storage/v1
+runtime: subprocess
+version: 0.1.0
+runtimeConfig:
+  platformCommand:
+  - command: store
+`), 0o644))
+
+	cfg := &Configuration{PluginsDirectories: []string{dir}}
+	require.NoError(t, cfg.Init(nil, "default", "store"))
+	assert.IsType(t, &driver.Plugin{}, cfg.Releases.Driver)
+
+	cfg = &Configuration{}
+	assert.ErrorContains(t, cfg.Init(nil, "default", "store"), `unknown driver "store": plugin:`)
+}
+
+func TestConfiguration_InitSQLite(t *testing.T) {
+	t.Setenv("HELM_DRIVER_SQL_CONNECTION_STRING", filepath.Join(t.TempDir(), "releases.db"))
+
+	cfg := &Configuration{}
+	require.NoError(t, cfg.Init(nil, "default", "sqlite"))
+	assert.IsType(t, &driver.SQL{}, cfg.Releases.Driver)
+}
+
 func TestGetVersionSet(t *testing.T) {
 	client := fakeclientset.NewClientset()
 
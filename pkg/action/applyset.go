@@ -0,0 +1,36 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"helm.sh/helm/v4/pkg/kube"
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// applySetID returns the ID of the ApplySet of a release.
+func applySetID(rel *release.Release) string {
+	return kube.NewApplySet(kube.ApplySetName(rel.Name), rel.Namespace).ID()
+}
+
+// releaseApplySet returns the ApplySet of a release, or nil if the release
+// does not use one.
+func releaseApplySet(rel *release.Release) *kube.ApplySet {
+	if rel.ApplySetID == "" {
+		return nil
+	}
+	return kube.NewApplySet(kube.ApplySetName(rel.Name), rel.Namespace)
+}
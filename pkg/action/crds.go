@@ -0,0 +1,184 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/kube"
+)
+
+// CRDPolicy determines how the CRDs in the crds/ directories of a chart and
+// its subcharts are applied.
+type CRDPolicy string
+
+const (
+	// CRDPolicySkip leaves the CRDs of the chart alone.
+	CRDPolicySkip CRDPolicy = "skip"
+	// CRDPolicyCreate creates the CRDs which are not present in the cluster.
+	CRDPolicyCreate CRDPolicy = "create"
+	// CRDPolicyCreateOrUpdate creates the CRDs which are not present in the
+	// cluster, and updates the present ones with server-side apply. Updates
+	// which would remove or stop serving a version still holding objects are
+	// refused.
+	CRDPolicyCreateOrUpdate CRDPolicy = "create-or-update"
+)
+
+// Validate returns an error if the policy is not known. The empty policy is
+// valid, and stands for the default policy of an action.
+func (p CRDPolicy) Validate() error {
+	switch p {
+	case "", CRDPolicySkip, CRDPolicyCreate, CRDPolicyCreateOrUpdate:
+		return nil
+	}
+	return fmt.Errorf("invalid CRD policy %q, allowed values: %s, %s, %s", p, CRDPolicySkip, CRDPolicyCreate, CRDPolicyCreateOrUpdate)
+}
+
+// resolveCRDPolicy returns the CRD policy to use for an action, given the
+// policy it is configured with, whether it skips CRDs, and its default
+// policy.
+func resolveCRDPolicy(policy CRDPolicy, skipCRDs bool, defaultPolicy CRDPolicy) (CRDPolicy, error) {
+	if err := policy.Validate(); err != nil {
+		return "", err
+	}
+	if skipCRDs {
+		if policy != "" && policy != CRDPolicySkip {
+			return "", fmt.Errorf("skipping CRDs conflicts with the CRD policy %q", policy)
+		}
+		return CRDPolicySkip, nil
+	}
+	if policy == "" {
+		return defaultPolicy, nil
+	}
+	return policy, nil
+}
+
+// addCRDs adds the permissions to apply CRDs with the policy.
+func (p *permissions) addCRDs(policy CRDPolicy) {
+	if policy == CRDPolicySkip {
+		return
+	}
+	for _, verb := range []string{"get", "create", "patch"} {
+		p.resources = append(p.resources, kube.Permission{Verb: verb, Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"})
+	}
+}
+
+// installCRDs applies the CRDs of a chart with the policy, waits for the
+// created and updated ones to be recognized, and resets the caches which
+// depend on them.
+func (cfg *Configuration) installCRDs(crds []chart.CRD, policy CRDPolicy, serverSideApply, forceConflicts bool, waitStrategy kube.WaitStrategy) error {
+	if policy == CRDPolicySkip || len(crds) == 0 {
+		return nil
+	}
+
+	// We do these one file at a time in the order they were read.
+	var resources kube.ResourceList
+	for _, obj := range crds {
+		// Read in the resources
+		res, err := cfg.KubeClient.Build(bytes.NewBuffer(obj.File.Data), false)
+		if err != nil {
+			return fmt.Errorf("failed to install CRD %s: %w", obj.Name, err)
+		}
+		resources = append(resources, res...)
+	}
+
+	totalItems, err := cfg.applyCRDs(resources, policy, serverSideApply, forceConflicts)
+	if err != nil {
+		return err
+	}
+	if len(totalItems) > 0 {
+		waiter, err := cfg.getWaiter(waitStrategy)
+		if err != nil {
+			return fmt.Errorf("unable to get waiter: %w", err)
+		}
+		// Give time for the CRD to be recognized.
+		if err := waiter.Wait(totalItems, 60*time.Second); err != nil {
+			return err
+		}
+
+		// If we have already gathered the capabilities, we need to invalidate
+		// the cache so that the new CRDs are recognized. This should only be
+		// the case when an action configuration is reused for multiple actions,
+		// as otherwise it is later loaded by ourselves when getCapabilities
+		// is called later on in the installation process.
+		if cfg.Capabilities != nil {
+			discoveryClient, err := cfg.RESTClientGetter.ToDiscoveryClient()
+			if err != nil {
+				return err
+			}
+
+			slog.Debug("clearing discovery cache")
+			discoveryClient.Invalidate()
+
+			_, _ = discoveryClient.ServerGroups()
+		}
+
+		// Invalidate the REST mapper, since it will not have the new CRDs
+		// present.
+		restMapper, err := cfg.RESTClientGetter.ToRESTMapper()
+		if err != nil {
+			return err
+		}
+		if resettable, ok := restMapper.(meta.ResettableRESTMapper); ok {
+			slog.Debug("clearing REST mapper cache")
+			resettable.Reset()
+		}
+	}
+	return nil
+}
+
+// applyCRDs applies the CRDs with the policy, and returns the ones which were
+// created or updated. CRDs are always applied server-side by clients which
+// support it. Otherwise, they can only be created.
+func (cfg *Configuration) applyCRDs(crds kube.ResourceList, policy CRDPolicy, serverSideApply, forceConflicts bool) (kube.ResourceList, error) {
+	if applier, ok := cfg.KubeClient.(kube.InterfaceCRDs); ok {
+		res, err := applier.ApplyCRDs(crds, policy == CRDPolicyCreateOrUpdate, forceConflicts)
+		if err != nil {
+			return nil, err
+		}
+		cfg.emitResult(res)
+		return append(res.Created, res.Updated...), nil
+	}
+	if policy == CRDPolicyCreateOrUpdate {
+		return nil, errors.New("the Kubernetes client does not support updating CRDs")
+	}
+
+	var created kube.ResourceList
+	for _, info := range crds {
+		// Send them to Kube
+		if _, err := cfg.KubeClient.Create(
+			kube.ResourceList{info},
+			kube.ClientCreateOptionServerSideApply(serverSideApply, forceConflicts)); err != nil {
+			// If the error is CRD already exists, continue.
+			if apierrors.IsAlreadyExists(err) {
+				slog.Debug("CRD is already present. Skipping", "crd", info.Name)
+				continue
+			}
+			return nil, fmt.Errorf("failed to install CRD %s: %w", info.Name, err)
+		}
+		created = append(created, info)
+	}
+	return created, nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v4/pkg/chart/common"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/kube"
+	kubefake "helm.sh/helm/v4/pkg/kube/fake"
+	rcommon "helm.sh/helm/v4/pkg/release/common"
+)
+
+func TestResolveCRDPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy        CRDPolicy
+		skipCRDs      bool
+		expected      CRDPolicy
+		expectedError string
+	}{
+		"default": {
+			expected: CRDPolicyCreate,
+		},
+		"policy": {
+			policy:   CRDPolicyCreateOrUpdate,
+			expected: CRDPolicyCreateOrUpdate,
+		},
+		"skip CRDs": {
+			skipCRDs: true,
+			expected: CRDPolicySkip,
+		},
+		"skip CRDs with skip policy": {
+			policy:   CRDPolicySkip,
+			skipCRDs: true,
+			expected: CRDPolicySkip,
+		},
+		"skip CRDs with create policy": {
+			policy:        CRDPolicyCreate,
+			skipCRDs:      true,
+			expectedError: `skipping CRDs conflicts with the CRD policy "create"`,
+		},
+		"invalid policy": {
+			policy:        "update",
+			expectedError: `invalid CRD policy "update", allowed values: skip, create, create-or-update`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			policy, err := resolveCRDPolicy(tt.policy, tt.skipCRDs, CRDPolicyCreate)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, policy)
+		})
+	}
+}
+
+// crdKubeClient records whether CRDs are updated when it is asked to apply
+// them.
+type crdKubeClient struct {
+	*kubefake.FailingKubeClient
+	updates []bool
+}
+
+func (c *crdKubeClient) ApplyCRDs(_ kube.ResourceList, update, _ bool) (*kube.Result, error) {
+	c.updates = append(c.updates, update)
+	return &kube.Result{}, nil
+}
+
+func buildChartWithCRDs() *chart.Chart {
+	ch := buildChart()
+	ch.Files = append(ch.Files, &common.File{
+		Name: "crds/widgets.yaml",
+		Data: []byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\n"),
+	})
+	return ch
+}
+
+func TestUpgradeRelease_CRDPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy          CRDPolicy
+		skipCRDs        bool
+		expectedUpdates []bool
+		expectedError   string
+	}{
+		"default": {},
+		"create": {
+			policy:          CRDPolicyCreate,
+			expectedUpdates: []bool{false},
+		},
+		"create or update": {
+			policy:          CRDPolicyCreateOrUpdate,
+			expectedUpdates: []bool{true},
+		},
+		"skip CRDs with create or update policy": {
+			policy:        CRDPolicyCreateOrUpdate,
+			skipCRDs:      true,
+			expectedError: `skipping CRDs conflicts with the CRD policy "create-or-update"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			upAction := upgradeAction(t)
+			kubeClient := &crdKubeClient{FailingKubeClient: upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)}
+			upAction.cfg.KubeClient = kubeClient
+			upAction.CRDPolicy = tt.policy
+			upAction.SkipCRDs = tt.skipCRDs
+			rel := releaseStub()
+			rel.Name = "crds"
+			rel.Info.Status = rcommon.StatusDeployed
+			require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+			_, err := upAction.Run(rel.Name, buildChartWithCRDs(), map[string]interface{}{})
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedUpdates, kubeClient.updates)
+		})
+	}
+}
+
+func TestInstallRelease_CRDPolicy(t *testing.T) {
+	instAction := installAction(t)
+	kubeClient := &crdKubeClient{FailingKubeClient: instAction.cfg.KubeClient.(*kubefake.FailingKubeClient)}
+	instAction.cfg.KubeClient = kubeClient
+
+	_, err := instAction.Run(buildChartWithCRDs(), map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false}, kubeClient.updates)
+}
@@ -0,0 +1,515 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/structured-merge-diff/v6/fieldpath"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v4/pkg/chart"
+	chartv2 "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/kube"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	releaseutil "helm.sh/helm/v4/pkg/release/v1/util"
+)
+
+// DiffChange describes how a single resource differs between two manifests.
+type DiffChange string
+
+const (
+	// DiffAdded indicates the resource only exists in the target manifest.
+	DiffAdded DiffChange = "added"
+	// DiffRemoved indicates the resource only exists in the current manifest.
+	DiffRemoved DiffChange = "removed"
+	// DiffModified indicates the resource exists in both manifests with different content.
+	DiffModified DiffChange = "modified"
+	// DiffUnchanged indicates the resource exists in both manifests with identical content.
+	DiffUnchanged DiffChange = "unchanged"
+)
+
+// secretMask replaces Secret values that are hidden from the diff output.
+const secretMask = "++++++++"
+
+var sourceCommentRe = regexp.MustCompile(`(?m)^#\s*Source:\s*(.+)$`)
+
+// ResourceDiff is the difference of a single Kubernetes resource between two
+// release manifests.
+type ResourceDiff struct {
+	// Key identifies the resource as "apiVersion/kind/namespace/name".
+	Key        string `json:"key"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Source is the chart template the resource was rendered from.
+	Source string     `json:"source,omitempty"`
+	Change DiffChange `json:"change"`
+	// Diff is the unified diff of the normalized resource.
+	Diff string `json:"diff,omitempty"`
+	// Ownership lists the server-side apply field ownership changes caused by
+	// applying the resource. It is only populated when Diff.ShowOwnership is set.
+	Ownership []FieldOwnershipChange `json:"ownership,omitempty"`
+}
+
+// FieldOwnershipChange records a field whose set of server-side apply managers
+// would change when the target manifest is applied.
+type FieldOwnershipChange struct {
+	Field  string   `json:"field"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// Diff is the action for previewing the changes an install, upgrade or
+// rollback would make to a release.
+//
+// It provides the implementation of 'helm diff'.
+type Diff struct {
+	cfg *Configuration
+
+	// ShowSecrets disables masking of Secret data and stringData values.
+	ShowSecrets bool
+	// Context is the number of context lines shown around each change.
+	Context int
+	// ShowUnchanged includes resources without changes in the result.
+	ShowUnchanged bool
+	// ShowOwnership computes server-side apply field ownership changes by
+	// performing a server-side dry-run apply of every added or modified resource.
+	ShowOwnership bool
+	// ForceConflicts is passed to the server-side dry-run apply used for ShowOwnership.
+	ForceConflicts bool
+}
+
+// NewDiff creates a new Diff object with the given configuration.
+func NewDiff(cfg *Configuration) *Diff {
+	return &Diff{
+		cfg:     cfg,
+		Context: 3,
+	}
+}
+
+// RunUpgrade computes the changes the given upgrade would make to the named release.
+//
+// The upgrade is prepared with a server-side dry-run so that nothing is
+// persisted to the cluster or to release storage.
+func (d *Diff) RunUpgrade(u *Upgrade, name string, ch chart.Charter, vals map[string]interface{}) ([]*ResourceDiff, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	var chrt *chartv2.Chart
+	switch c := ch.(type) {
+	case *chartv2.Chart:
+		chrt = c
+	case chartv2.Chart:
+		chrt = &c
+	default:
+		return nil, errors.New("invalid chart apiVersion")
+	}
+
+	u.DryRunStrategy = DryRunServer
+	current, target, _, err := u.prepareUpgrade(name, chrt, vals)
+	if err != nil {
+		return nil, err
+	}
+	return d.Releases(current, target)
+}
+
+// RunRollback computes the changes the given rollback would make to the named release.
+func (d *Diff) RunRollback(r *Rollback, name string) ([]*ResourceDiff, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	r.DryRunStrategy = DryRunServer
+	current, target, _, err := r.prepareRollback(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.Releases(current, target)
+}
+
+// Releases computes the per-resource differences between the manifests of two
+// releases. A nil current release is treated as an empty manifest, which is
+// the case for an install.
+func (d *Diff) Releases(current, target *release.Release) ([]*ResourceDiff, error) {
+	var currentManifest, namespace string
+	if current != nil {
+		currentManifest = current.Manifest
+		namespace = current.Namespace
+	}
+	if target == nil {
+		return nil, errMissingRelease
+	}
+	if namespace == "" {
+		namespace = target.Namespace
+	}
+
+	diffs, err := d.Manifests(currentManifest, target.Manifest, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.ShowOwnership {
+		if err := d.addOwnershipChanges(diffs, target); err != nil {
+			return nil, err
+		}
+	}
+	return diffs, nil
+}
+
+// Manifests computes the per-resource differences between two manifest streams.
+//
+// Resources are matched by apiVersion, kind, namespace and name. Resources
+// without a namespace are assumed to live in the given namespace.
+func (d *Diff) Manifests(current, target, namespace string) ([]*ResourceDiff, error) {
+	before, beforeOrder, err := parseDiffManifest(current, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse current manifest: %w", err)
+	}
+	after, afterOrder, err := parseDiffManifest(target, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse target manifest: %w", err)
+	}
+
+	// Keep the order of the target manifest, followed by removed resources in
+	// the order of the current manifest.
+	keys := slices.Clone(afterOrder)
+	for _, k := range beforeOrder {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	var result []*ResourceDiff
+	for _, k := range keys {
+		a, b := before[k], after[k]
+
+		ref := b
+		if ref == nil {
+			ref = a
+		}
+		rd := &ResourceDiff{
+			Key:        k,
+			APIVersion: ref.apiVersion,
+			Kind:       ref.kind,
+			Namespace:  ref.namespace,
+			Name:       ref.name,
+			Source:     ref.source,
+		}
+
+		var aObj, bObj map[string]interface{}
+		if a != nil {
+			aObj = a.object
+		}
+		if b != nil {
+			bObj = b.object
+		}
+		if !d.ShowSecrets && ref.kind == "Secret" {
+			aObj, bObj = maskSecretData(aObj, bObj)
+		}
+
+		aText, err := marshalDiffObject(aObj)
+		if err != nil {
+			return nil, err
+		}
+		bText, err := marshalDiffObject(bObj)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case a == nil:
+			rd.Change = DiffAdded
+		case b == nil:
+			rd.Change = DiffRemoved
+		case aText == bText:
+			rd.Change = DiffUnchanged
+		default:
+			rd.Change = DiffModified
+		}
+
+		if rd.Change == DiffUnchanged && !d.ShowUnchanged {
+			continue
+		}
+
+		if rd.Change != DiffUnchanged {
+			rd.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        splitDiffLines(aText),
+				B:        splitDiffLines(bText),
+				FromFile: k,
+				ToFile:   k,
+				Context:  d.Context,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to diff %s: %w", k, err)
+			}
+		}
+		result = append(result, rd)
+	}
+	return result, nil
+}
+
+// addOwnershipChanges performs a server-side dry-run apply of the target
+// resources and compares the resulting managedFields with the live objects.
+func (d *Diff) addOwnershipChanges(diffs []*ResourceDiff, target *release.Release) error {
+	byKey := make(map[string]*ResourceDiff, len(diffs))
+	for _, rd := range diffs {
+		if rd.Change == DiffAdded || rd.Change == DiffModified {
+			byKey[rd.Key] = rd
+		}
+	}
+	if len(byKey) == 0 {
+		return nil
+	}
+
+	resources, err := d.cfg.KubeClient.Build(bytes.NewBufferString(target.Manifest), false)
+	if err != nil {
+		return fmt.Errorf("unable to build kubernetes objects from target manifest: %w", err)
+	}
+	if err := resources.Visit(setMetadataVisitor(target.Name, target.Namespace, true)); err != nil {
+		return err
+	}
+
+	return resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		rd, ok := byKey[objectKey(info)]
+		if !ok {
+			return nil
+		}
+
+		var live runtime.Object
+		if rd.Change == DiffModified {
+			live, err = resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("could not get information about the resource %s: %w", resourceString(info), err)
+			}
+		}
+
+		if _, err := d.cfg.KubeClient.Create(
+			kube.ResourceList{info},
+			kube.ClientCreateOptionServerSideApply(true, d.ForceConflicts),
+			kube.ClientCreateOptionDryRun(true)); err != nil {
+			return fmt.Errorf("server-side dry-run apply of %s failed: %w", resourceString(info), err)
+		}
+
+		rd.Ownership, err = fieldOwnershipChanges(live, info.Object)
+		return err
+	})
+}
+
+// fieldOwnershipChanges compares the managedFields of two versions of an object
+// and returns every leaf field whose set of managers differs.
+func fieldOwnershipChanges(before, after runtime.Object) ([]FieldOwnershipChange, error) {
+	beforeOwners, err := fieldOwners(before)
+	if err != nil {
+		return nil, err
+	}
+	afterOwners, err := fieldOwners(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]struct{}, len(beforeOwners)+len(afterOwners))
+	for f := range beforeOwners {
+		fields[f] = struct{}{}
+	}
+	for f := range afterOwners {
+		fields[f] = struct{}{}
+	}
+
+	var changes []FieldOwnershipChange
+	for f := range fields {
+		b, a := beforeOwners[f], afterOwners[f]
+		if slices.Equal(b, a) {
+			continue
+		}
+		changes = append(changes, FieldOwnershipChange{Field: f, Before: b, After: a})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// fieldOwners maps every leaf field path of an object's managedFields to the
+// sorted list of managers owning it.
+func fieldOwners(obj runtime.Object) (map[string][]string, error) {
+	owners := map[string][]string{}
+	if obj == nil {
+		return owners, nil
+	}
+
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	for _, mf := range metaObj.GetManagedFields() {
+		if mf.FieldsV1 == nil {
+			continue
+		}
+		set := &fieldpath.Set{}
+		if err := set.FromJSON(bytes.NewReader(mf.FieldsV1.Raw)); err != nil {
+			return nil, fmt.Errorf("unable to parse managed fields of manager %q: %w", mf.Manager, err)
+		}
+		manager := managerName(mf)
+		set.Leaves().Iterate(func(p fieldpath.Path) {
+			f := p.String()
+			if !slices.Contains(owners[f], manager) {
+				owners[f] = append(owners[f], manager)
+			}
+		})
+	}
+	for f := range owners {
+		sort.Strings(owners[f])
+	}
+	return owners, nil
+}
+
+func managerName(mf metav1.ManagedFieldsEntry) string {
+	if mf.Operation == metav1.ManagedFieldsOperationUpdate {
+		return fmt.Sprintf("%s (%s)", mf.Manager, mf.Operation)
+	}
+	return mf.Manager
+}
+
+type diffObject struct {
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+	source     string
+	object     map[string]interface{}
+}
+
+// parseDiffManifest splits a manifest stream with releaseutil.SplitManifests
+// and indexes the parsed documents by their resource key.
+func parseDiffManifest(manifest, namespace string) (map[string]*diffObject, []string, error) {
+	docs := releaseutil.SplitManifests(manifest)
+	names := make([]string, 0, len(docs))
+	for name := range docs {
+		names = append(names, name)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(names))
+
+	objects := make(map[string]*diffObject, len(docs))
+	var order []string
+	for _, name := range names {
+		content := docs[name]
+
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(content), &obj); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+
+		o := &diffObject{object: obj}
+		o.apiVersion, _ = obj["apiVersion"].(string)
+		o.kind, _ = obj["kind"].(string)
+		if md, ok := obj["metadata"].(map[string]interface{}); ok {
+			o.name, _ = md["name"].(string)
+			o.namespace, _ = md["namespace"].(string)
+		}
+		if o.namespace == "" {
+			o.namespace = namespace
+		}
+		if m := sourceCommentRe.FindStringSubmatch(content); m != nil {
+			o.source = strings.TrimSpace(m[1])
+		}
+
+		key := fmt.Sprintf("%s/%s/%s/%s", o.apiVersion, o.kind, o.namespace, o.name)
+		if _, ok := objects[key]; ok {
+			slog.Warn("duplicate resource in manifest, ignoring", "key", key, "manifest", name)
+			continue
+		}
+		objects[key] = o
+		order = append(order, key)
+	}
+	return objects, order, nil
+}
+
+func marshalDiffObject(obj map[string]interface{}) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// splitDiffLines splits text into newline terminated lines for difflib.
+// Unlike difflib.SplitLines, it does not add an empty trailing line.
+func splitDiffLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maskSecretData returns copies of two versions of a Secret where all data and
+// stringData values have been replaced by a mask. Values that differ between
+// the versions get distinct masks so the change is still visible.
+func maskSecretData(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	before, after = maps.Clone(before), maps.Clone(after)
+	for _, field := range []string{"data", "stringData"} {
+		bData, _ := before[field].(map[string]interface{})
+		aData, _ := after[field].(map[string]interface{})
+
+		if bData != nil {
+			masked := make(map[string]interface{}, len(bData))
+			for k, v := range bData {
+				masked[k] = maskValue(v, aData, k, "before")
+			}
+			before[field] = masked
+		}
+		if aData != nil {
+			masked := make(map[string]interface{}, len(aData))
+			for k, v := range aData {
+				masked[k] = maskValue(v, bData, k, "after")
+			}
+			after[field] = masked
+		}
+	}
+	return before, after
+}
+
+func maskValue(v interface{}, other map[string]interface{}, key, side string) string {
+	if ov, ok := other[key]; ok && fmt.Sprint(ov) == fmt.Sprint(v) {
+		return secretMask
+	}
+	return fmt.Sprintf("%s (%s, %d bytes)", secretMask, side, len(fmt.Sprint(v)))
+}
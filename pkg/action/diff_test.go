@@ -0,0 +1,189 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const diffCurrentManifest = `---
+# Source: chart/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  key: old
+---
+# Source: chart/templates/removed.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: removed
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+data:
+  same: c2FtZQ==
+  changed: b2xk
+`
+
+const diffTargetManifest = `---
+# Source: chart/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  key: new
+---
+# Source: chart/templates/added.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: added
+  namespace: other
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+data:
+  same: c2FtZQ==
+  changed: bmV3dmFs
+`
+
+func TestDiffManifests(t *testing.T) {
+	client := NewDiff(actionConfigFixture(t))
+
+	diffs, err := client.Manifests(diffCurrentManifest, diffTargetManifest, "default")
+	require.NoError(t, err)
+
+	changes := map[string]DiffChange{}
+	for _, d := range diffs {
+		changes[d.Key] = d.Change
+	}
+	assert.Equal(t, map[string]DiffChange{
+		"v1/ConfigMap/default/cm":      DiffModified,
+		"v1/ConfigMap/other/added":     DiffAdded,
+		"v1/Secret/default/secret":     DiffModified,
+		"v1/ConfigMap/default/removed": DiffRemoved,
+	}, changes)
+
+	assert.Equal(t, "v1/ConfigMap/default/removed", diffs[len(diffs)-1].Key, "removed resources are listed last")
+	assert.Equal(t, "chart/templates/cm.yaml", diffs[0].Source)
+	assert.Contains(t, diffs[0].Diff, "-  key: old\n+  key: new\n")
+}
+
+func TestDiffManifestsUnchanged(t *testing.T) {
+	client := NewDiff(actionConfigFixture(t))
+
+	diffs, err := client.Manifests(diffCurrentManifest, diffCurrentManifest, "default")
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	client.ShowUnchanged = true
+	diffs, err = client.Manifests(diffCurrentManifest, diffCurrentManifest, "default")
+	require.NoError(t, err)
+	require.Len(t, diffs, 3)
+	for _, d := range diffs {
+		assert.Equal(t, DiffUnchanged, d.Change)
+		assert.Empty(t, d.Diff)
+	}
+}
+
+func TestDiffManifestsMasksSecrets(t *testing.T) {
+	client := NewDiff(actionConfigFixture(t))
+
+	diffs, err := client.Manifests(diffCurrentManifest, diffTargetManifest, "default")
+	require.NoError(t, err)
+
+	var secret *ResourceDiff
+	for _, d := range diffs {
+		if d.Kind == "Secret" {
+			secret = d
+		}
+	}
+	require.NotNil(t, secret)
+	assert.NotContains(t, secret.Diff, "b2xk")
+	assert.NotContains(t, secret.Diff, "bmV3dmFs")
+	assert.NotContains(t, secret.Diff, "c2FtZQ==")
+	assert.Contains(t, secret.Diff, "-  changed: ++++++++ (before, 4 bytes)\n")
+	assert.Contains(t, secret.Diff, "+  changed: ++++++++ (after, 8 bytes)\n")
+
+	client.ShowSecrets = true
+	diffs, err = client.Manifests(diffCurrentManifest, diffTargetManifest, "default")
+	require.NoError(t, err)
+	for _, d := range diffs {
+		if d.Kind == "Secret" {
+			assert.Contains(t, d.Diff, "+  changed: bmV3dmFs\n")
+		}
+	}
+}
+
+func TestDiffReleasesInstall(t *testing.T) {
+	client := NewDiff(actionConfigFixture(t))
+
+	target := releaseStub()
+	target.Manifest = diffTargetManifest
+	diffs, err := client.Releases(nil, target)
+	require.NoError(t, err)
+	require.Len(t, diffs, 3)
+	for _, d := range diffs {
+		assert.Equal(t, DiffAdded, d.Change)
+	}
+}
+
+func TestFieldOwnershipChanges(t *testing.T) {
+	withManagers := func(entries ...metav1.ManagedFieldsEntry) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetManagedFields(entries)
+		return u
+	}
+	entry := func(manager string, op metav1.ManagedFieldsOperationType, fields string) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{
+			Manager:   manager,
+			Operation: op,
+			FieldsV1:  &metav1.FieldsV1{Raw: []byte(fields)},
+		}
+	}
+
+	before := withManagers(
+		entry("helm", metav1.ManagedFieldsOperationApply, `{"f:data":{"f:a":{},"f:b":{}}}`),
+		entry("kubectl", metav1.ManagedFieldsOperationUpdate, `{"f:data":{"f:c":{}}}`),
+	)
+	after := withManagers(
+		entry("helm", metav1.ManagedFieldsOperationApply, `{"f:data":{"f:a":{},"f:b":{},"f:c":{}}}`),
+	)
+
+	changes, err := fieldOwnershipChanges(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldOwnershipChange{{
+		Field:  ".data.c",
+		Before: []string{"kubectl (Update)"},
+		After:  []string{"helm"},
+	}}, changes)
+}
@@ -0,0 +1,366 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// DriftStatus describes how a deployed resource compares to the release manifest.
+type DriftStatus string
+
+const (
+	// DriftInSync indicates the live resource matches the release manifest.
+	DriftInSync DriftStatus = "in sync"
+	// DriftModified indicates fields of the live resource differ from the release manifest.
+	DriftModified DriftStatus = "modified"
+	// DriftMissing indicates the resource does not exist in the cluster.
+	DriftMissing DriftStatus = "missing"
+)
+
+// ResourceDrift reports the drift of a single resource of a release.
+type ResourceDrift struct {
+	// Key identifies the resource as "apiVersion/kind/namespace/name".
+	Key        string      `json:"key"`
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Name       string      `json:"name"`
+	Status     DriftStatus `json:"status"`
+	// Fields lists the fields whose live value differs from the manifest.
+	Fields []FieldDrift `json:"fields,omitempty"`
+}
+
+// FieldDrift is a field of a resource whose live value differs from the
+// release manifest.
+type FieldDrift struct {
+	// Field is the path of the field, e.g. `spec.template.spec.containers[name="web"].image`.
+	Field string `json:"field"`
+	// Expected is the value in the release manifest.
+	Expected interface{} `json:"expected,omitempty"`
+	// Live is the value in the cluster, or nil if the field was removed.
+	Live interface{} `json:"live,omitempty"`
+	// Managers are the field managers owning the live value.
+	Managers []string `json:"managers,omitempty"`
+}
+
+// Drift is the action for detecting changes made to the resources of a
+// deployed release outside of Helm.
+//
+// It provides the implementation of 'helm status --drift'.
+type Drift struct {
+	cfg *Configuration
+
+	// Version is the revision of the release to compare with. Zero selects
+	// the latest revision.
+	Version int
+	// ShowSecrets disables masking of Secret data values.
+	ShowSecrets bool
+	// IgnoreManagers are field managers whose changes are not reported, such
+	// as an autoscaler owning spec.replicas.
+	IgnoreManagers []string
+}
+
+// NewDrift creates a new Drift object with the given configuration.
+func NewDrift(cfg *Configuration) *Drift {
+	return &Drift{
+		cfg: cfg,
+	}
+}
+
+// driftIgnoredMetadata are metadata fields populated by the API server.
+var driftIgnoredMetadata = []string{
+	"creationTimestamp",
+	"deletionGracePeriodSeconds",
+	"deletionTimestamp",
+	"generation",
+	"managedFields",
+	"resourceVersion",
+	"selfLink",
+	"uid",
+}
+
+// Run compares the resources of the stored manifest of the named release
+// with the live objects in the cluster.
+//
+// Only fields set in the manifest are compared, so fields populated by the
+// API server or added by other clients are ignored. Each drifted field is
+// attributed to the managers owning its live value according to the
+// managedFields of the object.
+func (d *Drift) Run(name string) ([]*ResourceDrift, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	reli, err := d.cfg.releaseContent(name, d.Version)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := releaserToV1Release(reli)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := d.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build kubernetes objects from release manifest: %w", err)
+	}
+	// Helm sets these labels and annotations when applying the manifest.
+	if err := resources.Visit(setMetadataVisitor(rel.Name, rel.Namespace, true)); err != nil {
+		return nil, err
+	}
+
+	var drifts []*ResourceDrift
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		rd, err := d.resourceDrift(info)
+		if err != nil {
+			return err
+		}
+		drifts = append(drifts, rd)
+		return nil
+	})
+	return drifts, err
+}
+
+func (d *Drift) resourceDrift(info *resource.Info) (*ResourceDrift, error) {
+	gvk := info.Object.GetObjectKind().GroupVersionKind()
+	rd := &ResourceDrift{
+		Key:        objectKey(info),
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  info.Namespace,
+		Name:       info.Name,
+		Status:     DriftInSync,
+	}
+
+	live, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+	if apierrors.IsNotFound(err) {
+		rd.Status = DriftMissing
+		return rd, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get information about the resource %s: %w", resourceString(info), err)
+	}
+
+	expected, err := driftObject(info.Object)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := driftObject(live)
+	if err != nil {
+		return nil, err
+	}
+	if gvk.Group == "" && gvk.Kind == "Secret" {
+		secretStringDataToData(expected)
+	}
+	owners, err := fieldOwners(live)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []FieldDrift
+	compareDriftFields("", expected, actual, func(path string, exp, act interface{}, present bool) {
+		if path == ".apiVersion" || path == ".kind" || path == ".status" || strings.HasPrefix(path, ".status.") {
+			return
+		}
+		for _, f := range driftIgnoredMetadata {
+			if path == ".metadata."+f || strings.HasPrefix(path, ".metadata."+f+".") {
+				return
+			}
+		}
+		managers := fieldManagers(owners, path)
+		if len(managers) > 0 && !slices.ContainsFunc(managers, func(m string) bool { return !d.ignoredManager(m) }) {
+			return
+		}
+		fd := FieldDrift{Field: strings.TrimPrefix(path, "."), Expected: exp, Managers: managers}
+		if present {
+			fd.Live = act
+		}
+		if rd.Kind == "Secret" && !d.ShowSecrets && (strings.HasPrefix(path, ".data.") || strings.HasPrefix(path, ".stringData.")) {
+			fd.Expected = secretMask
+			if present {
+				fd.Live = secretMask
+			}
+		}
+		fields = append(fields, fd)
+	})
+	if len(fields) > 0 {
+		rd.Status = DriftModified
+		rd.Fields = fields
+	}
+	return rd, nil
+}
+
+func (d *Drift) ignoredManager(manager string) bool {
+	name, _, _ := strings.Cut(manager, " (")
+	return slices.Contains(d.IgnoreManagers, name)
+}
+
+// driftObject converts an object to its JSON representation, so numbers of
+// manifests and live objects compare equal.
+func driftObject(obj runtime.Object) (map[string]interface{}, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// secretStringDataToData merges the write-only stringData of a Secret into
+// its data, as the API server does.
+func secretStringDataToData(secret map[string]interface{}) {
+	stringData, ok := secret["stringData"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	data, _ := secret["data"].(map[string]interface{})
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	for k, v := range stringData {
+		data[k] = base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(v)))
+	}
+	secret["data"] = data
+	delete(secret, "stringData")
+}
+
+// compareDriftFields walks the fields of the expected value and reports each
+// leaf whose actual value differs. Fields only present in the actual value are
+// ignored. List elements with a name are matched by name, others by index.
+// Paths use the notation of managedFields, e.g. `.spec.containers[name="web"].image`.
+func compareDriftFields(path string, expected, actual interface{}, report func(path string, expected, actual interface{}, present bool)) {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			report(path, expected, actual, actual != nil)
+			return
+		}
+		for _, k := range slices.Sorted(maps.Keys(exp)) {
+			v, present := act[k]
+			if !present {
+				if exp[k] != nil {
+					report(path+"."+k, exp[k], nil, false)
+				}
+				continue
+			}
+			compareDriftFields(path+"."+k, exp[k], v, report)
+		}
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			report(path, expected, actual, actual != nil)
+			return
+		}
+		for i, e := range exp {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			var match interface{}
+			if name, ok := listElementName(e); ok {
+				elemPath = fmt.Sprintf("%s[name=%q]", path, name)
+				for _, a := range act {
+					if n, ok := listElementName(a); ok && n == name {
+						match = a
+						break
+					}
+				}
+			} else if i < len(act) {
+				match = act[i]
+			}
+			if match == nil {
+				report(elemPath, e, nil, false)
+				continue
+			}
+			compareDriftFields(elemPath, e, match, report)
+		}
+	default:
+		if !scalarsEqual(path, expected, actual) {
+			report(path, expected, actual, true)
+		}
+	}
+}
+
+func listElementName(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := m["name"].(string)
+	return name, ok
+}
+
+// quantityPath matches the paths of resource requirements, such as
+// `.spec.containers[name="web"].resources.limits.cpu` or the
+// `.spec.resources.requests.storage` of a PersistentVolumeClaim. Resource
+// names may contain dots, e.g. `nvidia.com/gpu`.
+var quantityPath = regexp.MustCompile(`\.resources\.(limits|requests)\.[^\[\]]+$`)
+
+// scalarsEqual compares the scalar values at path. Values at resource
+// requirement paths are compared as quantities, so "0.5" and "500m" are
+// equal, all others must match exactly.
+func scalarsEqual(path string, a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if !quantityPath.MatchString(path) {
+		return false
+	}
+	qa, err := apiresource.ParseQuantity(fmt.Sprint(a))
+	if err != nil {
+		return false
+	}
+	qb, err := apiresource.ParseQuantity(fmt.Sprint(b))
+	if err != nil {
+		return false
+	}
+	return qa.Cmp(qb) == 0
+}
+
+// fieldManagers returns the managers owning a field, or the closest of its
+// parents owned as a whole.
+func fieldManagers(owners map[string][]string, path string) []string {
+	p := path
+	for p != "" {
+		if m, ok := owners[p]; ok {
+			return m
+		}
+		i := strings.LastIndexAny(p, ".[")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	return nil
+}
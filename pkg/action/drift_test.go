@@ -0,0 +1,220 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kuberuntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+
+	"helm.sh/helm/v4/pkg/kube"
+)
+
+func driftDeployment(replicas int32, image, cpu string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "spaced"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{
+						Name:  "web",
+						Image: image,
+						Resources: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1.ResourceCPU: apiresource.MustParse(cpu)},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// driftResources returns a resource list with the expected object whose
+// client returns the live object, or NotFound if live is nil.
+func driftResources(expected, live *appsv1.Deployment) kube.ResourceList {
+	info := &resource.Info{
+		Name:      expected.Name,
+		Namespace: expected.Namespace,
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			Scope:            meta.RESTScopeNamespace,
+		},
+		Object: expected,
+	}
+	info.Client = &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Group: "apps", Version: "v1"},
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: fake.CreateHTTPClient(func(_ *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", kuberuntime.ContentTypeJSON)
+			if live == nil {
+				return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+			}
+			body := kuberuntime.EncodeOrDie(appsv1Codec, live)
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader([]byte(body)))}, nil
+		}),
+	}
+	return kube.ResourceList{info}
+}
+
+func driftLiveDeployment() *appsv1.Deployment {
+	live := driftDeployment(5, "nginx:1.26", "500m")
+	live.Labels = map[string]string{
+		"app.kubernetes.io/managed-by": "Helm",
+		"added-by":                     "someone-else",
+	}
+	live.Annotations = map[string]string{
+		"meta.helm.sh/release-name":      "drift",
+		"meta.helm.sh/release-namespace": "spaced",
+	}
+	live.ResourceVersion = "42"
+	live.UID = "1234"
+	live.Status.ReadyReplicas = 5
+	live.ManagedFields = []metav1.ManagedFieldsEntry{
+		{
+			Manager:    "helm",
+			Operation:  metav1.ManagedFieldsOperationApply,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{"f:resources":{"f:requests":{"f:cpu":{}}}}}}}}}`)},
+		},
+		{
+			Manager:    "hpa",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+		},
+		{
+			Manager:    "kubectl-set",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{"f:image":{}}}}}}}`)},
+		},
+	}
+	return live
+}
+
+func TestDrift(t *testing.T) {
+	expected := driftDeployment(2, "nginx:1.25", "0.5")
+	cfg := actionConfigFixtureWithDummyResources(t, driftResources(expected, driftLiveDeployment()))
+	rel := releaseStub()
+	rel.Name = "drift"
+	rel.Namespace = "spaced"
+	require.NoError(t, cfg.Releases.Create(rel))
+
+	drifts, err := NewDrift(cfg).Run("drift")
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+
+	rd := drifts[0]
+	assert.Equal(t, "apps/v1/Deployment/spaced/web", rd.Key)
+	assert.Equal(t, DriftModified, rd.Status)
+	assert.Equal(t, []FieldDrift{
+		{
+			Field:    "spec.replicas",
+			Expected: float64(2),
+			Live:     float64(5),
+			Managers: []string{"hpa (Update)"},
+		},
+		{
+			Field:    `spec.template.spec.containers[name="web"].image`,
+			Expected: "nginx:1.25",
+			Live:     "nginx:1.26",
+			Managers: []string{"kubectl-set (Update)"},
+		},
+	}, rd.Fields)
+}
+
+func TestDriftIgnoreManagers(t *testing.T) {
+	expected := driftDeployment(2, "nginx:1.25", "0.5")
+	cfg := actionConfigFixtureWithDummyResources(t, driftResources(expected, driftLiveDeployment()))
+	rel := releaseStub()
+	rel.Name = "drift"
+	rel.Namespace = "spaced"
+	require.NoError(t, cfg.Releases.Create(rel))
+
+	client := NewDrift(cfg)
+	client.IgnoreManagers = []string{"hpa", "kubectl-set"}
+	drifts, err := client.Run("drift")
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, DriftInSync, drifts[0].Status)
+	assert.Empty(t, drifts[0].Fields)
+}
+
+func TestDriftMissing(t *testing.T) {
+	expected := driftDeployment(2, "nginx:1.25", "0.5")
+	cfg := actionConfigFixtureWithDummyResources(t, driftResources(expected, nil))
+	rel := releaseStub()
+	rel.Name = "drift"
+	rel.Namespace = "spaced"
+	require.NoError(t, cfg.Releases.Create(rel))
+
+	drifts, err := NewDrift(cfg).Run("drift")
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, DriftMissing, drifts[0].Status)
+}
+
+func TestDriftSecretStringData(t *testing.T) {
+	expected := map[string]interface{}{
+		"kind":       "Secret",
+		"stringData": map[string]interface{}{"password": "hunter2"},
+	}
+	live := map[string]interface{}{
+		"kind": "Secret",
+		"data": map[string]interface{}{"password": "Y2hhbmdlZA=="},
+	}
+	secretStringDataToData(expected)
+
+	var fields []string
+	compareDriftFields("", expected, live, func(path string, _, _ interface{}, _ bool) {
+		fields = append(fields, path)
+	})
+	assert.Equal(t, []string{".data.password"}, fields)
+}
+
+func TestScalarsEqual(t *testing.T) {
+	cpu := `.spec.template.spec.containers[name="web"].resources.limits.cpu`
+	assert.True(t, scalarsEqual(cpu, "0.5", "500m"))
+	assert.True(t, scalarsEqual(cpu, float64(1), "1"))
+	assert.True(t, scalarsEqual(`.spec.resources.requests.storage`, "1Gi", "1024Mi"))
+	assert.True(t, scalarsEqual(`.spec.containers[name="ml"].resources.limits.nvidia.com/gpu`, float64(1), "1"))
+	assert.False(t, scalarsEqual(`.spec.resources.requests.storage`, "1Gi", "1G"))
+	assert.True(t, scalarsEqual(".spec.replicas", float64(1), float64(1)))
+	assert.False(t, scalarsEqual(".spec.template.spec.containers[name=\"web\"].image", "nginx:1.25", "nginx:1.26"))
+
+	// Elsewhere, values which parse as equal quantities still differ
+	assert.False(t, scalarsEqual(".data.ratio", "0.5", "500m"))
+	assert.False(t, scalarsEqual(".metadata.labels.version", "1.0", "1"))
+}
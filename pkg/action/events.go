@@ -0,0 +1,159 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v4/pkg/kube"
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// Event is a progress event emitted by an action while it runs.
+type Event interface {
+	// Type returns the name of the event type, e.g. "ResourceApplied".
+	Type() string
+}
+
+// EventSink receives the progress events of actions.
+//
+// Actions may emit events from several goroutines at once, e.g. while hooks
+// of equal weight run concurrently, so an EventSink must be safe for
+// concurrent use.
+type EventSink func(Event)
+
+// ReleaseRendered is emitted when the manifests of a release have been
+// rendered, before any resource is applied.
+type ReleaseRendered struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	// Resources is the number of resources in the rendered manifest.
+	Resources int `json:"resources"`
+	// Hooks is the number of rendered hooks.
+	Hooks int `json:"hooks"`
+}
+
+// Type implements Event.
+func (ReleaseRendered) Type() string { return "ReleaseRendered" }
+
+// ResourceAction describes what was done to a resource.
+type ResourceAction string
+
+const (
+	ResourceCreated ResourceAction = "created"
+	ResourceUpdated ResourceAction = "updated"
+	ResourceDeleted ResourceAction = "deleted"
+)
+
+// ResourceApplied is emitted for each resource created, updated or deleted
+// while applying a release.
+type ResourceApplied struct {
+	Name      string         `json:"name"`
+	Namespace string         `json:"namespace,omitempty"`
+	Kind      string         `json:"kind"`
+	Action    ResourceAction `json:"action"`
+}
+
+// Type implements Event.
+func (ResourceApplied) Type() string { return "ResourceApplied" }
+
+// HookPhaseChanged is emitted when a hook starts running and when it completes.
+type HookPhaseChanged struct {
+	Hook  string            `json:"hook"`
+	Kind  string            `json:"kind"`
+	Event release.HookEvent `json:"event"`
+	Phase release.HookPhase `json:"phase"`
+	Error string            `json:"error,omitempty"`
+	// Attempts is the number of times the hook was run, once it completed.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Type implements Event.
+func (HookPhaseChanged) Type() string { return "HookPhaseChanged" }
+
+// ResourceStatusChanged is emitted with the status of a resource observed
+// while waiting for it to become ready.
+type ResourceStatusChanged struct {
+	kube.StatusUpdate
+}
+
+// Type implements Event.
+func (ResourceStatusChanged) Type() string { return "ResourceStatusChanged" }
+
+// emit sends an event to the EventSink, if any.
+func (cfg *Configuration) emit(ev Event) {
+	if cfg.EventSink != nil {
+		cfg.EventSink(ev)
+	}
+}
+
+// emitRendered emits a ReleaseRendered event for rel.
+func (cfg *Configuration) emitRendered(rel *release.Release, resources kube.ResourceList) {
+	cfg.emit(ReleaseRendered{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		Resources: len(resources),
+		Hooks:     len(rel.Hooks),
+	})
+}
+
+// emitResult emits a ResourceApplied event for each resource of a result.
+func (cfg *Configuration) emitResult(result *kube.Result) {
+	if cfg.EventSink == nil || result == nil {
+		return
+	}
+	emitAll := func(resources kube.ResourceList, action ResourceAction) {
+		for _, info := range resources {
+			cfg.emit(ResourceApplied{
+				Name:      info.Name,
+				Namespace: info.Namespace,
+				Kind:      resourceKind(info),
+				Action:    action,
+			})
+		}
+	}
+	emitAll(result.Created, ResourceCreated)
+	emitAll(result.Updated, ResourceUpdated)
+	emitAll(result.Deleted, ResourceDeleted)
+}
+
+// getWaiter returns the waiter for a strategy. Status updates of waiters
+// which report them are forwarded to the EventSink.
+func (cfg *Configuration) getWaiter(strategy kube.WaitStrategy) (kube.Waiter, error) {
+	waiter, err := cfg.KubeClient.GetWaiter(strategy)
+	if err != nil {
+		return nil, err
+	}
+	if observable, ok := waiter.(kube.ObservableWaiter); ok && cfg.EventSink != nil {
+		observable.SetStatusObserver(func(u kube.StatusUpdate) {
+			cfg.emit(ResourceStatusChanged{StatusUpdate: u})
+		})
+	}
+	return waiter, nil
+}
+
+func resourceKind(info *resource.Info) string {
+	if info.Mapping != nil {
+		return info.Mapping.GroupVersionKind.Kind
+	}
+	if info.Object != nil {
+		return info.Object.GetObjectKind().GroupVersionKind().Kind
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v4/pkg/kube"
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// recordingSink collects the events sent to it.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordingSink) sink(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, ev)
+}
+
+func (s *recordingSink) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var types []string
+	for _, ev := range s.events {
+		types = append(types, ev.Type())
+	}
+	return types
+}
+
+func TestInstallEmitsEvents(t *testing.T) {
+	config := actionConfigFixtureWithDummyResources(t, createDummyResourceList(false))
+	rec := &recordingSink{}
+	config.EventSink = rec.sink
+	instAction := installActionWithConfig(config)
+	instAction.TakeOwnership = true
+
+	_, err := instAction.Run(buildChart(), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"ReleaseRendered",
+		"ResourceApplied",
+		"HookPhaseChanged",
+		"HookPhaseChanged",
+	}, rec.types())
+
+	assert.Equal(t, ReleaseRendered{
+		Name:      "test-install-release",
+		Namespace: "spaced",
+		Revision:  1,
+		Resources: 1,
+		Hooks:     1,
+	}, rec.events[0])
+	assert.Equal(t, ResourceApplied{
+		Name:      "dummyName",
+		Namespace: "spaced",
+		Kind:      "Deployment",
+		Action:    ResourceUpdated,
+	}, rec.events[1])
+	assert.Equal(t, HookPhaseChanged{
+		Hook:  "test-cm",
+		Kind:  "ConfigMap",
+		Event: release.HookPostInstall,
+		Phase: release.HookPhaseRunning,
+	}, rec.events[2])
+	assert.Equal(t, HookPhaseChanged{
+		Hook:     "test-cm",
+		Kind:     "ConfigMap",
+		Event:    release.HookPostInstall,
+		Phase:    release.HookPhaseSucceeded,
+		Attempts: 1,
+	}, rec.events[3])
+}
+
+// observableWaiter is a Waiter which reports a fixed status update.
+type observableWaiter struct {
+	kube.Waiter
+	observer kube.StatusObserverFunc
+}
+
+func (w *observableWaiter) SetStatusObserver(fn kube.StatusObserverFunc) {
+	w.observer = fn
+}
+
+func (w *observableWaiter) Wait(_ kube.ResourceList, _ time.Duration) error {
+	w.observer(kube.StatusUpdate{Name: "web", Kind: "Deployment", Status: "Current", Desired: "Current"})
+	return nil
+}
+
+type observableKubeClient struct {
+	kube.Interface
+	waiter *observableWaiter
+}
+
+func (c *observableKubeClient) GetWaiter(_ kube.WaitStrategy) (kube.Waiter, error) {
+	return c.waiter, nil
+}
+
+func TestGetWaiterForwardsStatusUpdates(t *testing.T) {
+	config := actionConfigFixture(t)
+	config.KubeClient = &observableKubeClient{Interface: config.KubeClient, waiter: &observableWaiter{}}
+
+	waiter, err := config.getWaiter(kube.StatusWatcherStrategy)
+	require.NoError(t, err)
+	assert.Nil(t, waiter.(*observableWaiter).observer, "no observer is set without an EventSink")
+
+	rec := &recordingSink{}
+	config.EventSink = rec.sink
+	waiter, err = config.getWaiter(kube.StatusWatcherStrategy)
+	require.NoError(t, err)
+	require.NoError(t, waiter.Wait(nil, time.Second))
+	assert.Equal(t, []Event{
+		ResourceStatusChanged{StatusUpdate: kube.StatusUpdate{Name: "web", Kind: "Deployment", Status: "Current", Desired: "Current"}},
+	}, rec.events)
+}
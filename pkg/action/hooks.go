@@ -17,10 +17,14 @@ package action
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"slices"
 	"sort"
+	"sync"
 	"time"
 
 	"helm.sh/helm/v4/pkg/kube"
@@ -31,8 +35,32 @@ import (
 	release "helm.sh/helm/v4/pkg/release/v1"
 )
 
+// hookRetryBackoff is the time to wait before the first retry of a failed
+// hook. It doubles with every retry, up to maxHookRetryBackoff.
+var hookRetryBackoff = 2 * time.Second
+
+const maxHookRetryBackoff = time.Minute
+
+// defaultHookParallelism is the number of hooks of equal weight executed
+// concurrently when Configuration.HookParallelism is not set.
+const defaultHookParallelism = 8
+
 // execHook executes all of the hooks for the given hook event.
+//
+// Hooks are executed in groups of equal weight, in order of ascending weight.
+// The hooks of a group are executed concurrently, up to HookParallelism at a
+// time, and all of them are waited for before the next group starts.
+//
+// The hooks of the event share the timeout: no attempt of a hook, including
+// the first, waits past the deadline it sets.
 func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent, waitStrategy kube.WaitStrategy, timeout time.Duration, serverSideApply bool) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	executingHooks := []*release.Hook{}
 
 	for _, h := range rl.Hooks {
@@ -46,51 +74,42 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 	// hooke are pre-ordered by kind, so keep order stable
 	sort.Stable(hookByWeight(executingHooks))
 
-	for i, h := range executingHooks {
-		// Set default delete policy to before-hook-creation
-		cfg.hookSetDeletePolicy(h)
-
-		if err := cfg.deleteHookByPolicy(h, release.HookBeforeHookCreation, waitStrategy, timeout); err != nil {
-			return err
-		}
-
-		resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), true)
-		if err != nil {
-			return fmt.Errorf("unable to build kubernetes object for %s hook %s: %w", hook, h.Path, err)
+	for start := 0; start < len(executingHooks); {
+		end := start + 1
+		for end < len(executingHooks) && executingHooks[end].Weight == executingHooks[start].Weight {
+			end++
 		}
-
-		// Record the time at which the hook was applied to the cluster
-		h.LastRun = release.HookExecution{
-			StartedAt: time.Now(),
-			Phase:     release.HookPhaseRunning,
+		group := executingHooks[start:end]
+
+		// Record the time at which the hooks were applied to the cluster. The release is recorded once per
+		// group, as the hooks of a group are updated concurrently.
+		cfg.mutex.Lock()
+		for _, h := range group {
+			h.LastRun = release.HookExecution{
+				StartedAt: time.Now(),
+				Phase:     release.HookPhaseRunning,
+			}
 		}
+		cfg.mutex.Unlock()
 		cfg.recordRelease(rl)
 
-		// As long as the implementation of WatchUntilReady does not panic, HookPhaseFailed or HookPhaseSucceeded
-		// should always be set by this function. If we fail to do that for any reason, then HookPhaseUnknown is
-		// the most appropriate value to surface.
-		h.LastRun.Phase = release.HookPhaseUnknown
-
-		// Create hook resources
-		if _, err := cfg.KubeClient.Create(
-			resources,
-			kube.ClientCreateOptionServerSideApply(serverSideApply, false)); err != nil {
-			h.LastRun.CompletedAt = time.Now()
-			h.LastRun.Phase = release.HookPhaseFailed
-			return fmt.Errorf("warning: Hook %s %s failed: %w", hook, h.Path, err)
-		}
+		results := cfg.execHookGroup(ctx, group, hook, waitStrategy, timeout, serverSideApply)
 
-		waiter, err := cfg.KubeClient.GetWaiter(waitStrategy)
-		if err != nil {
-			return fmt.Errorf("unable to get waiter: %w", err)
-		}
-		// Watch hook resources until they have completed
-		err = waiter.WatchUntilReady(resources, timeout)
-		// Note the time of success/failure
-		h.LastRun.CompletedAt = time.Now()
-		// Mark hook as succeeded or failed
-		if err != nil {
-			h.LastRun.Phase = release.HookPhaseFailed
+		var failed []error
+		succeeded := slices.DeleteFunc(slices.Clone(executingHooks[:start]), hookFailed)
+		for i, h := range group {
+			if results[i].err == nil {
+				succeeded = append(succeeded, h)
+				continue
+			}
+			if h.FailurePolicy == release.HookFailureContinue {
+				slog.Warn("hook failed, continuing due to its failure policy", "hook", h.Name, "event", hook, "error", results[i].err)
+			} else {
+				failed = append(failed, results[i].err)
+			}
+			if !results[i].watched {
+				continue
+			}
 			// If a hook is failed, check the annotation of the hook to determine if we should copy the logs client side
 			if errOutputting := cfg.outputLogsByPolicy(h, rl.Namespace, release.HookOutputOnFailed); errOutputting != nil {
 				// We log the error here as we want to propagate the hook failure upwards to the release object.
@@ -102,22 +121,26 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 				// We log the error here as we want to propagate the hook failure upwards to the release object.
 				log.Printf("error deleting the hook resource on hook failure: %v", errDeleting)
 			}
-
-			// If a hook is failed, check the annotation of the previous successful hooks to determine whether the hooks
+		}
+		if len(failed) > 0 {
+			// If a hook is failed, check the annotation of the successful hooks to determine whether the hooks
 			// should be deleted under succeeded condition.
-			if err := cfg.deleteHooksByPolicy(executingHooks[0:i], release.HookSucceeded, waitStrategy, timeout); err != nil {
+			if err := cfg.deleteHooksByPolicy(succeeded, release.HookSucceeded, waitStrategy, timeout); err != nil {
 				return err
 			}
 
-			return err
+			return errors.Join(failed...)
 		}
-		h.LastRun.Phase = release.HookPhaseSucceeded
+		start = end
 	}
 
 	// If all hooks are successful, check the annotation of each hook to determine whether the hook should be deleted
 	// or output should be logged under succeeded condition. If so, then clear the corresponding resource object in each hook
 	for i := len(executingHooks) - 1; i >= 0; i-- {
 		h := executingHooks[i]
+		if hookFailed(h) {
+			continue
+		}
 		if err := cfg.outputLogsByPolicy(h, rl.Namespace, release.HookOutputOnSucceeded); err != nil {
 			// We log here as we still want to attempt hook resource deletion even if output logging fails.
 			log.Printf("error outputting logs for hook failure: %v", err)
@@ -130,6 +153,189 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 	return nil
 }
 
+// execFailureHook executes the hooks for an event fired when an operation
+// failed. The error of the hooks is logged rather than returned, so it does not
+// hide the error which failed the operation. The release is recorded
+// afterwards, so the outcome of the hooks is stored with it.
+func (cfg *Configuration) execFailureHook(rl *release.Release, hook release.HookEvent, waitStrategy kube.WaitStrategy, timeout time.Duration, serverSideApply bool) {
+	if err := cfg.execHook(rl, hook, waitStrategy, timeout, serverSideApply); err != nil {
+		slog.Warn("failure hooks failed", "name", rl.Name, "event", hook, slog.Any("error", err))
+	}
+	cfg.recordRelease(rl)
+}
+
+// hookResult is the outcome of executing a single hook.
+type hookResult struct {
+	err error
+	// watched is set if the hook resources were created and watched, so a
+	// failure was reported by the hook itself.
+	watched bool
+}
+
+// execHookGroup executes hooks of equal weight concurrently and returns the
+// result of each hook, in the order of the hooks.
+func (cfg *Configuration) execHookGroup(ctx context.Context, hooks []*release.Hook, hook release.HookEvent, waitStrategy kube.WaitStrategy, timeout time.Duration, serverSideApply bool) []hookResult {
+	parallelism := cfg.HookParallelism
+	if parallelism <= 0 {
+		parallelism = defaultHookParallelism
+	}
+
+	run := func(h *release.Hook) hookResult {
+		cfg.emit(HookPhaseChanged{Hook: h.Name, Kind: h.Kind, Event: hook, Phase: release.HookPhaseRunning})
+		result := cfg.execSingleHook(ctx, h, hook, waitStrategy, timeout, serverSideApply)
+		ev := HookPhaseChanged{Hook: h.Name, Kind: h.Kind, Event: hook, Phase: h.LastRun.Phase, Attempts: h.LastRun.Attempts}
+		if result.err != nil {
+			ev.Error = result.err.Error()
+		}
+		cfg.emit(ev)
+		return result
+	}
+
+	results := make([]hookResult, len(hooks))
+	if len(hooks) == 1 || parallelism == 1 {
+		for i, h := range hooks {
+			results[i] = run(h)
+		}
+		return results
+	}
+
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, h := range hooks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = run(h)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// execSingleHook creates the resources of a hook and waits for them to be
+// ready, retrying as often as the hook allows. It only updates the LastRun of
+// the given hook, so hooks may be executed concurrently.
+//
+// Every attempt, and the backoff before a retry, is bounded by the deadline of
+// ctx: the timeout of an attempt is cut to the time left, and no attempt is
+// started once the deadline has passed.
+func (cfg *Configuration) execSingleHook(ctx context.Context, h *release.Hook, hook release.HookEvent, waitStrategy kube.WaitStrategy, timeout time.Duration, serverSideApply bool) hookResult {
+	if h.Timeout > 0 {
+		timeout = h.Timeout
+	}
+
+	// Set default delete policy to before-hook-creation
+	cfg.hookSetDeletePolicy(h)
+
+	if err := cfg.deleteHookByPolicy(h, release.HookBeforeHookCreation, waitStrategy, timeout); err != nil {
+		h.LastRun.Phase = release.HookPhaseUnknown
+		h.LastRun.Error = err.Error()
+		return hookResult{err: err}
+	}
+
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), true)
+	if err != nil {
+		h.LastRun.Phase = release.HookPhaseUnknown
+		err = fmt.Errorf("unable to build kubernetes object for %s hook %s: %w", hook, h.Path, err)
+		h.LastRun.Error = err.Error()
+		return hookResult{err: err}
+	}
+
+	backoff := hookRetryBackoff
+	for attempt := 1; ; attempt++ {
+		h.LastRun.Attempts = attempt
+		attemptTimeout := timeout
+		if deadline, ok := ctx.Deadline(); ok {
+			left := time.Until(deadline)
+			if left <= 0 {
+				err := fmt.Errorf("%s hook %s: the timeout expired before it could be executed", hook, h.Path)
+				h.LastRun.Phase = release.HookPhaseFailed
+				h.LastRun.Error = err.Error()
+				return hookResult{err: err}
+			}
+			attemptTimeout = min(attemptTimeout, left)
+		}
+		result := cfg.execHookAttempt(h, hook, resources, waitStrategy, attemptTimeout, serverSideApply)
+		if result.err == nil {
+			h.LastRun.Error = ""
+			return result
+		}
+		h.LastRun.Error = result.err.Error()
+		if attempt > h.Retries {
+			return result
+		}
+
+		slog.Debug("hook failed, retrying", "hook", h.Name, "event", hook, "attempt", attempt, "backoff", backoff, "error", result.err)
+		if err := sleepWithContext(ctx, backoff); err != nil {
+			err = fmt.Errorf("%s hook %s: the timeout expired before it could be retried: %w", hook, h.Path, result.err)
+			h.LastRun.Error = err.Error()
+			return hookResult{err: err, watched: result.watched}
+		}
+		backoff = min(2*backoff, maxHookRetryBackoff)
+
+		// The resources of the failed attempt have to go before they can be created again.
+		if err := cfg.deleteHookResources(h, waitStrategy, timeout); err != nil {
+			err = fmt.Errorf("unable to delete %s hook %s for retry: %w", hook, h.Path, err)
+			h.LastRun.Error = err.Error()
+			return hookResult{err: err, watched: result.watched}
+		}
+	}
+}
+
+// sleepWithContext waits for d to pass, and returns the error of ctx if it is
+// done before.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// execHookAttempt creates the resources of a hook and waits for them to be
+// ready once.
+func (cfg *Configuration) execHookAttempt(h *release.Hook, hook release.HookEvent, resources kube.ResourceList, waitStrategy kube.WaitStrategy, timeout time.Duration, serverSideApply bool) hookResult {
+	// As long as the implementation of WatchUntilReady does not panic, HookPhaseFailed or HookPhaseSucceeded
+	// should always be set by this function. If we fail to do that for any reason, then HookPhaseUnknown is
+	// the most appropriate value to surface.
+	h.LastRun.Phase = release.HookPhaseUnknown
+
+	// Create hook resources
+	if _, err := cfg.KubeClient.Create(
+		resources,
+		kube.ClientCreateOptionServerSideApply(serverSideApply, false)); err != nil {
+		h.LastRun.CompletedAt = time.Now()
+		h.LastRun.Phase = release.HookPhaseFailed
+		return hookResult{err: fmt.Errorf("warning: Hook %s %s failed: %w", hook, h.Path, err)}
+	}
+
+	waiter, err := cfg.getWaiter(waitStrategy)
+	if err != nil {
+		return hookResult{err: fmt.Errorf("unable to get waiter: %w", err)}
+	}
+	// Watch hook resources until they have completed
+	err = waiter.WatchUntilReady(resources, timeout)
+	// Note the time of success/failure
+	h.LastRun.CompletedAt = time.Now()
+	// Mark hook as succeeded or failed
+	if err != nil {
+		h.LastRun.Phase = release.HookPhaseFailed
+		return hookResult{err: err, watched: true}
+	}
+	h.LastRun.Phase = release.HookPhaseSucceeded
+	return hookResult{watched: true}
+}
+
+// hookFailed reports whether the last run of a hook failed.
+func hookFailed(h *release.Hook) bool {
+	return h.LastRun.Phase == release.HookPhaseFailed || h.LastRun.Phase == release.HookPhaseUnknown
+}
+
 // hookByWeight is a sorter for hooks
 type hookByWeight []*release.Hook
 
@@ -144,30 +350,33 @@ func (x hookByWeight) Less(i, j int) bool {
 
 // deleteHookByPolicy deletes a hook if the hook policy instructs it to
 func (cfg *Configuration) deleteHookByPolicy(h *release.Hook, policy release.HookDeletePolicy, waitStrategy kube.WaitStrategy, timeout time.Duration) error {
+	if cfg.hookHasDeletePolicy(h, policy) {
+		return cfg.deleteHookResources(h, waitStrategy, timeout)
+	}
+	return nil
+}
+
+// deleteHookResources deletes the resources of a hook and waits for them to be gone
+func (cfg *Configuration) deleteHookResources(h *release.Hook, waitStrategy kube.WaitStrategy, timeout time.Duration) error {
 	// Never delete CustomResourceDefinitions; this could cause lots of
 	// cascading garbage collection.
 	if h.Kind == "CustomResourceDefinition" {
 		return nil
 	}
-	if cfg.hookHasDeletePolicy(h, policy) {
-		resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), false)
-		if err != nil {
-			return fmt.Errorf("unable to build kubernetes object for deleting hook %s: %w", h.Path, err)
-		}
-		_, errs := cfg.KubeClient.Delete(resources, metav1.DeletePropagationBackground)
-		if len(errs) > 0 {
-			return joinErrors(errs, "; ")
-		}
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), false)
+	if err != nil {
+		return fmt.Errorf("unable to build kubernetes object for deleting hook %s: %w", h.Path, err)
+	}
+	_, errs := cfg.KubeClient.Delete(resources, metav1.DeletePropagationBackground)
+	if len(errs) > 0 {
+		return joinErrors(errs, "; ")
+	}
 
-		waiter, err := cfg.KubeClient.GetWaiter(waitStrategy)
-		if err != nil {
-			return err
-		}
-		if err := waiter.WaitForDelete(resources, timeout); err != nil {
-			return err
-		}
+	waiter, err := cfg.getWaiter(waitStrategy)
+	if err != nil {
+		return err
 	}
-	return nil
+	return waiter.WaitForDelete(resources, timeout)
 }
 
 // deleteHooksByPolicy deletes all hooks if the hook policy instructs it to
@@ -22,6 +22,7 @@ import (
 	"io"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -392,7 +393,7 @@ data:
 			}
 
 			serverSideApply := true
-			err := configuration.execHook(&tc.inputRelease, hookEvent, kube.StatusWatcherStrategy, 600, serverSideApply)
+			err := configuration.execHook(&tc.inputRelease, hookEvent, kube.StatusWatcherStrategy, 600*time.Second, serverSideApply)
 
 			if !reflect.DeepEqual(kubeClient.deleteRecord, tc.expectedDeleteRecord) {
 				t.Fatalf("Got unexpected delete record, expected: %#v, but got: %#v", kubeClient.deleteRecord, tc.expectedDeleteRecord)
@@ -408,3 +409,346 @@ data:
 		})
 	}
 }
+
+// concurrentHookKubeClient records the order in which hooks run and how many
+// run at the same time.
+type concurrentHookKubeClient struct {
+	kubefake.PrintingKubeClient
+	failOn string
+
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	events     []string
+}
+
+type concurrentHookKubeWaiter struct {
+	*kubefake.PrintingKubeWaiter
+	client *concurrentHookKubeClient
+}
+
+func (c *concurrentHookKubeClient) Build(reader io.Reader, validate bool) (kube.ResourceList, error) {
+	return (*HookFailingKubeClient)(nil).Build(reader, validate)
+}
+
+func (c *concurrentHookKubeClient) GetWaiter(strategy kube.WaitStrategy) (kube.Waiter, error) {
+	waiter, _ := c.PrintingKubeClient.GetWaiter(strategy)
+	return &concurrentHookKubeWaiter{PrintingKubeWaiter: waiter.(*kubefake.PrintingKubeWaiter), client: c}, nil
+}
+
+func (w *concurrentHookKubeWaiter) WatchUntilReady(resources kube.ResourceList, _ time.Duration) error {
+	c := w.client
+	name := resources[0].Name
+	c.mu.Lock()
+	c.running++
+	c.maxRunning = max(c.maxRunning, c.running)
+	c.events = append(c.events, "start "+name)
+	c.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	c.mu.Lock()
+	c.running--
+	c.events = append(c.events, "end "+name)
+	c.mu.Unlock()
+	if name == c.failOn {
+		return &HookFailedError{}
+	}
+	return nil
+}
+
+func concurrentHooksRelease(weights map[string]int) *release.Release {
+	rel := &release.Release{Name: "concurrent-hooks", Namespace: "test"}
+	for name, weight := range weights {
+		rel.Hooks = append(rel.Hooks, &release.Hook{
+			Name:     name,
+			Kind:     "ConfigMap",
+			Path:     "templates/" + name + ".yaml",
+			Manifest: fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n  namespace: test\n", name),
+			Weight:   weight,
+			Events:   []release.HookEvent{release.HookPreUpgrade},
+		})
+	}
+	return rel
+}
+
+func TestExecHookConcurrentWithinWeight(t *testing.T) {
+	is := assert.New(t)
+	kubeClient := &concurrentHookKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard}}
+	config := &Configuration{
+		Releases:     storage.Init(driver.NewMemory()),
+		KubeClient:   kubeClient,
+		Capabilities: common.DefaultCapabilities,
+	}
+	rel := concurrentHooksRelease(map[string]int{"migrate-a": 0, "migrate-b": 0, "migrate-c": 0, "verify": 5})
+
+	is.NoError(config.execHook(rel, release.HookPreUpgrade, kube.StatusWatcherStrategy, time.Minute, false))
+	is.Equal(3, kubeClient.maxRunning)
+	// The heavier hook starts after all hooks of the lighter weight ended
+	is.Equal("start verify", kubeClient.events[6])
+	for _, h := range rel.Hooks {
+		is.Equal(release.HookPhaseSucceeded, h.LastRun.Phase, h.Name)
+		is.False(h.LastRun.CompletedAt.IsZero(), h.Name)
+	}
+}
+
+func TestExecHookParallelismLimit(t *testing.T) {
+	kubeClient := &concurrentHookKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard}}
+	config := &Configuration{
+		Releases:        storage.Init(driver.NewMemory()),
+		KubeClient:      kubeClient,
+		Capabilities:    common.DefaultCapabilities,
+		HookParallelism: 2,
+	}
+	rel := concurrentHooksRelease(map[string]int{"a": 0, "b": 0, "c": 0, "d": 0, "e": 0})
+
+	assert.NoError(t, config.execHook(rel, release.HookPreUpgrade, kube.StatusWatcherStrategy, time.Minute, false))
+	assert.Equal(t, 2, kubeClient.maxRunning)
+}
+
+func TestExecHookConcurrentFailure(t *testing.T) {
+	is := assert.New(t)
+	kubeClient := &concurrentHookKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard}, failOn: "migrate-a"}
+	config := &Configuration{
+		Releases:     storage.Init(driver.NewMemory()),
+		KubeClient:   kubeClient,
+		Capabilities: common.DefaultCapabilities,
+	}
+	rel := concurrentHooksRelease(map[string]int{"migrate-a": 0, "migrate-b": 0, "verify": 5})
+
+	err := config.execHook(rel, release.HookPreUpgrade, kube.StatusWatcherStrategy, time.Minute, false)
+	var hookErr *HookFailedError
+	is.ErrorAs(err, &hookErr)
+	phases := map[string]release.HookPhase{}
+	for _, h := range rel.Hooks {
+		phases[h.Name] = h.LastRun.Phase
+	}
+	is.Equal(map[string]release.HookPhase{
+		"migrate-a": release.HookPhaseFailed,
+		"migrate-b": release.HookPhaseSucceeded,
+		"verify":    "",
+	}, phases)
+	is.NotContains(kubeClient.events, "start verify")
+}
+
+// retryHookKubeClient fails watching a hook as often as configured and
+// records the calls made for the hooks.
+type retryHookKubeClient struct {
+	kubefake.PrintingKubeClient
+	failures map[string]int
+	// delay is the time each hook takes to complete.
+	delay time.Duration
+
+	mu    sync.Mutex
+	calls []string
+	// timeouts holds the timeout of the last watch of each hook.
+	timeouts map[string]time.Duration
+}
+
+type retryHookKubeWaiter struct {
+	*kubefake.PrintingKubeWaiter
+	client *retryHookKubeClient
+}
+
+func (c *retryHookKubeClient) record(call string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+}
+
+func (c *retryHookKubeClient) Build(reader io.Reader, validate bool) (kube.ResourceList, error) {
+	return (*HookFailingKubeClient)(nil).Build(reader, validate)
+}
+
+func (c *retryHookKubeClient) Delete(resources kube.ResourceList, _ metav1.DeletionPropagation) (*kube.Result, []error) {
+	c.record("delete " + resources[0].Name)
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func (c *retryHookKubeClient) GetWaiter(strategy kube.WaitStrategy) (kube.Waiter, error) {
+	waiter, _ := c.PrintingKubeClient.GetWaiter(strategy)
+	return &retryHookKubeWaiter{PrintingKubeWaiter: waiter.(*kubefake.PrintingKubeWaiter), client: c}, nil
+}
+
+func (w *retryHookKubeWaiter) WatchUntilReady(resources kube.ResourceList, timeout time.Duration) error {
+	c := w.client
+	name := resources[0].Name
+	c.record("watch " + name)
+	time.Sleep(c.delay)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timeouts == nil {
+		c.timeouts = map[string]time.Duration{}
+	}
+	c.timeouts[name] = timeout
+	if c.failures[name] > 0 {
+		c.failures[name]--
+		return fmt.Errorf("%s failed", name)
+	}
+	return nil
+}
+
+func TestExecHookRetries(t *testing.T) {
+	defer func(backoff time.Duration) { hookRetryBackoff = backoff }(hookRetryBackoff)
+	hookRetryBackoff = time.Millisecond
+
+	tests := []struct {
+		name          string
+		failures      int
+		retries       int
+		expectErr     string
+		expectPhase   release.HookPhase
+		expectAttempt int
+	}{
+		{
+			name:          "succeeds after retries",
+			failures:      2,
+			retries:       3,
+			expectPhase:   release.HookPhaseSucceeded,
+			expectAttempt: 3,
+		},
+		{
+			name:          "fails when retries are exhausted",
+			failures:      3,
+			retries:       2,
+			expectErr:     "migrate failed",
+			expectPhase:   release.HookPhaseFailed,
+			expectAttempt: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := &retryHookKubeClient{
+				PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard},
+				failures:           map[string]int{"migrate": tt.failures},
+			}
+			config := &Configuration{
+				Releases:     storage.Init(driver.NewMemory()),
+				KubeClient:   kubeClient,
+				Capabilities: common.DefaultCapabilities,
+			}
+			rel := concurrentHooksRelease(map[string]int{"migrate": 0})
+			h := rel.Hooks[0]
+			h.Retries = tt.retries
+			h.Timeout = 30 * time.Second
+			h.DeletePolicies = []release.HookDeletePolicy{release.HookSucceeded}
+
+			err := config.execHook(rel, release.HookPreUpgrade, kube.StatusWatcherStrategy, time.Minute, false)
+			if tt.expectErr != "" {
+				assert.ErrorContains(t, err, tt.expectErr)
+				assert.Equal(t, tt.expectErr, h.LastRun.Error)
+			} else {
+				assert.NoError(t, err)
+				assert.Empty(t, h.LastRun.Error)
+			}
+			assert.Equal(t, tt.expectPhase, h.LastRun.Phase)
+			assert.Equal(t, tt.expectAttempt, h.LastRun.Attempts)
+
+			// Every attempt uses the timeout of the hook, and the resources of a
+			// failed attempt are deleted before the next one.
+			assert.Equal(t, 30*time.Second, kubeClient.timeouts["migrate"])
+			var expected []string
+			for i := 1; i <= tt.expectAttempt; i++ {
+				expected = append(expected, "watch migrate")
+				if i < tt.expectAttempt {
+					expected = append(expected, "delete migrate")
+				}
+			}
+			if tt.expectErr == "" {
+				expected = append(expected, "delete migrate")
+			}
+			assert.Equal(t, expected, kubeClient.calls)
+		})
+	}
+}
+
+func TestExecHookRetriesTimeout(t *testing.T) {
+	defer func(backoff time.Duration) { hookRetryBackoff = backoff }(hookRetryBackoff)
+	hookRetryBackoff = time.Hour
+
+	kubeClient := &retryHookKubeClient{
+		PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard},
+		failures:           map[string]int{"migrate": 1},
+	}
+	config := &Configuration{
+		Releases:     storage.Init(driver.NewMemory()),
+		KubeClient:   kubeClient,
+		Capabilities: common.DefaultCapabilities,
+	}
+	rel := concurrentHooksRelease(map[string]int{"migrate": 0})
+	h := rel.Hooks[0]
+	h.Retries = 3
+	h.Timeout = 30 * time.Second
+	h.DeletePolicies = []release.HookDeletePolicy{release.HookSucceeded}
+
+	// The backoff before the retry is cut short by the timeout of the operation.
+	start := time.Now()
+	err := config.execHook(rel, release.HookPreUpgrade, kube.StatusWatcherStrategy, 50*time.Millisecond, false)
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.EqualError(t, err, "pre-upgrade hook templates/migrate.yaml: the timeout expired before it could be retried: migrate failed")
+	assert.Equal(t, release.HookPhaseFailed, h.LastRun.Phase)
+	assert.Equal(t, 1, h.LastRun.Attempts)
+	assert.Equal(t, []string{"watch migrate"}, kubeClient.calls)
+	// The timeout of the hook is cut to the timeout of the operation.
+	assert.LessOrEqual(t, kubeClient.timeouts["migrate"], 50*time.Millisecond)
+}
+
+func TestExecHookSharedTimeout(t *testing.T) {
+	kubeClient := &retryHookKubeClient{
+		PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard},
+		failures:           map[string]int{},
+		delay:              100 * time.Millisecond,
+	}
+	config := &Configuration{
+		Releases:     storage.Init(driver.NewMemory()),
+		KubeClient:   kubeClient,
+		Capabilities: common.DefaultCapabilities,
+	}
+	rel := concurrentHooksRelease(map[string]int{"first": 0, "second": 1, "third": 2})
+	for _, h := range rel.Hooks {
+		h.Timeout = time.Hour
+		h.DeletePolicies = []release.HookDeletePolicy{release.HookSucceeded}
+	}
+
+	// Each group of hooks only gets the time the previous groups left, and
+	// the last group is not started once the timeout expired.
+	err := config.execHook(rel, release.HookPreUpgrade, kube.StatusWatcherStrategy, 150*time.Millisecond, false)
+	assert.EqualError(t, err, "pre-upgrade hook templates/third.yaml: the timeout expired before it could be executed")
+	assert.LessOrEqual(t, kubeClient.timeouts["first"], 150*time.Millisecond)
+	assert.LessOrEqual(t, kubeClient.timeouts["second"], 50*time.Millisecond)
+	assert.NotContains(t, kubeClient.calls, "watch third")
+}
+
+func TestExecHookFailurePolicyContinue(t *testing.T) {
+	is := assert.New(t)
+	kubeClient := &retryHookKubeClient{
+		PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard},
+		failures:           map[string]int{"notify": 1},
+	}
+	config := &Configuration{
+		Releases:     storage.Init(driver.NewMemory()),
+		KubeClient:   kubeClient,
+		Capabilities: common.DefaultCapabilities,
+	}
+	rel := concurrentHooksRelease(map[string]int{"notify": 0, "verify": 5})
+	for _, h := range rel.Hooks {
+		h.DeletePolicies = []release.HookDeletePolicy{release.HookSucceeded}
+		if h.Name == "notify" {
+			h.FailurePolicy = release.HookFailureContinue
+		}
+	}
+
+	is.NoError(config.execHook(rel, release.HookPreUpgrade, kube.StatusWatcherStrategy, time.Minute, false))
+	for _, h := range rel.Hooks {
+		switch h.Name {
+		case "notify":
+			is.Equal(release.HookPhaseFailed, h.LastRun.Phase)
+			is.Equal("notify failed", h.LastRun.Error)
+		case "verify":
+			is.Equal(release.HookPhaseSucceeded, h.LastRun.Phase)
+		}
+		is.Equal(1, h.LastRun.Attempts, h.Name)
+	}
+	// The failed hook is not deleted by the hook-succeeded policy
+	is.Equal([]string{"watch notify", "watch verify", "delete verify"}, kubeClient.calls)
+}
@@ -36,9 +36,7 @@ import (
 	"github.com/Masterminds/sprig/v3"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/cli-runtime/pkg/resource"
 	"sigs.k8s.io/yaml"
 
 	ci "helm.sh/helm/v4/pkg/chart"
@@ -128,7 +126,26 @@ type Install struct {
 	UseReleaseName bool
 	// TakeOwnership will ignore the check for helm annotations and take ownership of the resources.
 	TakeOwnership bool
-	PostRenderer  postrenderer.PostRenderer
+	// ApplySet labels the resources of the release as members of a KEP-3659
+	// ApplySet. Later upgrades of the release prune resources by querying the
+	// cluster for the members of the set.
+	ApplySet bool
+	// WarningsAsErrors fails the release if the API server returns warnings
+	// while its resources are applied.
+	WarningsAsErrors bool
+	// CRDPolicy determines how the CRDs in the crds/ directories of the chart
+	// are applied. It defaults to CRDPolicyCreate, and is CRDPolicySkip if
+	// SkipCRDs is set.
+	CRDPolicy CRDPolicy
+	// ServiceAccount, given as "namespace/name", is impersonated to operate on
+	// the resources of the release. It is recorded on the release, so later
+	// operations use it as well by default.
+	ServiceAccount string
+	// Preflight reviews the permissions required for the installation before any
+	// change is made, and fails with a *PreflightError listing the missing
+	// ones.
+	Preflight    bool
+	PostRenderer postrenderer.PostRenderer
 	// Lock to control raceconditions when the process receives a SIGTERM
 	Lock           sync.Mutex
 	goroutineCount atomic.Int32
@@ -176,71 +193,6 @@ func (i *Install) GetRegistryClient() *registry.Client {
 	return i.registryClient
 }
 
-func (i *Install) installCRDs(crds []chart.CRD) error {
-	// We do these one file at a time in the order they were read.
-	totalItems := []*resource.Info{}
-	for _, obj := range crds {
-		// Read in the resources
-		res, err := i.cfg.KubeClient.Build(bytes.NewBuffer(obj.File.Data), false)
-		if err != nil {
-			return fmt.Errorf("failed to install CRD %s: %w", obj.Name, err)
-		}
-
-		// Send them to Kube
-		if _, err := i.cfg.KubeClient.Create(
-			res,
-			kube.ClientCreateOptionServerSideApply(i.ServerSideApply, i.ForceConflicts)); err != nil {
-			// If the error is CRD already exists, continue.
-			if apierrors.IsAlreadyExists(err) {
-				crdName := res[0].Name
-				slog.Debug("CRD is already present. Skipping", "crd", crdName)
-				continue
-			}
-			return fmt.Errorf("failed to install CRD %s: %w", obj.Name, err)
-		}
-		totalItems = append(totalItems, res...)
-	}
-	if len(totalItems) > 0 {
-		waiter, err := i.cfg.KubeClient.GetWaiter(i.WaitStrategy)
-		if err != nil {
-			return fmt.Errorf("unable to get waiter: %w", err)
-		}
-		// Give time for the CRD to be recognized.
-		if err := waiter.Wait(totalItems, 60*time.Second); err != nil {
-			return err
-		}
-
-		// If we have already gathered the capabilities, we need to invalidate
-		// the cache so that the new CRDs are recognized. This should only be
-		// the case when an action configuration is reused for multiple actions,
-		// as otherwise it is later loaded by ourselves when getCapabilities
-		// is called later on in the installation process.
-		if i.cfg.Capabilities != nil {
-			discoveryClient, err := i.cfg.RESTClientGetter.ToDiscoveryClient()
-			if err != nil {
-				return err
-			}
-
-			slog.Debug("clearing discovery cache")
-			discoveryClient.Invalidate()
-
-			_, _ = discoveryClient.ServerGroups()
-		}
-
-		// Invalidate the REST mapper, since it will not have the new CRDs
-		// present.
-		restMapper, err := i.cfg.RESTClientGetter.ToRESTMapper()
-		if err != nil {
-			return err
-		}
-		if resettable, ok := restMapper.(meta.ResettableRESTMapper); ok {
-			slog.Debug("clearing REST mapper cache")
-			resettable.Reset()
-		}
-	}
-	return nil
-}
-
 // Run executes the installation
 //
 // If DryRun is set to true, this will prepare the release, but not install it
@@ -270,6 +222,16 @@ func (i *Install) RunWithContext(ctx context.Context, ch ci.Charter, vals map[st
 			slog.Error(fmt.Sprintf("cluster reachability check failed: %v", err))
 			return nil, fmt.Errorf("cluster reachability check failed: %w", err)
 		}
+
+		cfg, err := i.cfg.forServiceAccount(i.ServiceAccount)
+		if err != nil {
+			return nil, err
+		}
+		// The configuration of the service account is only used for this operation.
+		if cfg != i.cfg {
+			defer func(base *Configuration) { i.cfg = base }(i.cfg)
+			i.cfg = cfg
+		}
 	}
 
 	// HideSecret must be used with dry run. Otherwise, return an error.
@@ -278,6 +240,14 @@ func (i *Install) RunWithContext(ctx context.Context, ch ci.Charter, vals map[st
 		return nil, errors.New("hiding Kubernetes secrets requires a dry-run mode")
 	}
 
+	if !isDryRun(i.DryRunStrategy) {
+		unlock, err := i.cfg.lockRelease(i.ReleaseName, "install")
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	if err := i.availableName(); err != nil {
 		slog.Error("release name check failed", slog.Any("error", err))
 		return nil, fmt.Errorf("release name check failed: %w", err)
@@ -290,11 +260,22 @@ func (i *Install) RunWithContext(ctx context.Context, ch ci.Charter, vals map[st
 
 	// Pre-install anything in the crd/ directory. We do this before Helm
 	// contacts the upstream server and builds the capabilities object.
-	if crds := chrt.CRDObjects(); interactWithServer(i.DryRunStrategy) && !i.SkipCRDs && len(crds) > 0 {
+	crdPolicy, err := resolveCRDPolicy(i.CRDPolicy, i.SkipCRDs, CRDPolicyCreate)
+	if err != nil {
+		return nil, err
+	}
+	if crds := chrt.CRDObjects(); interactWithServer(i.DryRunStrategy) && crdPolicy != CRDPolicySkip && len(crds) > 0 {
+		if i.Preflight {
+			p := &permissions{}
+			p.addCRDs(crdPolicy)
+			if err := i.cfg.preflight(p); err != nil {
+				return nil, err
+			}
+		}
 		// On dry run, bail here
 		if isDryRun(i.DryRunStrategy) {
 			slog.Warn("This chart or one of its subcharts contains CRDs. Rendering may fail or contain inaccuracies.")
-		} else if err := i.installCRDs(crds); err != nil {
+		} else if err := i.cfg.installCRDs(crds, crdPolicy, i.ServerSideApply, i.ForceConflicts, i.WaitStrategy); err != nil {
 			return nil, err
 		}
 	}
@@ -374,6 +355,7 @@ func (i *Install) RunWithContext(ctx context.Context, ch ci.Charter, vals map[st
 	if err != nil {
 		return nil, err
 	}
+	i.cfg.emitRendered(rel, resources)
 
 	// Install requires an extra validation step of checking that resources
 	// don't already exist before we actually create resources. If we continue
@@ -392,6 +374,12 @@ func (i *Install) RunWithContext(ctx context.Context, ch ci.Charter, vals map[st
 		}
 	}
 
+	if i.Preflight && interactWithServer(i.DryRunStrategy) {
+		if err := i.preflight(rel, resources, toBeAdopted); err != nil {
+			return nil, err
+		}
+	}
+
 	// Bail out here if it is a dry run
 	if isDryRun(i.DryRunStrategy) {
 		rel.Info.Description = "Dry run complete"
@@ -484,28 +472,40 @@ func (i *Install) performInstall(rel *release.Release, toBeAdopted kube.Resource
 		}
 	}
 
-	// At this point, we can do the install. Note that before we were detecting whether to
-	// do an update, but it's not clear whether we WANT to do an update if the reuse is set
-	// to true, since that is basically an upgrade operation.
-	if len(toBeAdopted) == 0 && len(resources) > 0 {
-		_, err = i.cfg.KubeClient.Create(
-			resources,
-			kube.ClientCreateOptionServerSideApply(i.ServerSideApply, false))
-	} else if len(resources) > 0 {
-		updateThreeWayMergeForUnstructured := i.TakeOwnership && !i.ServerSideApply // Use three-way merge when taking ownership (and not using server-side apply)
-		_, err = i.cfg.KubeClient.Update(
-			toBeAdopted,
-			resources,
-			kube.ClientUpdateOptionForceReplace(i.ForceReplace),
-			kube.ClientUpdateOptionServerSideApply(i.ServerSideApply, i.ForceConflicts),
-			kube.ClientUpdateOptionThreeWayMergeForUnstructured(updateThreeWayMergeForUnstructured),
-			kube.ClientUpdateOptionUpgradeClientSideFieldManager(true))
-	}
+	waves, err := applyWaves(resources)
 	if err != nil {
 		return rel, err
 	}
+	var waveWaiter kube.Waiter
+	if len(waves) > 1 {
+		if waveWaiter, err = i.cfg.waveWaiter(i.WaitStrategy); err != nil {
+			return rel, fmt.Errorf("failed to get waiter: %w", err)
+		}
+	}
+	set := releaseApplySet(rel)
+	for n, wave := range waves {
+		if err := i.cfg.checkReleaseLock(rel.Name); err != nil {
+			return rel, err
+		}
+		result, err := i.applyResources(set, toBeAdopted.Intersect(wave.resources), wave.resources)
+		if err != nil {
+			return rel, err
+		}
+		i.cfg.emitResult(result)
+		recordWarnings(rel, result)
+		if i.WarningsAsErrors {
+			if err := warningsAsError(rel); err != nil {
+				return rel, err
+			}
+		}
+		if n < len(waves)-1 {
+			if err := waitForWave(waveWaiter, wave, i.WaitForJobs, i.Timeout); err != nil {
+				return rel, err
+			}
+		}
+	}
 
-	waiter, err := i.cfg.KubeClient.GetWaiter(i.WaitStrategy)
+	waiter, err := i.cfg.getWaiter(i.WaitStrategy)
 	if err != nil {
 		return rel, fmt.Errorf("failed to get waiter: %w", err)
 	}
@@ -545,8 +545,36 @@ func (i *Install) performInstall(rel *release.Release, toBeAdopted kube.Resource
 	return rel, nil
 }
 
+// applyResources creates resources, or updates them if some of them are adopted.
+func (i *Install) applyResources(set *kube.ApplySet, toBeAdopted, resources kube.ResourceList) (*kube.Result, error) {
+	// At this point, we can do the install. Note that before we were detecting whether to
+	// do an update, but it's not clear whether we WANT to do an update if the reuse is set
+	// to true, since that is basically an upgrade operation.
+	if len(resources) == 0 {
+		return nil, nil
+	}
+	if len(toBeAdopted) == 0 {
+		return i.cfg.KubeClient.Create(
+			resources,
+			kube.ClientCreateOptionServerSideApply(i.ServerSideApply, false),
+			kube.ClientCreateOptionApplySet(set))
+	}
+	updateThreeWayMergeForUnstructured := i.TakeOwnership && !i.ServerSideApply // Use three-way merge when taking ownership (and not using server-side apply)
+	return i.cfg.KubeClient.Update(
+		toBeAdopted,
+		resources,
+		kube.ClientUpdateOptionForceReplace(i.ForceReplace),
+		kube.ClientUpdateOptionServerSideApply(i.ServerSideApply, i.ForceConflicts),
+		kube.ClientUpdateOptionThreeWayMergeForUnstructured(updateThreeWayMergeForUnstructured),
+		kube.ClientUpdateOptionUpgradeClientSideFieldManager(true),
+		kube.ClientUpdateOptionApplySet(set, false))
+}
+
 func (i *Install) failRelease(rel *release.Release, err error) (*release.Release, error) {
 	rel.SetStatus(rcommon.StatusFailed, fmt.Sprintf("Release %q failed: %s", i.ReleaseName, err.Error()))
+	if !i.DisableHooks {
+		i.cfg.execFailureHook(rel, release.HookPostInstallFailure, i.WaitStrategy, i.Timeout, i.ServerSideApply)
+	}
 	if i.RollbackOnFailure {
 		slog.Debug("install failed and rollback-on-failure is set, uninstalling release", "release", i.ReleaseName)
 		uninstall := NewUninstall(i.cfg)
@@ -637,18 +665,51 @@ func (i *Install) createRelease(chrt *chart.Chart, rawVals map[string]interface{
 			LastDeployed:  ts,
 			Status:        rcommon.StatusUnknown,
 		},
-		Version:     1,
-		Labels:      labels,
-		ApplyMethod: string(determineReleaseSSApplyMethod(i.ServerSideApply)),
+		Version:        1,
+		Labels:         labels,
+		ApplyMethod:    string(determineReleaseSSApplyMethod(i.ServerSideApply)),
+		ServiceAccount: i.ServiceAccount,
+	}
+	if i.ApplySet {
+		r.ApplySetID = applySetID(r)
 	}
 
 	return r
 }
 
+// preflight reviews the permissions required to install the release.
+func (i *Install) preflight(rel *release.Release, resources, toBeAdopted kube.ResourceList) error {
+	p := &permissions{}
+	verbs := []string{"get", "create"}
+	if i.ServerSideApply {
+		verbs = append(verbs, "patch")
+	}
+	p.add(resources, verbs...)
+	p.add(toBeAdopted, "patch")
+	p.addWait(resources, i.WaitStrategy)
+	p.addApplySet(releaseApplySet(rel), resources)
+	if i.CreateNamespace {
+		p.resources = append(p.resources,
+			kube.Permission{Verb: "get", Resource: "namespaces", Name: i.Namespace},
+			kube.Permission{Verb: "create", Resource: "namespaces"})
+	}
+	if i.RollbackOnFailure {
+		p.add(resources, "delete")
+	}
+	if !i.DisableHooks {
+		if err := p.addHooks(i.cfg, rel, i.ServerSideApply, release.HookPreInstall, release.HookPostInstall, release.HookPostInstallFailure); err != nil {
+			return err
+		}
+	}
+	p.addStorage(i.cfg, rel.Namespace)
+	return i.cfg.preflight(p)
+}
+
 // recordRelease with an update operation in case reuse has been set.
 func (i *Install) recordRelease(r *release.Release) error {
-	// This is a legacy function which has been reduced to a oneliner. Could probably
-	// refactor it out.
+	if err := i.cfg.checkReleaseLock(r.Name); err != nil {
+		return err
+	}
 	return i.cfg.Releases.Update(r)
 }
 
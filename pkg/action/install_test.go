@@ -532,6 +532,62 @@ func TestInstallRelease_FailedHooks(t *testing.T) {
 	is.Equal(rcommon.StatusFailed, res.Info.Status)
 }
 
+func TestInstallRelease_FailureHooks(t *testing.T) {
+	for _, hookErr := range []error{nil, fmt.Errorf("failure hook failed")} {
+		t.Run(fmt.Sprintf("hook error %v", hookErr), func(t *testing.T) {
+			is := assert.New(t)
+			instAction := installAction(t)
+			instAction.ReleaseName = "failure-hooks"
+			instAction.WaitStrategy = kube.StatusWatcherStrategy
+			failer := instAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+			failer.WaitError = fmt.Errorf("I timed out")
+			failer.WatchUntilReadyError = hookErr
+
+			chrt := buildChartWithTemplates([]*common.File{
+				{Name: "templates/hello", Data: []byte("hello: world")},
+				{Name: "templates/failure-hooks", Data: []byte(manifestWithFailureHooks)},
+			})
+			resi, err := instAction.Run(chrt, map[string]interface{}{})
+			is.EqualError(err, "I timed out")
+			res, rerr := releaserToV1Release(resi)
+			is.NoError(rerr)
+			is.Equal(rcommon.StatusFailed, res.Info.Status)
+			is.Len(res.Hooks, 1)
+			if hookErr == nil {
+				is.Equal(release.HookPhaseSucceeded, res.Hooks[0].LastRun.Phase)
+			} else {
+				is.Equal(release.HookPhaseFailed, res.Hooks[0].LastRun.Phase)
+			}
+		})
+	}
+}
+
+func TestInstallRelease_Warnings(t *testing.T) {
+	for _, warningsAsErrors := range []bool{false, true} {
+		t.Run(fmt.Sprintf("warnings as errors %v", warningsAsErrors), func(t *testing.T) {
+			is := assert.New(t)
+			config := actionConfigFixtureWithDummyResources(t, createDummyResourceList(true))
+			config.KubeClient.(*kubefake.FailingKubeClient).Warnings = []kube.Warning{
+				{Kind: "Pod", Namespace: "spaced", Name: "web", Message: "spec.privileged is deprecated"},
+			}
+			instAction := installActionWithConfig(config)
+			instAction.WarningsAsErrors = warningsAsErrors
+
+			resi, err := instAction.Run(buildChart(), nil)
+			res, rerr := releaserToV1Release(resi)
+			is.NoError(rerr)
+			is.Equal([]string{"Pod spaced/web: spec.privileged is deprecated"}, res.Info.Warnings)
+			if !warningsAsErrors {
+				is.NoError(err)
+				is.Equal(rcommon.StatusDeployed, res.Info.Status)
+				return
+			}
+			is.EqualError(err, "the API server returned 1 warning(s), which are treated as errors:\nPod spaced/web: spec.privileged is deprecated")
+			is.Equal(rcommon.StatusFailed, res.Info.Status)
+		})
+	}
+}
+
 func TestInstallRelease_ReplaceRelease(t *testing.T) {
 	is := assert.New(t)
 	instAction := installAction(t)
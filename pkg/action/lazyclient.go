@@ -20,12 +20,15 @@ import (
 	"context"
 	"sync"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
+	applycoordinationv1 "k8s.io/client-go/applyconfigurations/coordination/v1"
 	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
 	"k8s.io/client-go/kubernetes"
+	typedcoordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
@@ -195,3 +198,75 @@ func (c *configMapClient) Apply(ctx context.Context, configMap *applycorev1.Conf
 	}
 	return c.client.CoreV1().ConfigMaps(c.namespace).Apply(ctx, configMap, opts)
 }
+
+// leaseClient implements a coordinationv1.LeaseInterface
+type leaseClient struct{ *lazyClient }
+
+var _ typedcoordinationv1.LeaseInterface = (*leaseClient)(nil)
+
+func newLeaseClient(lc *lazyClient) *leaseClient {
+	return &leaseClient{lazyClient: lc}
+}
+
+func (l *leaseClient) Create(ctx context.Context, lease *coordinationv1.Lease, opts metav1.CreateOptions) (*coordinationv1.Lease, error) {
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).Create(ctx, lease, opts)
+}
+
+func (l *leaseClient) Update(ctx context.Context, lease *coordinationv1.Lease, opts metav1.UpdateOptions) (*coordinationv1.Lease, error) {
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).Update(ctx, lease, opts)
+}
+
+func (l *leaseClient) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	if err := l.init(); err != nil {
+		return err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).Delete(ctx, name, opts)
+}
+
+func (l *leaseClient) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	if err := l.init(); err != nil {
+		return err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).DeleteCollection(ctx, opts, listOpts)
+}
+
+func (l *leaseClient) Get(ctx context.Context, name string, opts metav1.GetOptions) (*coordinationv1.Lease, error) {
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).Get(ctx, name, opts)
+}
+
+func (l *leaseClient) List(ctx context.Context, opts metav1.ListOptions) (*coordinationv1.LeaseList, error) {
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).List(ctx, opts)
+}
+
+func (l *leaseClient) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).Watch(ctx, opts)
+}
+
+func (l *leaseClient) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*coordinationv1.Lease, error) {
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).Patch(ctx, name, pt, data, opts, subresources...)
+}
+
+func (l *leaseClient) Apply(ctx context.Context, leaseConfiguration *applycoordinationv1.LeaseApplyConfiguration, opts metav1.ApplyOptions) (*coordinationv1.Lease, error) {
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	return l.client.CoordinationV1().Leases(l.namespace).Apply(ctx, leaseConfiguration, opts)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/rand"
+
+	rcommon "helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+// releaseLockDuration is how long a release lock is held without renewal.
+// Locks are renewed at a third of this interval while an operation runs, so a
+// killed process blocks other operations for at most this long.
+const releaseLockDuration = 60 * time.Second
+
+// releaseLockRenewInterval is the interval at which held release locks are
+// renewed.
+var releaseLockRenewInterval = releaseLockDuration / 3
+
+// errReleaseLockLost is returned when the lock of a release was taken over by
+// another holder while an operation was running.
+var errReleaseLockLost = errors.New("the release lock was lost to another holder")
+
+// lockHolderIdentity identifies this process as the holder of release locks.
+var lockHolderIdentity = sync.OnceValue(func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s_%d_%s", host, os.Getpid(), rand.String(5))
+})
+
+// heldLock is a release lock acquired through a Configuration.
+type heldLock struct {
+	// count is the number of operations holding the lock, as operations such
+	// as an atomic install nest an uninstall of the same release.
+	count int
+	stop  chan struct{}
+	done  chan struct{}
+	// lost is set when the lock was taken over by another holder.
+	lost error
+}
+
+// lockRelease acquires the lock of the named release for an operation and
+// renews it in the background. The returned function releases the lock.
+//
+// If no Locker is configured, or the user is not allowed to use it, the lock
+// is a no-op. Operations then fall back to refusing to change releases in a
+// pending state.
+func (cfg *Configuration) lockRelease(name, operation string) (func(), error) {
+	if cfg.parent != nil {
+		return cfg.parent.lockRelease(name, operation)
+	}
+	if cfg.Locker == nil {
+		return func() {}, nil
+	}
+
+	cfg.locksLock.Lock()
+	defer cfg.locksLock.Unlock()
+
+	if held, ok := cfg.locks[name]; ok {
+		held.count++
+		return func() { cfg.unlockRelease(name) }, nil
+	}
+
+	lock := &driver.LockInfo{
+		Name:      name,
+		Holder:    lockHolderIdentity(),
+		Operation: operation,
+		Duration:  releaseLockDuration,
+	}
+	if err := cfg.Locker.Lock(lock); err != nil {
+		if errors.Is(err, driver.ErrLocked) {
+			return nil, fmt.Errorf("%w: %w", errPending, err)
+		}
+		if apierrors.IsForbidden(err) {
+			slog.Warn("release locking is unavailable, falling back to checking for pending releases", "name", name, slog.Any("error", err))
+			return func() {}, nil
+		}
+		return nil, fmt.Errorf("unable to lock release %q: %w", name, err)
+	}
+	slog.Debug("acquired release lock", "name", name, "holder", lock.Holder, "operation", operation)
+
+	held := &heldLock{
+		count: 1,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go cfg.renewReleaseLock(name, lock.Holder, held)
+
+	if cfg.locks == nil {
+		cfg.locks = map[string]*heldLock{}
+	}
+	cfg.locks[name] = held
+	return func() { cfg.unlockRelease(name) }, nil
+}
+
+func (cfg *Configuration) renewReleaseLock(name, holder string, held *heldLock) {
+	defer close(held.done)
+	ticker := time.NewTicker(releaseLockRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-held.stop:
+			return
+		case <-ticker.C:
+			err := cfg.Locker.Renew(name, holder)
+			if errors.Is(err, driver.ErrLocked) {
+				slog.Error("release lock was taken over by another holder", "name", name, slog.Any("error", err))
+				cfg.locksLock.Lock()
+				held.lost = err
+				cfg.locksLock.Unlock()
+				return
+			}
+			if err != nil {
+				slog.Warn("unable to renew release lock", "name", name, slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (cfg *Configuration) unlockRelease(name string) {
+	cfg.locksLock.Lock()
+	defer cfg.locksLock.Unlock()
+
+	held, ok := cfg.locks[name]
+	if !ok {
+		return
+	}
+	if held.count--; held.count > 0 {
+		return
+	}
+	delete(cfg.locks, name)
+	close(held.stop)
+	<-held.done
+	if err := cfg.Locker.Unlock(name, lockHolderIdentity()); err != nil {
+		slog.Warn("unable to release lock", "name", name, slog.Any("error", err))
+	}
+}
+
+// holdsReleaseLock reports whether the lock of the named release is held
+// through this configuration.
+func (cfg *Configuration) holdsReleaseLock(name string) bool {
+	if cfg.parent != nil {
+		return cfg.parent.holdsReleaseLock(name)
+	}
+	cfg.locksLock.Lock()
+	defer cfg.locksLock.Unlock()
+	_, ok := cfg.locks[name]
+	return ok
+}
+
+// checkReleaseLock returns an error wrapping errReleaseLockLost if the lock
+// of the named release held through this configuration was taken over by
+// another holder. Operations check it before changing the release, so they
+// fail instead of racing with the new holder.
+func (cfg *Configuration) checkReleaseLock(name string) error {
+	if cfg.parent != nil {
+		return cfg.parent.checkReleaseLock(name)
+	}
+	cfg.locksLock.Lock()
+	defer cfg.locksLock.Unlock()
+	if held, ok := cfg.locks[name]; ok && held.lost != nil {
+		return fmt.Errorf("%w: %w", errReleaseLockLost, held.lost)
+	}
+	return nil
+}
+
+// recoverPendingRelease marks a release left in a pending state by an
+// interrupted operation as failed. It must only be called with the release
+// lock held.
+func (cfg *Configuration) recoverPendingRelease(rel *release.Release) error {
+	slog.Warn("release was left in a pending state by an interrupted operation", "name", rel.Name, "revision", rel.Version, "status", rel.Info.Status)
+	rel.SetStatus(rcommon.StatusFailed, fmt.Sprintf("Interrupted in state %s", rel.Info.Status))
+	if err := cfg.Releases.Update(rel); err != nil {
+		return fmt.Errorf("unable to mark interrupted release %q revision %d as failed: %w", rel.Name, rel.Version, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	kubefake "helm.sh/helm/v4/pkg/kube/fake"
+	"helm.sh/helm/v4/pkg/release/common"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+func lockedConfigFixture(t *testing.T) *Configuration {
+	t.Helper()
+	config := actionConfigFixture(t)
+	config.Locker = driver.NewLeases(fake.NewClientset().CoordinationV1().Leases("default"))
+	return config
+}
+
+func TestLockRelease(t *testing.T) {
+	config := lockedConfigFixture(t)
+
+	unlock, err := config.lockRelease("locked", "upgrade")
+	require.NoError(t, err)
+	// Nested operations through the same configuration share the lock
+	unlockNested, err := config.lockRelease("locked", "rollback")
+	require.NoError(t, err)
+
+	other := actionConfigFixture(t)
+	other.Locker = config.Locker
+	// Pretend to be another process by locking under a different holder
+	err = other.Locker.Lock(&driver.LockInfo{Name: "locked", Holder: "other", Duration: time.Minute})
+	assert.ErrorIs(t, err, driver.ErrLocked)
+
+	unlockNested()
+	assert.True(t, config.holdsReleaseLock("locked"))
+	info, err := config.Locker.GetLock("locked")
+	require.NoError(t, err)
+	assert.Equal(t, lockHolderIdentity(), info.Holder)
+	assert.Equal(t, "upgrade", info.Operation)
+
+	unlock()
+	assert.False(t, config.holdsReleaseLock("locked"))
+	_, err = config.Locker.GetLock("locked")
+	assert.ErrorIs(t, err, driver.ErrNotLocked)
+}
+
+func TestLockReleaseWithoutLocker(t *testing.T) {
+	config := actionConfigFixture(t)
+	unlock, err := config.lockRelease("unlocked", "install")
+	require.NoError(t, err)
+	assert.False(t, config.holdsReleaseLock("unlocked"))
+	unlock()
+}
+
+func TestLockReleaseServiceAccount(t *testing.T) {
+	config := lockedConfigFixture(t)
+	config.KubeClient = &serviceAccountKubeClient{FailingKubeClient: config.KubeClient.(*kubefake.FailingKubeClient)}
+	scoped, err := config.forServiceAccount("apps/deployer")
+	require.NoError(t, err)
+
+	// Locks taken through a configuration operating as a service account are
+	// held through the configuration it was derived from.
+	unlock, err := scoped.lockRelease("impersonated", "upgrade")
+	require.NoError(t, err)
+	assert.True(t, config.holdsReleaseLock("impersonated"))
+	assert.True(t, scoped.holdsReleaseLock("impersonated"))
+	unlock()
+	assert.False(t, config.holdsReleaseLock("impersonated"))
+
+	unscoped, err := scoped.forServiceAccount("")
+	require.NoError(t, err)
+	assert.Same(t, config, unscoped)
+}
+
+func TestLockReleaseForbidden(t *testing.T) {
+	client := fake.NewClientset()
+	client.PrependReactor("*", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, "", nil)
+	})
+	upAction := upgradeAction(t)
+	upAction.cfg.Locker = driver.NewLeases(client.CoordinationV1().Leases("default"))
+
+	unlock, err := upAction.cfg.lockRelease("forbidden", "upgrade")
+	require.NoError(t, err)
+	assert.False(t, upAction.cfg.holdsReleaseLock("forbidden"))
+	unlock()
+
+	// Without the lock, pending releases are not recovered.
+	rel := releaseStub()
+	rel.Name = "forbidden"
+	rel.Info.Status = common.StatusPendingUpgrade
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+	_, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	assert.ErrorIs(t, err, errPending)
+}
+
+func TestLockReleaseLost(t *testing.T) {
+	releaseLockRenewInterval = 10 * time.Millisecond
+	t.Cleanup(func() { releaseLockRenewInterval = releaseLockDuration / 3 })
+	config := lockedConfigFixture(t)
+
+	unlock, err := config.lockRelease("lost", "upgrade")
+	require.NoError(t, err)
+	defer unlock()
+	require.NoError(t, config.checkReleaseLock("lost"))
+
+	// Another holder takes the lock over, e.g. after it expired.
+	require.NoError(t, config.Locker.ForceUnlock("lost"))
+	require.NoError(t, config.Locker.Lock(&driver.LockInfo{Name: "lost", Holder: "other", Operation: "rollback", Duration: time.Minute}))
+
+	require.Eventually(t, func() bool {
+		return config.checkReleaseLock("lost") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	err = config.checkReleaseLock("lost")
+	assert.ErrorIs(t, err, errReleaseLockLost)
+	assert.ErrorIs(t, err, driver.ErrLocked)
+
+	// Releases are no longer changed through the configuration.
+	rel := releaseStub()
+	rel.Name = "lost"
+	require.NoError(t, config.Releases.Create(rel))
+	changed := releaseStub()
+	changed.Name = "lost"
+	changed.Info.Description = "changed"
+	config.recordRelease(changed)
+	stored, err := config.Releases.Get("lost", rel.Version)
+	require.NoError(t, err)
+	storedRel, err := releaserToV1Release(stored)
+	require.NoError(t, err)
+	assert.NotEqual(t, "changed", storedRel.Info.Description)
+}
+
+func TestUpgradeRelease_Locked(t *testing.T) {
+	upAction := upgradeAction(t)
+	upAction.cfg.Locker = driver.NewLeases(fake.NewClientset().CoordinationV1().Leases("default"))
+	rel := releaseStub()
+	rel.Name = "locked"
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+	require.NoError(t, upAction.cfg.Locker.Lock(&driver.LockInfo{Name: rel.Name, Holder: "other", Operation: "rollback", Duration: time.Minute}))
+
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	assert.ErrorIs(t, err, errPending)
+	assert.ErrorIs(t, err, driver.ErrLocked)
+	assert.ErrorContains(t, err, `release "locked" is locked by other for rollback`)
+}
+
+func TestUpgradeRelease_RecoverPending(t *testing.T) {
+	upAction := upgradeAction(t)
+	upAction.cfg.Locker = driver.NewLeases(fake.NewClientset().CoordinationV1().Leases("default"))
+	rel := releaseStub()
+	rel.Name = "interrupted"
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+	rel2 := releaseStub()
+	rel2.Name = "interrupted"
+	rel2.Info.Status = common.StatusPendingUpgrade
+	rel2.Version = 2
+	require.NoError(t, upAction.cfg.Releases.Create(rel2))
+
+	resi, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	require.NoError(t, err)
+	res, err := releaserToV1Release(resi)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Version)
+	assert.Equal(t, common.StatusDeployed, res.Info.Status)
+
+	interruptedi, err := upAction.cfg.Releases.Get(rel.Name, 2)
+	require.NoError(t, err)
+	interrupted, err := releaserToV1Release(interruptedi)
+	require.NoError(t, err)
+	assert.Equal(t, common.StatusFailed, interrupted.Info.Status)
+	assert.Equal(t, "Interrupted in state pending-upgrade", interrupted.Info.Description)
+
+	// The lock is released once the upgrade is done
+	_, err = upAction.cfg.Locker.GetLock(rel.Name)
+	assert.ErrorIs(t, err, driver.ErrNotLocked)
+}
+
+func TestUnlock(t *testing.T) {
+	config := lockedConfigFixture(t)
+	client := NewUnlock(config)
+
+	_, err := client.Run("stuck")
+	assert.ErrorContains(t, err, `release "stuck" is not locked`)
+
+	require.NoError(t, config.Locker.Lock(&driver.LockInfo{Name: "stuck", Holder: "killed", Operation: "upgrade", Duration: time.Minute}))
+	lock, err := client.Run("stuck")
+	require.NoError(t, err)
+	assert.Equal(t, "killed", lock.Holder)
+	assert.Equal(t, "upgrade", lock.Operation)
+
+	_, err = config.Locker.GetLock("stuck")
+	assert.ErrorIs(t, err, driver.ErrNotLocked)
+}
+
+func TestUnlockWithoutLocker(t *testing.T) {
+	_, err := NewUnlock(actionConfigFixture(t)).Run("stuck")
+	assert.ErrorContains(t, err, `storage driver "Memory" does not support release locks`)
+}
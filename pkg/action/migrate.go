@@ -0,0 +1,273 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	ri "helm.sh/helm/v4/pkg/release"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	"helm.sh/helm/v4/pkg/storage"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+// MigrationResult describes the outcome of migrating a single release record.
+type MigrationResult string
+
+const (
+	// MigrationCopied indicates the record was copied to the target and verified.
+	MigrationCopied MigrationResult = "copied"
+	// MigrationPending indicates the record would be copied if not for a dry run.
+	MigrationPending MigrationResult = "pending"
+	// MigrationAlreadyPresent indicates an identical record already exists in the target.
+	MigrationAlreadyPresent MigrationResult = "already present"
+	// MigrationFailed indicates the record could not be copied or verified.
+	MigrationFailed MigrationResult = "failed"
+)
+
+// MigratedRelease reports the migration of a single release revision.
+type MigratedRelease struct {
+	Name          string          `json:"name"`
+	Namespace     string          `json:"namespace"`
+	Revision      int             `json:"revision"`
+	Status        string          `json:"status"`
+	Result        MigrationResult `json:"result"`
+	SourceDeleted bool            `json:"sourceDeleted"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// Migrate is the action for moving release records between storage drivers.
+//
+// It provides the implementation of 'helm storage migrate'.
+type Migrate struct {
+	cfg *Configuration
+
+	// From and To are the names of the source and target storage drivers,
+	// as accepted by $HELM_DRIVER.
+	From string
+	To   string
+	// Namespace limits the migration to the release records of one namespace.
+	// It is ignored when AllNamespaces is set.
+	Namespace     string
+	AllNamespaces bool
+	// DeleteSource removes the source records once all records have been
+	// copied and verified.
+	DeleteSource bool
+	// DryRun reports what would be migrated without writing to either driver.
+	DryRun bool
+
+	// storageFn creates the storage for a driver name and namespace.
+	storageFn func(helmDriver, namespace string) (*storage.Storage, error)
+}
+
+// NewMigrate creates a new Migrate object with the given configuration.
+func NewMigrate(cfg *Configuration) *Migrate {
+	m := &Migrate{
+		cfg: cfg,
+	}
+	m.storageFn = m.newStorage
+	return m
+}
+
+// Run copies all revisions of all releases from the source to the target
+// driver and verifies each copy.
+//
+// Source records are only deleted when every record has been migrated
+// successfully. The returned report is sorted by namespace, name and revision.
+func (m *Migrate) Run() ([]*MigratedRelease, error) {
+	if driverAlias(m.From) == driverAlias(m.To) {
+		return nil, fmt.Errorf("source and target storage drivers are both %q", driverAlias(m.From))
+	}
+
+	namespace := m.Namespace
+	if m.AllNamespaces {
+		namespace = ""
+	}
+	source, err := m.storageFn(m.From, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize source storage driver %q: %w", m.From, err)
+	}
+	ls, err := source.ListReleases()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list release records: %w", err)
+	}
+	rels, err := releaseListToV1List(ls)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rels, func(i, j int) bool {
+		if rels[i].Namespace != rels[j].Namespace {
+			return rels[i].Namespace < rels[j].Namespace
+		}
+		if rels[i].Name != rels[j].Name {
+			return rels[i].Name < rels[j].Name
+		}
+		return rels[i].Version < rels[j].Version
+	})
+
+	// Drivers backed by Kubernetes objects are bound to a single namespace, so
+	// records are written and deleted through one storage per namespace.
+	sources := map[string]*storage.Storage{namespace: source}
+	targets := map[string]*storage.Storage{}
+	storageFor := func(cache map[string]*storage.Storage, helmDriver, namespace string) (*storage.Storage, error) {
+		if s, ok := cache[namespace]; ok {
+			return s, nil
+		}
+		s, err := m.storageFn(helmDriver, namespace)
+		if err != nil {
+			return nil, err
+		}
+		cache[namespace] = s
+		return s, nil
+	}
+
+	report := make([]*MigratedRelease, 0, len(rels))
+	var errs []error
+	for _, rel := range rels {
+		entry := &MigratedRelease{
+			Name:      rel.Name,
+			Namespace: rel.Namespace,
+			Revision:  rel.Version,
+		}
+		if rel.Info != nil {
+			entry.Status = rel.Info.Status.String()
+		}
+		report = append(report, entry)
+
+		target, err := storageFor(targets, m.To, rel.Namespace)
+		if err == nil {
+			entry.Result, err = m.migrateRecord(target, rel)
+		}
+		if err != nil {
+			entry.Result = MigrationFailed
+			entry.Error = err.Error()
+			errs = append(errs, fmt.Errorf("release %q revision %d in namespace %q: %w", rel.Name, rel.Version, rel.Namespace, err))
+		}
+	}
+	if len(errs) > 0 {
+		return report, fmt.Errorf("migration failed, source records were left in place: %w", errors.Join(errs...))
+	}
+
+	if !m.DeleteSource || m.DryRun {
+		return report, nil
+	}
+	for _, entry := range report {
+		src, err := storageFor(sources, m.From, entry.Namespace)
+		if err == nil {
+			_, err = src.Delete(entry.Name, entry.Revision)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to delete source record of release %q revision %d: %w", entry.Name, entry.Revision, err))
+			continue
+		}
+		entry.SourceDeleted = true
+	}
+	return report, errors.Join(errs...)
+}
+
+// migrateRecord copies a single release record to the target unless an
+// identical record is already present.
+func (m *Migrate) migrateRecord(target *storage.Storage, rel *release.Release) (MigrationResult, error) {
+	existing, err := target.Get(rel.Name, rel.Version)
+	switch {
+	case err == nil:
+		if err := verifyMigratedRecord(rel, existing); err != nil {
+			return MigrationFailed, fmt.Errorf("a different record already exists in the target: %w", err)
+		}
+		return MigrationAlreadyPresent, nil
+	case !errors.Is(err, driver.ErrReleaseNotFound):
+		return MigrationFailed, err
+	}
+
+	if m.DryRun {
+		return MigrationPending, nil
+	}
+	if err := target.Create(rel); err != nil {
+		return MigrationFailed, err
+	}
+	copied, err := target.Get(rel.Name, rel.Version)
+	if err != nil {
+		return MigrationFailed, fmt.Errorf("unable to read back the copied record: %w", err)
+	}
+	if err := verifyMigratedRecord(rel, copied); err != nil {
+		return MigrationFailed, err
+	}
+	return MigrationCopied, nil
+}
+
+func (m *Migrate) newStorage(helmDriver, namespace string) (*storage.Storage, error) {
+	getter, ok := m.cfg.RESTClientGetter.(genericclioptions.RESTClientGetter)
+	if !ok {
+		return nil, errors.New("storage drivers can only be created from a Kubernetes client configuration")
+	}
+	cfg := new(Configuration)
+	if err := cfg.Init(getter, namespace, helmDriver); err != nil {
+		return nil, err
+	}
+	return cfg.Releases, nil
+}
+
+// verifyMigratedRecord checks that a record read from the target matches the
+// source record. System labels are managed by each driver and are ignored.
+func verifyMigratedRecord(want *release.Release, got ri.Releaser) error {
+	rel, err := releaserToV1Release(got)
+	if err != nil {
+		return err
+	}
+	wantJSON, err := json.Marshal(withoutSystemLabels(want))
+	if err != nil {
+		return err
+	}
+	gotJSON, err := json.Marshal(withoutSystemLabels(rel))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(wantJSON, gotJSON) {
+		return errors.New("record in the target does not match the source")
+	}
+	return nil
+}
+
+func withoutSystemLabels(rel *release.Release) *release.Release {
+	c := *rel
+	c.Labels = maps.Clone(rel.Labels)
+	for _, k := range driver.GetSystemLabels() {
+		delete(c.Labels, k)
+	}
+	if len(c.Labels) == 0 {
+		c.Labels = nil
+	}
+	return &c
+}
+
+// driverAlias returns the canonical name of a storage driver name.
+func driverAlias(helmDriver string) string {
+	switch helmDriver {
+	case "secret", "secrets", "":
+		return "secret"
+	case "configmap", "configmaps":
+		return "configmap"
+	}
+	return helmDriver
+}
@@ -0,0 +1,152 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	rcommon "helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	"helm.sh/helm/v4/pkg/storage"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+// migrateFixture returns a Migrate action from a memory driver holding the
+// given releases to a secrets driver backed by a fake clientset.
+func migrateFixture(t *testing.T, rels ...*release.Release) (*Migrate, *storage.Storage, *storage.Storage) {
+	t.Helper()
+	config := actionConfigFixture(t)
+	for _, rel := range rels {
+		require.NoError(t, config.Releases.Create(rel))
+	}
+	target := storage.Init(driver.NewSecrets(fake.NewClientset().CoreV1().Secrets("default")))
+
+	m := NewMigrate(config)
+	m.From = "memory"
+	m.To = "secret"
+	m.Namespace = "default"
+	m.storageFn = func(helmDriver, _ string) (*storage.Storage, error) {
+		if helmDriver == "memory" {
+			return config.Releases, nil
+		}
+		return target, nil
+	}
+	return m, config.Releases, target
+}
+
+func migrateReleaseStubs() []*release.Release {
+	v1 := namedReleaseStub("migrate", rcommon.StatusSuperseded)
+	v1.Namespace = "default"
+	v1.Labels = map[string]string{"team": "blue"}
+	v2 := namedReleaseStub("migrate", rcommon.StatusDeployed)
+	v2.Namespace = "default"
+	v2.Version = 2
+	return []*release.Release{v1, v2}
+}
+
+func TestMigrateSameDriver(t *testing.T) {
+	m := NewMigrate(actionConfigFixture(t))
+	m.From = "secrets"
+	m.To = ""
+	_, err := m.Run()
+	assert.ErrorContains(t, err, `source and target storage drivers are both "secret"`)
+}
+
+func TestMigrate(t *testing.T) {
+	m, source, target := migrateFixture(t, migrateReleaseStubs()...)
+
+	report, err := m.Run()
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+	for i, entry := range report {
+		assert.Equal(t, "migrate", entry.Name)
+		assert.Equal(t, i+1, entry.Revision)
+		assert.Equal(t, MigrationCopied, entry.Result)
+		assert.False(t, entry.SourceDeleted)
+	}
+
+	got, err := target.Get("migrate", 1)
+	require.NoError(t, err)
+	rel, err := releaserToV1Release(got)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "blue"}, rel.Labels)
+
+	history, err := source.History("migrate")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	// Migrating again finds the identical records in the target
+	report, err = m.Run()
+	require.NoError(t, err)
+	assert.Equal(t, MigrationAlreadyPresent, report[0].Result)
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	m, _, target := migrateFixture(t, migrateReleaseStubs()...)
+	m.DryRun = true
+	m.DeleteSource = true
+
+	report, err := m.Run()
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+	assert.Equal(t, MigrationPending, report[0].Result)
+	assert.False(t, report[0].SourceDeleted)
+
+	_, err = target.Get("migrate", 1)
+	assert.ErrorIs(t, err, driver.ErrReleaseNotFound)
+}
+
+func TestMigrateDeleteSource(t *testing.T) {
+	m, source, target := migrateFixture(t, migrateReleaseStubs()...)
+	m.DeleteSource = true
+
+	report, err := m.Run()
+	require.NoError(t, err)
+	for _, entry := range report {
+		assert.True(t, entry.SourceDeleted)
+	}
+
+	_, err = source.History("migrate")
+	assert.ErrorIs(t, err, driver.ErrReleaseNotFound)
+	history, err := target.History("migrate")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestMigrateConflict(t *testing.T) {
+	rels := migrateReleaseStubs()
+	m, source, target := migrateFixture(t, rels...)
+	m.DeleteSource = true
+
+	conflicting := *rels[0]
+	conflicting.Manifest = "conflicting"
+	require.NoError(t, target.Create(&conflicting))
+
+	report, err := m.Run()
+	assert.ErrorContains(t, err, "a different record already exists in the target")
+	require.Len(t, report, 2)
+	assert.Equal(t, MigrationFailed, report[0].Result)
+	assert.Equal(t, MigrationCopied, report[1].Result)
+
+	history, err := source.History("migrate")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
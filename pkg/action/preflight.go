@@ -0,0 +1,281 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	ci "helm.sh/helm/v4/pkg/chart"
+	"helm.sh/helm/v4/pkg/kube"
+	"helm.sh/helm/v4/pkg/registry"
+	rcommon "helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	releaseutil "helm.sh/helm/v4/pkg/release/v1/util"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+// PreflightError is returned when permissions required for an operation on a
+// release are not granted.
+type PreflightError struct {
+	Missing []kube.Permission
+}
+
+func (e *PreflightError) Error() string {
+	lines := make([]string, len(e.Missing))
+	for i, p := range e.Missing {
+		lines[i] = "  " + p.String()
+	}
+	return fmt.Sprintf("preflight check failed: %d required permission(s) are not granted:\n%s", len(e.Missing), strings.Join(lines, "\n"))
+}
+
+// permissions collects the permissions required for an operation on a
+// release.
+type permissions struct {
+	// resources are the permissions required on the resources of the
+	// release, which are reviewed with the Kubernetes client.
+	resources []kube.Permission
+	// storage are the permissions required by the storage driver, which are
+	// reviewed with the credentials of the user.
+	storage []kube.Permission
+}
+
+func (p *permissions) add(resources kube.ResourceList, verbs ...string) {
+	p.resources = append(p.resources, kube.ResourcePermissions(resources, verbs...)...)
+}
+
+// addWait adds the permissions to wait for the resources.
+func (p *permissions) addWait(resources kube.ResourceList, waitStrategy kube.WaitStrategy) {
+	if waitStrategy == kube.HookOnlyStrategy || waitStrategy == "" {
+		return
+	}
+	p.add(resources, "list", "watch")
+}
+
+// addHooks adds the permissions to execute the hooks of a release for the
+// events.
+func (p *permissions) addHooks(cfg *Configuration, rel *release.Release, serverSideApply bool, events ...release.HookEvent) error {
+	verbs := []string{"get", "create", "delete", "list", "watch"}
+	if serverSideApply {
+		verbs = append(verbs, "patch")
+	}
+	for _, h := range rel.Hooks {
+		for _, e := range h.Events {
+			if !containsHookEvent(events, e) {
+				continue
+			}
+			resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), false)
+			if err != nil {
+				return fmt.Errorf("unable to build kubernetes object for %s hook %s: %w", e, h.Path, err)
+			}
+			p.add(resources, verbs...)
+			break
+		}
+	}
+	return nil
+}
+
+func containsHookEvent(events []release.HookEvent, event release.HookEvent) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// addApplySet adds the permissions to manage the ApplySet of a release.
+func (p *permissions) addApplySet(set *kube.ApplySet, resources kube.ResourceList) {
+	if set == nil {
+		return
+	}
+	for _, verb := range []string{"get", "create", "update"} {
+		perm := kube.Permission{Verb: verb, Resource: "secrets", Namespace: set.Namespace}
+		if verb != "create" {
+			perm.Name = set.Name
+		}
+		p.resources = append(p.resources, perm)
+	}
+	p.add(resources, "list")
+}
+
+// addStorage adds the permissions required by the storage driver and the
+// locker of the configuration for releases in the namespace.
+func (p *permissions) addStorage(cfg *Configuration, namespace string) {
+	storageVerbs := []string{"get", "list", "create", "update", "delete"}
+	if cfg.Releases != nil {
+		var resource string
+		switch cfg.Releases.Driver.(type) {
+		case *driver.Secrets:
+			resource = "secrets"
+		case *driver.ConfigMaps:
+			resource = "configmaps"
+		}
+		if resource != "" {
+			for _, verb := range storageVerbs {
+				p.storage = append(p.storage, kube.Permission{Verb: verb, Resource: resource, Namespace: namespace})
+			}
+		}
+	}
+	if _, ok := cfg.Locker.(*driver.Leases); ok {
+		for _, verb := range []string{"get", "create", "update", "delete"} {
+			p.storage = append(p.storage, kube.Permission{Verb: verb, Group: "coordination.k8s.io", Resource: "leases", Namespace: namespace})
+		}
+	}
+}
+
+// preflight reviews the permissions, and returns a *PreflightError listing
+// the ones which are not granted.
+func (cfg *Configuration) preflight(p *permissions) error {
+	checker, ok := cfg.KubeClient.(kube.InterfacePermissions)
+	if !ok {
+		return errors.New("the Kubernetes client does not support reviewing permissions")
+	}
+	missing, err := checker.CheckPermissions(dedupePermissions(p.resources))
+	if err != nil {
+		return fmt.Errorf("preflight check failed: %w", err)
+	}
+	if storage := dedupePermissions(p.storage); len(storage) > 0 {
+		client, err := cfg.KubernetesClientSet()
+		if err != nil {
+			return fmt.Errorf("preflight check failed: %w", err)
+		}
+		missingStorage, err := kube.CheckPermissions(context.Background(), client, storage)
+		if err != nil {
+			return fmt.Errorf("preflight check failed: %w", err)
+		}
+		missing = append(missing, missingStorage...)
+	}
+	slog.Debug("preflight check complete", "permissions", len(p.resources)+len(p.storage), "missing", len(missing))
+	if len(missing) > 0 {
+		return &PreflightError{Missing: missing}
+	}
+	return nil
+}
+
+func dedupePermissions(permissions []kube.Permission) []kube.Permission {
+	seen := make(map[kube.Permission]bool, len(permissions))
+	var result []kube.Permission
+	for _, p := range permissions {
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Preflight is the action for checking that the permissions required to
+// install or upgrade a release are granted, without changing anything.
+//
+// It provides the implementation of 'helm preflight'.
+type Preflight struct {
+	cfg *Configuration
+
+	ChartPathOptions
+
+	Devel           bool
+	Namespace       string
+	DisableHooks    bool
+	SkipCRDs        bool
+	CRDPolicy       CRDPolicy
+	TakeOwnership   bool
+	CreateNamespace bool
+	ApplySet        bool
+	WaitStrategy    kube.WaitStrategy
+	// ServerSideApply can be the string: "true", "false" or "auto", as for
+	// upgrades. Installs use server-side apply unless it is "false".
+	ServerSideApply          string
+	ServiceAccount           string
+	RecreateOnImmutable      bool
+	DisableOpenAPIValidation bool
+	SkipSchemaValidation     bool
+}
+
+// NewPreflight creates a new Preflight object with the given configuration.
+func NewPreflight(cfg *Configuration) *Preflight {
+	p := &Preflight{
+		cfg:             cfg,
+		ServerSideApply: "auto",
+	}
+	p.registryClient = cfg.RegistryClient
+	return p
+}
+
+// SetRegistryClient sets the registry client to use when pulling a chart from
+// a registry.
+func (p *Preflight) SetRegistryClient(client *registry.Client) {
+	p.registryClient = client
+}
+
+// Run checks the permissions required to install the chart as the release,
+// or to upgrade the release if it exists. It returns a *PreflightError if
+// permissions are missing, and whether the release would be upgraded.
+func (p *Preflight) Run(name string, chrt ci.Charter, vals map[string]interface{}) (upgrade bool, err error) {
+	history, err := p.cfg.Releases.History(name)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return false, err
+	}
+	rels, err := releaseListToV1List(history)
+	if err != nil {
+		return false, err
+	}
+	releaseutil.SortByRevision(rels)
+	if len(rels) > 0 && rels[len(rels)-1].Info.Status != rcommon.StatusUninstalled {
+		u := NewUpgrade(p.cfg)
+		u.Namespace = p.Namespace
+		u.DryRunStrategy = DryRunServer
+		u.Preflight = true
+		u.DisableHooks = p.DisableHooks
+		u.SkipCRDs = p.SkipCRDs
+		u.CRDPolicy = p.CRDPolicy
+		u.TakeOwnership = p.TakeOwnership
+		u.ApplySet = p.ApplySet
+		u.WaitStrategy = p.WaitStrategy
+		u.ServerSideApply = p.ServerSideApply
+		u.ServiceAccount = p.ServiceAccount
+		u.RecreateOnImmutable = p.RecreateOnImmutable
+		u.DisableOpenAPIValidation = p.DisableOpenAPIValidation
+		u.SkipSchemaValidation = p.SkipSchemaValidation
+		_, err := u.Run(name, chrt, vals)
+		return true, err
+	}
+
+	i := NewInstall(p.cfg)
+	i.ReleaseName = name
+	i.Namespace = p.Namespace
+	i.DryRunStrategy = DryRunServer
+	i.Preflight = true
+	i.Replace = len(rels) > 0
+	i.DisableHooks = p.DisableHooks
+	i.SkipCRDs = p.SkipCRDs
+	i.CRDPolicy = p.CRDPolicy
+	i.TakeOwnership = p.TakeOwnership
+	i.CreateNamespace = p.CreateNamespace
+	i.ApplySet = p.ApplySet
+	i.WaitStrategy = p.WaitStrategy
+	i.ServerSideApply = p.ServerSideApply != "false"
+	i.ServiceAccount = p.ServiceAccount
+	i.DisableOpenAPIValidation = p.DisableOpenAPIValidation
+	i.SkipSchemaValidation = p.SkipSchemaValidation
+	_, err = i.Run(chrt, vals)
+	return false, err
+}
@@ -0,0 +1,102 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v4/pkg/kube"
+	kubefake "helm.sh/helm/v4/pkg/kube/fake"
+	rcommon "helm.sh/helm/v4/pkg/release/common"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+func TestInstallRelease_Preflight(t *testing.T) {
+	config := actionConfigFixtureWithDummyResources(t, createDummyResourceList(true))
+	kubeClient := config.KubeClient.(*kubefake.FailingKubeClient)
+	missing := kube.Permission{Verb: "patch", Group: "apps", Resource: "deployment", Namespace: "spaced", Name: "dummyName"}
+	kubeClient.MissingPermissions = []kube.Permission{missing}
+
+	instAction := installActionWithConfig(config)
+	instAction.Preflight = true
+	_, err := instAction.Run(buildChart(withSampleTemplates()), nil)
+
+	var preflightErr *PreflightError
+	require.True(t, errors.As(err, &preflightErr), "expected a preflight error, got %v", err)
+	assert.Equal(t, []kube.Permission{missing}, preflightErr.Missing)
+	assert.EqualError(t, err, "preflight check failed: 1 required permission(s) are not granted:\n  patch deployment.apps/dummyName in namespace spaced")
+	assert.Contains(t, kubeClient.CheckedPermissions,
+		kube.Permission{Verb: "create", Group: "apps", Resource: "deployment", Namespace: "spaced"})
+
+	// Nothing was changed.
+	_, err = config.Releases.Last(instAction.ReleaseName)
+	assert.ErrorIs(t, err, driver.ErrReleaseNotFound)
+}
+
+func TestUninstallRelease_Preflight(t *testing.T) {
+	config := actionConfigFixtureWithDummyResources(t, createDummyResourceList(true))
+	kubeClient := config.KubeClient.(*kubefake.FailingKubeClient)
+	kubeClient.MissingPermissions = []kube.Permission{
+		{Verb: "delete", Group: "apps", Resource: "deployment", Namespace: "spaced", Name: "dummyName"},
+	}
+
+	rel := releaseStub()
+	require.NoError(t, config.Releases.Create(rel))
+
+	unAction := NewUninstall(config)
+	unAction.Preflight = true
+	_, err := unAction.Run(rel.Name)
+	assert.EqualError(t, err, "preflight check failed: 1 required permission(s) are not granted:\n  delete deployment.apps/dummyName in namespace spaced")
+
+	last, err := config.Releases.Last(rel.Name)
+	require.NoError(t, err)
+	current, err := releaserToV1Release(last)
+	require.NoError(t, err)
+	assert.Equal(t, rcommon.StatusDeployed, current.Info.Status)
+}
+
+func TestPreflight(t *testing.T) {
+	config := actionConfigFixtureWithDummyResources(t, createDummyResourceList(true))
+	kubeClient := config.KubeClient.(*kubefake.FailingKubeClient)
+
+	client := NewPreflight(config)
+	client.Namespace = "spaced"
+
+	upgrade, err := client.Run("test-install-release", buildChart(withSampleTemplates()), nil)
+	require.NoError(t, err)
+	assert.False(t, upgrade)
+	assert.NotEmpty(t, kubeClient.CheckedPermissions)
+	// The installation is only simulated.
+	_, err = config.Releases.Last("test-install-release")
+	assert.ErrorIs(t, err, driver.ErrReleaseNotFound)
+
+	rel := releaseStub()
+	rel.Name = "test-install-release"
+	rel.Namespace = "spaced"
+	require.NoError(t, config.Releases.Create(rel))
+
+	kubeClient.CheckedPermissions = nil
+	upgrade, err = client.Run(rel.Name, buildChart(withSampleTemplates()), nil)
+	require.NoError(t, err)
+	assert.True(t, upgrade)
+	assert.Contains(t, kubeClient.CheckedPermissions,
+		kube.Permission{Verb: "patch", Group: "apps", Resource: "deployment", Namespace: "spaced", Name: "dummyName"})
+}
@@ -0,0 +1,49 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+// Rekey is the action for rewriting stored release records with the current
+// encryption keys.
+//
+// It provides the implementation of 'helm storage rekey'.
+type Rekey struct {
+	cfg *Configuration
+}
+
+// NewRekey creates a new Rekey object with the given configuration.
+func NewRekey(cfg *Configuration) *Rekey {
+	return &Rekey{
+		cfg: cfg,
+	}
+}
+
+// Run re-encrypts all release records of the configured storage driver and
+// returns the number of rewritten records. It fails if no key to encrypt the
+// records is configured.
+func (r *Rekey) Run() (int, error) {
+	rk, ok := r.cfg.Releases.Driver.(driver.Rekeyer)
+	if !ok {
+		return 0, fmt.Errorf("storage driver %q does not support encryption", r.cfg.Releases.Name())
+	}
+	return rk.Rekey()
+}
@@ -0,0 +1,83 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"helm.sh/helm/v4/pkg/storage"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+func TestRekeyUnsupportedDriver(t *testing.T) {
+	config := actionConfigFixture(t)
+	_, err := NewRekey(config).Run()
+	assert.ErrorContains(t, err, `storage driver "Memory" does not support encryption`)
+}
+
+func TestRekeyWithoutEncryptionKey(t *testing.T) {
+	config := actionConfigFixture(t)
+	d := driver.NewSecrets(fake.NewClientset().CoreV1().Secrets("default"))
+	config.Releases = storage.Init(d)
+
+	enc, err := driver.NewAESEncryption(make([]byte, 32))
+	assert.NoError(t, err)
+	d.Encryption = enc
+	rel := releaseStub()
+	rel.Name = "rekey"
+	assert.NoError(t, config.Releases.Create(rel))
+
+	// Identities alone only decrypt, so the records must not be rewritten
+	// unencrypted.
+	for _, enc := range []*driver.Encryption{nil, driver.NewAgeEncryption(nil, nil)} {
+		d.Encryption = enc
+		_, err = NewRekey(config).Run()
+		assert.ErrorIs(t, err, driver.ErrNoEncryptionKey)
+	}
+
+	d.Encryption = nil
+	_, err = config.Releases.Get(rel.Name, rel.Version)
+	assert.ErrorIs(t, err, driver.ErrEncryptedRelease)
+}
+
+func TestRekeySecrets(t *testing.T) {
+	config := actionConfigFixture(t)
+	d := driver.NewSecrets(fake.NewClientset().CoreV1().Secrets("default"))
+	config.Releases = storage.Init(d)
+
+	rel := releaseStub()
+	rel.Name = "rekey"
+	assert.NoError(t, config.Releases.Create(rel))
+
+	enc, err := driver.NewAESEncryption(make([]byte, 32))
+	assert.NoError(t, err)
+	d.Encryption = enc
+
+	n, err := NewRekey(config).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = config.Releases.Get(rel.Name, rel.Version)
+	assert.NoError(t, err)
+
+	d.Encryption = nil
+	_, err = config.Releases.Get(rel.Name, rel.Version)
+	assert.ErrorIs(t, err, driver.ErrEncryptedRelease)
+}
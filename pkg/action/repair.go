@@ -0,0 +1,224 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"helm.sh/helm/v4/pkg/kube"
+	"helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+// repairNote is appended to the description of a repaired release.
+const repairNote = "repaired"
+
+// Repair is the action for re-applying the manifest of the current revision
+// of a release, e.g. after its resources were changed outside of Helm or an
+// apply failed partway through.
+//
+// Unlike an upgrade, a repair does not run hooks and does not create a new
+// revision.
+//
+// It provides the implementation of 'helm repair'.
+type Repair struct {
+	cfg *Configuration
+
+	// DryRun lists the resources which would be changed without applying them.
+	DryRun bool
+	// ForceConflicts causes server-side apply to force conflicts ("Overwrite value, become sole manager")
+	// see: https://kubernetes.io/docs/reference/using-api/server-side-apply/#conflicts
+	ForceConflicts bool
+	WaitStrategy   kube.WaitStrategy
+	WaitForJobs    bool
+	Timeout        time.Duration
+}
+
+// NewRepair creates a new Repair object with the given configuration.
+func NewRepair(cfg *Configuration) *Repair {
+	return &Repair{
+		cfg: cfg,
+	}
+}
+
+// Run re-applies the manifest of the current revision of the named release
+// with server-side apply.
+//
+// It returns the resources which differed from the manifest before the
+// repair. In dry-run mode nothing is applied.
+func (r *Repair) Run(name string) ([]*ResourceDrift, error) {
+	if err := r.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if !r.DryRun {
+		unlock, err := r.cfg.lockRelease(name, "repair")
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	reli, err := r.cfg.Releases.Last(name)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := releaserToV1Release(reli)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case rel.Info.Status == common.StatusUninstalled || rel.Info.Status == common.StatusUninstalling:
+		return nil, fmt.Errorf("release %q is uninstalled and cannot be repaired", name)
+	case rel.Info.Status.IsPending() && (r.DryRun || !r.cfg.holdsReleaseLock(name)):
+		return nil, errPending
+	}
+
+	drift := NewDrift(r.cfg)
+	drift.Version = rel.Version
+	drifts, err := drift.Run(name)
+	if err != nil {
+		return nil, err
+	}
+	var changed []*ResourceDrift
+	for _, d := range drifts {
+		if d.Status != DriftInSync {
+			changed = append(changed, d)
+		}
+	}
+	if r.DryRun {
+		return changed, nil
+	}
+
+	// Resources are applied as the service account the release was deployed with.
+	cfg, err := r.cfg.forServiceAccount(rel.ServiceAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build kubernetes objects from release manifest: %w", err)
+	}
+	// It is safe to use "forceOwnership" here because these are the resources of the release.
+	if err := resources.Visit(setMetadataVisitor(rel.Name, rel.Namespace, true)); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("re-applying release manifest", "name", rel.Name, "revision", rel.Version, "changed", len(changed))
+	result, err := cfg.KubeClient.Update(
+		resources,
+		resources,
+		kube.ClientUpdateOptionServerSideApply(true, r.ForceConflicts),
+		kube.ClientUpdateOptionThreeWayMergeForUnstructured(false),
+		kube.ClientUpdateOptionUpgradeClientSideFieldManager(true),
+		// The manifest is unchanged, so nothing of the release is pruned.
+		kube.ClientUpdateOptionApplySet(releaseApplySet(rel), false))
+	if err != nil {
+		// Fields changed by another field manager conflict unless conflicts are
+		// forced, so the drift cannot be reverted.
+		if apierrors.IsConflict(err) && !r.ForceConflicts {
+			return nil, fmt.Errorf("unable to repair release %q, as fields of its resources are managed by %s; use --force-conflicts to revert them: %w",
+				rel.Name, driftManagers(changed), err)
+		}
+		return nil, fmt.Errorf("unable to repair release %q: %w", rel.Name, err)
+	}
+	r.cfg.emitResult(result)
+
+	waiter, err := cfg.getWaiter(r.WaitStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get waiter: %w", err)
+	}
+	if r.WaitForJobs {
+		err = waiter.WaitWithJobs(resources, r.Timeout)
+	} else {
+		err = waiter.Wait(resources, r.Timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("release %s failed: %w", rel.Name, err)
+	}
+
+	// A failed or interrupted revision matches its manifest again, so it
+	// becomes the deployed revision.
+	if rel.Info.Status != common.StatusDeployed {
+		if err := r.supersedeDeployed(rel); err != nil {
+			return nil, err
+		}
+		rel.Info.Status = common.StatusDeployed
+	}
+	rel.Info.Description = repairDescription(rel.Info.Description)
+	if err := r.cfg.Releases.Update(rel); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// supersedeDeployed marks the deployed revisions other than rel as superseded.
+func (r *Repair) supersedeDeployed(rel *release.Release) error {
+	deployed, err := r.cfg.Releases.DeployedAll(rel.Name)
+	if err != nil && !errors.Is(err, driver.ErrNoDeployedReleases) {
+		return err
+	}
+	for _, reli := range deployed {
+		d, err := releaserToV1Release(reli)
+		if err != nil {
+			return err
+		}
+		if d.Version == rel.Version {
+			continue
+		}
+		slog.Debug("superseding previous deployment", "version", d.Version)
+		d.Info.Status = common.StatusSuperseded
+		r.cfg.recordRelease(d)
+	}
+	return nil
+}
+
+// driftManagers describes the field managers owning the drifted fields.
+func driftManagers(drifts []*ResourceDrift) string {
+	var managers []string
+	for _, d := range drifts {
+		for _, f := range d.Fields {
+			managers = append(managers, f.Managers...)
+		}
+	}
+	if len(managers) == 0 {
+		return "another field manager"
+	}
+	slices.Sort(managers)
+	return strings.Join(slices.Compact(managers), ", ")
+}
+
+// repairDescription appends the repair note to a release description, once.
+func repairDescription(description string) string {
+	switch {
+	case description == "":
+		return "Release " + repairNote
+	case strings.HasSuffix(description, "("+repairNote+")"):
+		return description
+	}
+	return description + " (" + repairNote + ")"
+}
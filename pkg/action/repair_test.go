@@ -0,0 +1,155 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"helm.sh/helm/v4/pkg/kube"
+	kubefake "helm.sh/helm/v4/pkg/kube/fake"
+	"helm.sh/helm/v4/pkg/release/common"
+)
+
+func repairConfigFixture(t *testing.T) *Configuration {
+	t.Helper()
+	expected := driftDeployment(2, "nginx:1.25", "0.5")
+	cfg := actionConfigFixtureWithDummyResources(t, driftResources(expected, driftLiveDeployment()))
+
+	deployed := namedReleaseStub("drift", common.StatusDeployed)
+	deployed.Namespace = "spaced"
+	failed := namedReleaseStub("drift", common.StatusFailed)
+	failed.Namespace = "spaced"
+	failed.Version = 2
+	failed.Info.Description = "Upgrade \"drift\" failed: timed out"
+	require.NoError(t, cfg.Releases.Create(deployed))
+	require.NoError(t, cfg.Releases.Create(failed))
+	return cfg
+}
+
+func TestRepair(t *testing.T) {
+	cfg := repairConfigFixture(t)
+
+	changed, err := NewRepair(cfg).Run("drift")
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.Equal(t, DriftModified, changed[0].Status)
+
+	history, err := cfg.Releases.History("drift")
+	require.NoError(t, err)
+	assert.Len(t, history, 2, "repair must not create a revision")
+
+	reli, err := cfg.Releases.Get("drift", 2)
+	require.NoError(t, err)
+	rel, err := releaserToV1Release(reli)
+	require.NoError(t, err)
+	assert.Equal(t, common.StatusDeployed, rel.Info.Status)
+	assert.Equal(t, "Upgrade \"drift\" failed: timed out (repaired)", rel.Info.Description)
+
+	reli, err = cfg.Releases.Get("drift", 1)
+	require.NoError(t, err)
+	rel, err = releaserToV1Release(reli)
+	require.NoError(t, err)
+	assert.Equal(t, common.StatusSuperseded, rel.Info.Status)
+}
+
+func TestRepairServiceAccount(t *testing.T) {
+	cfg := repairConfigFixture(t)
+	kubeClient := &serviceAccountKubeClient{FailingKubeClient: cfg.KubeClient.(*kubefake.FailingKubeClient)}
+	cfg.KubeClient = kubeClient
+	reli, err := cfg.Releases.Get("drift", 2)
+	require.NoError(t, err)
+	rel, err := releaserToV1Release(reli)
+	require.NoError(t, err)
+	rel.ServiceAccount = "apps/deployer"
+	require.NoError(t, cfg.Releases.Update(rel))
+
+	_, err = NewRepair(cfg).Run("drift")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"apps/deployer"}, kubeClient.serviceAccounts)
+	assert.Same(t, kubeClient, cfg.KubeClient)
+}
+
+// conflictingKubeClient fails server-side applies with a conflict, as fields
+// of the resources are managed by another field manager.
+type conflictingKubeClient struct {
+	*kubefake.FailingKubeClient
+}
+
+func (c *conflictingKubeClient) Update(_, target kube.ResourceList, _ ...kube.ClientUpdateOption) (*kube.Result, error) {
+	conflict := apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, target[0].Name,
+		fmt.Errorf(`Apply failed with 1 conflict: conflict with "kubectl-set" using apps/v1: .spec.template.spec.containers[name="web"].image`))
+	return nil, fmt.Errorf("conflict occurred while applying object %s/%s: %w", target[0].Namespace, target[0].Name, conflict)
+}
+
+func TestRepairConflictingManager(t *testing.T) {
+	cfg := repairConfigFixture(t)
+	cfg.KubeClient = &conflictingKubeClient{FailingKubeClient: cfg.KubeClient.(*kubefake.FailingKubeClient)}
+
+	_, err := NewRepair(cfg).Run("drift")
+	require.Error(t, err)
+	assert.True(t, apierrors.IsConflict(err))
+	assert.Contains(t, err.Error(), "managed by hpa (Update), kubectl-set (Update); use --force-conflicts to revert them")
+
+	// The release is left unchanged
+	reli, err := cfg.Releases.Get("drift", 2)
+	require.NoError(t, err)
+	rel, err := releaserToV1Release(reli)
+	require.NoError(t, err)
+	assert.Equal(t, common.StatusFailed, rel.Info.Status)
+}
+
+func TestRepairDryRun(t *testing.T) {
+	cfg := repairConfigFixture(t)
+
+	client := NewRepair(cfg)
+	client.DryRun = true
+	changed, err := client.Run("drift")
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.Equal(t, "apps/v1/Deployment/spaced/web", changed[0].Key)
+
+	reli, err := cfg.Releases.Get("drift", 2)
+	require.NoError(t, err)
+	rel, err := releaserToV1Release(reli)
+	require.NoError(t, err)
+	assert.Equal(t, common.StatusFailed, rel.Info.Status)
+	assert.Equal(t, "Upgrade \"drift\" failed: timed out", rel.Info.Description)
+}
+
+func TestRepairRejectsUninstalledAndPending(t *testing.T) {
+	for _, status := range []common.Status{common.StatusUninstalled, common.StatusPendingUpgrade} {
+		t.Run(status.String(), func(t *testing.T) {
+			cfg := actionConfigFixture(t)
+			require.NoError(t, cfg.Releases.Create(namedReleaseStub("broken", status)))
+
+			_, err := NewRepair(cfg).Run("broken")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRepairDescription(t *testing.T) {
+	assert.Equal(t, "Release repaired", repairDescription(""))
+	assert.Equal(t, "Upgrade complete (repaired)", repairDescription("Upgrade complete"))
+	assert.Equal(t, "Upgrade complete (repaired)", repairDescription("Upgrade complete (repaired)"))
+}
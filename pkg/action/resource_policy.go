@@ -17,8 +17,12 @@ limitations under the License.
 package action
 
 import (
+	"fmt"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/resource"
+
 	"helm.sh/helm/v4/pkg/kube"
 	releaseutil "helm.sh/helm/v4/pkg/release/v1/util"
 )
@@ -44,3 +48,35 @@ func filterManifestsToKeep(manifests []releaseutil.Manifest) (keep, remaining []
 	}
 	return keep, remaining
 }
+
+// recreatableResources returns the resources which are deleted and created
+// again if an immutable field changes, which are all of them if all is set.
+func recreatableResources(resources kube.ResourceList, all bool) kube.ResourceList {
+	if all {
+		return resources
+	}
+	return resources.Filter(func(info *resource.Info) bool {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			return false
+		}
+		return accessor.GetAnnotations()[kube.ResourcePolicyAnno] == kube.RecreateOnImmutablePolicy
+	})
+}
+
+// describeRecreated appends the resources of a result which were recreated,
+// since an immutable field changed, to the description of an operation.
+func describeRecreated(description string, result *kube.Result) string {
+	if result == nil || len(result.Recreated) == 0 {
+		return description
+	}
+	names := make([]string, len(result.Recreated))
+	for i, info := range result.Recreated {
+		name := info.Name
+		if info.Namespace != "" {
+			name = info.Namespace + "/" + name
+		}
+		names[i] = fmt.Sprintf("%s %s", info.Mapping.GroupVersionKind.Kind, name)
+	}
+	return fmt.Sprintf("%s; recreated %s since immutable fields changed", description, strings.Join(names, ", "))
+}
@@ -18,6 +18,7 @@ package action
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -58,6 +59,18 @@ type Rollback struct {
 	ServerSideApply string
 	CleanupOnFail   bool
 	MaxHistory      int // MaxHistory limits the maximum number of revisions saved per release
+	// ServiceAccount, given as "namespace/name", is impersonated to operate on
+	// the resources of the release. Defaults to the service account of the
+	// release.
+	ServiceAccount string
+	// Preflight reviews the permissions required for the rollback before any
+	// change is made, and fails with a *PreflightError listing the missing
+	// ones.
+	Preflight bool
+	// RecreateOnImmutable deletes and creates again the resources whose
+	// update fails because an immutable field changed, as the
+	// recreate-on-immutable resource policy does for a single resource.
+	RecreateOnImmutable bool
 }
 
 // NewRollback creates a new Rollback object with the given configuration.
@@ -76,12 +89,36 @@ func (r *Rollback) Run(name string) error {
 
 	r.cfg.Releases.MaxHistory = r.MaxHistory
 
+	if !isDryRun(r.DryRunStrategy) {
+		unlock, err := r.cfg.lockRelease(name, "rollback")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
 	slog.Debug("preparing rollback", "name", name)
 	currentRelease, targetRelease, serverSideApply, err := r.prepareRollback(name)
 	if err != nil {
 		return err
 	}
 
+	cfg, err := r.cfg.forServiceAccount(targetRelease.ServiceAccount)
+	if err != nil {
+		return err
+	}
+	// The configuration of the service account is only used for this operation.
+	if cfg != r.cfg {
+		defer func(base *Configuration) { r.cfg = base }(r.cfg)
+		r.cfg = cfg
+	}
+
+	if r.Preflight {
+		if err := r.preflight(currentRelease, targetRelease, serverSideApply); err != nil {
+			return err
+		}
+	}
+
 	if !isDryRun(r.DryRunStrategy) {
 		slog.Debug("creating rolled back release", "name", name)
 		if err := r.cfg.Releases.Create(targetRelease); err != nil {
@@ -91,11 +128,17 @@ func (r *Rollback) Run(name string) error {
 
 	slog.Debug("performing rollback", "name", name)
 	if _, err := r.performRollback(currentRelease, targetRelease, serverSideApply); err != nil {
+		if !isDryRun(r.DryRunStrategy) && !r.DisableHooks {
+			r.cfg.execFailureHook(targetRelease, release.HookPostRollbackFailure, r.WaitStrategy, r.Timeout, serverSideApply)
+		}
 		return err
 	}
 
 	if !isDryRun(r.DryRunStrategy) {
 		slog.Debug("updating status for rolled back release", "name", name)
+		if err := r.cfg.checkReleaseLock(name); err != nil {
+			return err
+		}
 		if err := r.cfg.Releases.Update(targetRelease); err != nil {
 			return err
 		}
@@ -182,16 +225,55 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 			// message here, and only override it later if we experience failure.
 			Description: fmt.Sprintf("Rollback to %d", previousVersion),
 		},
-		Version:     currentRelease.Version + 1,
-		Labels:      previousRelease.Labels,
-		Manifest:    previousRelease.Manifest,
-		Hooks:       previousRelease.Hooks,
-		ApplyMethod: string(determineReleaseSSApplyMethod(serverSideApply)),
+		Version:        currentRelease.Version + 1,
+		Labels:         previousRelease.Labels,
+		Manifest:       previousRelease.Manifest,
+		Hooks:          previousRelease.Hooks,
+		ApplyMethod:    string(determineReleaseSSApplyMethod(serverSideApply)),
+		ServiceAccount: cmp.Or(r.ServiceAccount, currentRelease.ServiceAccount),
+	}
+	// Once a release uses an ApplySet, it keeps using it to prune resources.
+	if currentRelease.ApplySetID != "" || previousRelease.ApplySetID != "" {
+		targetRelease.ApplySetID = applySetID(targetRelease)
 	}
 
 	return currentRelease, targetRelease, serverSideApply, nil
 }
 
+// preflight reviews the permissions required to roll the release back from
+// the current release to the target release.
+func (r *Rollback) preflight(currentRelease, targetRelease *release.Release, serverSideApply bool) error {
+	current, err := r.cfg.KubeClient.Build(bytes.NewBufferString(currentRelease.Manifest), false)
+	if err != nil {
+		return fmt.Errorf("unable to build kubernetes objects from current release manifest: %w", err)
+	}
+	target, err := r.cfg.KubeClient.Build(bytes.NewBufferString(targetRelease.Manifest), false)
+	if err != nil {
+		return fmt.Errorf("unable to build kubernetes objects from new release manifest: %w", err)
+	}
+
+	p := &permissions{}
+	verbs := []string{"get", "create", "patch"}
+	if r.ForceReplace {
+		verbs = append(verbs, "update")
+	}
+	p.add(target, verbs...)
+	p.add(current.Difference(target), "get", "delete")
+	p.add(recreatableResources(target, r.RecreateOnImmutable), "delete")
+	p.addWait(target, r.WaitStrategy)
+	p.addApplySet(releaseApplySet(targetRelease), target)
+	if r.CleanupOnFail {
+		p.add(target, "delete")
+	}
+	if !r.DisableHooks {
+		if err := p.addHooks(r.cfg, targetRelease, serverSideApply, release.HookPreRollback, release.HookPostRollback, release.HookPostRollbackFailure); err != nil {
+			return err
+		}
+	}
+	p.addStorage(r.cfg, targetRelease.Namespace)
+	return r.cfg.preflight(p)
+}
+
 func (r *Rollback) performRollback(currentRelease, targetRelease *release.Release, serverSideApply bool) (*release.Release, error) {
 	if isDryRun(r.DryRunStrategy) {
 		slog.Debug("dry run", "name", targetRelease.Name)
@@ -222,13 +304,18 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 	if err != nil {
 		return targetRelease, fmt.Errorf("unable to set metadata visitor from target release: %w", err)
 	}
+	if err := r.cfg.checkReleaseLock(targetRelease.Name); err != nil {
+		return targetRelease, err
+	}
 	results, err := r.cfg.KubeClient.Update(
 		current,
 		target,
 		kube.ClientUpdateOptionForceReplace(r.ForceReplace),
 		kube.ClientUpdateOptionServerSideApply(serverSideApply, r.ForceConflicts),
 		kube.ClientUpdateOptionThreeWayMergeForUnstructured(false),
-		kube.ClientUpdateOptionUpgradeClientSideFieldManager(true))
+		kube.ClientUpdateOptionUpgradeClientSideFieldManager(true),
+		kube.ClientUpdateOptionApplySet(releaseApplySet(targetRelease), true),
+		kube.ClientUpdateOptionRecreateOnImmutable(r.RecreateOnImmutable))
 
 	if err != nil {
 		msg := fmt.Sprintf("Rollback %q failed: %s", targetRelease.Name, err)
@@ -250,8 +337,11 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		}
 		return targetRelease, err
 	}
+	r.cfg.emitResult(results)
+	recordWarnings(targetRelease, results)
+	targetRelease.Info.Description = describeRecreated(targetRelease.Info.Description, results)
 
-	waiter, err := r.cfg.KubeClient.GetWaiter(r.WaitStrategy)
+	waiter, err := r.cfg.getWaiter(r.WaitStrategy)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get waiter: %w", err)
 	}
@@ -0,0 +1,79 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	kubefake "helm.sh/helm/v4/pkg/kube/fake"
+	"helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	"helm.sh/helm/v4/pkg/storage"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+func TestRollbackRelease_FailureHooks(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	// Secrets store a copy of the release, so only what is recorded is seen.
+	config.Releases = storage.Init(driver.NewSecrets(fake.NewClientset().CoreV1().Secrets("default")))
+	previous := namedReleaseStub("come-fail-away", common.StatusSuperseded)
+	previous.ApplyMethod = string(release.ApplyMethodClientSideApply)
+	previous.Hooks = append(previous.Hooks, &release.Hook{
+		Name:     "on-failure",
+		Kind:     "ConfigMap",
+		Path:     "on-failure",
+		Manifest: manifestWithFailureHooks,
+		Events:   []release.HookEvent{release.HookPostRollbackFailure},
+	})
+	req.NoError(config.Releases.Create(previous))
+	current := namedReleaseStub("come-fail-away", common.StatusDeployed)
+	current.Version = 2
+	current.ApplyMethod = string(release.ApplyMethodClientSideApply)
+	req.NoError(config.Releases.Create(current))
+
+	failer := config.KubeClient.(*kubefake.FailingKubeClient)
+	failer.UpdateError = errors.New("update failed")
+
+	rollAction := NewRollback(config)
+	rollAction.Version = 1
+	rollAction.ServerSideApply = "auto"
+	req.ErrorContains(rollAction.Run(previous.Name), "update failed")
+
+	// The outcome of the failure hooks is stored with the release.
+	storedi, err := config.Releases.Get(previous.Name, 3)
+	req.NoError(err)
+	stored, err := releaserToV1Release(storedi)
+	req.NoError(err)
+	is.Equal(common.StatusFailed, stored.Info.Status)
+	var hook *release.Hook
+	for _, h := range stored.Hooks {
+		if h.Name == "on-failure" {
+			hook = h
+		}
+	}
+	req.NotNil(hook)
+	is.Equal(release.HookPhaseSucceeded, hook.LastRun.Phase)
+	is.Equal(1, hook.LastRun.Attempts)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"log/slog"
+
+	"helm.sh/helm/v4/pkg/kube"
+)
+
+// forServiceAccount returns a configuration whose Kubernetes client operates
+// on resources as the service account. Releases are still stored with the
+// credentials of the user.
+//
+// The returned configuration is a copy, so cfg is left unchanged for other
+// operations, and release locks are still held through cfg. Without a service
+// account the configuration cfg was derived from is returned.
+func (cfg *Configuration) forServiceAccount(serviceAccount string) (*Configuration, error) {
+	if cfg.parent != nil {
+		return cfg.parent.forServiceAccount(serviceAccount)
+	}
+	if serviceAccount == "" {
+		return cfg, nil
+	}
+	c, ok := cfg.KubeClient.(kube.InterfaceServiceAccount)
+	if !ok {
+		return nil, fmt.Errorf("the Kubernetes client does not support operating as service account %s", serviceAccount)
+	}
+	kc, err := c.AsServiceAccount(serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("operating on resources as service account", "serviceAccount", serviceAccount)
+	return &Configuration{
+		RESTClientGetter:    cfg.RESTClientGetter,
+		Releases:            cfg.Releases,
+		Locker:              cfg.Locker,
+		KubeClient:          kc,
+		RegistryClient:      cfg.RegistryClient,
+		Capabilities:        cfg.Capabilities,
+		CustomTemplateFuncs: cfg.CustomTemplateFuncs,
+		HookOutputFunc:      cfg.HookOutputFunc,
+		HookParallelism:     cfg.HookParallelism,
+		EventSink:           cfg.EventSink,
+		PluginsDirectories:  cfg.PluginsDirectories,
+		parent:              cfg,
+	}, nil
+}
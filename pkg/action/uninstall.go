@@ -17,6 +17,7 @@ limitations under the License.
 package action
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -48,6 +49,14 @@ type Uninstall struct {
 	DeletionPropagation string
 	Timeout             time.Duration
 	Description         string
+	// ServiceAccount, given as "namespace/name", is impersonated to operate on
+	// the resources of the release. Defaults to the service account of the
+	// release.
+	ServiceAccount string
+	// Preflight reviews the permissions required for the uninstallation before any
+	// change is made, and fails with a *PreflightError listing the missing
+	// ones.
+	Preflight bool
 }
 
 // NewUninstall creates a new Uninstall object with the given configuration.
@@ -63,11 +72,6 @@ func (u *Uninstall) Run(name string) (*releasei.UninstallReleaseResponse, error)
 		return nil, err
 	}
 
-	waiter, err := u.cfg.KubeClient.GetWaiter(u.WaitStrategy)
-	if err != nil {
-		return nil, err
-	}
-
 	if u.DryRun {
 		ri, err := u.cfg.releaseContent(name, 0)
 
@@ -88,6 +92,12 @@ func (u *Uninstall) Run(name string) (*releasei.UninstallReleaseResponse, error)
 		return nil, fmt.Errorf("uninstall: Release name is invalid: %s", name)
 	}
 
+	unlock, err := u.cfg.lockRelease(name, "uninstall")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	relsi, err := u.cfg.Releases.History(name)
 	if err != nil {
 		if u.IgnoreNotFound {
@@ -119,6 +129,27 @@ func (u *Uninstall) Run(name string) (*releasei.UninstallReleaseResponse, error)
 		return nil, fmt.Errorf("the release named %q is already deleted", name)
 	}
 
+	cfg, err := u.cfg.forServiceAccount(cmp.Or(u.ServiceAccount, rel.ServiceAccount))
+	if err != nil {
+		return nil, err
+	}
+	// The configuration of the service account is only used for this operation.
+	if cfg != u.cfg {
+		defer func(base *Configuration) { u.cfg = base }(u.cfg)
+		u.cfg = cfg
+	}
+
+	waiter, err := u.cfg.getWaiter(u.WaitStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Preflight {
+		if err := u.preflight(rel); err != nil {
+			return nil, err
+		}
+	}
+
 	slog.Debug("uninstall: deleting release", "name", name)
 	rel.Info.Status = common.StatusUninstalling
 	rel.Info.Deleted = time.Now()
@@ -140,6 +171,9 @@ func (u *Uninstall) Run(name string) (*releasei.UninstallReleaseResponse, error)
 		slog.Debug("uninstall: Failed to store updated release", slog.Any("error", err))
 	}
 
+	if err := u.cfg.checkReleaseLock(name); err != nil {
+		return res, err
+	}
 	deletedResources, kept, errs := u.deleteRelease(rel)
 	if errs != nil {
 		slog.Debug("uninstall: Failed to delete release", slog.Any("error", errs))
@@ -162,6 +196,10 @@ func (u *Uninstall) Run(name string) (*releasei.UninstallReleaseResponse, error)
 		}
 	}
 
+	if err := u.cfg.checkReleaseLock(name); err != nil {
+		return res, err
+	}
+
 	rel.Info.Status = common.StatusUninstalled
 	if len(u.Description) > 0 {
 		rel.Info.Description = u.Description
@@ -256,10 +294,79 @@ func (u *Uninstall) deleteRelease(rel *release.Release) (kube.ResourceList, stri
 	if err != nil {
 		return nil, "", []error{fmt.Errorf("unable to build kubernetes objects for delete: %w", err)}
 	}
-	if len(resources) > 0 {
-		_, errs = u.cfg.KubeClient.Delete(resources, parseCascadingFlag(u.DeletionPropagation))
+	waves, err := applyWaves(resources)
+	if err != nil {
+		return nil, "", []error{err}
+	}
+	var waveWaiter kube.Waiter
+	if len(waves) > 1 {
+		if waveWaiter, err = u.cfg.waveWaiter(u.WaitStrategy); err != nil {
+			return nil, "", []error{err}
+		}
+	}
+	// Waves are deleted in reverse order, so resources are deleted before
+	// the resources they depend on. A failing wave does not stop the
+	// remaining waves from being deleted; its errors are collected instead.
+	var deleted kube.ResourceList
+	for n := len(waves) - 1; n >= 0; n-- {
+		res, deleteErrs := u.cfg.KubeClient.Delete(waves[n].resources, parseCascadingFlag(u.DeletionPropagation))
+		if deleteErrs != nil {
+			errs = append(errs, deleteErrs...)
+		}
+		// Without a result the client could not tell which resources of the
+		// wave were deleted, so none of them are reported as deleted.
+		if res == nil {
+			continue
+		}
+		deleted = append(deleted, res.Deleted...)
+		if n > 0 {
+			if err := waveWaiter.WaitForDelete(res.Deleted, u.Timeout); err != nil {
+				errs = append(errs, fmt.Errorf("apply wave %d: %w", waves[n].wave, err))
+			}
+		}
+	}
+	// Members of the ApplySet missing from the manifest are deleted as well.
+	if set := releaseApplySet(rel); set != nil {
+		if c, ok := u.cfg.KubeClient.(kube.InterfaceApplySet); ok {
+			if _, err := c.DeleteApplySet(set); err != nil {
+				errs = append(errs, fmt.Errorf("unable to delete ApplySet %s: %w", set.ID(), err))
+			}
+		}
+	}
+	return deleted, kept, errs
+}
+
+// preflight reviews the permissions required to uninstall the release.
+func (u *Uninstall) preflight(rel *release.Release) error {
+	manifests := releaseutil.SplitManifests(rel.Manifest)
+	_, files, err := releaseutil.SortManifests(manifests, nil, releaseutil.UninstallOrder)
+	if err != nil {
+		return fmt.Errorf("corrupted release record: %w", err)
+	}
+	_, filesToDelete := filterManifestsToKeep(files)
+	var builder strings.Builder
+	for _, file := range filesToDelete {
+		builder.WriteString("\n---\n" + file.Content)
+	}
+	resources, err := u.cfg.KubeClient.Build(strings.NewReader(builder.String()), false)
+	if err != nil {
+		return fmt.Errorf("unable to build kubernetes objects for delete: %w", err)
+	}
+
+	p := &permissions{}
+	p.add(resources, "get", "delete")
+	p.addWait(resources, u.WaitStrategy)
+	if set := releaseApplySet(rel); set != nil {
+		p.add(resources, "list")
+		p.resources = append(p.resources, kube.Permission{Verb: "delete", Resource: "secrets", Namespace: set.Namespace, Name: set.Name})
+	}
+	if !u.DisableHooks {
+		if err := p.addHooks(u.cfg, rel, true, release.HookPreDelete, release.HookPostDelete); err != nil {
+			return err
+		}
 	}
-	return resources, kept, errs
+	p.addStorage(u.cfg, rel.Namespace)
+	return u.cfg.preflight(p)
 }
 
 func parseCascadingFlag(cascadingFlag string) v1.DeletionPropagation {
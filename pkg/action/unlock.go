@@ -0,0 +1,63 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"errors"
+	"fmt"
+
+	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
+	"helm.sh/helm/v4/pkg/storage/driver"
+)
+
+// Unlock is the action for removing the lock of a release left behind by an
+// interrupted operation.
+//
+// It provides the implementation of 'helm release unlock'.
+type Unlock struct {
+	cfg *Configuration
+}
+
+// NewUnlock creates a new Unlock object with the given configuration.
+func NewUnlock(cfg *Configuration) *Unlock {
+	return &Unlock{
+		cfg: cfg,
+	}
+}
+
+// Run removes the lock of the named release regardless of its holder and
+// returns the removed lock.
+func (u *Unlock) Run(name string) (*driver.LockInfo, error) {
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, fmt.Errorf("unlock: Release name is invalid: %s", name)
+	}
+	if u.cfg.Locker == nil {
+		return nil, fmt.Errorf("storage driver %q does not support release locks", u.cfg.Releases.Name())
+	}
+
+	lock, err := u.cfg.Locker.GetLock(name)
+	if err != nil {
+		if errors.Is(err, driver.ErrNotLocked) {
+			return nil, fmt.Errorf("release %q is not locked", name)
+		}
+		return nil, err
+	}
+	if err := u.cfg.Locker.ForceUnlock(name); err != nil && !errors.Is(err, driver.ErrNotLocked) {
+		return nil, err
+	}
+	return lock, nil
+}
@@ -18,6 +18,7 @@ package action
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -68,6 +69,9 @@ type Upgrade struct {
 	Namespace string
 	// SkipCRDs skips installing CRDs when install flag is enabled during upgrade
 	SkipCRDs bool
+	// CRDPolicy determines how the CRDs in the crds/ directories of the chart
+	// are applied. It defaults to CRDPolicySkip.
+	CRDPolicy CRDPolicy
 	// Timeout is the timeout for this operation
 	Timeout time.Duration
 	// WaitStrategy determines what type of waiting should be done
@@ -103,6 +107,11 @@ type Upgrade struct {
 	MaxHistory int
 	// RollbackOnFailure enables rolling back the upgraded release on failure
 	RollbackOnFailure bool
+	// VerifyFor is how long the resources of the release are watched after the
+	// upgrade succeeded, while its test hooks run. If a resource becomes
+	// unready, a container restarts or a test hook fails within that time, the
+	// upgrade fails and is rolled back, even if RollbackOnFailure is not set.
+	VerifyFor time.Duration
 	// CleanupOnFail will, if true, cause the upgrade to delete newly-created resources on a failed update.
 	CleanupOnFail bool
 	// SubNotes determines whether sub-notes are rendered in the chart.
@@ -129,6 +138,26 @@ type Upgrade struct {
 	EnableDNS bool
 	// TakeOwnership will skip the check for helm annotations and adopt all existing resources.
 	TakeOwnership bool
+	// ApplySet labels the resources of the release as members of a KEP-3659
+	// ApplySet, and prunes resources removed from the chart by querying the
+	// cluster for the members of the set. It is always enabled for releases
+	// which already use an ApplySet.
+	ApplySet bool
+	// WarningsAsErrors fails the upgrade if the API server returns warnings
+	// while the resources are applied.
+	WarningsAsErrors bool
+	// ServiceAccount, given as "namespace/name", is impersonated to operate on
+	// the resources of the release. Defaults to the service account of the
+	// release.
+	ServiceAccount string
+	// Preflight reviews the permissions required for the upgrade before any
+	// change is made, and fails with a *PreflightError listing the missing
+	// ones.
+	Preflight bool
+	// RecreateOnImmutable deletes and creates again the resources whose
+	// update fails because an immutable field changed, as the
+	// recreate-on-immutable resource policy does for a single resource.
+	RecreateOnImmutable bool
 }
 
 type resultMessage struct {
@@ -175,9 +204,9 @@ func (u *Upgrade) RunWithContext(ctx context.Context, name string, ch chart.Char
 		return nil, errors.New("invalid chart apiVersion")
 	}
 
-	// Make sure wait is set if RollbackOnFailure. This makes it so
+	// Make sure wait is set if RollbackOnFailure or VerifyFor. This makes it so
 	// the user doesn't have to specify both
-	if u.WaitStrategy == kube.HookOnlyStrategy && u.RollbackOnFailure {
+	if u.WaitStrategy == kube.HookOnlyStrategy && (u.RollbackOnFailure || u.VerifyFor > 0) {
 		u.WaitStrategy = kube.StatusWatcherStrategy
 	}
 
@@ -185,12 +214,30 @@ func (u *Upgrade) RunWithContext(ctx context.Context, name string, ch chart.Char
 		return nil, fmt.Errorf("release name is invalid: %s", name)
 	}
 
+	if !isDryRun(u.DryRunStrategy) {
+		unlock, err := u.cfg.lockRelease(name, "upgrade")
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	slog.Debug("preparing upgrade", "name", name)
 	currentRelease, upgradedRelease, serverSideApply, err := u.prepareUpgrade(name, chrt, vals)
 	if err != nil {
 		return nil, err
 	}
 
+	cfg, err := u.cfg.forServiceAccount(upgradedRelease.ServiceAccount)
+	if err != nil {
+		return nil, err
+	}
+	// The configuration of the service account is only used for this operation.
+	if cfg != u.cfg {
+		defer func(base *Configuration) { u.cfg = base }(u.cfg)
+		u.cfg = cfg
+	}
+
 	u.cfg.Releases.MaxHistory = u.MaxHistory
 
 	slog.Debug("performing update", "name", name)
@@ -202,6 +249,9 @@ func (u *Upgrade) RunWithContext(ctx context.Context, name string, ch chart.Char
 	// Do not update for dry runs
 	if !isDryRun(u.DryRunStrategy) {
 		slog.Debug("updating status for upgraded release", "name", name)
+		if err := u.cfg.checkReleaseLock(name); err != nil {
+			return res, err
+		}
 		if err := u.cfg.Releases.Update(upgradedRelease); err != nil {
 			return res, err
 		}
@@ -236,9 +286,15 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chartv2.Chart, vals map[str
 		return nil, nil, false, err
 	}
 
-	// Concurrent `helm upgrade`s will either fail here with `errPending` or when creating the release with "already exists". This should act as a pessimistic lock.
+	// Without a release lock, concurrent `helm upgrade`s will either fail here with `errPending` or when creating the release with "already exists".
+	// With the lock held, a pending release was left behind by an interrupted operation and is marked as failed.
 	if lastRelease.Info.Status.IsPending() {
-		return nil, nil, false, errPending
+		if !u.cfg.holdsReleaseLock(name) {
+			return nil, nil, false, errPending
+		}
+		if err := u.cfg.recoverPendingRelease(lastRelease); err != nil {
+			return nil, nil, false, err
+		}
 	}
 
 	var currentRelease *release.Release
@@ -274,6 +330,12 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chartv2.Chart, vals map[str
 		return nil, nil, false, err
 	}
 
+	// Apply anything in the crds/ directory before the capabilities are
+	// gathered, as on install.
+	if err := u.applyCRDs(chart, cmp.Or(u.ServiceAccount, lastRelease.ServiceAccount)); err != nil {
+		return nil, nil, false, err
+	}
+
 	// Increment revision count. This is passed to templates, and also stored on
 	// the release object.
 	revision := lastRelease.Version + 1
@@ -322,11 +384,15 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chartv2.Chart, vals map[str
 			Status:        rcommon.StatusPendingUpgrade,
 			Description:   "Preparing upgrade", // This should be overwritten later.
 		},
-		Version:     revision,
-		Manifest:    manifestDoc.String(),
-		Hooks:       hooks,
-		Labels:      mergeCustomLabels(lastRelease.Labels, u.Labels),
-		ApplyMethod: string(determineReleaseSSApplyMethod(serverSideApply)),
+		Version:        revision,
+		Manifest:       manifestDoc.String(),
+		Hooks:          hooks,
+		Labels:         mergeCustomLabels(lastRelease.Labels, u.Labels),
+		ApplyMethod:    string(determineReleaseSSApplyMethod(serverSideApply)),
+		ServiceAccount: cmp.Or(u.ServiceAccount, lastRelease.ServiceAccount),
+	}
+	if u.ApplySet || lastRelease.ApplySetID != "" {
+		upgradedRelease.ApplySetID = applySetID(upgradedRelease)
 	}
 
 	if len(notesTxt) > 0 {
@@ -358,6 +424,7 @@ func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedR
 	if err != nil {
 		return upgradedRelease, err
 	}
+	u.cfg.emitRendered(upgradedRelease, target)
 
 	// Do a basic diff using gvk + name to figure out what new resources are being created so we can validate they don't already exist
 	existingResources := make(map[string]bool)
@@ -390,6 +457,12 @@ func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedR
 		return nil
 	})
 
+	if u.Preflight {
+		if err := u.preflight(upgradedRelease, current, target, serverSideApply); err != nil {
+			return upgradedRelease, err
+		}
+	}
+
 	if isDryRun(u.DryRunStrategy) {
 		slog.Debug("dry run for release", "name", upgradedRelease.Name)
 		if len(u.Description) > 0 {
@@ -460,20 +533,74 @@ func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *rele
 		slog.Debug("upgrade hooks disabled", "name", upgradedRelease.Name)
 	}
 
-	upgradeClientSideFieldManager := isReleaseApplyMethodClientSideApply(originalRelease.ApplyMethod) && serverSideApply // Update client-side field manager if transitioning from client-side to server-side apply
-	results, err := u.cfg.KubeClient.Update(
-		current,
-		target,
-		kube.ClientUpdateOptionForceReplace(u.ForceReplace),
-		kube.ClientUpdateOptionServerSideApply(serverSideApply, u.ForceConflicts),
-		kube.ClientUpdateOptionUpgradeClientSideFieldManager(upgradeClientSideFieldManager))
+	waves, err := applyWaves(target)
 	if err != nil {
 		u.cfg.recordRelease(originalRelease)
-		u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
+		u.reportToPerformUpgrade(c, upgradedRelease, kube.ResourceList{}, err)
 		return
 	}
+	if len(waves) == 0 {
+		// Resources removed from the chart still need to be deleted.
+		waves = []applyWave{{}}
+	}
+	var waveWaiter kube.Waiter
+	if len(waves) > 1 {
+		if waveWaiter, err = u.cfg.waveWaiter(u.WaitStrategy); err != nil {
+			u.cfg.recordRelease(originalRelease)
+			u.reportToPerformUpgrade(c, upgradedRelease, kube.ResourceList{}, err)
+			return
+		}
+	}
+
+	upgradeClientSideFieldManager := isReleaseApplyMethodClientSideApply(originalRelease.ApplyMethod) && serverSideApply // Update client-side field manager if transitioning from client-side to server-side apply
+	results := &kube.Result{}
+	var applied kube.ResourceList
+	set := releaseApplySet(upgradedRelease)
+	for n, wave := range waves {
+		originals := current.Intersect(wave.resources)
+		if n == len(waves)-1 {
+			// Resources removed from the chart are deleted with the last wave.
+			originals = current.Difference(applied)
+		}
+		if err := u.cfg.checkReleaseLock(upgradedRelease.Name); err != nil {
+			u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
+			return
+		}
+		waveResults, err := u.cfg.KubeClient.Update(
+			originals,
+			wave.resources,
+			kube.ClientUpdateOptionForceReplace(u.ForceReplace),
+			kube.ClientUpdateOptionServerSideApply(serverSideApply, u.ForceConflicts),
+			kube.ClientUpdateOptionUpgradeClientSideFieldManager(upgradeClientSideFieldManager),
+			kube.ClientUpdateOptionApplySet(set, n == len(waves)-1),
+			kube.ClientUpdateOptionRecreateOnImmutable(u.RecreateOnImmutable))
+		results = mergeResults(results, waveResults)
+		if err != nil {
+			u.cfg.recordRelease(originalRelease)
+			u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
+			return
+		}
+		u.cfg.emitResult(waveResults)
+		recordWarnings(upgradedRelease, waveResults)
+		if u.WarningsAsErrors {
+			if err := warningsAsError(upgradedRelease); err != nil {
+				u.cfg.recordRelease(originalRelease)
+				u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
+				return
+			}
+		}
+
+		if n < len(waves)-1 {
+			if err := waitForWave(waveWaiter, wave, u.WaitForJobs, u.Timeout); err != nil {
+				u.cfg.recordRelease(originalRelease)
+				u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
+				return
+			}
+		}
+		applied = append(applied, wave.resources...)
+	}
 
-	waiter, err := u.cfg.KubeClient.GetWaiter(u.WaitStrategy)
+	waiter, err := u.cfg.getWaiter(u.WaitStrategy)
 	if err != nil {
 		u.cfg.recordRelease(originalRelease)
 		u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
@@ -501,6 +628,13 @@ func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *rele
 		}
 	}
 
+	if u.VerifyFor > 0 {
+		if err := u.verify(upgradedRelease, target, serverSideApply); err != nil {
+			u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
+			return
+		}
+	}
+
 	originalRelease.Info.Status = rcommon.StatusSuperseded
 	u.cfg.recordRelease(originalRelease)
 
@@ -510,9 +644,67 @@ func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *rele
 	} else {
 		upgradedRelease.Info.Description = "Upgrade complete"
 	}
+	upgradedRelease.Info.Description = describeRecreated(upgradedRelease.Info.Description, results)
 	u.reportToPerformUpgrade(c, upgradedRelease, nil, nil)
 }
 
+// applyCRDs applies the CRDs of the chart with the CRD policy, operating as the
+// service account of the upgraded release, if any.
+func (u *Upgrade) applyCRDs(chart *chartv2.Chart, serviceAccount string) error {
+	crdPolicy, err := resolveCRDPolicy(u.CRDPolicy, u.SkipCRDs, CRDPolicySkip)
+	if err != nil {
+		return err
+	}
+	crds := chart.CRDObjects()
+	if !interactWithServer(u.DryRunStrategy) || crdPolicy == CRDPolicySkip || len(crds) == 0 {
+		return nil
+	}
+
+	cfg, err := u.cfg.forServiceAccount(serviceAccount)
+	if err != nil {
+		return err
+	}
+
+	if u.Preflight {
+		p := &permissions{}
+		p.addCRDs(crdPolicy)
+		if err := cfg.preflight(p); err != nil {
+			return err
+		}
+	}
+	// On dry run, bail here
+	if isDryRun(u.DryRunStrategy) {
+		slog.Warn("This chart or one of its subcharts contains CRDs. Rendering may fail or contain inaccuracies.")
+		return nil
+	}
+	return cfg.installCRDs(crds, crdPolicy, true, u.ForceConflicts, u.WaitStrategy)
+}
+
+// preflight reviews the permissions required to upgrade the release from the
+// current resources to the target resources.
+func (u *Upgrade) preflight(rel *release.Release, current, target kube.ResourceList, serverSideApply bool) error {
+	p := &permissions{}
+	verbs := []string{"get", "create", "patch"}
+	if u.ForceReplace {
+		verbs = append(verbs, "update")
+	}
+	p.add(target, verbs...)
+	p.add(current.Difference(target), "get", "delete")
+	p.add(recreatableResources(target, u.RecreateOnImmutable), "delete")
+	p.addWait(target, u.WaitStrategy)
+	p.addApplySet(releaseApplySet(rel), target)
+	if u.CleanupOnFail {
+		p.add(target, "delete")
+	}
+	if !u.DisableHooks {
+		if err := p.addHooks(u.cfg, rel, serverSideApply, release.HookPreUpgrade, release.HookPostUpgrade, release.HookPostUpgradeFailure); err != nil {
+			return err
+		}
+	}
+	p.addStorage(u.cfg, rel.Namespace)
+	return u.cfg.preflight(p)
+}
+
 func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, err error) (*release.Release, error) {
 	msg := fmt.Sprintf("Upgrade %q failed: %s", rel.Name, err)
 	slog.Warn("upgrade failed", "name", rel.Name, slog.Any("error", err))
@@ -520,6 +712,10 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 	rel.Info.Status = rcommon.StatusFailed
 	rel.Info.Description = msg
 	u.cfg.recordRelease(rel)
+	if !u.DisableHooks {
+		serverSideApply := rel.ApplyMethod == string(release.ApplyMethodServerSideApply)
+		u.cfg.execFailureHook(rel, release.HookPostUpgradeFailure, u.WaitStrategy, u.Timeout, serverSideApply)
+	}
 	if u.CleanupOnFail && len(created) > 0 {
 		slog.Debug("cleanup on fail set", "cleaning_resources", len(created))
 		_, errs := u.cfg.KubeClient.Delete(created, metav1.DeletePropagationBackground)
@@ -536,8 +732,9 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 		slog.Debug("resource cleanup complete")
 	}
 
-	if u.RollbackOnFailure {
-		slog.Debug("Upgrade failed and rollback-on-failure is set, rolling back to previous successful release")
+	var verr *VerificationError
+	if u.RollbackOnFailure || errors.As(err, &verr) {
+		slog.Debug("Upgrade failed and rollback-on-failure is set or verification failed, rolling back to previous successful release")
 
 		// As a protection, get the last successful release before rollback.
 		// If there are no successful releases, bail out
@@ -571,10 +768,14 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 		rollin.ForceReplace = u.ForceReplace
 		rollin.ForceConflicts = u.ForceConflicts
 		rollin.ServerSideApply = u.ServerSideApply
+		rollin.RecreateOnImmutable = u.RecreateOnImmutable
 		rollin.Timeout = u.Timeout
 		if rollErr := rollin.Run(rel.Name); rollErr != nil {
 			return rel, fmt.Errorf("an error occurred while rolling back the release. original upgrade error: %w: %w", err, rollErr)
 		}
+		if verr != nil && !u.RollbackOnFailure {
+			return rel, fmt.Errorf("release %s failed, and has been rolled back: %w", rel.Name, err)
+		}
 		return rel, fmt.Errorf("release %s failed, and has been rolled back due to rollback-on-failure being set: %w", rel.Name, err)
 	}
 
@@ -25,12 +25,15 @@ import (
 	"testing"
 	"time"
 
+	chartcommon "helm.sh/helm/v4/pkg/chart/common"
 	chart "helm.sh/helm/v4/pkg/chart/v2"
 	"helm.sh/helm/v4/pkg/kube"
+	"helm.sh/helm/v4/pkg/storage"
 	"helm.sh/helm/v4/pkg/storage/driver"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 
 	kubefake "helm.sh/helm/v4/pkg/kube/fake"
 	"helm.sh/helm/v4/pkg/release/common"
@@ -77,6 +80,121 @@ func TestUpgradeRelease_Success(t *testing.T) {
 	is.Equal(lastRelease.Info.Status, common.StatusDeployed)
 }
 
+func TestUpgradeRelease_ApplySet(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "applyset"
+	rel.Info.Status = common.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	// The ApplySet is enabled with the flag.
+	upAction.ApplySet = true
+	resi, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	res, err := releaserToV1Release(resi)
+	req.NoError(err)
+	is.Equal(kube.NewApplySet("sh.helm.applyset.v1.applyset", rel.Namespace).ID(), res.ApplySetID)
+
+	// Once enabled, the ApplySet is kept.
+	upAction.ApplySet = false
+	resi, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	res, err = releaserToV1Release(resi)
+	req.NoError(err)
+	is.Equal(kube.NewApplySet("sh.helm.applyset.v1.applyset", rel.Namespace).ID(), res.ApplySetID)
+}
+
+// serviceAccountKubeClient records the service accounts it is asked to
+// operate as.
+type serviceAccountKubeClient struct {
+	*kubefake.FailingKubeClient
+	serviceAccounts []string
+}
+
+func (c *serviceAccountKubeClient) AsServiceAccount(serviceAccount string) (kube.Interface, error) {
+	c.serviceAccounts = append(c.serviceAccounts, serviceAccount)
+	return c.FailingKubeClient, nil
+}
+
+func TestUpgradeRelease_ServiceAccount(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	kubeClient := &serviceAccountKubeClient{FailingKubeClient: upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)}
+	upAction.cfg.KubeClient = kubeClient
+	cfg := upAction.cfg
+	rel := releaseStub()
+	rel.Name = "impersonated"
+	rel.Info.Status = common.StatusDeployed
+	rel.ServiceAccount = "apps/deployer"
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	// The service account of the release is used by default.
+	resi, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	res, err := releaserToV1Release(resi)
+	req.NoError(err)
+	is.Equal("apps/deployer", res.ServiceAccount)
+
+	upAction.ServiceAccount = "apps/admin"
+	resi, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	res, err = releaserToV1Release(resi)
+	req.NoError(err)
+	is.Equal("apps/admin", res.ServiceAccount)
+
+	is.Equal([]string{"apps/deployer", "apps/admin"}, kubeClient.serviceAccounts)
+	// Neither the shared configuration nor the action's configuration is changed.
+	is.Same(kubeClient, cfg.KubeClient)
+	is.Same(cfg, upAction.cfg)
+}
+
+func TestUpgradeRelease_ServiceAccountUnsupported(t *testing.T) {
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "impersonated"
+	rel.Info.Status = common.StatusDeployed
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	upAction.ServiceAccount = "apps/deployer"
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	assert.EqualError(t, err, "the Kubernetes client does not support operating as service account apps/deployer")
+}
+
+// recreatingKubeClient reports all updated resources as recreated.
+type recreatingKubeClient struct {
+	*kubefake.FailingKubeClient
+}
+
+func (c *recreatingKubeClient) Update(original, target kube.ResourceList, options ...kube.ClientUpdateOption) (*kube.Result, error) {
+	result, err := c.FailingKubeClient.Update(original, target, options...)
+	if result != nil {
+		result.Recreated = target
+	}
+	return result, err
+}
+
+func TestUpgradeRelease_Recreated(t *testing.T) {
+	upAction := NewUpgrade(actionConfigFixtureWithDummyResources(t, createDummyResourceList(true)))
+	upAction.Namespace = "spaced"
+	upAction.cfg.KubeClient = &recreatingKubeClient{FailingKubeClient: upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)}
+	upAction.RecreateOnImmutable = true
+	rel := releaseStub()
+	rel.Name = "recreated"
+	rel.Info.Status = common.StatusDeployed
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	resi, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	require.NoError(t, err)
+	res, err := releaserToV1Release(resi)
+	require.NoError(t, err)
+	assert.Equal(t, "Upgrade complete; recreated Deployment spaced/dummyName since immutable fields changed", res.Info.Description)
+}
+
 func TestUpgradeRelease_Wait(t *testing.T) {
 	is := assert.New(t)
 	req := require.New(t)
@@ -101,6 +219,56 @@ func TestUpgradeRelease_Wait(t *testing.T) {
 	is.Equal(res.Info.Status, common.StatusFailed)
 }
 
+func TestUpgradeRelease_FailureHooks(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "come-fail-away"
+	rel.Info.Status = common.StatusDeployed
+	// Secrets store a copy of the release, so only what is recorded is seen.
+	upAction.cfg.Releases = storage.Init(driver.NewSecrets(k8sfake.NewClientset().CoreV1().Secrets("default")))
+	upAction.cfg.Releases.Create(rel)
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WaitError = fmt.Errorf("I timed out")
+	upAction.WaitStrategy = kube.StatusWatcherStrategy
+	var events []release.HookEvent
+	upAction.cfg.EventSink = func(ev Event) {
+		if hookEv, ok := ev.(HookPhaseChanged); ok && hookEv.Phase == release.HookPhaseSucceeded {
+			events = append(events, hookEv.Event)
+		}
+	}
+
+	chrt := buildChartWithTemplates([]*chartcommon.File{
+		{Name: "templates/hello", Data: []byte("hello: world")},
+		{Name: "templates/hooks", Data: []byte(manifestWithHook)},
+		{Name: "templates/failure-hooks", Data: []byte(manifestWithFailureHooks)},
+	})
+	resi, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	req.EqualError(err, "I timed out")
+	res, err := releaserToV1Release(resi)
+	req.NoError(err)
+	is.Equal(common.StatusFailed, res.Info.Status)
+	is.Equal([]release.HookEvent{release.HookPostUpgradeFailure}, events)
+
+	// The outcome of the failure hooks is stored with the release.
+	storedi, err := upAction.cfg.Releases.Get(res.Name, res.Version)
+	req.NoError(err)
+	stored, err := releaserToV1Release(storedi)
+	req.NoError(err)
+	var hook *release.Hook
+	for _, h := range stored.Hooks {
+		if h.Name == "on-failure" {
+			hook = h
+		}
+	}
+	req.NotNil(hook)
+	is.Equal(release.HookPhaseSucceeded, hook.LastRun.Phase)
+	is.Equal(1, hook.LastRun.Attempts)
+}
+
 func TestUpgradeRelease_WaitForJobs(t *testing.T) {
 	is := assert.New(t)
 	req := require.New(t)
@@ -208,6 +376,89 @@ func TestUpgradeRelease_RollbackOnFailure(t *testing.T) {
 	})
 }
 
+func TestUpgradeRelease_VerifyFor(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	t.Run("verification succeeds", func(t *testing.T) {
+		upAction := upgradeAction(t)
+		rel := releaseStub()
+		rel.Name = "steady"
+		rel.Info.Status = common.StatusDeployed
+		req.NoError(upAction.cfg.Releases.Create(rel))
+		upAction.VerifyFor = time.Millisecond
+
+		resi, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+		req.NoError(err)
+		res, err := releaserToV1Release(resi)
+		req.NoError(err)
+		is.Equal(2, res.Version)
+		is.Equal(common.StatusDeployed, res.Info.Status)
+	})
+
+	t.Run("failed verification rolls back", func(t *testing.T) {
+		upAction := upgradeAction(t)
+		rel := releaseStub()
+		rel.Name = "crashloop"
+		rel.Info.Status = common.StatusDeployed
+		req.NoError(upAction.cfg.Releases.Create(rel))
+
+		failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+		failer.VerifyError = &kube.VerificationError{Kind: "Pod", Namespace: "spaced", Name: "web-1", Reason: "restarted"}
+		upAction.VerifyFor = time.Minute
+
+		resi, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+		req.Error(err)
+		is.Contains(err.Error(), "verification failed: Pod spaced/web-1 restarted")
+		is.Contains(err.Error(), "has been rolled back")
+		var verr *VerificationError
+		is.ErrorAs(err, &verr)
+
+		res, err := releaserToV1Release(resi)
+		req.NoError(err)
+		is.Equal(common.StatusFailed, res.Info.Status)
+
+		rolledBacki, err := upAction.cfg.Releases.Get(rel.Name, 3)
+		req.NoError(err)
+		rolledBack, err := releaserToV1Release(rolledBacki)
+		req.NoError(err)
+		is.Equal(common.StatusDeployed, rolledBack.Info.Status)
+	})
+
+	t.Run("failed test hook rolls back", func(t *testing.T) {
+		upAction := upgradeAction(t)
+		rel := releaseStub()
+		rel.Name = "smoke"
+		rel.Info.Status = common.StatusDeployed
+		req.NoError(upAction.cfg.Releases.Create(rel))
+
+		failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+		failer.WatchUntilReadyError = errors.New("smoke test failed")
+		upAction.VerifyFor = time.Minute
+
+		ch := buildChartWithTemplates([]*chartcommon.File{
+			{Name: "templates/hello", Data: []byte("hello: world")},
+			{Name: "templates/test", Data: []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: smoke-test
+  annotations:
+    "helm.sh/hook": test
+`)},
+		})
+		_, err := upAction.Run(rel.Name, ch, map[string]interface{}{})
+		req.Error(err)
+		is.Contains(err.Error(), "verification failed: test hooks failed")
+		is.Contains(err.Error(), "smoke test failed")
+
+		rolledBacki, err := upAction.cfg.Releases.Get(rel.Name, 3)
+		req.NoError(err)
+		rolledBack, err := releaserToV1Release(rolledBacki)
+		req.NoError(err)
+		is.Equal(common.StatusDeployed, rolledBack.Info.Status)
+	})
+}
+
 func TestUpgradeRelease_ReuseValues(t *testing.T) {
 	is := assert.New(t)
 
@@ -0,0 +1,89 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"helm.sh/helm/v4/pkg/kube"
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// VerificationError is returned by an upgrade whose release failed within the
+// verification window set by Upgrade.VerifyFor.
+type VerificationError struct {
+	Err error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verification failed: %s", e.Err)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// verify watches the resources of an upgraded release for u.VerifyFor while
+// running its test hooks. It returns a *VerificationError if a resource
+// becomes unready, a container restarts or a test hook fails.
+func (u *Upgrade) verify(rel *release.Release, resources kube.ResourceList, serverSideApply bool) error {
+	slog.Debug("verifying upgraded release", "name", rel.Name, "duration", u.VerifyFor)
+
+	// Verification watches the release even if only hooks are waited for.
+	waiter, err := u.cfg.getWaiter(kube.StatusWatcherStrategy)
+	if err != nil {
+		return fmt.Errorf("unable to get waiter: %w", err)
+	}
+	verifier, ok := waiter.(kube.VerifyingWaiter)
+	if !ok {
+		return errors.New("the kubernetes client does not support verifying releases")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), u.VerifyFor)
+	defer cancel()
+
+	errs := make(chan error, 2)
+	running := 1
+	go func() { errs <- verifier.Verify(ctx, resources) }()
+	if !u.DisableHooks {
+		running++
+		go func() {
+			if err := u.cfg.execHook(rel, release.HookTest, kube.StatusWatcherStrategy, u.Timeout, serverSideApply); err != nil {
+				errs <- fmt.Errorf("test hooks failed: %w", err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+
+	// The first failure ends the window, but the test hooks cannot be
+	// interrupted, so they are waited for before the release is changed.
+	var failure error
+	for ; running > 0; running-- {
+		if err := <-errs; err != nil && failure == nil {
+			failure = err
+			cancel()
+		}
+	}
+	if failure != nil {
+		return &VerificationError{Err: failure}
+	}
+	return nil
+}
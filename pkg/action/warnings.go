@@ -0,0 +1,46 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v4/pkg/kube"
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// recordWarnings adds the warnings of the API server in a result to the
+// release.
+func recordWarnings(rel *release.Release, result *kube.Result) {
+	if result == nil {
+		return
+	}
+	for _, w := range result.Warnings {
+		rel.Info.Warnings = append(rel.Info.Warnings, w.String())
+	}
+}
+
+// warningsAsError returns an error listing the warnings of a release, if it
+// has any.
+func warningsAsError(rel *release.Release) error {
+	if len(rel.Info.Warnings) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the API server returned %d warning(s), which are treated as errors:\n%s",
+		len(rel.Info.Warnings), strings.Join(rel.Info.Warnings, "\n"))
+}
@@ -0,0 +1,122 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"helm.sh/helm/v4/pkg/kube"
+)
+
+// ApplyWaveAnnotation orders the resources of a release into waves.
+//
+// The value is an integer, resources without it are in wave 0. Install and
+// upgrade apply the waves in ascending order and wait for the resources of a
+// wave to be ready before applying the next one. Uninstall deletes the waves
+// in descending order, waiting for the resources of a wave to be deleted
+// before deleting the next one.
+const ApplyWaveAnnotation = "helm.sh/apply-wave"
+
+// applyWave is the set of resources of a release with the same
+// ApplyWaveAnnotation.
+type applyWave struct {
+	wave      int
+	resources kube.ResourceList
+}
+
+// applyWaves splits resources into waves by their ApplyWaveAnnotation, in
+// ascending order. Resources keep their order within a wave.
+func applyWaves(resources kube.ResourceList) ([]applyWave, error) {
+	byWave := map[int]kube.ResourceList{}
+	for _, info := range resources {
+		wave, err := waveOf(info.Object)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", resourceString(info), err)
+		}
+		byWave[wave] = append(byWave[wave], info)
+	}
+	waves := make([]applyWave, 0, len(byWave))
+	for _, w := range slices.Sorted(maps.Keys(byWave)) {
+		waves = append(waves, applyWave{wave: w, resources: byWave[w]})
+	}
+	return waves, nil
+}
+
+func waveOf(obj runtime.Object) (int, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return 0, nil
+	}
+	value, ok := accessor.GetAnnotations()[ApplyWaveAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	wave, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %q: must be an integer", ApplyWaveAnnotation, value)
+	}
+	return wave, nil
+}
+
+// waveWaiter returns the waiter for all but the last wave. Later waves depend
+// on the readiness of earlier ones, so earlier waves are waited for even if
+// only hooks are.
+func (cfg *Configuration) waveWaiter(strategy kube.WaitStrategy) (kube.Waiter, error) {
+	if strategy == kube.HookOnlyStrategy {
+		strategy = kube.StatusWatcherStrategy
+	}
+	return cfg.getWaiter(strategy)
+}
+
+// waitForWave waits for the resources of a wave to be ready.
+func waitForWave(waiter kube.Waiter, wave applyWave, withJobs bool, timeout time.Duration) error {
+	var err error
+	if withJobs {
+		err = waiter.WaitWithJobs(wave.resources, timeout)
+	} else {
+		err = waiter.Wait(wave.resources, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("apply wave %d: %w", wave.wave, err)
+	}
+	return nil
+}
+
+// mergeResults appends the resources of b to those of a.
+func mergeResults(a, b *kube.Result) *kube.Result {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &kube.Result{
+		Created:   append(a.Created, b.Created...),
+		Updated:   append(a.Updated, b.Updated...),
+		Deleted:   append(a.Deleted, b.Deleted...),
+		Recreated: append(a.Recreated, b.Recreated...),
+		Warnings:  append(a.Warnings, b.Warnings...),
+	}
+}
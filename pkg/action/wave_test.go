@@ -0,0 +1,281 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+
+	"helm.sh/helm/v4/pkg/kube"
+	kubefake "helm.sh/helm/v4/pkg/kube/fake"
+	"helm.sh/helm/v4/pkg/release/common"
+)
+
+func waveConfigMap(name, wave string) *resource.Info {
+	cm := &v1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "spaced"},
+	}
+	if wave != "" {
+		cm.Annotations = map[string]string{ApplyWaveAnnotation: wave}
+	}
+	return &resource.Info{
+		Name:      name,
+		Namespace: "spaced",
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			Scope:            meta.RESTScopeNamespace,
+		},
+		Object: cm,
+		Client: &fake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+			Client: fake.CreateHTTPClient(func(_ *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+			}),
+		},
+	}
+}
+
+// waveKubeClient records the order of the calls which apply, delete and
+// wait for resources.
+type waveKubeClient struct {
+	kubefake.FailingKubeClient
+	mu    sync.Mutex
+	calls []string
+	// failDelete names a resource whose deletion fails.
+	failDelete string
+}
+
+func (c *waveKubeClient) record(call string, resources kube.ResourceList) {
+	var names []string
+	for _, r := range resources {
+		names = append(names, r.Name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, fmt.Sprintf("%s %s", call, strings.Join(names, ",")))
+}
+
+func (c *waveKubeClient) Create(resources kube.ResourceList, _ ...kube.ClientCreateOption) (*kube.Result, error) {
+	c.record("create", resources)
+	return &kube.Result{Created: resources}, nil
+}
+
+func (c *waveKubeClient) Update(original, target kube.ResourceList, _ ...kube.ClientUpdateOption) (*kube.Result, error) {
+	c.record("update", target)
+	if deleted := original.Difference(target); len(deleted) > 0 {
+		c.record("delete", deleted)
+	}
+	return &kube.Result{Updated: target}, nil
+}
+
+func (c *waveKubeClient) Delete(resources kube.ResourceList, _ metav1.DeletionPropagation) (*kube.Result, []error) {
+	c.record("delete", resources)
+	for _, r := range resources {
+		if r.Name == c.failDelete {
+			return nil, []error{fmt.Errorf("unable to delete %s", r.Name)}
+		}
+	}
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func (c *waveKubeClient) GetWaiter(strategy kube.WaitStrategy) (kube.Waiter, error) {
+	return &waveWaiter{client: c, strategy: strategy}, nil
+}
+
+type waveWaiter struct {
+	client   *waveKubeClient
+	strategy kube.WaitStrategy
+}
+
+func (w *waveWaiter) Wait(resources kube.ResourceList, _ time.Duration) error {
+	w.client.record(fmt.Sprintf("wait(%s)", w.strategy), resources)
+	return nil
+}
+
+func (w *waveWaiter) WaitWithJobs(resources kube.ResourceList, timeout time.Duration) error {
+	return w.Wait(resources, timeout)
+}
+
+func (w *waveWaiter) WaitForDelete(resources kube.ResourceList, _ time.Duration) error {
+	w.client.record(fmt.Sprintf("waitForDelete(%s)", w.strategy), resources)
+	return nil
+}
+
+func (w *waveWaiter) WatchUntilReady(_ kube.ResourceList, _ time.Duration) error {
+	return nil
+}
+
+func waveConfigFixture(t *testing.T) (*Configuration, *waveKubeClient) {
+	t.Helper()
+	cfg := actionConfigFixture(t)
+	client := &waveKubeClient{}
+	client.DummyResources = kube.ResourceList{
+		waveConfigMap("app", ""),
+		waveConfigMap("db", "-1"),
+		waveConfigMap("web", "5"),
+		waveConfigMap("cache", "-1"),
+	}
+	cfg.KubeClient = client
+	return cfg, client
+}
+
+func TestApplyWaves(t *testing.T) {
+	waves, err := applyWaves(kube.ResourceList{
+		waveConfigMap("app", ""),
+		waveConfigMap("db", "-1"),
+		waveConfigMap("web", " 5 "),
+		waveConfigMap("cache", "-1"),
+	})
+	require.NoError(t, err)
+
+	var got []string
+	for _, w := range waves {
+		var names []string
+		for _, r := range w.resources {
+			names = append(names, r.Name)
+		}
+		got = append(got, fmt.Sprintf("%d: %s", w.wave, strings.Join(names, ",")))
+	}
+	assert.Equal(t, []string{"-1: db,cache", "0: app", "5: web"}, got)
+
+	_, err = applyWaves(kube.ResourceList{waveConfigMap("app", "first")})
+	assert.ErrorContains(t, err, `invalid helm.sh/apply-wave annotation "first"`)
+}
+
+func TestInstallApplyWaves(t *testing.T) {
+	cfg, client := waveConfigFixture(t)
+	instAction := installActionWithConfig(cfg)
+	instAction.DisableHooks = true
+	instAction.WaitStrategy = kube.HookOnlyStrategy
+
+	_, err := instAction.RunWithContext(context.Background(), buildChart(), map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"create db,cache",
+		"wait(watcher) db,cache",
+		"create app",
+		"wait(watcher) app",
+		"create web",
+		"wait(hookOnly) app,db,web,cache",
+	}, client.calls)
+}
+
+func TestUpgradeApplyWaves(t *testing.T) {
+	cfg, client := waveConfigFixture(t)
+	upAction := upgradeAction(t)
+	upAction.cfg = cfg
+	upAction.WaitStrategy = kube.StatusWatcherStrategy
+	upAction.DisableHooks = true
+
+	rel := releaseStub()
+	rel.Name = "waves"
+	rel.Info.Status = common.StatusDeployed
+	require.NoError(t, cfg.Releases.Create(rel))
+
+	_, err := upAction.RunWithContext(context.Background(), rel.Name, buildChart(), map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"update db,cache",
+		"wait(watcher) db,cache",
+		"update app",
+		"wait(watcher) app",
+		"update web",
+		"wait(watcher) app,db,web,cache",
+	}, client.calls)
+}
+
+func TestUninstallApplyWaves(t *testing.T) {
+	cfg, client := waveConfigFixture(t)
+	unAction := uninstallAction(t)
+	unAction.cfg = cfg
+	unAction.DisableHooks = true
+	unAction.WaitStrategy = kube.HookOnlyStrategy
+
+	rel := releaseStub()
+	rel.Name = "waves"
+	rel.Manifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+`
+	require.NoError(t, cfg.Releases.Create(rel))
+
+	_, err := unAction.Run(rel.Name)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"delete web",
+		"waitForDelete(watcher) web",
+		"delete app",
+		"waitForDelete(watcher) app",
+		"delete db,cache",
+		"waitForDelete(hookOnly) web,app,db,cache",
+	}, client.calls)
+}
+
+func TestUninstallApplyWavesDeleteError(t *testing.T) {
+	cfg, client := waveConfigFixture(t)
+	client.failDelete = "web"
+	unAction := uninstallAction(t)
+	unAction.cfg = cfg
+	unAction.DisableHooks = true
+	unAction.WaitStrategy = kube.HookOnlyStrategy
+
+	rel := releaseStub()
+	rel.Name = "waves"
+	rel.Manifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+`
+
+	deleted, _, errs := unAction.deleteRelease(rel)
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "unable to delete web")
+
+	var names []string
+	for _, r := range deleted {
+		names = append(names, r.Name)
+	}
+	assert.Equal(t, []string{"app", "db", "cache"}, names)
+	assert.Equal(t, []string{
+		"delete web",
+		"delete app",
+		"waitForDelete(watcher) app",
+		"delete db,cache",
+	}, client.calls)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/cli/output"
+	"helm.sh/helm/v4/pkg/cmd/require"
+)
+
+var diffHelp = `
+This command consists of multiple subcommands which can be used to preview
+the changes an operation would make to a release, without applying them.
+
+The rendered manifests are compared resource by resource against the manifest
+stored with the current revision of the release. Values of Secrets are masked
+unless '--show-secrets' is set.
+`
+
+func newDiffCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "preview the changes of an install, upgrade or rollback",
+		Long:  diffHelp,
+		Args:  require.NoArgs,
+	}
+
+	cmd.AddCommand(newDiffInstallCmd(cfg, out))
+	cmd.AddCommand(newDiffUpgradeCmd(cfg, out))
+	cmd.AddCommand(newDiffRollbackCmd(cfg, out))
+
+	return cmd
+}
+
+func addDiffFlags(f *pflag.FlagSet, client *action.Diff) {
+	f.BoolVar(&client.ShowSecrets, "show-secrets", false, "do not mask the values of Secrets in the output")
+	f.IntVar(&client.Context, "context", 3, "number of lines of context to show around each change")
+	f.BoolVar(&client.ShowUnchanged, "show-unchanged", false, "include resources without changes in the output")
+	f.BoolVar(&client.ShowOwnership, "show-ownership", false, "show server-side apply field ownership changes (performs a server-side dry-run apply)")
+}
+
+type diffPrinter struct {
+	diffs   []*action.ResourceDiff
+	noColor bool
+}
+
+func (p diffPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.resourceDiffs())
+}
+
+func (p diffPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.resourceDiffs())
+}
+
+func (p diffPrinter) WriteTable(out io.Writer) error {
+	if len(p.diffs) == 0 {
+		_, _ = fmt.Fprintln(out, "No changes.")
+		return nil
+	}
+
+	for _, d := range p.diffs {
+		header := fmt.Sprintf("%s, %s/%s (%s), %s", d.Namespace, d.Kind, d.Name, d.APIVersion, d.Change)
+		_, _ = fmt.Fprintln(out, p.colorize(color.FgYellow, header))
+		if d.Source != "" {
+			_, _ = fmt.Fprintf(out, "# Source: %s\n", d.Source)
+		}
+		for _, line := range strings.SplitAfter(d.Diff, "\n") {
+			if line == "" {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+				_, _ = fmt.Fprint(out, line)
+			case strings.HasPrefix(line, "+"):
+				_, _ = fmt.Fprint(out, p.colorize(color.FgGreen, line))
+			case strings.HasPrefix(line, "-"):
+				_, _ = fmt.Fprint(out, p.colorize(color.FgRed, line))
+			default:
+				_, _ = fmt.Fprint(out, line)
+			}
+		}
+		if len(d.Ownership) > 0 {
+			_, _ = fmt.Fprintln(out, "FIELD OWNERSHIP CHANGES:")
+			for _, o := range d.Ownership {
+				_, _ = fmt.Fprintf(out, "  %s: [%s] -> [%s]\n", o.Field, strings.Join(o.Before, ", "), strings.Join(o.After, ", "))
+			}
+		}
+		_, _ = fmt.Fprintln(out)
+	}
+	return nil
+}
+
+func (p diffPrinter) colorize(attr color.Attribute, s string) string {
+	if p.noColor {
+		return s
+	}
+	// Trailing newlines are kept outside of the color sequence
+	trimmed := strings.TrimSuffix(s, "\n")
+	return color.New(attr).Sprint(trimmed) + s[len(trimmed):]
+}
+
+// resourceDiffs never returns nil so the structured output is always a list.
+func (p diffPrinter) resourceDiffs() []*action.ResourceDiff {
+	if p.diffs == nil {
+		return []*action.ResourceDiff{}
+	}
+	return p.diffs
+}
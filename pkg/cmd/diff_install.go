@@ -0,0 +1,84 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/cli/output"
+	"helm.sh/helm/v4/pkg/cli/values"
+	"helm.sh/helm/v4/pkg/cmd/require"
+)
+
+const diffInstallHelp = `
+This command shows the resources that would be created by installing a chart.
+
+The chart is rendered with a server-side dry-run, so the arguments and flags
+are the same as for 'helm install'.
+
+    $ helm diff install my-release ./mychart -f values.yaml
+`
+
+func newDiffInstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewInstall(cfg)
+	diffClient := action.NewDiff(cfg)
+	valueOpts := &values.Options{}
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "install [NAME] [CHART]",
+		Short: "preview the resources an install would create",
+		Long:  diffInstallHelp,
+		Args:  require.MinimumNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return compInstall(args, toComplete, client)
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			registryClient, err := newRegistryClient(client.CertFile, client.KeyFile, client.CaFile,
+				client.InsecureSkipTLSverify, client.PlainHTTP, client.Username, client.Password)
+			if err != nil {
+				return fmt.Errorf("missing registry client: %w", err)
+			}
+			client.SetRegistryClient(registryClient)
+			client.DryRunStrategy = action.DryRunServer
+			diffClient.ForceConflicts = client.ForceConflicts
+
+			rel, err := runInstall(args, client, valueOpts, out)
+			if err != nil {
+				return err
+			}
+
+			diffs, err := diffClient.Releases(nil, rel)
+			if err != nil {
+				return err
+			}
+			return outfmt.Write(out, &diffPrinter{diffs: diffs, noColor: settings.ShouldDisableColor()})
+		},
+	}
+
+	f := cmd.Flags()
+	addInstallFlags(cmd, f, client, valueOpts)
+	addDiffFlags(f, diffClient)
+	bindOutputFlag(cmd, &outfmt)
+	bindPostRenderFlag(cmd, &client.PostRenderer, settings)
+
+	return cmd
+}
@@ -0,0 +1,83 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/cli/output"
+	"helm.sh/helm/v4/pkg/cmd/require"
+)
+
+const diffRollbackHelp = `
+This command shows the changes rolling back a release to a previous revision
+would make.
+
+The first argument is the name of a release, and the second is a revision
+number. If the revision is omitted or set to 0, the previous release is used.
+`
+
+func newDiffRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewRollback(cfg)
+	diffClient := action.NewDiff(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "rollback <RELEASE> [REVISION]",
+		Short: "preview the changes of a rollback",
+		Long:  diffRollbackHelp,
+		Args:  require.MinimumNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return compListReleases(toComplete, args, cfg)
+			}
+			if len(args) == 1 {
+				return compListRevisions(toComplete, cfg, args[0])
+			}
+			return noMoreArgsComp()
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				ver, err := strconv.Atoi(args[1])
+				if err != nil {
+					return fmt.Errorf("could not convert revision to a number: %v", err)
+				}
+				client.Version = ver
+			}
+			diffClient.ForceConflicts = client.ForceConflicts
+
+			diffs, err := diffClient.RunRollback(client, args[0])
+			if err != nil {
+				return err
+			}
+			return outfmt.Write(out, &diffPrinter{diffs: diffs, noColor: settings.ShouldDisableColor()})
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.ForceConflicts, "force-conflicts", false, "if set server-side apply will force changes against conflicts")
+	f.StringVar(&client.ServerSideApply, "server-side", "auto", "must be \"true\", \"false\" or \"auto\". Object updates run in the server instead of the client (\"auto\" defaults the value from the previous chart release's method)")
+	addDiffFlags(f, diffClient)
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
@@ -0,0 +1,120 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+func TestDiffRollbackCmd(t *testing.T) {
+	rels := []*release.Release{
+		{
+			Name:      "funny-honey",
+			Namespace: "default",
+			Info:      &release.Info{Status: common.StatusSuperseded},
+			Chart:     &chart.Chart{},
+			Version:   1,
+			Manifest: `---
+# Source: funny/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: funny
+data:
+  key: old
+---
+# Source: funny/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: funny
+data:
+  password: b2xk
+`,
+		},
+		{
+			Name:      "funny-honey",
+			Namespace: "default",
+			Info:      &release.Info{Status: common.StatusDeployed},
+			Chart:     &chart.Chart{},
+			Version:   2,
+			Manifest: `---
+# Source: funny/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: funny
+data:
+  key: new
+`,
+		},
+	}
+
+	tests := []cmdTestCase{{
+		name:   "diff a rollback",
+		cmd:    "diff rollback funny-honey 1",
+		golden: "output/diff-rollback.txt",
+		rels:   rels,
+	}, {
+		name:   "diff a rollback with secrets shown",
+		cmd:    "diff rollback funny-honey 1 --show-secrets",
+		golden: "output/diff-rollback-show-secrets.txt",
+		rels:   rels,
+	}, {
+		name:   "diff a rollback in json",
+		cmd:    "diff rollback funny-honey 1 -o json",
+		golden: "output/diff-rollback.json",
+		rels:   rels,
+	}, {
+		name:   "diff a rollback to the current revision",
+		cmd:    "diff rollback funny-honey 2",
+		golden: "output/diff-rollback-no-changes.txt",
+		rels:   rels,
+	}, {
+		name:      "diff a rollback without release name",
+		cmd:       "diff rollback",
+		golden:    "output/diff-rollback-no-args.txt",
+		rels:      rels,
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestDiffUpgradeCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "funny-bunny", Version: 1}),
+	}
+
+	tests := []cmdTestCase{{
+		name:      "diff an upgrade of a release that does not exist",
+		cmd:       "diff upgrade bogus testdata/testcharts/empty",
+		golden:    "output/diff-upgrade-not-found.txt",
+		rels:      rels,
+		wantError: true,
+	}, {
+		name:      "diff an upgrade without a chart",
+		cmd:       "diff upgrade funny-bunny",
+		golden:    "output/diff-upgrade-no-args.txt",
+		rels:      rels,
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
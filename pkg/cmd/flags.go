@@ -22,6 +22,7 @@ import (
 	"log"
 	"log/slog"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -44,6 +45,8 @@ const (
 	outputFlag         = "output"
 	postRenderFlag     = "post-renderer"
 	postRenderArgsFlag = "post-renderer-args"
+	postRenderKustFlag = "post-renderer-kustomize"
+	progressFlag       = "progress"
 )
 
 func addValueOptionsFlags(f *pflag.FlagSet, v *values.Options) {
@@ -101,6 +104,44 @@ func (ws *waitValue) Type() string {
 	return "WaitStrategy"
 }
 
+// addCRDPolicyFlag adds the --crd-policy flag, which is mutually exclusive
+// with the --skip-crds flag, to the given command.
+func addCRDPolicyFlag(cmd *cobra.Command, policy *action.CRDPolicy, usage string) {
+	cmd.Flags().Var((*crdPolicyValue)(policy), "crd-policy",
+		fmt.Sprintf("%s. Allowed values: %s, %s, %s", usage, action.CRDPolicySkip, action.CRDPolicyCreate, action.CRDPolicyCreateOrUpdate))
+	cmd.MarkFlagsMutuallyExclusive("skip-crds", "crd-policy")
+
+	err := cmd.RegisterFlagCompletionFunc("crd-policy", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return []string{
+			string(action.CRDPolicySkip) + "\tleave the CRDs of the chart alone",
+			string(action.CRDPolicyCreate) + "\tcreate the CRDs which are not present",
+			string(action.CRDPolicyCreateOrUpdate) + "\tcreate the CRDs, and update the present ones with server-side apply",
+		}, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type crdPolicyValue action.CRDPolicy
+
+func (p *crdPolicyValue) String() string {
+	return string(*p)
+}
+
+func (p *crdPolicyValue) Set(s string) error {
+	policy := action.CRDPolicy(s)
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	*p = crdPolicyValue(policy)
+	return nil
+}
+
+func (p *crdPolicyValue) Type() string {
+	return "policy"
+}
+
 func addChartPathOptionsFlags(f *pflag.FlagSet, c *action.ChartPathOptions) {
 	f.StringVar(&c.Version, "version", "", "specify a version constraint for the chart version to use. This constraint can be a specific tag (e.g. 1.1.1) or it may reference a valid range (e.g. ^2.0.0). If this is not specified, the latest version is used")
 	f.BoolVar(&c.Verify, "verify", false, "verify the package before using it")
@@ -167,16 +208,105 @@ func (o *outputValue) Set(s string) error {
 
 // TODO there is probably a better way to pass cobra settings than as a param
 func bindPostRenderFlag(cmd *cobra.Command, varRef *postrenderer.PostRenderer, settings *cli.EnvSettings) {
-	p := &postRendererOptions{varRef, "", []string{}, settings}
-	cmd.Flags().Var(&postRendererString{p}, postRenderFlag, "the name of a postrenderer type plugin to be used for post rendering. If it exists, the plugin will be used")
-	cmd.Flags().Var(&postRendererArgsSlice{p}, postRenderArgsFlag, "an argument to the post-renderer (can specify multiple)")
+	p := &postRendererOptions{renderer: varRef, settings: settings}
+	cmd.Flags().Var(&postRendererString{p}, postRenderFlag, "the name of a postrenderer type plugin to be used for post rendering. If it exists, the plugin will be used. Can be repeated to run several post-renderers in the order given")
+	cmd.Flags().Var(&postRendererArgsSlice{p}, postRenderArgsFlag, "an argument to the preceding --post-renderer, or to the first one if given before any (can specify multiple)")
+	cmd.Flags().Var(&postRendererKustomizeString{p}, postRenderKustFlag, "the path to a kustomization directory applied to the rendered manifests by the built-in kustomize post-renderer. Runs in the order given among --post-renderer flags")
+	if err := cmd.MarkFlagDirname(postRenderKustFlag); err != nil {
+		log.Fatal(err)
+	}
 }
 
+// postRendererOptions holds the post-renderers given on the command line, in
+// the order they are run.
 type postRendererOptions struct {
-	renderer   *postrenderer.PostRenderer
-	pluginName string
-	args       []string
-	settings   *cli.EnvSettings
+	renderer *postrenderer.PostRenderer
+	stages   []*postRendererStage
+	// pendingArgs are the arguments given before the first --post-renderer.
+	pendingArgs []string
+	settings    *cli.EnvSettings
+}
+
+// postRendererStage is a single post-renderer of the chain. Exactly one of
+// pluginName and kustomizeDir is set.
+type postRendererStage struct {
+	pluginName   string
+	args         []string
+	kustomizeDir string
+	renderer     postrenderer.PostRenderer
+}
+
+func (s *postRendererStage) build(settings *cli.EnvSettings) error {
+	var err error
+	if s.pluginName != "" {
+		s.renderer, err = postrenderer.NewPostRendererPlugin(settings, s.pluginName, s.args...)
+	} else {
+		s.renderer, err = postrenderer.NewPostRendererKustomize(s.kustomizeDir)
+	}
+	return err
+}
+
+// addStage builds a post-renderer and appends it to the chain.
+func (o *postRendererOptions) addStage(stage *postRendererStage) error {
+	if stage.pluginName != "" && !o.hasPluginStage() {
+		stage.args = o.pendingArgs
+		o.pendingArgs = nil
+	}
+	if err := stage.build(o.settings); err != nil {
+		return err
+	}
+	o.stages = append(o.stages, stage)
+	o.update()
+	return nil
+}
+
+// argsStage returns the plugin post-renderer which --post-renderer-args
+// currently apply to, or nil if no --post-renderer was given yet.
+func (o *postRendererOptions) argsStage() *postRendererStage {
+	if n := len(o.stages); n > 0 && o.stages[n-1].pluginName != "" {
+		return o.stages[n-1]
+	}
+	return nil
+}
+
+// hasPluginStage reports whether a --post-renderer was given yet.
+func (o *postRendererOptions) hasPluginStage() bool {
+	return slices.ContainsFunc(o.stages, func(s *postRendererStage) bool { return s.pluginName != "" })
+}
+
+// setArgs sets the arguments of the plugin post-renderer they apply to.
+// Arguments following a --post-renderer-kustomize apply to no post-renderer
+// once a --post-renderer was given, so they are rejected.
+func (o *postRendererOptions) setArgs(args []string) error {
+	stage := o.argsStage()
+	if stage == nil {
+		if o.hasPluginStage() {
+			return fmt.Errorf("--%s must follow the --%s it applies to, not --%s", postRenderArgsFlag, postRenderFlag, postRenderKustFlag)
+		}
+		o.pendingArgs = args
+		return nil
+	}
+	stage.args = args
+	if err := stage.build(o.settings); err != nil {
+		return err
+	}
+	o.update()
+	return nil
+}
+
+func (o *postRendererOptions) args() []string {
+	if stage := o.argsStage(); stage != nil {
+		return stage.args
+	}
+	return o.pendingArgs
+}
+
+func (o *postRendererOptions) update() {
+	renderers := make([]postrenderer.PostRenderer, 0, len(o.stages))
+	for _, s := range o.stages {
+		renderers = append(renderers, s.renderer)
+	}
+	*o.renderer = postrenderer.NewChain(renderers...)
 }
 
 type postRendererString struct {
@@ -184,7 +314,13 @@ type postRendererString struct {
 }
 
 func (p *postRendererString) String() string {
-	return p.options.pluginName
+	var names []string
+	for _, s := range p.options.stages {
+		if s.pluginName != "" {
+			names = append(names, s.pluginName)
+		}
+	}
+	return strings.Join(names, ",")
 }
 
 func (p *postRendererString) Type() string {
@@ -195,16 +331,32 @@ func (p *postRendererString) Set(val string) error {
 	if val == "" {
 		return nil
 	}
-	if p.options.pluginName != "" {
-		return fmt.Errorf("cannot specify --post-renderer flag more than once")
+	return p.options.addStage(&postRendererStage{pluginName: val})
+}
+
+type postRendererKustomizeString struct {
+	options *postRendererOptions
+}
+
+func (p *postRendererKustomizeString) String() string {
+	var dirs []string
+	for _, s := range p.options.stages {
+		if s.kustomizeDir != "" {
+			dirs = append(dirs, s.kustomizeDir)
+		}
 	}
-	p.options.pluginName = val
-	pr, err := postrenderer.NewPostRendererPlugin(p.options.settings, p.options.pluginName, p.options.args...)
-	if err != nil {
-		return err
+	return strings.Join(dirs, ",")
+}
+
+func (p *postRendererKustomizeString) Type() string {
+	return "string"
+}
+
+func (p *postRendererKustomizeString) Set(val string) error {
+	if val == "" {
+		return nil
 	}
-	*p.options.renderer = pr
-	return nil
+	return p.options.addStage(&postRendererStage{kustomizeDir: val})
 }
 
 type postRendererArgsSlice struct {
@@ -212,7 +364,7 @@ type postRendererArgsSlice struct {
 }
 
 func (p *postRendererArgsSlice) String() string {
-	return "[" + strings.Join(p.options.args, ",") + "]"
+	return "[" + strings.Join(p.options.args(), ",") + "]"
 }
 
 func (p *postRendererArgsSlice) Type() string {
@@ -220,34 +372,20 @@ func (p *postRendererArgsSlice) Type() string {
 }
 
 func (p *postRendererArgsSlice) Set(val string) error {
-
 	// a post-renderer defined by a user may accept empty arguments
-	p.options.args = append(p.options.args, val)
-
-	if p.options.pluginName == "" {
-		return nil
-	}
-	// overwrite if already create PostRenderer by `post-renderer` flags
-	pr, err := postrenderer.NewPostRendererPlugin(p.options.settings, p.options.pluginName, p.options.args...)
-	if err != nil {
-		return err
-	}
-	*p.options.renderer = pr
-	return nil
+	return p.options.setArgs(append(slices.Clone(p.options.args()), val))
 }
 
 func (p *postRendererArgsSlice) Append(val string) error {
-	p.options.args = append(p.options.args, val)
-	return nil
+	return p.options.setArgs(append(slices.Clone(p.options.args()), val))
 }
 
 func (p *postRendererArgsSlice) Replace(val []string) error {
-	p.options.args = val
-	return nil
+	return p.options.setArgs(val)
 }
 
 func (p *postRendererArgsSlice) GetSlice() []string {
-	return p.options.args
+	return p.options.args()
 }
 
 func compVersionFlag(chartRef string, _ string) ([]string, cobra.ShellCompDirective) {
@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"helm.sh/helm/v4/pkg/action"
@@ -99,25 +100,41 @@ func outputFlagCompletionTest(t *testing.T, cmdName string) {
 	runTestCmd(t, tests)
 }
 
-func TestPostRendererFlagSetOnce(t *testing.T) {
+func TestPostRendererFlagChain(t *testing.T) {
 	cfg := action.Configuration{}
 	client := action.NewInstall(&cfg)
 	settings.PluginsDirectory = "testdata/helmhome/helm/plugins"
-	str := postRendererString{
-		options: &postRendererOptions{
-			renderer: &client.PostRenderer,
-			settings: settings,
-		},
+	options := &postRendererOptions{
+		renderer: &client.PostRenderer,
+		settings: settings,
 	}
-	// Set the plugin name once
-	err := str.Set("postrenderer-v1")
-	require.NoError(t, err)
+	str := postRendererString{options: options}
+	args := postRendererArgsSlice{options: options}
+	kustomize := postRendererKustomizeString{options: options}
 
-	// Set the plugin name again to the same value is not ok
-	err = str.Set("postrenderer-v1")
-	require.Error(t, err)
+	// Arguments given before the first post-renderer apply to it
+	require.NoError(t, args.Set("ARG1"))
+	require.NoError(t, str.Set("postrenderer-v1"))
+	require.NoError(t, args.Set("ARG2"))
+	require.NoError(t, kustomize.Set("testdata/kustomize"))
+	// Arguments given after a post-renderer apply to it
+	require.NoError(t, str.Set("postrenderer-v1"))
+	require.NoError(t, args.Set("ARG3"))
 
-	// Set the plugin name again to a different value is not ok
-	err = str.Set("cat")
-	require.Error(t, err)
+	require.Len(t, options.stages, 3)
+	assert.Equal(t, []string{"ARG1", "ARG2"}, options.stages[0].args)
+	assert.Equal(t, "testdata/kustomize", options.stages[1].kustomizeDir)
+	assert.Equal(t, []string{"ARG3"}, options.stages[2].args)
+	assert.Equal(t, "postrenderer-v1,postrenderer-v1", str.String())
+	assert.Equal(t, "[ARG3]", args.String())
+	assert.NotNil(t, client.PostRenderer)
+
+	// Unknown plugins are rejected
+	require.Error(t, str.Set("cat"))
+	require.Len(t, options.stages, 3)
+
+	// Arguments following a kustomize post-renderer apply to no post-renderer
+	require.NoError(t, kustomize.Set("testdata/kustomize"))
+	require.EqualError(t, args.Set("ARG4"), "--post-renderer-args must follow the --post-renderer it applies to, not --post-renderer-kustomize")
+	assert.Equal(t, []string{"ARG3"}, options.stages[2].args)
 }
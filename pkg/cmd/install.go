@@ -158,6 +158,7 @@ func newInstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 			rel, err := runInstall(args, client, valueOpts, out)
 			if err != nil {
+				writeWaitDiagnostics(cmd.ErrOrStderr(), err)
 				return fmt.Errorf("INSTALLATION FAILED: %w", err)
 			}
 
@@ -179,6 +180,7 @@ func newInstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	addDryRunFlag(cmd)
 	bindOutputFlag(cmd, &outfmt)
 	bindPostRenderFlag(cmd, &client.PostRenderer, settings)
+	bindProgressFlag(cmd, cfg)
 
 	return cmd
 }
@@ -209,9 +211,14 @@ func addInstallFlags(cmd *cobra.Command, f *pflag.FlagSet, client *action.Instal
 	f.BoolVar(&client.EnableDNS, "enable-dns", false, "enable DNS lookups when rendering templates")
 	f.BoolVar(&client.HideNotes, "hide-notes", false, "if set, do not show notes in install output. Does not affect presence in chart metadata")
 	f.BoolVar(&client.TakeOwnership, "take-ownership", false, "if set, install will ignore the check for helm annotations and take ownership of the existing resources")
+	f.BoolVar(&client.WarningsAsErrors, "warnings-as-errors", false, "if set, fail the installation if the API server returns warnings, such as deprecation warnings, while the resources are applied")
+	f.BoolVar(&client.Preflight, "preflight", false, "if set, check that all permissions required for the installation are granted before changing anything, and list the missing ones otherwise")
+	f.StringVar(&client.ServiceAccount, "service-account", "", "the service account, given as namespace/name, to impersonate when operating on the resources of the release. It is recorded on the release and used by later operations by default")
+	f.BoolVar(&client.ApplySet, "applyset", false, "if set, label the resources of the release as members of a KEP-3659 ApplySet, so upgrades prune removed resources by querying the cluster")
 	addValueOptionsFlags(f, valueOpts)
 	addChartPathOptionsFlags(f, &client.ChartPathOptions)
 	AddWaitFlag(cmd, &client.WaitStrategy)
+	addCRDPolicyFlag(cmd, &client.CRDPolicy, "how to apply the CRDs in the crds/ directories of the chart. Defaults to \"create\"")
 	cmd.MarkFlagsMutuallyExclusive("force-replace", "force-conflicts")
 	cmd.MarkFlagsMutuallyExclusive("force", "force-conflicts")
 
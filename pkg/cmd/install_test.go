@@ -141,6 +141,17 @@ func TestInstall(t *testing.T) {
 			name: "install with verification, valid",
 			cmd:  "install signtest testdata/testcharts/signtest-0.1.0.tgz --verify --keyring testdata/helm-test-key.pub",
 		},
+		// Install, CRD policy
+		{
+			name:      "install with invalid CRD policy",
+			cmd:       "install crds testdata/testcharts/chart-with-only-crds --crd-policy update",
+			wantError: true,
+		},
+		{
+			name:      "install with CRD policy and skipped CRDs",
+			cmd:       "install crds testdata/testcharts/chart-with-only-crds --crd-policy create --skip-crds",
+			wantError: true,
+		},
 		// Install, chart with missing dependencies in /charts
 		{
 			name:      "install chart with missing dependencies",
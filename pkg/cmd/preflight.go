@@ -0,0 +1,133 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+	ci "helm.sh/helm/v4/pkg/chart"
+	"helm.sh/helm/v4/pkg/chart/loader"
+	"helm.sh/helm/v4/pkg/cli/values"
+	"helm.sh/helm/v4/pkg/cmd/require"
+	"helm.sh/helm/v4/pkg/getter"
+)
+
+const preflightDesc = `
+This command checks that you are allowed to install a chart as a release, or to
+upgrade the release if it already exists, without changing anything.
+
+The chart is rendered as for 'helm upgrade --install --dry-run=server', and a
+SelfSubjectAccessReview is issued for every verb, resource and namespace the
+operation would touch, including hooks, waiting and the storage of the
+release. All permissions which are not granted are listed.
+
+The same check can be run as part of an operation with the '--preflight' flag
+of 'helm install', 'helm upgrade', 'helm rollback' and 'helm uninstall'.
+`
+
+func newPreflightCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewPreflight(cfg)
+	valueOpts := &values.Options{}
+
+	cmd := &cobra.Command{
+		Use:   "preflight [RELEASE] [CHART]",
+		Short: "check the permissions required to install or upgrade a release",
+		Long:  preflightDesc,
+		Args:  require.ExactArgs(2),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return compListReleases(toComplete, args, cfg)
+			}
+			if len(args) == 1 {
+				return compListCharts(toComplete, true)
+			}
+			return noMoreArgsComp()
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			client.Namespace = settings.Namespace()
+
+			registryClient, err := newRegistryClient(client.CertFile, client.KeyFile, client.CaFile,
+				client.InsecureSkipTLSverify, client.PlainHTTP, client.Username, client.Password)
+			if err != nil {
+				return fmt.Errorf("missing registry client: %w", err)
+			}
+			client.SetRegistryClient(registryClient)
+
+			if client.Version == "" && client.Devel {
+				slog.Debug("setting version to >0.0.0-0")
+				client.Version = ">0.0.0-0"
+			}
+
+			chartPath, err := client.LocateChart(args[1], settings)
+			if err != nil {
+				return err
+			}
+			vals, err := valueOpts.MergeValues(getter.All(settings))
+			if err != nil {
+				return err
+			}
+			chrt, err := loader.Load(chartPath)
+			if err != nil {
+				return err
+			}
+			ac, err := ci.NewAccessor(chrt)
+			if err != nil {
+				return err
+			}
+			if req := ac.MetaDependencies(); req != nil {
+				if err := action.CheckDependencies(chrt, req); err != nil {
+					return fmt.Errorf("an error occurred while checking for chart dependencies. You may need to run `helm dependency build` to fetch missing dependencies: %w", err)
+				}
+			}
+
+			upgrade, err := client.Run(args[0], chrt, vals)
+			if err != nil {
+				return err
+			}
+			operation := "install"
+			if upgrade {
+				operation = "upgrade"
+			}
+			fmt.Fprintf(out, "All permissions required to %s release %q are granted.\n", operation, args[0])
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored")
+	f.BoolVar(&client.DisableHooks, "no-hooks", false, "do not check the permissions required to run hooks")
+	f.BoolVar(&client.SkipCRDs, "skip-crds", false, "do not check the permissions required to install CRDs")
+	f.BoolVar(&client.CreateNamespace, "create-namespace", false, "check the permissions required to create the release namespace")
+	f.BoolVar(&client.TakeOwnership, "take-ownership", false, "check the permissions required to take ownership of existing resources")
+	f.BoolVar(&client.ApplySet, "applyset", false, "check the permissions required to manage a KEP-3659 ApplySet for the release")
+	f.BoolVar(&client.RecreateOnImmutable, "recreate-on-immutable", false, "check the permissions required to recreate resources whose update fails because an immutable field changed")
+	f.StringVar(&client.ServerSideApply, "server-side", "auto", "must be \"true\", \"false\" or \"auto\". Check the permissions required for server-side apply")
+	f.StringVar(&client.ServiceAccount, "service-account", "", "the service account, given as namespace/name, whose permissions to check on the resources of the release. Defaults to the service account recorded on the release")
+	f.BoolVar(&client.DisableOpenAPIValidation, "disable-openapi-validation", false, "if set, the rendered templates are not validated against the Kubernetes OpenAPI Schema")
+	f.BoolVar(&client.SkipSchemaValidation, "skip-schema-validation", false, "if set, disables JSON schema validation")
+	addChartPathOptionsFlags(f, &client.ChartPathOptions)
+	addValueOptionsFlags(f, valueOpts)
+	AddWaitFlag(cmd, &client.WaitStrategy)
+	addCRDPolicyFlag(cmd, &client.CRDPolicy, "check the permissions required to apply the CRDs in the crds/ directories of the chart with the policy. Defaults to the policy of 'helm upgrade --install'")
+
+	return cmd
+}
@@ -0,0 +1,42 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+func TestPreflightCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "preflight of an installation",
+		cmd:    "preflight funny-bunny testdata/testcharts/empty",
+		golden: "output/preflight-install.txt",
+	}, {
+		name:   "preflight of an upgrade",
+		cmd:    "preflight funny-bunny testdata/testcharts/empty",
+		golden: "output/preflight-upgrade.txt",
+		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "funny-bunny"})},
+	}, {
+		name:      "preflight with missing arguments",
+		cmd:       "preflight funny-bunny",
+		golden:    "output/preflight-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
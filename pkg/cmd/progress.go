@@ -0,0 +1,131 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+)
+
+const (
+	// progressNone disables progress output.
+	progressNone = "none"
+	// progressJSON streams progress events as newline delimited JSON.
+	progressJSON = "json"
+	// progressLive renders the status of the resources being waited for.
+	progressLive = "live"
+)
+
+// bindProgressFlag adds the --progress flag to the given command. Progress
+// events of the action configuration are written to the error stream of the
+// command, so they do not interfere with the output of the command.
+func bindProgressFlag(cmd *cobra.Command, cfg *action.Configuration) {
+	value := &progressValue{cmd: cmd, cfg: cfg, format: progressNone}
+	cmd.Flags().Var(value, progressFlag,
+		fmt.Sprintf("report the progress of the operation on stderr. Allowed values: %s, %s, %s", progressNone, progressJSON, progressLive))
+
+	// The live view renders until the command is done.
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			defer func() {
+				if value.view != nil {
+					value.view.close()
+				}
+			}()
+			return run(cmd, args)
+		}
+	}
+
+	err := cmd.RegisterFlagCompletionFunc(progressFlag, func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return []string{
+			progressNone + "\tno progress output",
+			progressJSON + "\tstream progress events as newline delimited JSON",
+			progressLive + "\tshow the status of the resources being waited for, in place if stdout is a terminal",
+		}, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type progressValue struct {
+	cmd    *cobra.Command
+	cfg    *action.Configuration
+	format string
+	view   *progressView
+}
+
+func (p *progressValue) String() string {
+	return p.format
+}
+
+func (p *progressValue) Type() string {
+	return "format"
+}
+
+func (p *progressValue) Set(s string) error {
+	p.view = nil
+	switch s {
+	case progressNone:
+		p.cfg.EventSink = nil
+	case progressJSON:
+		p.cfg.EventSink = newJSONEventSink(p.cmd.ErrOrStderr())
+	case progressLive:
+		p.view = newProgressView(p.cmd.ErrOrStderr(), p.cmd.OutOrStdout())
+		p.cfg.EventSink = p.view.sink
+	default:
+		return fmt.Errorf("invalid progress format %q, allowed values: %s, %s, %s", s, progressNone, progressJSON, progressLive)
+	}
+	p.format = s
+	return nil
+}
+
+// progressLine is a single line of --progress=json output.
+type progressLine struct {
+	Time  time.Time    `json:"time"`
+	Type  string       `json:"type"`
+	Event action.Event `json:"event"`
+}
+
+// newJSONEventSink returns an EventSink writing each event to out as a line
+// of JSON.
+func newJSONEventSink(out io.Writer) action.EventSink {
+	var mu sync.Mutex
+	return func(ev action.Event) {
+		line, err := json.Marshal(progressLine{
+			Time:  time.Now().UTC(),
+			Type:  ev.Type(),
+			Event: ev,
+		})
+		if err != nil {
+			slog.Warn("unable to encode progress event", "type", ev.Type(), slog.Any("error", err))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(out, "%s\n", line)
+	}
+}
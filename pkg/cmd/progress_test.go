@@ -0,0 +1,76 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/kube"
+)
+
+func TestJSONEventSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := newJSONEventSink(&buf)
+	sink(action.ResourceApplied{Name: "web", Namespace: "default", Kind: "Deployment", Action: action.ResourceCreated})
+	sink(action.ResourceStatusChanged{StatusUpdate: kube.StatusUpdate{Name: "web", Kind: "Deployment", Status: "InProgress", Desired: "Current"}})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var first struct {
+		Time  string                 `json:"time"`
+		Type  string                 `json:"type"`
+		Event action.ResourceApplied `json:"event"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.NotEmpty(t, first.Time)
+	assert.Equal(t, "ResourceApplied", first.Type)
+	assert.Equal(t, action.ResourceApplied{Name: "web", Namespace: "default", Kind: "Deployment", Action: action.ResourceCreated}, first.Event)
+
+	var second map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Equal(t, "ResourceStatusChanged", second["type"])
+	assert.Equal(t, map[string]any{"name": "web", "kind": "Deployment", "status": "InProgress", "desired": "Current"}, second["event"])
+}
+
+func TestInstallProgressJSON(t *testing.T) {
+	_, out, err := executeActionCommand("install aeneas testdata/testcharts/alpine --namespace default --progress=json")
+	require.NoError(t, err)
+
+	line, _, _ := strings.Cut(out, "\n")
+	var got map[string]any
+	require.NoError(t, json.Unmarshal([]byte(line), &got), "first line is not a progress event: %q", line)
+	assert.Equal(t, "ReleaseRendered", got["type"])
+	assert.Equal(t, map[string]any{"name": "aeneas", "namespace": "default", "revision": float64(1), "resources": float64(0), "hooks": float64(0)}, got["event"])
+}
+
+func TestProgressFlagInvalid(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "install with an invalid progress format",
+		cmd:       "install aeneas testdata/testcharts/alpine --progress=yaml",
+		golden:    "output/install-progress-invalid.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
@@ -0,0 +1,252 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"golang.org/x/term"
+
+	"helm.sh/helm/v4/pkg/action"
+)
+
+const (
+	// progressTTYInterval is the interval in which the live view is redrawn
+	// on a terminal.
+	progressTTYInterval = 500 * time.Millisecond
+	// progressLineInterval is the interval in which pending resources are
+	// reported when the output is not a terminal.
+	progressLineInterval = 10 * time.Second
+)
+
+// resourceProgress is the last observed status of a resource.
+type resourceProgress struct {
+	kind, namespace, name string
+	status, message       string
+	desired               string
+	// since is when the resource was first observed, and done is when it
+	// reached the desired status.
+	since, done time.Time
+	// reported is set once a line mode report includes the resource in its
+	// final status.
+	reported bool
+}
+
+func (r *resourceProgress) elapsed(now time.Time) time.Duration {
+	if !r.done.IsZero() {
+		now = r.done
+	}
+	return now.Sub(r.since).Round(time.Second)
+}
+
+func (r *resourceProgress) id() string {
+	if r.namespace == "" {
+		return r.kind + "/" + r.name
+	}
+	return r.kind + "/" + r.namespace + "/" + r.name
+}
+
+// progressView renders the status of the resources an action waits for. On a
+// terminal, it redraws a table of all resources in place. Otherwise, it
+// periodically writes a line for each resource that is still pending or
+// became ready since the last report.
+type progressView struct {
+	out      io.Writer
+	tty      bool
+	width    int
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	resources []*resourceProgress
+	index     map[string]*resourceProgress
+	// drawn is the number of lines of the last frame drawn on a terminal.
+	drawn   int
+	changed bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newProgressView returns a progressView writing to out. The view is drawn in
+// place if both out and stdout are terminals, and falls back to periodic line
+// output otherwise, e.g. when the output of the command is redirected.
+func newProgressView(out, stdout io.Writer) *progressView {
+	v := &progressView{
+		out:      out,
+		interval: progressLineInterval,
+		now:      time.Now,
+		index:    map[string]*resourceProgress{},
+	}
+	if isTerminal(out) && isTerminal(stdout) {
+		v.tty = true
+		v.interval = progressTTYInterval
+		if width, _, err := term.GetSize(int(out.(*os.File).Fd())); err == nil {
+			v.width = width
+		}
+	}
+	return v
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// sink is the EventSink of the view. The view starts rendering with the first
+// status update.
+func (v *progressView) sink(ev action.Event) {
+	e, ok := ev.(action.ResourceStatusChanged)
+	if !ok {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	r := &resourceProgress{kind: e.Kind, namespace: e.Namespace, name: e.Name}
+	if existing, ok := v.index[r.id()]; ok {
+		r = existing
+	} else {
+		v.index[r.id()] = r
+		v.resources = append(v.resources, r)
+	}
+	if r.desired != e.Desired || (!r.done.IsZero() && e.Status != e.Desired) {
+		// The resource is waited on again, e.g. for its deletion.
+		r.since, r.done, r.reported = now, time.Time{}, false
+	}
+	if r.since.IsZero() {
+		r.since = now
+	}
+	r.status, r.message, r.desired = e.Status, e.Message, e.Desired
+	if r.status == r.desired && r.done.IsZero() {
+		r.done = now
+	}
+	v.changed = true
+
+	if v.stop == nil {
+		v.stop, v.stopped = make(chan struct{}), make(chan struct{})
+		go v.run()
+	}
+}
+
+func (v *progressView) run() {
+	defer close(v.stopped)
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-ticker.C:
+			v.mu.Lock()
+			v.render()
+			v.mu.Unlock()
+		}
+	}
+}
+
+// close stops rendering and draws the final state of the view.
+func (v *progressView) close() {
+	v.mu.Lock()
+	stop := v.stop
+	v.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-v.stopped
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.render()
+	v.stop = nil
+}
+
+// render draws the view. It must be called with the lock held.
+func (v *progressView) render() {
+	if v.tty {
+		v.renderTable()
+		return
+	}
+	v.renderLines()
+}
+
+func (v *progressView) renderTable() {
+	now := v.now()
+	tbl := uitable.New()
+	tbl.AddRow("KIND", "NAMESPACE", "NAME", "STATUS", "TIME", "MESSAGE")
+	for _, r := range v.resources {
+		tbl.AddRow(r.kind, r.namespace, r.name, r.status, r.elapsed(now), r.message)
+	}
+
+	var b strings.Builder
+	if v.drawn > 0 {
+		// Move the cursor to the start of the last frame and clear it.
+		fmt.Fprintf(&b, "\x1b[%dA\x1b[J", v.drawn)
+	}
+	lines := strings.Split(tbl.String(), "\n")
+	for _, line := range lines {
+		if v.width > 0 && len(line) >= v.width {
+			// Wrapped lines would break moving the cursor up.
+			line = line[:v.width-1]
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	v.drawn = len(lines)
+	fmt.Fprint(v.out, b.String())
+}
+
+func (v *progressView) renderLines() {
+	if !v.changed && v.pending() == 0 {
+		return
+	}
+	v.changed = false
+	now := v.now()
+	for _, r := range v.resources {
+		if r.reported {
+			continue
+		}
+		if !r.done.IsZero() {
+			r.reported = true
+			fmt.Fprintf(v.out, "%s is %s after %s\n", r.id(), r.status, r.elapsed(now))
+			continue
+		}
+		line := fmt.Sprintf("waiting for %s to be %s (%s, %s)", r.id(), r.desired, r.status, r.elapsed(now))
+		if r.message != "" {
+			line += ": " + r.message
+		}
+		fmt.Fprintln(v.out, line)
+	}
+}
+
+func (v *progressView) pending() int {
+	n := 0
+	for _, r := range v.resources {
+		if r.done.IsZero() {
+			n++
+		}
+	}
+	return n
+}
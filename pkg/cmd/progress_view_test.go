@@ -0,0 +1,94 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/kube"
+)
+
+func statusChanged(kind, name, status, message string) action.Event {
+	return action.ResourceStatusChanged{StatusUpdate: kube.StatusUpdate{
+		Kind:      kind,
+		Namespace: "default",
+		Name:      name,
+		Status:    status,
+		Message:   message,
+		Desired:   "Current",
+	}}
+}
+
+// testProgressView returns a view with a clock advanced by the returned
+// function.
+func testProgressView(out *bytes.Buffer, tty bool) (*progressView, func(time.Duration)) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	v := newProgressView(out, out)
+	v.tty = tty
+	v.interval = time.Hour
+	v.now = func() time.Time { return now }
+	return v, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestProgressViewLines(t *testing.T) {
+	var buf bytes.Buffer
+	v, advance := testProgressView(&buf, false)
+	defer v.close()
+
+	v.sink(action.ReleaseRendered{Name: "web"})
+	v.sink(statusChanged("Deployment", "web", "InProgress", "Replicas: 0/2"))
+	v.sink(statusChanged("Service", "web", "Current", ""))
+	advance(5 * time.Second)
+	v.render()
+	assert.Equal(t, "waiting for Deployment/default/web to be Current (InProgress, 5s): Replicas: 0/2\n"+
+		"Service/default/web is Current after 0s\n", buf.String())
+
+	buf.Reset()
+	advance(5 * time.Second)
+	v.sink(statusChanged("Deployment", "web", "Current", ""))
+	advance(5 * time.Second)
+	v.render()
+	assert.Equal(t, "Deployment/default/web is Current after 10s\n", buf.String())
+
+	buf.Reset()
+	v.render()
+	assert.Empty(t, buf.String())
+}
+
+func TestProgressViewTTY(t *testing.T) {
+	var buf bytes.Buffer
+	v, advance := testProgressView(&buf, true)
+
+	v.sink(statusChanged("Deployment", "web", "InProgress", "Replicas: 0/2"))
+	advance(3 * time.Second)
+	v.render()
+	assert.Equal(t, "KIND      \tNAMESPACE\tNAME\tSTATUS    \tTIME\tMESSAGE      \n"+
+		"Deployment\tdefault  \tweb \tInProgress\t3s  \tReplicas: 0/2\n", buf.String())
+
+	buf.Reset()
+	v.sink(statusChanged("Deployment", "web", "Current", ""))
+	advance(time.Second)
+	v.close()
+	assert.Equal(t, "\x1b[2A\x1b[J"+
+		"KIND      \tNAMESPACE\tNAME\tSTATUS \tTIME\tMESSAGE\n"+
+		"Deployment\tdefault  \tweb \tCurrent\t3s  \t       \n", buf.String())
+}
@@ -0,0 +1,72 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/cmd/require"
+)
+
+const releaseUnlockHelp = `
+This command removes the lock of a release.
+
+Install, upgrade, rollback and uninstall lock the release they operate on, so
+that concurrent operations on the same release fail instead of interfering with
+each other. The lock is stored in a Lease named 'sh.helm.release.lock.<name>'
+for the secret and configmap drivers, and in the database for the sql and
+sqlite drivers.
+
+A lock expires when it has not been renewed for 60 seconds, for example after
+the holding process was killed. Use this command to remove the lock right away
+instead. The next upgrade marks a revision left in a pending state as failed.
+
+Only remove a lock if no operation on the release is still running.
+`
+
+func newReleaseUnlockCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewUnlock(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "unlock RELEASE_NAME",
+		Short: "remove the lock of a release",
+		Long:  releaseUnlockHelp,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return noMoreArgsComp()
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			lock, err := client.Run(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Removed the lock of release %q held by %s for %s since %s\n",
+				lock.Name, lock.Holder, lock.Operation, lock.Acquired.Format(time.RFC3339))
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,86 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/cli/output"
+	"helm.sh/helm/v4/pkg/cmd/require"
+)
+
+const repairDesc = `
+This command re-applies the manifest of the current revision of a release with
+server-side apply, restoring resources which were changed or deleted outside of
+Helm, or which were not applied because an install or upgrade failed partway
+through.
+
+Unlike 'helm upgrade', a repair does not run hooks and does not create a new
+revision. A note is added to the description of the revision, and a failed
+revision becomes the deployed one.
+
+The resources which differed from the manifest are listed. Use '--dry-run' to
+list them without applying anything.
+`
+
+func newRepairCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewRepair(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "repair RELEASE_NAME",
+		Short: "re-apply the manifest of the current revision of a release",
+		Long:  repairDesc,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return noMoreArgsComp()
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			changed, err := client.Run(args[0])
+			if err != nil {
+				writeWaitDiagnostics(cmd.ErrOrStderr(), err)
+				return err
+			}
+			if err := outfmt.Write(out, driftPrinter{drifts: changed, empty: "No drift detected."}); err != nil {
+				return err
+			}
+			if outfmt == output.Table && !client.DryRun {
+				fmt.Fprintf(out, "Release %q has been repaired.\n", args[0])
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.DryRun, "dry-run", false, "list the resources which would be changed without applying the manifest")
+	f.BoolVar(&client.ForceConflicts, "force-conflicts", false, "if set server-side apply will force changes against conflicts. Required to revert fields changed by another field manager")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	AddWaitFlag(cmd, &client.WaitStrategy)
+	bindOutputFlag(cmd, &outfmt)
+	bindProgressFlag(cmd, cfg)
+
+	return cmd
+}
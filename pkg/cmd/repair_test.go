@@ -0,0 +1,62 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+func TestRepairCmd(t *testing.T) {
+	rels := []*release.Release{{
+		Name:    "funny-honey",
+		Info:    &release.Info{Status: common.StatusFailed},
+		Chart:   &chart.Chart{},
+		Version: 1,
+	}}
+
+	tests := []cmdTestCase{{
+		name:   "repair a release",
+		cmd:    "repair funny-honey",
+		golden: "output/repair.txt",
+		rels:   rels,
+	}, {
+		name:   "repair a release in dry-run mode",
+		cmd:    "repair funny-honey --dry-run -o json",
+		golden: "output/repair-dry-run.json",
+		rels:   rels,
+	}, {
+		name:      "repair a release which does not exist",
+		cmd:       "repair unknown",
+		golden:    "output/repair-not-found.txt",
+		wantError: true,
+	}, {
+		name:      "repair without release name",
+		cmd:       "repair",
+		golden:    "output/repair-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestRepairCompletion(t *testing.T) {
+	checkFileCompletion(t, "repair", false)
+	checkFileCompletion(t, "repair myrelease", false)
+}
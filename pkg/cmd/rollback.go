@@ -73,6 +73,7 @@ func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			client.DryRunStrategy = dryRunStrategy
 
 			if err := client.Run(args[0]); err != nil {
+				writeWaitDiagnostics(cmd.ErrOrStderr(), err)
 				return err
 			}
 
@@ -85,15 +86,19 @@ func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.ForceReplace, "force-replace", false, "force resource updates by replacement")
 	f.BoolVar(&client.ForceReplace, "force", false, "deprecated")
 	f.MarkDeprecated("force", "use --force-replace instead")
+	f.BoolVar(&client.RecreateOnImmutable, "recreate-on-immutable", false, "if set, delete and recreate resources whose update fails because an immutable field changed. Without it, only resources with the \"helm.sh/resource-policy: recreate-on-immutable\" annotation are recreated")
 	f.BoolVar(&client.ForceConflicts, "force-conflicts", false, "if set server-side apply will force changes against conflicts")
 	f.StringVar(&client.ServerSideApply, "server-side", "auto", "must be \"true\", \"false\" or \"auto\". Object updates run in the server instead of the client (\"auto\" defaults the value from the previous chart release's method)")
 	f.BoolVar(&client.DisableHooks, "no-hooks", false, "prevent hooks from running during rollback")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
 	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.CleanupOnFail, "cleanup-on-fail", false, "allow deletion of new resources created in this rollback when rollback fails")
+	f.BoolVar(&client.Preflight, "preflight", false, "if set, check that all permissions required for the rollback are granted before changing anything, and list the missing ones otherwise")
+	f.StringVar(&client.ServiceAccount, "service-account", "", "the service account, given as namespace/name, to impersonate when operating on the resources of the release. Defaults to the service account recorded on the release")
 	f.IntVar(&client.MaxHistory, "history-max", settings.MaxHistory, "limit the maximum number of revisions saved per release. Use 0 for no limit")
 	addDryRunFlag(cmd)
 	AddWaitFlag(cmd, &client.WaitStrategy)
+	bindProgressFlag(cmd, cfg)
 	cmd.MarkFlagsMutuallyExclusive("force-replace", "force-conflicts")
 	cmd.MarkFlagsMutuallyExclusive("force", "force-conflicts")
 
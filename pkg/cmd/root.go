@@ -24,6 +24,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/fatih/color"
@@ -62,8 +63,11 @@ Environment variables:
 | $HELM_CONFIG_HOME                  | set an alternative location for storing Helm configuration.                                                |
 | $HELM_DATA_HOME                    | set an alternative location for storing Helm data.                                                         |
 | $HELM_DEBUG                        | indicate whether or not Helm is running in Debug mode                                                      |
-| $HELM_DRIVER                       | set the backend storage driver. Values are: configmap, secret, memory, sql.                                |
-| $HELM_DRIVER_SQL_CONNECTION_STRING | set the connection string the SQL storage driver should use.                                               |
+| $HELM_DRIVER                       | set the backend storage driver. Values are: configmap, secret, memory, sql, sqlite, or a storage plugin.   |
+| $HELM_DRIVER_SQL_CONNECTION_STRING | set the connection string the SQL storage driver should use. For sqlite, the path of the database file.    |
+| $HELM_DRIVER_ENCRYPTION_KEY_FILE   | set the file of AES keys used to encrypt release records at rest.                                          |
+| $HELM_DRIVER_AGE_RECIPIENTS_FILE   | set the file of age recipients used to encrypt release records at rest.                                    |
+| $HELM_DRIVER_AGE_IDENTITY_FILE     | set the file of age identities used to decrypt release records.                                            |
 | $HELM_MAX_HISTORY                  | set the maximum number of helm release history.                                                            |
 | $HELM_NAMESPACE                    | set the namespace used for the helm operations.                                                            |
 | $HELM_NO_PLUGINS                   | disable plugins. Set HELM_NO_PLUGINS=1 to disable plugins.                                                 |
@@ -110,6 +114,7 @@ func NewRootCmd(out io.Writer, args []string, logSetup func(bool)) (*cobra.Comma
 	}
 	cobra.OnInitialize(func() {
 		helmDriver := os.Getenv("HELM_DRIVER")
+		actionConfig.PluginsDirectories = filepath.SplitList(settings.PluginsDirectory)
 		if err := actionConfig.Init(settings.RESTClientGetter(), settings.Namespace(), helmDriver); err != nil {
 			log.Fatal(err)
 		}
@@ -267,13 +272,18 @@ func newRootCmdWithConfig(actionConfig *action.Configuration, out io.Writer, arg
 		newVerifyCmd(out),
 
 		// release commands
+		newDiffCmd(actionConfig, out),
 		newGetCmd(actionConfig, out),
 		newHistoryCmd(actionConfig, out),
 		newInstallCmd(actionConfig, out),
 		newListCmd(actionConfig, out),
+		newPreflightCmd(actionConfig, out),
+		newReleaseCmd(actionConfig, out),
 		newReleaseTestCmd(actionConfig, out),
+		newRepairCmd(actionConfig, out),
 		newRollbackCmd(actionConfig, out),
 		newStatusCmd(actionConfig, out),
+		newStorageCmd(actionConfig, out),
 		newTemplateCmd(actionConfig, out),
 		newUninstallCmd(actionConfig, out),
 		newUpgradeCmd(actionConfig, out),
@@ -18,12 +18,14 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 
 	"k8s.io/kubectl/pkg/cmd/get"
@@ -49,11 +51,19 @@ The status consists of:
 - list of resources that this release consists of
 - details on last test suite run, if applicable
 - additional notes provided by the chart
+
+With --drift, the resources of the release manifest are instead compared with
+the live objects in the cluster, and fields changed outside of Helm are listed
+together with the field managers that changed them. Only fields set in the
+manifest are compared. Use --drift-ignore-manager to skip changes made by
+managers such as autoscalers.
 `
 
 func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewStatus(cfg)
+	drift := action.NewDrift(cfg)
 	var outfmt output.Format
+	var showDrift bool
 
 	cmd := &cobra.Command{
 		Use:   "status RELEASE_NAME",
@@ -67,6 +77,15 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
+			if showDrift {
+				drift.Version = client.Version
+				drifts, err := drift.Run(args[0])
+				if err != nil {
+					return err
+				}
+				return outfmt.Write(out, driftPrinter{drifts: drifts, empty: "No resources found."})
+			}
+
 			// When the output format is a table the resources should be fetched
 			// and displayed as a table. When YAML or JSON the resources will be
 			// returned. This mirrors the handling in kubectl.
@@ -109,6 +128,10 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		log.Fatal(err)
 	}
 
+	f.BoolVar(&showDrift, "drift", false, "compare the resources of the release with the live objects in the cluster")
+	f.BoolVar(&drift.ShowSecrets, "show-secrets", false, "do not mask the values of Secrets in the drift output")
+	f.StringSliceVar(&drift.IgnoreManagers, "drift-ignore-manager", []string{}, "field manager whose changes are not reported as drift (can specify multiple)")
+
 	bindOutputFlag(cmd, &outfmt)
 
 	return cmd
@@ -158,6 +181,12 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 		_, _ = fmt.Fprintf(out, "APP_VERSION: %s\n", rel.Chart.Metadata.AppVersion)
 	}
 	_, _ = fmt.Fprintf(out, "DESCRIPTION: %s\n", rel.Info.Description)
+	if len(rel.Info.Warnings) > 0 {
+		_, _ = fmt.Fprintf(out, "WARNINGS:\n")
+		for _, w := range rel.Info.Warnings {
+			_, _ = fmt.Fprintf(out, "  %s\n", w)
+		}
+	}
 
 	if len(rel.Info.Resources) > 0 {
 		buf := new(bytes.Buffer)
@@ -201,6 +230,9 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 				fmt.Sprintf("Last Completed: %s", h.LastRun.CompletedAt.Format(time.ANSIC)),
 				fmt.Sprintf("Phase:          %s", h.LastRun.Phase),
 			)
+			if h.LastRun.Attempts > 1 {
+				_, _ = fmt.Fprintf(out, "Attempts:       %d\n", h.LastRun.Attempts)
+			}
 		}
 	}
 
@@ -242,6 +274,69 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 	return nil
 }
 
+type driftPrinter struct {
+	drifts []*action.ResourceDrift
+	// empty is printed instead of an empty table.
+	empty string
+}
+
+func (p driftPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.resourceDrifts())
+}
+
+func (p driftPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.resourceDrifts())
+}
+
+func (p driftPrinter) WriteTable(out io.Writer) error {
+	if len(p.drifts) == 0 {
+		_, _ = fmt.Fprintln(out, p.empty)
+		return nil
+	}
+	tbl := uitable.New()
+	tbl.AddRow("RESOURCE", "STATUS", "FIELD", "EXPECTED", "LIVE", "MANAGERS")
+	for _, d := range p.drifts {
+		resource := fmt.Sprintf("%s/%s", d.Kind, d.Name)
+		if d.Namespace != "" {
+			resource = d.Namespace + "/" + resource
+		}
+		if len(d.Fields) == 0 {
+			tbl.AddRow(resource, d.Status, "", "", "", "")
+			continue
+		}
+		for _, f := range d.Fields {
+			tbl.AddRow(resource, d.Status, f.Field, driftValue(f.Expected), driftValue(f.Live), strings.Join(f.Managers, ", "))
+		}
+	}
+	return output.EncodeTable(out, tbl)
+}
+
+// resourceDrifts never returns nil so the structured output is always a list.
+func (p driftPrinter) resourceDrifts() []*action.ResourceDrift {
+	if p.drifts == nil {
+		return []*action.ResourceDrift{}
+	}
+	return p.drifts
+}
+
+// driftValue formats a field value for the table output. Missing values are
+// shown as <none>, structured values as JSON.
+func driftValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<none>"
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
 func executionsByHookEvent(rel *releasev1.Release) map[releasev1.HookEvent][]*releasev1.Hook {
 	result := make(map[releasev1.HookEvent][]*releasev1.Hook)
 	for _, h := range rel.Hooks {
@@ -17,9 +17,14 @@ limitations under the License.
 package cmd
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v4/internal/test"
+	"helm.sh/helm/v4/pkg/action"
 	chart "helm.sh/helm/v4/pkg/chart/v2"
 	"helm.sh/helm/v4/pkg/release/common"
 	release "helm.sh/helm/v4/pkg/release/v1"
@@ -52,6 +57,14 @@ func TestStatusCmd(t *testing.T) {
 			Status:      common.StatusDeployed,
 			Description: "Mock description",
 		}),
+	}, {
+		name:   "get status of a deployed release with warnings",
+		cmd:    "status flummoxed-chickadee",
+		golden: "output/status-with-warnings.txt",
+		rels: releasesMockWithStatus(&release.Info{
+			Status:   common.StatusDeployed,
+			Warnings: []string{"Pod default/web: spec.privileged is deprecated"},
+		}),
 	}, {
 		name:   "get status of a deployed release with notes",
 		cmd:    "status flummoxed-chickadee",
@@ -114,6 +127,7 @@ func TestStatusCmd(t *testing.T) {
 					StartedAt:   mustParseTime("2006-01-02T15:10:05Z"),
 					CompletedAt: mustParseTime("2006-01-02T15:10:07Z"),
 					Phase:       release.HookPhaseFailed,
+					Attempts:    3,
 				},
 			},
 			&release.Hook{
@@ -126,10 +140,58 @@ func TestStatusCmd(t *testing.T) {
 				},
 			},
 		),
+	}, {
+		name:   "get drift of a deployed release without resources",
+		cmd:    "status flummoxed-chickadee --drift",
+		golden: "output/status-drift-empty.txt",
+		rels: releasesMockWithStatus(&release.Info{
+			Status: common.StatusDeployed,
+		}),
+	}, {
+		name:   "get drift of a deployed release without resources in json",
+		cmd:    "status flummoxed-chickadee --drift -o json",
+		golden: "output/status-drift-empty.json",
+		rels: releasesMockWithStatus(&release.Info{
+			Status: common.StatusDeployed,
+		}),
 	}}
 	runTestCmd(t, tests)
 }
 
+func TestDriftPrinter(t *testing.T) {
+	drifts := []*action.ResourceDrift{{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Namespace:  "default",
+		Name:       "web",
+		Status:     action.DriftModified,
+		Fields: []action.FieldDrift{{
+			Field:    "spec.replicas",
+			Expected: float64(2),
+			Live:     float64(5),
+			Managers: []string{"hpa (Update)"},
+		}, {
+			Field:    "metadata.labels",
+			Expected: map[string]interface{}{"app": "web"},
+		}},
+	}, {
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Namespace:  "default",
+		Name:       "settings",
+		Status:     action.DriftInSync,
+	}, {
+		APIVersion: "rbac.authorization.k8s.io/v1",
+		Kind:       "ClusterRole",
+		Name:       "reader",
+		Status:     action.DriftMissing,
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, driftPrinter{drifts: drifts}.WriteTable(&buf))
+	test.AssertGoldenString(t, buf.String(), "output/status-drift.txt")
+}
+
 func mustParseTime(t string) time.Time {
 	res, _ := time.Parse(time.RFC3339, t)
 	return res
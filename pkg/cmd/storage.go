@@ -0,0 +1,45 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/cmd/require"
+)
+
+var storageHelp = `
+This command consists of multiple subcommands to manage the storage backend
+holding release records, as selected by $HELM_DRIVER.
+`
+
+func newStorageCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "manage the storage of release records",
+		Long:  storageHelp,
+		Args:  require.NoArgs,
+	}
+
+	cmd.AddCommand(newStorageMigrateCmd(cfg, out))
+	cmd.AddCommand(newStorageRekeyCmd(cfg, out))
+
+	return cmd
+}
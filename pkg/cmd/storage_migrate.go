@@ -0,0 +1,117 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/cli/output"
+	"helm.sh/helm/v4/pkg/cmd/require"
+)
+
+const storageMigrateHelp = `
+This command copies the release records of all revisions of all releases from
+one storage driver to another, for example from configmaps to secrets:
+
+    $ helm storage migrate --from configmap --to secret --namespace my-namespace
+
+Labels are copied with each record, and every copied record is read back from
+the target driver and compared with the source record. Records which already
+exist in the target driver are left untouched if they are identical, and
+reported as failed otherwise.
+
+The source records are kept unless '--delete-source' is set, in which case they
+are deleted only after every record has been copied and verified. Use
+'--dry-run' to report what would be migrated without changing either driver.
+
+The sql and sqlite drivers read their connection string from
+$HELM_DRIVER_SQL_CONNECTION_STRING.
+`
+
+func newStorageMigrateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewMigrate(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:               "migrate --from DRIVER --to DRIVER",
+		Short:             "copy release records from one storage driver to another",
+		Long:              storageMigrateHelp,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noMoreArgsCompFunc,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			client.Namespace = settings.Namespace()
+			report, err := client.Run()
+			if report != nil {
+				if werr := outfmt.Write(out, migrateReport(report)); werr != nil {
+					return werr
+				}
+			}
+			return err
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&client.From, "from", "", "storage driver to copy release records from")
+	f.StringVar(&client.To, "to", "", "storage driver to copy release records to")
+	f.BoolVarP(&client.AllNamespaces, "all-namespaces", "A", false, "migrate release records across all namespaces")
+	f.BoolVar(&client.DeleteSource, "delete-source", false, "delete the source records after all records have been copied and verified")
+	f.BoolVar(&client.DryRun, "dry-run", false, "report the records which would be migrated without changing either driver")
+	bindOutputFlag(cmd, &outfmt)
+
+	for _, name := range []string{"from", "to"} {
+		if err := cmd.MarkFlagRequired(name); err != nil {
+			log.Fatal(err)
+		}
+		err := cmd.RegisterFlagCompletionFunc(name, func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return []string{"secret", "configmap", "memory", "sql", "sqlite"}, cobra.ShellCompDirectiveNoFileComp
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	return cmd
+}
+
+type migrateReport []*action.MigratedRelease
+
+func (r migrateReport) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, r)
+}
+
+func (r migrateReport) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, r)
+}
+
+func (r migrateReport) WriteTable(out io.Writer) error {
+	if len(r) == 0 {
+		_, _ = fmt.Fprintln(out, "No release records found.")
+		return nil
+	}
+	tbl := uitable.New()
+	tbl.AddRow("NAME", "NAMESPACE", "REVISION", "STATUS", "RESULT", "SOURCE DELETED", "ERROR")
+	for _, item := range r {
+		tbl.AddRow(item.Name, item.Namespace, item.Revision, item.Status, item.Result, item.SourceDeleted, item.Error)
+	}
+	return output.EncodeTable(out, tbl)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/cmd/require"
+)
+
+const storageRekeyHelp = `
+This command rewrites all release records with the currently configured
+encryption keys. It is used to encrypt existing records after enabling
+encryption, and to rotate keys.
+
+Release records stored by the secret and configmap drivers are encrypted when
+one of the following is set:
+
+- $HELM_DRIVER_ENCRYPTION_KEY_FILE: a file of base64 encoded 256-bit AES keys,
+  one per line. The first key encrypts, all keys decrypt.
+- $HELM_DRIVER_AGE_RECIPIENTS_FILE: a file of age recipients to encrypt to.
+- $HELM_DRIVER_AGE_IDENTITY_FILE: a file of age identities to decrypt with.
+  Without recipients or AES keys, records are encrypted to its identities.
+
+To rotate an AES key, add the new key as the first line of the key file, run
+'helm storage rekey', then remove the old key:
+
+    $ helm storage rekey --all-namespaces
+`
+
+func newStorageRekeyCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewRekey(cfg)
+	var allNamespaces bool
+
+	cmd := &cobra.Command{
+		Use:               "rekey",
+		Short:             "re-encrypt release records with the current keys",
+		Long:              storageRekeyHelp,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noMoreArgsCompFunc,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if allNamespaces {
+				if err := cfg.Init(settings.RESTClientGetter(), "", os.Getenv("HELM_DRIVER")); err != nil {
+					return err
+				}
+			}
+
+			n, err := client.Run()
+			if n > 0 || err == nil {
+				fmt.Fprintf(out, "Rekeyed %d release records\n", n)
+			}
+			return err
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVarP(&allNamespaces, "all-namespaces", "A", false, "rekey release records across all namespaces")
+
+	return cmd
+}
@@ -0,0 +1,51 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestStorageRekeyCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "rekey with a driver without encryption support",
+		cmd:       "storage rekey",
+		golden:    "output/storage-rekey-unsupported.txt",
+		wantError: true,
+	}, {
+		name:      "rekey with arguments",
+		cmd:       "storage rekey foo",
+		golden:    "output/storage-rekey-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestStorageMigrateCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "migrate without target driver",
+		cmd:       "storage migrate --from configmap",
+		golden:    "output/storage-migrate-no-target.txt",
+		wantError: true,
+	}, {
+		name:      "migrate to the same driver",
+		cmd:       "storage migrate --from secrets --to secret",
+		golden:    "output/storage-migrate-same-driver.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
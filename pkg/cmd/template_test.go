@@ -19,6 +19,7 @@ package cmd
 import (
 	"fmt"
 	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -48,6 +49,17 @@ func TestTemplateCmd(t *testing.T) {
 			cmd:    fmt.Sprintf(`template '%s' --name-template='foobar-{{ b64enc "abc" | lower }}-baz'`, chartPath),
 			golden: "output/template-name-template.txt",
 		},
+		{
+			name:   "check kustomize post-renderer",
+			cmd:    "template testdata/testcharts/alpine --set Name=kustomize --post-renderer-kustomize testdata/kustomize",
+			golden: "output/template-post-renderer-kustomize.txt",
+		},
+		{
+			name:      "check kustomize post-renderer without kustomization",
+			cmd:       "template testdata/testcharts/alpine --post-renderer-kustomize testdata/testcharts",
+			wantError: true,
+			golden:    "output/template-post-renderer-kustomize-missing.txt",
+		},
 		{
 			name:      "check no args",
 			cmd:       "template",
@@ -206,3 +218,26 @@ func TestTemplateFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "template myname", true)
 	checkFileCompletion(t, "template myname mychart", false)
 }
+
+func TestTemplatePostRendererChain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the post-renderer plugin is a shell script")
+	}
+	t.Setenv("HELM_PLUGINS", "testdata/helmhome/helm/plugins")
+	settings.PluginsDirectory = "testdata/helmhome/helm/plugins"
+
+	tests := []cmdTestCase{
+		{
+			name:   "chain a plugin and the kustomize post-renderer",
+			cmd:    "template testdata/testcharts/alpine --set Name=FOOTEST --post-renderer postrenderer-v1 --post-renderer-args chained --post-renderer-kustomize testdata/kustomize",
+			golden: "output/template-post-renderer-chain.txt",
+		},
+		{
+			name:      "report the failed post-renderer",
+			cmd:       "template testdata/testcharts/alpine --post-renderer postrenderer-v1 --post-renderer-args 'x/g; s/^/:/' --post-renderer-kustomize testdata/kustomize",
+			golden:    "output/template-post-renderer-chain-failed.txt",
+			wantError: true,
+		},
+	}
+	runTestCmd(t, tests)
+}
@@ -79,7 +79,10 @@ func newUninstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.StringVar(&client.DeletionPropagation, "cascade", "background", "Must be \"background\", \"orphan\", or \"foreground\". Selects the deletion cascading strategy for the dependents. Defaults to background.")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
 	f.StringVar(&client.Description, "description", "", "add a custom description")
+	f.BoolVar(&client.Preflight, "preflight", false, "if set, check that all permissions required for the uninstallation are granted before changing anything, and list the missing ones otherwise")
+	f.StringVar(&client.ServiceAccount, "service-account", "", "the service account, given as namespace/name, to impersonate when deleting the resources of the release. Defaults to the service account recorded on the release")
 	AddWaitFlag(cmd, &client.WaitStrategy)
+	bindProgressFlag(cmd, cfg)
 
 	return cmd
 }
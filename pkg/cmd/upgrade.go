@@ -136,6 +136,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 					instClient.DryRunStrategy = client.DryRunStrategy
 					instClient.DisableHooks = client.DisableHooks
 					instClient.SkipCRDs = client.SkipCRDs
+					instClient.CRDPolicy = client.CRDPolicy
 					instClient.Timeout = client.Timeout
 					instClient.WaitStrategy = client.WaitStrategy
 					instClient.WaitForJobs = client.WaitForJobs
@@ -153,6 +154,10 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 					instClient.EnableDNS = client.EnableDNS
 					instClient.HideSecret = client.HideSecret
 					instClient.TakeOwnership = client.TakeOwnership
+					instClient.ApplySet = client.ApplySet
+					instClient.WarningsAsErrors = client.WarningsAsErrors
+					instClient.ServiceAccount = client.ServiceAccount
+					instClient.Preflight = client.Preflight
 
 					if isReleaseUninstalled(versions) {
 						instClient.Replace = true
@@ -160,6 +165,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 					rel, err := runInstall(args, instClient, valueOpts, out)
 					if err != nil {
+						writeWaitDiagnostics(cmd.ErrOrStderr(), err)
 						return err
 					}
 					return outfmt.Write(out, &statusPrinter{
@@ -249,6 +255,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 			rel, err := client.RunWithContext(ctx, args[0], ch, vals)
 			if err != nil {
+				writeWaitDiagnostics(cmd.ErrOrStderr(), err)
 				return fmt.Errorf("UPGRADE FAILED: %w", err)
 			}
 
@@ -274,6 +281,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.ForceReplace, "force-replace", false, "force resource updates by replacement")
 	f.BoolVar(&client.ForceReplace, "force", false, "deprecated")
 	f.MarkDeprecated("force", "use --force-replace instead")
+	f.BoolVar(&client.RecreateOnImmutable, "recreate-on-immutable", false, "if set, delete and recreate resources whose update fails because an immutable field changed. Without it, only resources with the \"helm.sh/resource-policy: recreate-on-immutable\" annotation are recreated")
 	f.BoolVar(&client.ForceConflicts, "force-conflicts", false, "if set server-side apply will force changes against conflicts")
 	f.StringVar(&client.ServerSideApply, "server-side", "auto", "must be \"true\", \"false\" or \"auto\". Object updates run in the server instead of the client (\"auto\" defaults the value from the previous chart release's method)")
 	f.BoolVar(&client.DisableHooks, "no-hooks", false, "disable pre/post upgrade hooks")
@@ -285,6 +293,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.ResetThenReuseValues, "reset-then-reuse-values", false, "when upgrading, reset the values to the ones built into the chart, apply the last release's values and merge in any overrides from the command line via --set and -f. If '--reset-values' or '--reuse-values' is specified, this is ignored")
 	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.RollbackOnFailure, "rollback-on-failure", false, "if set, Helm will rollback the upgrade to previous success release upon failure. The --wait flag will be defaulted to \"watcher\" if --rollback-on-failure is set")
+	f.DurationVar(&client.VerifyFor, "verify-for", 0, "if set, watch the release for this long after the upgrade and roll it back if a resource becomes unready, a container restarts or a test hook fails. The --wait flag will be defaulted to \"watcher\" if --verify-for is set")
 	f.BoolVar(&client.RollbackOnFailure, "atomic", false, "deprecated")
 	f.MarkDeprecated("atomic", "use --rollback-on-failure instead")
 	f.IntVar(&client.MaxHistory, "history-max", settings.MaxHistory, "limit the maximum number of revisions saved per release. Use 0 for no limit")
@@ -297,12 +306,18 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.DependencyUpdate, "dependency-update", false, "update dependencies if they are missing before installing the chart")
 	f.BoolVar(&client.EnableDNS, "enable-dns", false, "enable DNS lookups when rendering templates")
 	f.BoolVar(&client.TakeOwnership, "take-ownership", false, "if set, upgrade will ignore the check for helm annotations and take ownership of the existing resources")
+	f.BoolVar(&client.WarningsAsErrors, "warnings-as-errors", false, "if set, fail the upgrade if the API server returns warnings, such as deprecation warnings, while the resources are applied")
+	f.BoolVar(&client.Preflight, "preflight", false, "if set, check that all permissions required for the upgrade are granted before changing anything, and list the missing ones otherwise")
+	f.StringVar(&client.ServiceAccount, "service-account", "", "the service account, given as namespace/name, to impersonate when operating on the resources of the release. Defaults to the service account recorded on the release")
+	f.BoolVar(&client.ApplySet, "applyset", false, "if set, label the resources of the release as members of a KEP-3659 ApplySet and prune removed resources by querying the cluster. Releases already using an ApplySet keep using it")
 	addDryRunFlag(cmd)
 	addChartPathOptionsFlags(f, &client.ChartPathOptions)
 	addValueOptionsFlags(f, valueOpts)
 	bindOutputFlag(cmd, &outfmt)
 	bindPostRenderFlag(cmd, &client.PostRenderer, settings)
+	bindProgressFlag(cmd, cfg)
 	AddWaitFlag(cmd, &client.WaitStrategy)
+	addCRDPolicyFlag(cmd, &client.CRDPolicy, "how to apply the CRDs in the crds/ directories of the chart. Defaults to \"skip\", or to \"create\" when the release is installed with --install")
 	cmd.MarkFlagsMutuallyExclusive("force-replace", "force-conflicts")
 	cmd.MarkFlagsMutuallyExclusive("force", "force-conflicts")
 
@@ -0,0 +1,73 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"helm.sh/helm/v4/pkg/kube"
+)
+
+// writeWaitDiagnostics writes the report of why resources did not become
+// ready if err is caused by a wait which timed out.
+func writeWaitDiagnostics(out io.Writer, err error) {
+	var timeoutErr *kube.WaitTimeoutError
+	if !errors.As(err, &timeoutErr) || len(timeoutErr.Resources) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("WAIT DIAGNOSTICS:\n")
+	for _, r := range timeoutErr.Resources {
+		name := r.Name
+		if r.Namespace != "" {
+			name = r.Namespace + "/" + r.Name
+		}
+		fmt.Fprintf(&b, "%s %s: %s", r.Kind, name, r.Status)
+		if r.Message != "" {
+			fmt.Fprintf(&b, ": %s", r.Message)
+		}
+		b.WriteString("\n")
+		for _, c := range r.Containers {
+			fmt.Fprintf(&b, "  Pod %s, container %s: %s", c.Pod, c.Container, c.State)
+			if c.Reason != "" {
+				fmt.Fprintf(&b, ": %s", c.Reason)
+			}
+			if c.ExitCode != 0 {
+				fmt.Fprintf(&b, " (exit code %d)", c.ExitCode)
+			}
+			if c.Message != "" {
+				fmt.Fprintf(&b, ": %s", c.Message)
+			}
+			b.WriteString("\n")
+		}
+		for _, claim := range r.PendingClaims {
+			fmt.Fprintf(&b, "  PersistentVolumeClaim %s: not bound\n", claim)
+		}
+		for _, e := range r.Events {
+			fmt.Fprintf(&b, "  Event %s/%s: %s %s: %s", e.Kind, e.Name, e.Type, e.Reason, e.Message)
+			if e.Count > 1 {
+				fmt.Fprintf(&b, " (x%d)", e.Count)
+			}
+			b.WriteString("\n")
+		}
+	}
+	fmt.Fprint(out, b.String())
+}
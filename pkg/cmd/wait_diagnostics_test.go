@@ -0,0 +1,60 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"helm.sh/helm/v4/internal/test"
+	"helm.sh/helm/v4/pkg/kube"
+)
+
+func TestWriteWaitDiagnostics(t *testing.T) {
+	err := fmt.Errorf("UPGRADE FAILED: %w", &kube.WaitTimeoutError{
+		Err: errors.Join(errors.New("resource not ready, name: web, kind: Deployment, status: InProgress"), context.DeadlineExceeded),
+		Resources: []kube.ResourceDiagnostics{{
+			Kind:      "Deployment",
+			Namespace: "default",
+			Name:      "web",
+			Status:    "InProgress",
+			Message:   "Deployment does not have minimum availability.",
+			Containers: []kube.ContainerDiagnostics{
+				{Pod: "web-7d9c-pull", Container: "app", State: "waiting", Reason: "ImagePullBackOff", Message: `Back-off pulling image "nginx:nope"`},
+				{Pod: "web-7d9c-oom", Container: "app", State: "last terminated", Reason: "OOMKilled", ExitCode: 137},
+			},
+			PendingClaims: []string{"data-web"},
+			Events: []kube.EventDiagnostics{
+				{Kind: "Pod", Name: "web-7d9c-pull", Type: "Warning", Reason: "Failed", Message: "Error: ErrImagePull", Count: 3},
+				{Kind: "PersistentVolumeClaim", Name: "data-web", Type: "Warning", Reason: "ProvisioningFailed", Message: `storageclass.storage.k8s.io "fast" not found`, Count: 1},
+			},
+		}},
+	})
+
+	var buf bytes.Buffer
+	writeWaitDiagnostics(&buf, err)
+	test.AssertGoldenString(t, buf.String(), "output/wait-diagnostics.txt")
+
+	buf.Reset()
+	writeWaitDiagnostics(&buf, errors.New("UPGRADE FAILED: boom"))
+	if buf.Len() != 0 {
+		t.Errorf("expected no diagnostics, got %q", buf.String())
+	}
+}
@@ -0,0 +1,360 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ApplySetParentIDLabel is the label of the parent of an ApplySet holding
+	// the ID of the set.
+	ApplySetParentIDLabel = "applyset.kubernetes.io/id"
+	// ApplySetPartOfLabel is the label of the members of an ApplySet holding
+	// the ID of the set.
+	ApplySetPartOfLabel = "applyset.kubernetes.io/part-of"
+	// ApplySetToolingAnnotation is the annotation of the parent of an ApplySet
+	// naming the tool managing the set.
+	ApplySetToolingAnnotation = "applyset.kubernetes.io/tooling"
+	// ApplySetGroupKindsAnnotation is the annotation of the parent of an
+	// ApplySet listing the group kinds of its members.
+	ApplySetGroupKindsAnnotation = "applyset.kubernetes.io/contains-group-kinds"
+	// ApplySetAdditionalNamespacesAnnotation is the annotation of the parent of
+	// an ApplySet listing the namespaces of its members other than the
+	// namespace of the parent.
+	ApplySetAdditionalNamespacesAnnotation = "applyset.kubernetes.io/additional-namespaces"
+
+	applySetTooling = "helm/v4"
+)
+
+// ApplySet is a set of resources applied together, as specified by KEP-3659.
+// Members of the set are labeled with its ID, and its parent, a Secret,
+// records the group kinds and namespaces of the members. This allows members
+// which are no longer applied to be found in the cluster and pruned, and tools
+// such as kubectl to recognize the set.
+//
+// An ApplySet records the resources applied with it, so the same ApplySet must
+// be used for all resources applied in one operation.
+type ApplySet struct {
+	// Name and Namespace identify the parent Secret of the set.
+	Name      string
+	Namespace string
+
+	mu      sync.Mutex
+	applied map[applySetMember]bool
+}
+
+type applySetMember struct {
+	group, kind, namespace, name string
+}
+
+// NewApplySet returns the ApplySet with the given parent Secret.
+func NewApplySet(name, namespace string) *ApplySet {
+	return &ApplySet{
+		Name:      name,
+		Namespace: namespace,
+		applied:   map[applySetMember]bool{},
+	}
+}
+
+// ApplySetName returns the name of the parent Secret of the ApplySet of a
+// release.
+func ApplySetName(release string) string {
+	return "sh.helm.applyset.v1." + release
+}
+
+// ID returns the ID of the set, derived from its parent as specified by
+// KEP-3659.
+func (s *ApplySet) ID() string {
+	unencoded := strings.Join([]string{s.Name, s.Namespace, "Secret", ""}, ".")
+	hashed := sha256.Sum256([]byte(unencoded))
+	return fmt.Sprintf("applyset-%s-v1", base64.RawURLEncoding.EncodeToString(hashed[:]))
+}
+
+func (s *ApplySet) isApplied(gk schema.GroupKind, namespace, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applied[applySetMember{gk.Group, gk.Kind, namespace, name}]
+}
+
+// appliedScope returns the group kinds and namespaces of the applied members.
+func (s *ApplySet) appliedScope() (sets.Set[string], sets.Set[string]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	groupKinds, namespaces := sets.New[string](), sets.New[string]()
+	for m := range s.applied {
+		groupKinds.Insert(schema.GroupKind{Group: m.group, Kind: m.kind}.String())
+		if m.namespace != "" {
+			namespaces.Insert(m.namespace)
+		}
+	}
+	return groupKinds, namespaces
+}
+
+// applySetClient manages the parent and the members of ApplySets.
+type applySetClient struct {
+	kubeClient kubernetes.Interface
+	client     dynamic.Interface
+	mapper     meta.RESTMapper
+}
+
+func (c *Client) applySetClient() (*applySetClient, error) {
+	kubeClient, err := c.getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := c.Factory.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := c.restMapper()
+	if err != nil {
+		return nil, err
+	}
+	return &applySetClient{kubeClient: kubeClient, client: dynamicClient, mapper: mapper}, nil
+}
+
+// prepare labels the resources as members of the set and records them as
+// applied. Unless dryRun is set, the parent of the set is created or updated
+// to include the group kinds and namespaces of the resources, before they are
+// applied.
+func (a *applySetClient) prepare(ctx context.Context, set *ApplySet, resources ResourceList, dryRun bool) error {
+	id := set.ID()
+	set.mu.Lock()
+	for _, info := range resources {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			set.mu.Unlock()
+			return fmt.Errorf("unable to label %s as member of ApplySet: %w", info.ObjectName(), err)
+		}
+		labels := accessor.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[ApplySetPartOfLabel] = id
+		accessor.SetLabels(labels)
+
+		gk := info.Mapping.GroupVersionKind.GroupKind()
+		set.applied[applySetMember{gk.Group, gk.Kind, info.Namespace, info.Name}] = true
+	}
+	set.mu.Unlock()
+
+	if dryRun {
+		return nil
+	}
+	groupKinds, namespaces := set.appliedScope()
+	return a.updateParent(ctx, set, groupKinds, namespaces, true)
+}
+
+// updateParent sets the group kinds and namespaces recorded by the parent of
+// the set, creating it if it does not exist. If merge is set, the recorded
+// group kinds and namespaces are extended instead.
+func (a *applySetClient) updateParent(ctx context.Context, set *ApplySet, groupKinds, namespaces sets.Set[string], merge bool) error {
+	secrets := a.kubeClient.CoreV1().Secrets(set.Namespace)
+	parent, err := secrets.Get(ctx, set.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		parent = &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      set.Name,
+			Namespace: set.Namespace,
+			Labels:    map[string]string{ApplySetParentIDLabel: set.ID()},
+		}}
+		setParentAnnotations(parent, groupKinds, namespaces)
+		_, err = secrets.Create(ctx, parent, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get ApplySet parent %s/%s: %w", set.Namespace, set.Name, err)
+	}
+	if err := checkParent(parent, set); err != nil {
+		return err
+	}
+	if merge {
+		recordedGroupKinds, recordedNamespaces := parentScope(parent)
+		groupKinds = groupKinds.Union(recordedGroupKinds)
+		namespaces = namespaces.Union(recordedNamespaces)
+	}
+	setParentAnnotations(parent, groupKinds, namespaces)
+	_, err = secrets.Update(ctx, parent, metav1.UpdateOptions{})
+	return err
+}
+
+// checkParent verifies that an existing Secret is the parent of the set, and
+// the set is managed by Helm.
+func checkParent(parent *v1.Secret, set *ApplySet) error {
+	if id := parent.Labels[ApplySetParentIDLabel]; id != set.ID() {
+		return fmt.Errorf("secret %s/%s is not the parent of ApplySet %s", set.Namespace, set.Name, set.ID())
+	}
+	if tooling := parent.Annotations[ApplySetToolingAnnotation]; tooling != "" && tooling != applySetTooling {
+		return fmt.Errorf("ApplySet %s is managed by %s", set.ID(), tooling)
+	}
+	return nil
+}
+
+func setParentAnnotations(parent *v1.Secret, groupKinds, namespaces sets.Set[string]) {
+	if parent.Annotations == nil {
+		parent.Annotations = map[string]string{}
+	}
+	parent.Annotations[ApplySetToolingAnnotation] = applySetTooling
+	parent.Annotations[ApplySetGroupKindsAnnotation] = strings.Join(sets.List(groupKinds), ",")
+	additional := namespaces.Clone().Delete(parent.Namespace)
+	if additional.Len() == 0 {
+		delete(parent.Annotations, ApplySetAdditionalNamespacesAnnotation)
+		return
+	}
+	parent.Annotations[ApplySetAdditionalNamespacesAnnotation] = strings.Join(sets.List(additional), ",")
+}
+
+// parentScope returns the group kinds and namespaces recorded by the parent.
+func parentScope(parent *v1.Secret) (sets.Set[string], sets.Set[string]) {
+	split := func(s string) sets.Set[string] {
+		result := sets.New[string]()
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result.Insert(item)
+			}
+		}
+		return result
+	}
+	namespaces := split(parent.Annotations[ApplySetAdditionalNamespacesAnnotation])
+	namespaces.Insert(parent.Namespace)
+	return split(parent.Annotations[ApplySetGroupKindsAnnotation]), namespaces
+}
+
+// prune deletes the members of the set in the cluster which were not applied
+// with it, except for the resources already deleted and the ones with the
+// keep resource policy. The parent is updated to only include the group kinds
+// and namespaces of the applied members afterwards.
+func (a *applySetClient) prune(ctx context.Context, set *ApplySet, deleted ResourceList) (ResourceList, error) {
+	parent, err := a.kubeClient.CoreV1().Secrets(set.Namespace).Get(ctx, set.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get ApplySet parent %s/%s: %w", set.Namespace, set.Name, err)
+	}
+	if err := checkParent(parent, set); err != nil {
+		return nil, err
+	}
+
+	alreadyDeleted := map[applySetMember]bool{}
+	for _, info := range deleted {
+		gk := info.Mapping.GroupVersionKind.GroupKind()
+		alreadyDeleted[applySetMember{gk.Group, gk.Kind, info.Namespace, info.Name}] = true
+	}
+
+	var pruned ResourceList
+	var errs []error
+	groupKinds, namespaces := parentScope(parent)
+	for _, groupKind := range sets.List(groupKinds) {
+		gk := schema.ParseGroupKind(groupKind)
+		mapping, err := a.mapper.RESTMapping(gk)
+		if meta.IsNoMatchError(err) {
+			// There can be no members of a kind which is no longer served.
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to map ApplySet group kind %s: %w", groupKind, err))
+			continue
+		}
+		scopes := []string{metav1.NamespaceNone}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			scopes = sets.List(namespaces)
+		}
+		for _, namespace := range scopes {
+			list, err := a.client.Resource(mapping.Resource).Namespace(namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: ApplySetPartOfLabel + "=" + set.ID(),
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to list %s members of ApplySet: %w", groupKind, err))
+				continue
+			}
+			for i := range list.Items {
+				u := &list.Items[i]
+				member := applySetMember{gk.Group, gk.Kind, u.GetNamespace(), u.GetName()}
+				if set.isApplied(gk, member.namespace, member.name) || alreadyDeleted[member] {
+					continue
+				}
+				if u.GetAnnotations()[ResourcePolicyAnno] == KeepPolicy {
+					slog.Debug("skipping prune due to annotation", "namespace", member.namespace, "name", member.name, "kind", gk.Kind, "annotation", ResourcePolicyAnno, "value", KeepPolicy)
+					continue
+				}
+				slog.Debug("pruning ApplySet member", "namespace", member.namespace, "name", member.name, "kind", gk.Kind)
+				policy := metav1.DeletePropagationBackground
+				err := a.client.Resource(mapping.Resource).Namespace(member.namespace).Delete(ctx, member.name, metav1.DeleteOptions{PropagationPolicy: &policy})
+				if err != nil && !apierrors.IsNotFound(err) {
+					errs = append(errs, fmt.Errorf("failed to prune resource %s: %w", member.name, err))
+					continue
+				}
+				pruned = append(pruned, &resource.Info{
+					Name:      member.name,
+					Namespace: member.namespace,
+					Mapping:   mapping,
+					Object:    u,
+				})
+			}
+		}
+	}
+	if len(errs) != 0 {
+		// The parent must keep the group kinds and namespaces of the members
+		// which could not be pruned.
+		return pruned, errors.Join(errs...)
+	}
+
+	appliedGroupKinds, appliedNamespaces := set.appliedScope()
+	return pruned, a.updateParent(ctx, set, appliedGroupKinds, appliedNamespaces, false)
+}
+
+// DeleteApplySet deletes all members of an ApplySet, except for the ones with
+// the keep resource policy, and its parent.
+func (c *Client) DeleteApplySet(set *ApplySet) (*Result, error) {
+	a, err := c.applySetClient()
+	if err != nil {
+		return nil, err
+	}
+	return a.delete(context.Background(), set)
+}
+
+func (a *applySetClient) delete(ctx context.Context, set *ApplySet) (*Result, error) {
+	empty := NewApplySet(set.Name, set.Namespace)
+	pruned, err := a.prune(ctx, empty, nil)
+	res := &Result{Deleted: pruned}
+	if err != nil {
+		return res, err
+	}
+	err = a.kubeClient.CoreV1().Secrets(set.Namespace).Delete(ctx, set.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return res, fmt.Errorf("unable to delete ApplySet parent %s/%s: %w", set.Namespace, set.Name, err)
+	}
+	return res, nil
+}
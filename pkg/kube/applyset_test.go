@@ -0,0 +1,140 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/fluxcd/cli-utils/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+func TestApplySetID(t *testing.T) {
+	set := NewApplySet(ApplySetName("web"), "default")
+	assert.Equal(t, "sh.helm.applyset.v1.web", set.Name)
+	assert.Regexp(t, regexp.MustCompile(`^applyset-[A-Za-z0-9_-]{43}-v1$`), set.ID())
+	assert.Equal(t, set.ID(), NewApplySet(ApplySetName("web"), "default").ID())
+	assert.NotEqual(t, set.ID(), NewApplySet(ApplySetName("web"), "other").ID())
+}
+
+func applySetPod(namespace, name string, labels, annotations map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("Pod")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetLabels(labels)
+	u.SetAnnotations(annotations)
+	return u
+}
+
+func TestApplySetPrune(t *testing.T) {
+	set := NewApplySet(ApplySetName("web"), "ns")
+	member := map[string]string{ApplySetPartOfLabel: set.ID()}
+
+	mapper := testutil.NewFakeRESTMapper(
+		v1.SchemeGroupVersion.WithKind("Pod"),
+		v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	)
+	podMapping, err := mapper.RESTMapping(v1.SchemeGroupVersion.WithKind("Pod").GroupKind())
+	require.NoError(t, err)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme)
+	for _, pod := range []*unstructured.Unstructured{
+		applySetPod("ns", "applied", member, nil),
+		applySetPod("ns", "removed", member, nil),
+		applySetPod("ns", "kept", member, map[string]string{ResourcePolicyAnno: KeepPolicy}),
+		applySetPod("ns", "unrelated", nil, nil),
+		applySetPod("other", "removed", member, nil),
+	} {
+		require.NoError(t, dynamicClient.Tracker().Create(podMapping.Resource, pod, pod.GetNamespace()))
+	}
+
+	// The parent records the scope of a previous apply.
+	kubeClient := fake.NewClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      set.Name,
+		Namespace: set.Namespace,
+		Labels:    map[string]string{ApplySetParentIDLabel: set.ID()},
+		Annotations: map[string]string{
+			ApplySetToolingAnnotation:              "helm/v4",
+			ApplySetGroupKindsAnnotation:           "ConfigMap,Pod,Widget.example.com",
+			ApplySetAdditionalNamespacesAnnotation: "other",
+		},
+	}})
+	a := &applySetClient{kubeClient: kubeClient, client: dynamicClient, mapper: mapper}
+
+	applied := &resource.Info{
+		Name:      "applied",
+		Namespace: "ns",
+		Mapping:   podMapping,
+		Object:    applySetPod("ns", "applied", nil, nil),
+	}
+	require.NoError(t, a.prepare(t.Context(), set, ResourceList{applied}, false))
+	assert.Equal(t, set.ID(), applied.Object.(*unstructured.Unstructured).GetLabels()[ApplySetPartOfLabel])
+
+	pruned, err := a.prune(t.Context(), set, nil)
+	require.NoError(t, err)
+	var prunedNames []string
+	for _, info := range pruned {
+		prunedNames = append(prunedNames, info.Namespace+"/"+info.Name)
+	}
+	assert.ElementsMatch(t, []string{"ns/removed", "other/removed"}, prunedNames)
+
+	remaining, err := dynamicClient.Resource(podMapping.Resource).Namespace("ns").List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	var remainingNames []string
+	for _, u := range remaining.Items {
+		remainingNames = append(remainingNames, u.GetName())
+	}
+	assert.ElementsMatch(t, []string{"applied", "kept", "unrelated"}, remainingNames)
+
+	parent, err := kubeClient.CoreV1().Secrets("ns").Get(t.Context(), set.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Pod", parent.Annotations[ApplySetGroupKindsAnnotation])
+	assert.NotContains(t, parent.Annotations, ApplySetAdditionalNamespacesAnnotation)
+
+	res, err := a.delete(t.Context(), set)
+	require.NoError(t, err)
+	require.Len(t, res.Deleted, 1)
+	assert.Equal(t, "applied", res.Deleted[0].Name)
+	_, err = kubeClient.CoreV1().Secrets("ns").Get(t.Context(), set.Name, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestApplySetForeignParent(t *testing.T) {
+	set := NewApplySet(ApplySetName("web"), "ns")
+	kubeClient := fake.NewClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:        set.Name,
+		Namespace:   set.Namespace,
+		Labels:      map[string]string{ApplySetParentIDLabel: set.ID()},
+		Annotations: map[string]string{ApplySetToolingAnnotation: "kubectl/v1.33"},
+	}})
+	a := &applySetClient{kubeClient: kubeClient}
+
+	err := a.prepare(t.Context(), set, nil, false)
+	assert.EqualError(t, err, "ApplySet "+set.ID()+" is managed by kubectl/v1.33")
+}
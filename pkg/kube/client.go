@@ -83,7 +83,10 @@ type Client struct {
 	Namespace string
 
 	Waiter
-	kubeClient kubernetes.Interface
+	// kubeClientMu guards the lazy creation of kubeClient, as the client is
+	// used concurrently, e.g. by hooks executed in parallel.
+	kubeClientMu sync.Mutex
+	kubeClient   kubernetes.Interface
 }
 
 var _ Interface = (*Client)(nil)
@@ -115,26 +118,36 @@ func init() {
 	}
 }
 
-func (c *Client) newStatusWatcher() (*statusWaiter, error) {
+// restMapper returns a RESTMapper discovering the resources of the cluster.
+func (c *Client) restMapper() (meta.RESTMapper, error) {
 	cfg, err := c.Factory.ToRESTConfig()
 	if err != nil {
 		return nil, err
 	}
+	httpClient, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return apiutil.NewDynamicRESTMapper(cfg, httpClient)
+}
+
+func (c *Client) newStatusWatcher() (*statusWaiter, error) {
 	dynamicClient, err := c.Factory.DynamicClient()
 	if err != nil {
 		return nil, err
 	}
-	httpClient, err := rest.HTTPClientFor(cfg)
+	restMapper, err := c.restMapper()
 	if err != nil {
 		return nil, err
 	}
-	restMapper, err := apiutil.NewDynamicRESTMapper(cfg, httpClient)
+	kubeClient, err := c.getKubeClient()
 	if err != nil {
 		return nil, err
 	}
 	return &statusWaiter{
 		restMapper: restMapper,
 		client:     dynamicClient,
+		diagnoser:  &diagnoser{client: kubeClient},
 	}, nil
 }
 
@@ -182,12 +195,16 @@ func New(getter genericclioptions.RESTClientGetter) *Client {
 
 // getKubeClient get or create a new KubernetesClientSet
 func (c *Client) getKubeClient() (kubernetes.Interface, error) {
-	var err error
+	c.kubeClientMu.Lock()
+	defer c.kubeClientMu.Unlock()
 	if c.kubeClient == nil {
-		c.kubeClient, err = c.Factory.KubernetesClientSet()
+		kubeClient, err := c.Factory.KubernetesClientSet()
+		if err != nil {
+			return nil, err
+		}
+		c.kubeClient = kubeClient
 	}
-
-	return c.kubeClient, err
+	return c.kubeClient, nil
 }
 
 // IsReachable tests connectivity to the cluster.
@@ -212,6 +229,7 @@ type clientCreateOptions struct {
 	forceConflicts           bool
 	dryRun                   bool
 	fieldValidationDirective FieldValidationDirective
+	applySet                 *ApplySet
 }
 
 type ClientCreateOption func(*clientCreateOptions) error
@@ -256,6 +274,17 @@ func ClientCreateOptionFieldValidationDirective(fieldValidationDirective FieldVa
 	}
 }
 
+// ClientCreateOptionApplySet labels the resources as members of the given
+// ApplySet and records their group kinds and namespaces in its parent. A nil
+// ApplySet is ignored.
+func ClientCreateOptionApplySet(set *ApplySet) ClientCreateOption {
+	return func(o *clientCreateOptions) error {
+		o.applySet = set
+
+		return nil
+	}
+}
+
 // Create creates Kubernetes resources specified in the resource list.
 func (c *Client) Create(resources ResourceList, options ...ClientCreateOption) (*Result, error) {
 	slog.Debug("creating resource(s)", "resources", len(resources))
@@ -298,10 +327,23 @@ func (c *Client) Create(resources ResourceList, options ...ClientCreateOption) (
 		return createResource
 	}
 
+	if createOptions.applySet != nil {
+		a, err := c.applySetClient()
+		if err != nil {
+			return nil, err
+		}
+		if err := a.prepare(context.Background(), createOptions.applySet, resources, createOptions.dryRun); err != nil {
+			return nil, err
+		}
+	}
+
+	warnings := &warningCollector{}
+	restore := warnings.collect(resources)
+	defer restore()
 	if err := perform(resources, makeCreateApplyFunc()); err != nil {
 		return nil, err
 	}
-	return &Result{Created: resources}, nil
+	return &Result{Created: resources, Warnings: warnings.list()}, nil
 }
 
 func transformRequests(req *rest.Request) {
@@ -591,6 +633,9 @@ type clientUpdateOptions struct {
 	dryRun                        bool
 	fieldValidationDirective      FieldValidationDirective
 	upgradeClientSideFieldManager bool
+	applySet                      *ApplySet
+	prune                         bool
+	recreateOnImmutable           bool
 }
 
 type ClientUpdateOption func(*clientUpdateOptions) error
@@ -674,6 +719,32 @@ func ClientUpdateOptionUpgradeClientSideFieldManager(upgradeClientSideFieldManag
 	}
 }
 
+// ClientUpdateOptionApplySet labels the target resources as members of the
+// given ApplySet and records their group kinds and namespaces in its parent.
+// If prune is set, members of the set in the cluster which were not applied
+// with it are deleted after the update, in addition to the original resources
+// missing from the targets. A nil ApplySet is ignored.
+func ClientUpdateOptionApplySet(set *ApplySet, prune bool) ClientUpdateOption {
+	return func(o *clientUpdateOptions) error {
+		o.applySet = set
+		o.prune = prune
+
+		return nil
+	}
+}
+
+// ClientUpdateOptionRecreateOnImmutable deletes and creates again the
+// resources whose update is rejected because an immutable field changed, such
+// as the clusterIP of a Service or the template of a Job. Without it, only the
+// resources with the recreate-on-immutable resource policy are recreated.
+func ClientUpdateOptionRecreateOnImmutable(recreateOnImmutable bool) ClientUpdateOption {
+	return func(o *clientUpdateOptions) error {
+		o.recreateOnImmutable = recreateOnImmutable
+
+		return nil
+	}
+}
+
 type UpdateApplyFunc func(original, target *resource.Info) error
 
 // Update takes the current list of objects and target list of objects and
@@ -684,6 +755,11 @@ type UpdateApplyFunc func(original, target *resource.Info) error
 // resource updates, creations, and deletions that were attempted. These can be
 // used for cleanup or other logging purposes.
 //
+// Resources whose update is rejected because an immutable field changed are
+// deleted and created again if they have the recreate-on-immutable resource
+// policy, or with ClientUpdateOptionRecreateOnImmutable. They are listed in
+// the Recreated resources of the Result.
+//
 // The default is to use server-side apply, equivalent to: `ClientUpdateOptionServerSideApply(true)`
 func (c *Client) Update(originals, targets ResourceList, options ...ClientUpdateOption) (*Result, error) {
 	updateOptions := clientUpdateOptions{
@@ -771,7 +847,39 @@ func (c *Client) Update(originals, targets ResourceList, options ...ClientUpdate
 		}
 	}
 
-	return c.update(originals, targets, makeUpdateApplyFunc())
+	warnings := &warningCollector{}
+	restore := warnings.collect(originals, targets)
+	defer restore()
+
+	var recreated ResourceList
+	updateApplyFunc := recreateOnImmutable(makeUpdateApplyFunc(), &updateOptions, &recreated)
+	res, err := c.updateApplySet(originals, targets, &updateOptions, updateApplyFunc)
+	res.Recreated = recreated
+	res.Warnings = warnings.list()
+	return res, err
+}
+
+// updateApplySet updates the resources, and maintains the ApplySet of the
+// update options, if any.
+func (c *Client) updateApplySet(originals, targets ResourceList, updateOptions *clientUpdateOptions, updateApplyFunc UpdateApplyFunc) (*Result, error) {
+	if updateOptions.applySet == nil {
+		return c.update(originals, targets, updateApplyFunc)
+	}
+
+	a, err := c.applySetClient()
+	if err != nil {
+		return &Result{}, err
+	}
+	if err := a.prepare(context.Background(), updateOptions.applySet, targets, updateOptions.dryRun); err != nil {
+		return &Result{}, err
+	}
+	res, err := c.update(originals, targets, updateApplyFunc)
+	if err != nil || !updateOptions.prune || updateOptions.dryRun {
+		return res, err
+	}
+	pruned, err := a.prune(context.Background(), updateOptions.applySet, res.Deleted)
+	res.Deleted = append(res.Deleted, pruned...)
+	return res, err
 }
 
 // Delete deletes Kubernetes resources specified in the resources list with
@@ -782,6 +890,9 @@ func (c *Client) Delete(resources ResourceList, policy metav1.DeletionPropagatio
 	var errs []error
 	res := &Result{}
 	mtx := sync.Mutex{}
+	warnings := &warningCollector{}
+	restore := warnings.collect(resources)
+	defer restore()
 	err := perform(resources, func(target *resource.Info) error {
 		slog.Debug("starting delete resource", "namespace", target.Namespace, "name", target.Name, "kind", target.Mapping.GroupVersionKind.Kind)
 		err := deleteResource(target, policy)
@@ -809,6 +920,7 @@ func (c *Client) Delete(resources ResourceList, policy metav1.DeletionPropagatio
 	if errs != nil {
 		return nil, errs
 	}
+	res.Warnings = warnings.list()
 	return res, nil
 }
 
@@ -1121,7 +1233,11 @@ func patchResourceServerSide(target *resource.Info, dryRun bool, forceConflicts
 
 // GetPodList uses the kubernetes interface to get the list of pods filtered by listOptions
 func (c *Client) GetPodList(namespace string, listOptions metav1.ListOptions) (*v1.PodList, error) {
-	podList, err := c.kubeClient.CoreV1().Pods(namespace).List(context.Background(), listOptions)
+	kubeClient, err := c.getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	podList, err := kubeClient.CoreV1().Pods(namespace).List(context.Background(), listOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pod list with options: %+v with error: %v", listOptions, err)
 	}
@@ -1130,12 +1246,16 @@ func (c *Client) GetPodList(namespace string, listOptions metav1.ListOptions) (*
 
 // OutputContainerLogsForPodList is a helper that outputs logs for a list of pods
 func (c *Client) OutputContainerLogsForPodList(podList *v1.PodList, namespace string, writerFunc func(namespace, pod, container string) io.Writer) error {
+	kubeClient, err := c.getKubeClient()
+	if err != nil {
+		return err
+	}
 	for _, pod := range podList.Items {
 		for _, container := range pod.Spec.Containers {
 			options := &v1.PodLogOptions{
 				Container: container.Name,
 			}
-			request := c.kubeClient.CoreV1().Pods(namespace).GetLogs(pod.Name, options)
+			request := kubeClient.CoreV1().Pods(namespace).GetLogs(pod.Name, options)
 			err2 := copyRequestStreamToWriter(request, pod.Name, container.Name, writerFunc(namespace, pod.Name, container.Name))
 			if err2 != nil {
 				return err2
@@ -1299,6 +1299,46 @@ func newTestClientWithDiscoveryError(t *testing.T, err error) *Client {
 	return c
 }
 
+// countingFactory counts the clientsets created by the wrapped Factory.
+type countingFactory struct {
+	Factory
+	mu    sync.Mutex
+	count int
+}
+
+func (f *countingFactory) KubernetesClientSet() (*kubernetes.Clientset, error) {
+	f.mu.Lock()
+	f.count++
+	f.mu.Unlock()
+	return f.Factory.KubernetesClientSet()
+}
+
+func TestGetKubeClientConcurrent(t *testing.T) {
+	testFactory := newTestClient(t).Factory
+	testFactory.(*cmdtesting.TestFactory).Client = &fake.RESTClient{NegotiatedSerializer: unstructuredSerializer}
+	factory := &countingFactory{Factory: testFactory}
+	c := &Client{Factory: factory}
+
+	const n = 10
+	clients := make([]kubernetes.Interface, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kubeClient, err := c.getKubeClient()
+			assert.NoError(t, err)
+			clients[i] = kubeClient
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, factory.count)
+	for _, kubeClient := range clients {
+		assert.Same(t, clients[0], kubeClient)
+	}
+}
+
 func TestIsReachable(t *testing.T) {
 	const (
 		expectedUnreachableMsg = "kubernetes cluster unreachable"
@@ -0,0 +1,122 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// ApplyCRDs creates the CRDs which do not exist yet with server-side apply.
+// If update is set, the existing CRDs are updated with server-side apply too,
+// after all of them were checked with CheckCRDUpdate. Otherwise they are left
+// unchanged.
+func (c *Client) ApplyCRDs(crds ResourceList, update, forceConflicts bool) (*Result, error) {
+	res := &Result{}
+	var apply ResourceList
+	for _, info := range crds {
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		current, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return res, fmt.Errorf("could not get information about CRD %s: %w", info.Name, err)
+			}
+			res.Created = append(res.Created, info)
+			apply = append(apply, info)
+			continue
+		}
+		if !update {
+			slog.Debug("CRD is already present. Skipping", "crd", info.Name)
+			continue
+		}
+		if err := CheckCRDUpdate(current, info.Object); err != nil {
+			return res, err
+		}
+		res.Updated = append(res.Updated, info)
+		apply = append(apply, info)
+	}
+
+	warnings := &warningCollector{}
+	restore := warnings.collect(apply)
+	defer restore()
+
+	for _, info := range apply {
+		if err := patchResourceServerSide(info, false, forceConflicts, FieldValidationDirectiveStrict); err != nil {
+			res.Warnings = warnings.list()
+			return res, fmt.Errorf("failed to apply CRD %s: %w", info.Name, err)
+		}
+		slog.Debug("applied CRD", "crd", info.Name)
+	}
+	res.Warnings = warnings.list()
+	return res, nil
+}
+
+// CheckCRDUpdate returns an error if updating a CRD in the cluster to the
+// target would remove a version which objects are still stored in, or stop
+// serving it. These are the stored versions in the status of the CRD. Objects
+// which are not v1 CRDs are not checked.
+func CheckCRDUpdate(current, target runtime.Object) error {
+	currentCRD, ok, err := asCRD(current)
+	if err != nil || !ok {
+		return err
+	}
+	targetCRD, ok, err := asCRD(target)
+	if err != nil || !ok {
+		return err
+	}
+
+	served := make(map[string]bool, len(targetCRD.Spec.Versions))
+	for _, v := range targetCRD.Spec.Versions {
+		served[v.Name] = v.Served
+	}
+	var problems []string
+	for _, v := range currentCRD.Status.StoredVersions {
+		isServed, found := served[v]
+		switch {
+		case !found:
+			problems = append(problems, fmt.Sprintf("version %s would be removed", v))
+		case !isServed:
+			problems = append(problems, fmt.Sprintf("version %s would no longer be served", v))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("refusing to update CRD %s: %s, but objects are still stored in it. Migrate the stored objects and remove the version from the stored versions of the CRD first",
+			currentCRD.Name, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// asCRD converts an object to a v1 CRD, and returns whether it is one.
+func asCRD(obj runtime.Object) (*apiextv1.CustomResourceDefinition, bool, error) {
+	if obj.GetObjectKind().GroupVersionKind() != apiextv1.SchemeGroupVersion.WithKind("CustomResourceDefinition") {
+		return nil, false, nil
+	}
+	if crd, ok := obj.(*apiextv1.CustomResourceDefinition); ok {
+		return crd, true, nil
+	}
+	crd := &apiextv1.CustomResourceDefinition{}
+	if err := kubernetesNativeScheme().Convert(obj, crd, nil); err != nil {
+		return nil, false, fmt.Errorf("unable to convert CRD: %w", err)
+	}
+	return crd, true, nil
+}
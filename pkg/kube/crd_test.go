@@ -0,0 +1,189 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest/fake"
+)
+
+var crdMapping = &meta.RESTMapping{
+	Resource:         apiextv1.SchemeGroupVersion.WithResource("customresourcedefinitions"),
+	GroupVersionKind: apiextv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"),
+	Scope:            meta.RESTScopeRoot,
+}
+
+// newTestCRD returns a CRD with the versions, given as name and whether it
+// is served, and the stored versions.
+func newTestCRD(t *testing.T, name string, versions map[string]bool, storedVersions ...string) *unstructured.Unstructured {
+	t.Helper()
+	crd := &apiextv1.CustomResourceDefinition{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     apiextv1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+	}
+	for v, served := range versions {
+		crd.Spec.Versions = append(crd.Spec.Versions, apiextv1.CustomResourceDefinitionVersion{Name: v, Served: served})
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crd)
+	require.NoError(t, err)
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func TestCheckCRDUpdate(t *testing.T) {
+	current := newTestCRD(t, "widgets.example.com", map[string]bool{"v1alpha1": true, "v1": true}, "v1alpha1", "v1")
+
+	tests := map[string]struct {
+		target   runtime.Object
+		expected string
+	}{
+		"all stored versions served": {
+			target: newTestCRD(t, "widgets.example.com", map[string]bool{"v1alpha1": true, "v1": true, "v2": true}),
+		},
+		"stored version removed": {
+			target:   newTestCRD(t, "widgets.example.com", map[string]bool{"v1": true}),
+			expected: "refusing to update CRD widgets.example.com: version v1alpha1 would be removed, but objects are still stored in it",
+		},
+		"stored version no longer served": {
+			target:   newTestCRD(t, "widgets.example.com", map[string]bool{"v1alpha1": false, "v1": true}),
+			expected: "refusing to update CRD widgets.example.com: version v1alpha1 would no longer be served, but objects are still stored in it",
+		},
+		"not a CRD": {
+			target: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := CheckCRDUpdate(current, tt.target)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestApplyCRDs(t *testing.T) {
+	existing := newTestCRD(t, "widgets.example.com", map[string]bool{"v1": true}, "v1")
+
+	tests := map[string]struct {
+		update          bool
+		targetVersions  map[string]bool
+		expectedActions []string
+		expectedCreated []string
+		expectedUpdated []string
+		expectedError   string
+	}{
+		"create": {
+			targetVersions: map[string]bool{"v1": true, "v2": true},
+			expectedActions: []string{
+				"GET /customresourcedefinitions/widgets.example.com",
+				"GET /customresourcedefinitions/gadgets.example.com",
+				"PATCH /customresourcedefinitions/gadgets.example.com",
+			},
+			expectedCreated: []string{"gadgets.example.com"},
+		},
+		"create or update": {
+			update:         true,
+			targetVersions: map[string]bool{"v1": true, "v2": true},
+			expectedActions: []string{
+				"GET /customresourcedefinitions/widgets.example.com",
+				"GET /customresourcedefinitions/gadgets.example.com",
+				"PATCH /customresourcedefinitions/widgets.example.com",
+				"PATCH /customresourcedefinitions/gadgets.example.com",
+			},
+			expectedCreated: []string{"gadgets.example.com"},
+			expectedUpdated: []string{"widgets.example.com"},
+		},
+		"refuse to stop serving a stored version": {
+			update:         true,
+			targetVersions: map[string]bool{"v1": false, "v2": true},
+			expectedActions: []string{
+				"GET /customresourcedefinitions/widgets.example.com",
+			},
+			expectedError: "refusing to update CRD widgets.example.com: version v1 would no longer be served",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var actions []string
+			client := &fake.RESTClient{
+				NegotiatedSerializer: unstructuredSerializer,
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					actions = append(actions, req.Method+" "+req.URL.Path)
+					name := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+					switch {
+					case req.Method == http.MethodPatch:
+						// Respond with the applied CRD.
+						body, err := io.ReadAll(req.Body)
+						require.NoError(t, err)
+						return newResponseJSON(http.StatusOK, body)
+					case name != "widgets.example.com":
+						return newResponse(http.StatusNotFound, notFoundBody())
+					}
+					body, err := json.Marshal(existing.Object)
+					require.NoError(t, err)
+					return newResponseJSON(http.StatusOK, body)
+				}),
+			}
+
+			var crds ResourceList
+			for _, name := range []string{"widgets.example.com", "gadgets.example.com"} {
+				crds = append(crds, &resource.Info{
+					Client:  client,
+					Mapping: crdMapping,
+					Name:    name,
+					Object:  newTestCRD(t, name, tt.targetVersions),
+				})
+			}
+
+			c := newTestClient(t)
+			result, err := c.ApplyCRDs(crds, tt.update, false)
+			assert.Equal(t, tt.expectedActions, actions)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			var created, updated []string
+			for _, info := range result.Created {
+				created = append(created, info.Name)
+			}
+			for _, info := range result.Updated {
+				updated = append(updated, info.Name)
+			}
+			assert.Equal(t, tt.expectedCreated, created)
+			assert.Equal(t, tt.expectedUpdated, updated)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// diagnosticsTimeout bounds the time spent on collecting diagnostics
+	// after a wait timed out.
+	diagnosticsTimeout = 10 * time.Second
+	// maxDiagnosedPods is the number of unready pods of a resource which are
+	// diagnosed.
+	maxDiagnosedPods = 3
+	// maxEventsPerObject is the number of most recent events reported for
+	// each object.
+	maxEventsPerObject = 5
+)
+
+// WaitTimeoutError is returned by a Waiter when resources did not become ready
+// before the timeout. Besides the error of the waiter, it carries a report of
+// why the resources are not ready.
+type WaitTimeoutError struct {
+	// Err is the error reported by the waiter. It wraps the error of the
+	// context.
+	Err error
+	// Resources are the diagnostics of the resources which are not ready.
+	Resources []ResourceDiagnostics
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *WaitTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// ResourceDiagnostics describes why a resource is not ready.
+type ResourceDiagnostics struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Status is the status of the resource, such as InProgress or Failed.
+	Status string `json:"status"`
+	// Message is the message explaining the status, if any.
+	Message string `json:"message,omitempty"`
+	// Containers are the containers of the unready pods of the resource which
+	// are waiting or were terminated.
+	Containers []ContainerDiagnostics `json:"containers,omitempty"`
+	// PendingClaims are the names of the PersistentVolumeClaims which are not
+	// bound, either the resource itself or the ones used by its pods.
+	PendingClaims []string `json:"pendingClaims,omitempty"`
+	// Events are the most recent events of the resource, of its unready pods
+	// and of its pending claims.
+	Events []EventDiagnostics `json:"events,omitempty"`
+}
+
+// ContainerDiagnostics describes the state of a container which is not
+// running.
+type ContainerDiagnostics struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	// State is "waiting", "terminated" or "last terminated".
+	State    string `json:"state"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	ExitCode int32  `json:"exitCode,omitempty"`
+}
+
+// EventDiagnostics is an event recorded for a resource.
+type EventDiagnostics struct {
+	// Kind and Name identify the object of the event.
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count,omitempty"`
+	LastSeen time.Time `json:"lastSeen,omitzero"`
+}
+
+// diagnoser collects the diagnostics of unready resources. Diagnostics are
+// collected on a best effort basis: errors are logged and the affected
+// details are left out.
+type diagnoser struct {
+	client kubernetes.Interface
+}
+
+// diagnose returns the diagnostics of a resource with the given status.
+func (d *diagnoser) diagnose(ctx context.Context, info *resource.Info, status, message string) ResourceDiagnostics {
+	diag := ResourceDiagnostics{
+		Kind:      info.Mapping.GroupVersionKind.Kind,
+		Namespace: info.Namespace,
+		Name:      info.Name,
+		Status:    status,
+		Message:   message,
+	}
+	if d == nil || d.client == nil {
+		return diag
+	}
+
+	d.addEvents(ctx, &diag, info.Namespace, diag.Kind, info.Name)
+
+	if diag.Kind == "PersistentVolumeClaim" {
+		d.addClaim(ctx, &diag, info.Namespace, info.Name)
+		return diag
+	}
+
+	for _, pod := range d.unreadyPods(ctx, info) {
+		for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+			for _, cs := range statuses {
+				diag.Containers = append(diag.Containers, containerDiagnostics(pod.Name, cs)...)
+			}
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				d.addClaim(ctx, &diag, pod.Namespace, volume.PersistentVolumeClaim.ClaimName)
+			}
+		}
+		d.addEvents(ctx, &diag, pod.Namespace, "Pod", pod.Name)
+	}
+	return diag
+}
+
+// unreadyPods returns up to maxDiagnosedPods pods of a resource which are not
+// ready.
+func (d *diagnoser) unreadyPods(ctx context.Context, info *resource.Info) []corev1.Pod {
+	var pods []corev1.Pod
+	switch value := AsVersioned(info).(type) {
+	case *corev1.Pod:
+		pod, err := d.client.CoreV1().Pods(info.Namespace).Get(ctx, info.Name, metav1.GetOptions{})
+		if err != nil {
+			slog.Debug("unable to get pod for diagnostics", "namespace", info.Namespace, "name", info.Name, slog.Any("error", err))
+			return nil
+		}
+		pods = []corev1.Pod{*pod}
+	default:
+		var selector labels.Selector
+		if job, ok := value.(*batchv1.Job); ok && job.Spec.Selector == nil {
+			// The selector of a job is usually generated by the API server.
+			selector = labels.SelectorFromSet(labels.Set{"job-name": info.Name})
+		} else {
+			var err error
+			if selector, err = SelectorsForObject(value); err != nil || selector.Empty() {
+				return nil
+			}
+		}
+		list, err := d.client.CoreV1().Pods(info.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			slog.Debug("unable to list pods for diagnostics", "resource", info.ObjectName(), slog.Any("error", err))
+			return nil
+		}
+		pods = list.Items
+	}
+
+	var unready []corev1.Pod
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || podReady(&pod) {
+			continue
+		}
+		unready = append(unready, pod)
+		if len(unready) == maxDiagnosedPods {
+			break
+		}
+	}
+	return unready
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// containerDiagnostics returns the diagnostics of a container which is waiting
+// or terminated unsuccessfully, including the reason of its last termination.
+func containerDiagnostics(pod string, cs corev1.ContainerStatus) []ContainerDiagnostics {
+	var diags []ContainerDiagnostics
+	switch {
+	case cs.State.Waiting != nil && cs.State.Waiting.Reason != "":
+		diags = append(diags, ContainerDiagnostics{
+			Pod:       pod,
+			Container: cs.Name,
+			State:     "waiting",
+			Reason:    cs.State.Waiting.Reason,
+			Message:   cs.State.Waiting.Message,
+		})
+	case cs.State.Terminated != nil && cs.State.Terminated.ExitCode != 0:
+		diags = append(diags, ContainerDiagnostics{
+			Pod:       pod,
+			Container: cs.Name,
+			State:     "terminated",
+			Reason:    cs.State.Terminated.Reason,
+			Message:   cs.State.Terminated.Message,
+			ExitCode:  cs.State.Terminated.ExitCode,
+		})
+	}
+	if last := cs.LastTerminationState.Terminated; last != nil && cs.State.Running == nil && last.ExitCode != 0 {
+		diags = append(diags, ContainerDiagnostics{
+			Pod:       pod,
+			Container: cs.Name,
+			State:     "last terminated",
+			Reason:    last.Reason,
+			Message:   last.Message,
+			ExitCode:  last.ExitCode,
+		})
+	}
+	return diags
+}
+
+// addClaim adds a PersistentVolumeClaim to the pending claims of diag if it is
+// not bound, along with its events.
+func (d *diagnoser) addClaim(ctx context.Context, diag *ResourceDiagnostics, namespace, name string) {
+	for _, pending := range diag.PendingClaims {
+		if pending == name {
+			return
+		}
+	}
+	pvc, err := d.client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		slog.Debug("unable to get persistent volume claim for diagnostics", "namespace", namespace, "name", name, slog.Any("error", err))
+		return
+	}
+	if pvc.Status.Phase == corev1.ClaimBound {
+		return
+	}
+	diag.PendingClaims = append(diag.PendingClaims, name)
+	if diag.Kind != "PersistentVolumeClaim" {
+		d.addEvents(ctx, diag, namespace, "PersistentVolumeClaim", name)
+	}
+}
+
+// addEvents adds the most recent events of an object to diag.
+func (d *diagnoser) addEvents(ctx context.Context, diag *ResourceDiagnostics, namespace, kind, name string) {
+	if namespace == "" {
+		// Events of cluster scoped objects are recorded in the default namespace.
+		namespace = metav1.NamespaceDefault
+	}
+	list, err := d.client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": kind, "involvedObject.name": name}.String(),
+	})
+	if err != nil {
+		slog.Debug("unable to list events for diagnostics", "kind", kind, "namespace", namespace, "name", name, slog.Any("error", err))
+		return
+	}
+
+	var events []EventDiagnostics
+	for _, e := range list.Items {
+		// The field selector may not be supported by every server.
+		if e.InvolvedObject.Kind != kind || e.InvolvedObject.Name != name {
+			continue
+		}
+		events = append(events, EventDiagnostics{
+			Kind:     kind,
+			Name:     name,
+			Type:     e.Type,
+			Reason:   e.Reason,
+			Message:  e.Message,
+			Count:    e.Count,
+			LastSeen: eventTime(&e),
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastSeen.Before(events[j].LastSeen)
+	})
+	if len(events) > maxEventsPerObject {
+		events = events[len(events)-maxEventsPerObject:]
+	}
+	diag.Events = append(diag.Events, events...)
+}
+
+// eventTime returns the time an event was last observed.
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const diagnosedDeploymentManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: ns
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: app
+        image: nginx
+`
+
+func diagnosedPod(name string, ready bool, status v1.ContainerStatus, claim string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{"app": "web"}},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{status},
+		},
+	}
+	if ready {
+		pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+	}
+	if claim != "" {
+		pod.Spec.Volumes = []v1.Volume{{
+			Name:         "data",
+			VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+		}}
+	}
+	return pod
+}
+
+func diagnosedEvent(name, kind, object, reason string, lastSeen time.Time) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		InvolvedObject: v1.ObjectReference{Kind: kind, Name: object, Namespace: "ns"},
+		Type:           v1.EventTypeWarning,
+		Reason:         reason,
+		Message:        reason + " of " + object,
+		Count:          2,
+		LastTimestamp:  metav1.NewTime(lastSeen),
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	now := time.Date(2025, 10, 8, 12, 0, 0, 0, time.UTC)
+	client := fake.NewClientset(
+		diagnosedPod("web-pull", false, v1.ContainerStatus{
+			Name:  "app",
+			State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: `Back-off pulling image "nginx:nope"`}},
+		}, ""),
+		diagnosedPod("web-oom", false, v1.ContainerStatus{
+			Name:                 "app",
+			State:                v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+		}, "data-web"),
+		diagnosedPod("web-ready", true, v1.ContainerStatus{
+			Name:  "app",
+			State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+		}, ""),
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-web", Namespace: "ns"},
+			Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+		},
+		diagnosedEvent("e1", "Pod", "web-pull", "Failed", now.Add(-time.Minute)),
+		diagnosedEvent("e2", "Pod", "web-pull", "BackOff", now),
+		diagnosedEvent("e3", "PersistentVolumeClaim", "data-web", "ProvisioningFailed", now),
+		diagnosedEvent("e4", "Pod", "web-ready", "Pulled", now),
+	)
+
+	c := newTestClient(t)
+	resources, err := c.Build(bytes.NewBufferString(diagnosedDeploymentManifest), false)
+	require.NoError(t, err)
+
+	d := &diagnoser{client: client}
+	diag := d.diagnose(context.Background(), resources[0], "InProgress", "Deployment does not have minimum availability.")
+
+	assert.Equal(t, "Deployment", diag.Kind)
+	assert.Equal(t, "ns", diag.Namespace)
+	assert.Equal(t, "web", diag.Name)
+	assert.Equal(t, "InProgress", diag.Status)
+	assert.Equal(t, "Deployment does not have minimum availability.", diag.Message)
+	assert.ElementsMatch(t, []ContainerDiagnostics{
+		{Pod: "web-pull", Container: "app", State: "waiting", Reason: "ImagePullBackOff", Message: `Back-off pulling image "nginx:nope"`},
+		{Pod: "web-oom", Container: "app", State: "waiting", Reason: "CrashLoopBackOff"},
+		{Pod: "web-oom", Container: "app", State: "last terminated", Reason: "OOMKilled", ExitCode: 137},
+	}, diag.Containers)
+	assert.Equal(t, []string{"data-web"}, diag.PendingClaims)
+
+	var events []string
+	for _, e := range diag.Events {
+		events = append(events, e.Kind+"/"+e.Name+" "+e.Reason)
+	}
+	assert.ElementsMatch(t, []string{
+		"Pod/web-pull Failed",
+		"Pod/web-pull BackOff",
+		"PersistentVolumeClaim/data-web ProvisioningFailed",
+	}, events)
+}
+
+func TestDiagnoseWithoutClient(t *testing.T) {
+	c := newTestClient(t)
+	resources, err := c.Build(bytes.NewBufferString(diagnosedDeploymentManifest), false)
+	require.NoError(t, err)
+
+	var d *diagnoser
+	assert.Equal(t, ResourceDiagnostics{
+		Kind:      "Deployment",
+		Namespace: "ns",
+		Name:      "web",
+		Status:    "InProgress",
+	}, d.diagnose(context.Background(), resources[0], "InProgress", ""))
+}
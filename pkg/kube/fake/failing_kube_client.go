@@ -18,7 +18,9 @@ limitations under the License.
 package fake
 
 import (
+	"context"
 	"io"
+	"slices"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -46,7 +48,14 @@ type FailingKubeClient struct {
 	WaitError              error
 	WaitForDeleteError     error
 	WatchUntilReadyError   error
+	VerifyError            error
 	WaitDuration           time.Duration
+	// Warnings are returned in the results of Create and Update.
+	Warnings []kube.Warning
+	// MissingPermissions are reported as not granted by CheckPermissions.
+	MissingPermissions []kube.Permission
+	// CheckedPermissions records the permissions passed to CheckPermissions.
+	CheckedPermissions []kube.Permission
 }
 
 var _ kube.Interface = &FailingKubeClient{}
@@ -58,6 +67,7 @@ type FailingKubeWaiter struct {
 	waitError            error
 	waitForDeleteError   error
 	watchUntilReadyError error
+	verifyError          error
 	waitDuration         time.Duration
 }
 
@@ -66,7 +76,11 @@ func (f *FailingKubeClient) Create(resources kube.ResourceList, options ...kube.
 	if f.CreateError != nil {
 		return nil, f.CreateError
 	}
-	return f.PrintingKubeClient.Create(resources, options...)
+	res, err := f.PrintingKubeClient.Create(resources, options...)
+	if res != nil {
+		res.Warnings = f.Warnings
+	}
+	return res, err
 }
 
 // Get returns the configured error if set or prints
@@ -119,12 +133,24 @@ func (f *FailingKubeWaiter) WatchUntilReady(resources kube.ResourceList, d time.
 	return f.PrintingKubeWaiter.WatchUntilReady(resources, d)
 }
 
+// Verify returns the configured error if set or prints
+func (f *FailingKubeWaiter) Verify(ctx context.Context, resources kube.ResourceList) error {
+	if f.verifyError != nil {
+		return f.verifyError
+	}
+	return f.PrintingKubeWaiter.Verify(ctx, resources)
+}
+
 // Update returns the configured error if set or prints
 func (f *FailingKubeClient) Update(r, modified kube.ResourceList, options ...kube.ClientUpdateOption) (*kube.Result, error) {
 	if f.UpdateError != nil {
 		return &kube.Result{}, f.UpdateError
 	}
-	return f.PrintingKubeClient.Update(r, modified, options...)
+	res, err := f.PrintingKubeClient.Update(r, modified, options...)
+	if res != nil {
+		res.Warnings = f.Warnings
+	}
+	return res, err
 }
 
 // Build returns the configured error if set or prints
@@ -157,10 +183,24 @@ func (f *FailingKubeClient) GetWaiter(ws kube.WaitStrategy) (kube.Waiter, error)
 		waitError:            f.WaitError,
 		waitForDeleteError:   f.WaitForDeleteError,
 		watchUntilReadyError: f.WatchUntilReadyError,
+		verifyError:          f.VerifyError,
 		waitDuration:         f.WaitDuration,
 	}, nil
 }
 
+// CheckPermissions records the permissions and returns the ones configured as
+// missing.
+func (f *FailingKubeClient) CheckPermissions(permissions []kube.Permission) ([]kube.Permission, error) {
+	f.CheckedPermissions = append(f.CheckedPermissions, permissions...)
+	var missing []kube.Permission
+	for _, p := range permissions {
+		if slices.Contains(f.MissingPermissions, p) {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
+
 func (f *FailingKubeClient) IsReachable() error {
 	if f.ConnectionError != nil {
 		return f.ConnectionError
@@ -17,6 +17,7 @@ limitations under the License.
 package fake
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -82,6 +83,13 @@ func (p *PrintingKubeWaiter) WaitForDelete(resources kube.ResourceList, _ time.D
 	return err
 }
 
+// Verify implements kube.VerifyingWaiter. It prints the resources and returns
+// immediately.
+func (p *PrintingKubeWaiter) Verify(_ context.Context, resources kube.ResourceList) error {
+	_, err := io.Copy(p.Out, bufferize(resources))
+	return err
+}
+
 // WatchUntilReady implements KubeClient WatchUntilReady.
 func (p *PrintingKubeWaiter) WatchUntilReady(resources kube.ResourceList, _ time.Duration) error {
 	_, err := io.Copy(p.Out, bufferize(resources))
@@ -121,6 +129,11 @@ func (p *PrintingKubeClient) BuildTable(_ io.Reader, _ bool) (kube.ResourceList,
 	return []*resource.Info{}, nil
 }
 
+// CheckPermissions reports all permissions as granted.
+func (p *PrintingKubeClient) CheckPermissions(_ []kube.Permission) ([]kube.Permission, error) {
+	return nil, nil
+}
+
 // WaitAndGetCompletedPodPhase implements KubeClient WaitAndGetCompletedPodPhase.
 func (p *PrintingKubeClient) WaitAndGetCompletedPodPhase(_ string, _ time.Duration) (v1.PodPhase, error) {
 	return v1.PodSucceeded, nil
@@ -0,0 +1,85 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+)
+
+// ParseServiceAccount parses a service account given as "namespace/name".
+func ParseServiceAccount(serviceAccount string) (namespace, name string, err error) {
+	namespace, name, ok := strings.Cut(serviceAccount, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("invalid service account %q: must be of the form namespace/name", serviceAccount)
+	}
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid service account %q: invalid namespace: %s", serviceAccount, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid service account %q: invalid name: %s", serviceAccount, strings.Join(errs, ", "))
+	}
+	return namespace, name, nil
+}
+
+// ServiceAccountUsername returns the username the API server authenticates a
+// service account as.
+func ServiceAccountUsername(namespace, name string) string {
+	return "system:serviceaccount:" + namespace + ":" + name
+}
+
+// AsServiceAccount returns a copy of the client making all its requests as
+// the service account given as "namespace/name". The credentials of the
+// client must allow to impersonate the service account.
+func (c *Client) AsServiceAccount(serviceAccount string) (Interface, error) {
+	namespace, name, err := ParseServiceAccount(serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+	getter, ok := c.Factory.(genericclioptions.RESTClientGetter)
+	if !ok {
+		return nil, errors.New("the client factory does not support impersonation")
+	}
+	client := New(&impersonatingGetter{
+		RESTClientGetter: getter,
+		username:         ServiceAccountUsername(namespace, name),
+	})
+	client.Namespace = c.Namespace
+	return client, nil
+}
+
+// impersonatingGetter returns REST configs impersonating a user. Discovery
+// still uses the credentials of the wrapped getter.
+type impersonatingGetter struct {
+	genericclioptions.RESTClientGetter
+	username string
+}
+
+func (g *impersonatingGetter) ToRESTConfig() (*rest.Config, error) {
+	cfg, err := g.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg = rest.CopyConfig(cfg)
+	cfg.Impersonate = rest.ImpersonationConfig{UserName: g.username}
+	return cfg, nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServiceAccount(t *testing.T) {
+	namespace, name, err := ParseServiceAccount("apps/deployer")
+	require.NoError(t, err)
+	assert.Equal(t, "apps", namespace)
+	assert.Equal(t, "deployer", name)
+
+	for _, invalid := range []string{"", "deployer", "apps/", "/deployer", "apps/deploy/er", "Apps/deployer"} {
+		_, _, err := ParseServiceAccount(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestAsServiceAccount(t *testing.T) {
+	c := newTestClient(t)
+	c.Namespace = "apps"
+
+	ic, err := c.AsServiceAccount("apps/deployer")
+	require.NoError(t, err)
+	impersonating := ic.(*Client)
+	assert.Equal(t, "apps", impersonating.Namespace)
+
+	cfg, err := impersonating.Factory.ToRESTConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:apps:deployer", cfg.Impersonate.UserName)
+
+	// The original client is unchanged.
+	cfg, err = c.Factory.ToRESTConfig()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Impersonate.UserName)
+
+	_, err = c.AsServiceAccount("deployer")
+	assert.EqualError(t, err, `invalid service account "deployer": must be of the form namespace/name`)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package kube
 
 import (
+	"context"
 	"io"
 	"time"
 
@@ -77,6 +78,46 @@ type Interface interface {
 	BuildTable(reader io.Reader, validate bool) (ResourceList, error)
 }
 
+// InterfaceApplySet is implemented by clients which manage KEP-3659 ApplySets.
+type InterfaceApplySet interface {
+	// DeleteApplySet deletes the members of an ApplySet remaining in the
+	// cluster, except for the ones with the keep resource policy, and its
+	// parent.
+	DeleteApplySet(set *ApplySet) (*Result, error)
+}
+
+var _ InterfaceApplySet = (*Client)(nil)
+
+// InterfaceServiceAccount is implemented by clients which can make requests as
+// a service account.
+type InterfaceServiceAccount interface {
+	// AsServiceAccount returns a client making requests as the service
+	// account given as "namespace/name".
+	AsServiceAccount(serviceAccount string) (Interface, error)
+}
+
+var _ InterfaceServiceAccount = (*Client)(nil)
+
+// InterfacePermissions is implemented by clients which can review the
+// permissions of their user.
+type InterfacePermissions interface {
+	// CheckPermissions returns the permissions which are not granted to the
+	// user of the client.
+	CheckPermissions(permissions []Permission) ([]Permission, error)
+}
+
+var _ InterfacePermissions = (*Client)(nil)
+
+// InterfaceCRDs is implemented by clients which can create and update CRDs.
+type InterfaceCRDs interface {
+	// ApplyCRDs creates the CRDs which do not exist yet, and updates the
+	// existing ones if update is set. CRD updates which would remove or stop
+	// serving a version still holding objects are refused.
+	ApplyCRDs(crds ResourceList, update, forceConflicts bool) (*Result, error)
+}
+
+var _ InterfaceCRDs = (*Client)(nil)
+
 // Waiter defines methods related to waiting for resource states.
 type Waiter interface {
 	// Wait waits up to the given timeout for the specified resources to be ready.
@@ -99,3 +140,38 @@ type Waiter interface {
 	// error.
 	WatchUntilReady(resources ResourceList, timeout time.Duration) error
 }
+
+// StatusUpdate reports the status of a resource observed while waiting.
+type StatusUpdate struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Kind      string `json:"kind"`
+	// Status is the kstatus status of the resource, e.g. "InProgress" or "Current".
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Desired is the status being waited for.
+	Desired string `json:"desired"`
+}
+
+// StatusObserverFunc is called with status updates of resources while waiting.
+// It may be called concurrently when several waits run at the same time.
+type StatusObserverFunc func(StatusUpdate)
+
+// ObservableWaiter is a Waiter which reports status updates of the resources
+// it waits on.
+type ObservableWaiter interface {
+	Waiter
+
+	// SetStatusObserver sets the function called with status updates.
+	SetStatusObserver(fn StatusObserverFunc)
+}
+
+// VerifyingWaiter is a Waiter which can watch ready resources for failures,
+// e.g. to verify a release for a while after it was deployed.
+type VerifyingWaiter interface {
+	Waiter
+	// Verify watches the resources until the context is done. It returns a
+	// *VerificationError as soon as one of them is no longer ready or a
+	// container of one of their pods restarts.
+	Verify(ctx context.Context, resources ResourceList) error
+}
@@ -0,0 +1,145 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// permissionCheckParallelism is the maximum number of access reviews issued
+// at a time.
+const permissionCheckParallelism = 8
+
+// Permission is the permission to perform a verb on a kind of resources.
+type Permission struct {
+	Verb      string `json:"verb"`
+	Group     string `json:"group,omitempty"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	// Name restricts the permission to a single resource. It is unset for
+	// verbs which do not apply to a single resource, such as create and list.
+	Name string `json:"name,omitempty"`
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Name != "" {
+		resource += "/" + p.Name
+	}
+	if p.Namespace == "" {
+		return p.Verb + " " + resource
+	}
+	return fmt.Sprintf("%s %s in namespace %s", p.Verb, resource, p.Namespace)
+}
+
+// ResourcePermissions returns the permissions to perform the verbs on the
+// resources.
+func ResourcePermissions(resources ResourceList, verbs ...string) []Permission {
+	var permissions []Permission
+	for _, info := range resources {
+		if info.Mapping == nil {
+			continue
+		}
+		for _, verb := range verbs {
+			p := Permission{
+				Verb:     verb,
+				Group:    info.Mapping.Resource.Group,
+				Resource: info.Mapping.Resource.Resource,
+			}
+			if info.Mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+				p.Namespace = info.Namespace
+			}
+			switch verb {
+			case "create", "list", "watch", "deletecollection":
+			default:
+				p.Name = info.Name
+			}
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+// CheckPermissions returns the permissions which are not granted to the user
+// of the client, in the order they were given.
+func (c *Client) CheckPermissions(permissions []Permission) ([]Permission, error) {
+	kubeClient, err := c.getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return CheckPermissions(context.Background(), kubeClient, permissions)
+}
+
+// CheckPermissions returns the permissions which are not granted to the user
+// of a Kubernetes client, in the order they were given. A
+// SelfSubjectAccessReview is issued for each permission.
+func CheckPermissions(ctx context.Context, client kubernetes.Interface, permissions []Permission) ([]Permission, error) {
+	allowed := make([]bool, len(permissions))
+	errs := make([]error, len(permissions))
+	sem := make(chan struct{}, permissionCheckParallelism)
+	var wg sync.WaitGroup
+	for i, p := range permissions {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			review := &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Verb:      p.Verb,
+						Group:     p.Group,
+						Resource:  p.Resource,
+						Namespace: p.Namespace,
+						Name:      p.Name,
+					},
+				},
+			}
+			result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+			if err != nil {
+				errs[i] = fmt.Errorf("unable to review permission to %s: %w", p, err)
+				return
+			}
+			allowed[i] = result.Status.Allowed
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var missing []Permission
+	for i, p := range permissions {
+		if !allowed[i] {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestResourcePermissions(t *testing.T) {
+	resources := ResourceList{
+		{
+			Name:      "web",
+			Namespace: "apps",
+			Mapping: &meta.RESTMapping{
+				Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+				Scope:    meta.RESTScopeNamespace,
+			},
+		},
+		{
+			Name: "web",
+			Mapping: &meta.RESTMapping{
+				Resource: schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+				Scope:    meta.RESTScopeRoot,
+			},
+		},
+		// Resources without a mapping are skipped.
+		{Name: "unknown"},
+	}
+
+	permissions := ResourcePermissions(resources, "create", "patch")
+	assert.Equal(t, []Permission{
+		{Verb: "create", Group: "apps", Resource: "deployments", Namespace: "apps"},
+		{Verb: "patch", Group: "apps", Resource: "deployments", Namespace: "apps", Name: "web"},
+		{Verb: "create", Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+		{Verb: "patch", Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "web"},
+	}, permissions)
+
+	assert.Equal(t, "create deployments.apps in namespace apps", permissions[0].String())
+	assert.Equal(t, "patch deployments.apps/web in namespace apps", permissions[1].String())
+	assert.Equal(t, "patch clusterroles.rbac.authorization.k8s.io/web", permissions[3].String())
+	assert.Equal(t, "list secrets in namespace apps", Permission{Verb: "list", Resource: "secrets", Namespace: "apps"}.String())
+}
+
+func TestCheckPermissions(t *testing.T) {
+	client := fake.NewClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Verb != "delete"
+		return true, review, nil
+	})
+
+	permissions := []Permission{
+		{Verb: "get", Resource: "secrets", Namespace: "apps"},
+		{Verb: "delete", Resource: "secrets", Namespace: "apps"},
+		{Verb: "create", Group: "apps", Resource: "deployments", Namespace: "apps"},
+		{Verb: "delete", Group: "apps", Resource: "deployments", Namespace: "apps", Name: "web"},
+	}
+	missing, err := CheckPermissions(t.Context(), client, permissions)
+	require.NoError(t, err)
+	assert.Equal(t, []Permission{permissions[1], permissions[3]}, missing)
+}
+
+func TestClientCheckPermissions(t *testing.T) {
+	c := newTestClient(t)
+	c.kubeClient = fake.NewClientset()
+	c.kubeClient.(*fake.Clientset).PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = true
+		return true, review, nil
+	})
+
+	missing, err := c.CheckPermissions(ResourcePermissions(ResourceList{&resource.Info{
+		Name:      "web",
+		Namespace: "apps",
+		Mapping: &meta.RESTMapping{
+			Resource: schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+			Scope:    meta.RESTScopeNamespace,
+		},
+	}}, "get", "create"))
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+}
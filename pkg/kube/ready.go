@@ -82,7 +82,22 @@ type ReadyChecker struct {
 //
 // IsReady will fetch the latest state of the object from the server prior to
 // performing readiness checks, and it will return any error encountered.
+//
+// Resources with a WaitAnnotation of "false" are always considered ready, and
+// the readiness of resources with a ReadyWhenAnnotation is determined by its
+// condition alone.
 func (c *ReadyChecker) IsReady(ctx context.Context, v *resource.Info) (bool, error) {
+	skip, cond, err := waitPolicy(v)
+	if err != nil {
+		return false, err
+	}
+	if skip {
+		return true, nil
+	}
+	if cond != nil {
+		return c.readyWhen(v, cond)
+	}
+
 	switch value := AsVersioned(v).(type) {
 	case *corev1.Pod:
 		pod, err := c.client.CoreV1().Pods(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
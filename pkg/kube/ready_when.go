@@ -0,0 +1,284 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/engine"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/event"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/statusreaders"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/watcher"
+	"github.com/fluxcd/cli-utils/pkg/object"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ReadyWhenAnnotation holds the condition a resource has to satisfy to be
+// ready. It replaces the readiness checks for the kind of the resource.
+//
+// The condition is either
+//
+//   - "condition=<type>[=<status>]", which is satisfied when the condition of
+//     the given type in .status.conditions has the given status, "True" if
+//     omitted, or
+//   - a JSONPath expression followed by "=<value>" or "!=<value>", such as
+//     "{.status.phase}=Running". Without a value, it is satisfied when the
+//     expression yields a value other than "" or "false".
+const ReadyWhenAnnotation = "helm.sh/ready-when"
+
+// WaitAnnotation excludes a resource from waiting when set to "false".
+const WaitAnnotation = "helm.sh/wait"
+
+// readyCondition is a parsed ReadyWhenAnnotation.
+type readyCondition struct {
+	expr string
+	path *jsonpath.JSONPath
+	// value is the value the path has to yield, if hasValue is set.
+	value    string
+	hasValue bool
+	negate   bool
+}
+
+// waitPolicy returns whether a resource is excluded from waiting, and the
+// condition for it to be ready, if it has one.
+func waitPolicy(info *resource.Info) (bool, *readyCondition, error) {
+	accessor, err := meta.Accessor(info.Object)
+	if err != nil {
+		return false, nil, nil
+	}
+	annotations := accessor.GetAnnotations()
+	if value, ok := annotations[WaitAnnotation]; ok {
+		wait, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return false, nil, fmt.Errorf("invalid %s annotation %q on %s: must be a boolean", WaitAnnotation, value, info.ObjectName())
+		}
+		if !wait {
+			return true, nil, nil
+		}
+	}
+	value, ok := annotations[ReadyWhenAnnotation]
+	if !ok {
+		return false, nil, nil
+	}
+	cond, err := parseReadyCondition(value)
+	if err != nil {
+		return false, nil, fmt.Errorf("invalid %s annotation %q on %s: %w", ReadyWhenAnnotation, value, info.ObjectName(), err)
+	}
+	return false, cond, nil
+}
+
+func parseReadyCondition(expr string) (*readyCondition, error) {
+	expr = strings.TrimSpace(expr)
+	cond := &readyCondition{expr: expr}
+
+	var path string
+	if conditionType, ok := strings.CutPrefix(expr, "condition="); ok {
+		conditionType, conditionStatus, found := strings.Cut(conditionType, "=")
+		if conditionType == "" {
+			return nil, fmt.Errorf("missing condition type")
+		}
+		if !found {
+			conditionStatus = "True"
+		}
+		path = fmt.Sprintf(`{.status.conditions[?(@.type=="%s")].status}`, conditionType)
+		cond.value, cond.hasValue = conditionStatus, true
+	} else {
+		end := strings.LastIndex(expr, "}")
+		if !strings.HasPrefix(expr, "{") || end < 0 {
+			return nil, fmt.Errorf("must be condition=<type>[=<status>] or a JSONPath expression in braces")
+		}
+		path = expr[:end+1]
+		switch rest := expr[end+1:]; {
+		case rest == "":
+		case strings.HasPrefix(rest, "!="):
+			cond.value, cond.hasValue, cond.negate = rest[2:], true, true
+		case strings.HasPrefix(rest, "="):
+			cond.value, cond.hasValue = strings.TrimPrefix(rest[1:], "="), true
+		default:
+			return nil, fmt.Errorf("unexpected %q after JSONPath expression", rest)
+		}
+	}
+
+	cond.path = jsonpath.New(ReadyWhenAnnotation).AllowMissingKeys(true)
+	if err := cond.path.Parse(path); err != nil {
+		return nil, err
+	}
+	return cond, nil
+}
+
+// satisfied evaluates the condition against a live object. It returns the
+// values yielded by the expression as well.
+func (c *readyCondition) satisfied(obj map[string]interface{}) (bool, string, error) {
+	results, err := c.path.FindResults(obj)
+	if err != nil {
+		return false, "", err
+	}
+	var values []string
+	for _, result := range results {
+		for _, v := range result {
+			var buf bytes.Buffer
+			if err := c.path.PrintResults(&buf, []reflect.Value{v}); err != nil {
+				return false, "", err
+			}
+			values = append(values, buf.String())
+		}
+	}
+	got := strings.Join(values, " ")
+
+	if !c.hasValue {
+		for _, v := range values {
+			if v != "" && v != "false" {
+				return true, got, nil
+			}
+		}
+		return false, got, nil
+	}
+	match := len(values) > 0
+	for _, v := range values {
+		if v != c.value {
+			match = false
+		}
+	}
+	return match != c.negate, got, nil
+}
+
+// status computes the kstatus result of a live object from the condition.
+func (c *readyCondition) status(u *unstructured.Unstructured) (*status.Result, error) {
+	ok, got, err := c.satisfied(u.Object)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &status.Result{
+			Status:  status.CurrentStatus,
+			Message: fmt.Sprintf("Resource satisfies %s", c.expr),
+		}, nil
+	}
+	message := fmt.Sprintf("Waiting for %s", c.expr)
+	if got != "" {
+		message += fmt.Sprintf(", got %q", got)
+	}
+	return &status.Result{
+		Status:  status.InProgressStatus,
+		Message: message,
+	}, nil
+}
+
+// readyWhenStatusReader computes the status of resources with a
+// ReadyWhenAnnotation from their condition, and of all other resources with
+// the delegate.
+type readyWhenStatusReader struct {
+	delegate   engine.StatusReader
+	mapper     meta.RESTMapper
+	conditions map[object.ObjMetadata]*readyCondition
+}
+
+var _ engine.StatusReader = (*readyWhenStatusReader)(nil)
+
+func (r *readyWhenStatusReader) Supports(schema.GroupKind) bool {
+	return true
+}
+
+func (r *readyWhenStatusReader) ReadStatus(ctx context.Context, reader engine.ClusterReader, id object.ObjMetadata) (*event.ResourceStatus, error) {
+	if cond, ok := r.conditions[id]; ok {
+		return statusreaders.NewGenericStatusReader(r.mapper, cond.status).ReadStatus(ctx, reader, id)
+	}
+	return r.delegate.ReadStatus(ctx, reader, id)
+}
+
+func (r *readyWhenStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, u *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	if cond, ok := r.conditions[object.UnstructuredToObjMetadata(u)]; ok {
+		return statusreaders.NewGenericStatusReader(r.mapper, cond.status).ReadStatusForObject(ctx, reader, u)
+	}
+	return r.delegate.ReadStatusForObject(ctx, reader, u)
+}
+
+// watchedResources returns the resources of the list to wait for. Resources
+// excluded by WaitAnnotation and paused Deployments are left out. The status of
+// resources with a ReadyWhenAnnotation is computed from their condition by sw.
+func watchedResources(resourceList ResourceList, sw *watcher.DefaultStatusWatcher, mapper meta.RESTMapper) ([]object.ObjMetadata, map[object.ObjMetadata]*resource.Info, error) {
+	resources := []object.ObjMetadata{}
+	infos := map[object.ObjMetadata]*resource.Info{}
+	conditions := map[object.ObjMetadata]*readyCondition{}
+	for _, info := range resourceList {
+		skip, cond, err := waitPolicy(info)
+		if err != nil {
+			return nil, nil, err
+		}
+		if skip {
+			continue
+		}
+		if value, ok := AsVersioned(info).(*appsv1.Deployment); ok && value.Spec.Paused {
+			continue
+		}
+		obj, err := object.RuntimeToObjMeta(info.Object)
+		if err != nil {
+			return nil, nil, err
+		}
+		resources = append(resources, obj)
+		infos[obj] = info
+		if cond != nil {
+			conditions[obj] = cond
+		}
+	}
+	if len(conditions) > 0 {
+		sw.StatusReader = &readyWhenStatusReader{
+			delegate:   sw.StatusReader,
+			mapper:     mapper,
+			conditions: conditions,
+		}
+	}
+	return resources, infos, nil
+}
+
+// readyWhen checks the ReadyWhenAnnotation condition of a resource against
+// its live object.
+func (c *ReadyChecker) readyWhen(v *resource.Info, cond *readyCondition) (bool, error) {
+	obj, err := resource.NewHelper(v.Client, v.Mapping).Get(v.Namespace, v.Name)
+	if err != nil {
+		return false, err
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return false, err
+		}
+		u = &unstructured.Unstructured{Object: content}
+	}
+	ready, got, err := cond.satisfied(u.Object)
+	if err != nil {
+		return false, err
+	}
+	if !ready {
+		slog.Debug("resource is not ready", "resource", v.ObjectName(), "readyWhen", cond.expr, "got", got)
+	}
+	return ready, nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluxcd/cli-utils/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+func TestReadyCondition(t *testing.T) {
+	obj := map[string]interface{}{
+		"status": map[string]interface{}{
+			"phase": "Running",
+			"ready": true,
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+				map[string]interface{}{"type": "Degraded", "status": "False"},
+			},
+		},
+	}
+
+	tests := []struct {
+		expr      string
+		want      bool
+		expectErr string
+	}{
+		{expr: "condition=Ready", want: true},
+		{expr: "condition=Ready=True", want: true},
+		{expr: "condition=Degraded", want: false},
+		{expr: "condition=Degraded=False", want: true},
+		{expr: "condition=Synced", want: false},
+		{expr: "{.status.phase}=Running", want: true},
+		{expr: "{.status.phase}==Running", want: true},
+		{expr: "{.status.phase}=Pending", want: false},
+		{expr: "{.status.phase}!=Failed", want: true},
+		{expr: "{.status.phase}!=Running", want: false},
+		{expr: "{.status.ready}", want: true},
+		{expr: "{.status.missing}", want: false},
+		{expr: "{.status.missing}!=Failed", want: true},
+		{expr: "condition=", expectErr: "missing condition type"},
+		{expr: ".status.phase", expectErr: "must be condition=<type>[=<status>] or a JSONPath expression in braces"},
+		{expr: "{.status.phase}>1", expectErr: `unexpected ">1" after JSONPath expression`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cond, err := parseReadyCondition(tt.expr)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			got, _, err := cond.satisfied(obj)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWaitPolicy(t *testing.T) {
+	info := func(annotations map[string]string) *resource.Info {
+		return &resource.Info{
+			Name:   "foo",
+			Object: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Annotations: annotations}},
+		}
+	}
+
+	skip, cond, err := waitPolicy(info(nil))
+	require.NoError(t, err)
+	assert.False(t, skip)
+	assert.Nil(t, cond)
+
+	skip, _, err = waitPolicy(info(map[string]string{WaitAnnotation: "false", ReadyWhenAnnotation: "condition=Ready"}))
+	require.NoError(t, err)
+	assert.True(t, skip)
+
+	skip, cond, err = waitPolicy(info(map[string]string{WaitAnnotation: "true", ReadyWhenAnnotation: "condition=Ready"}))
+	require.NoError(t, err)
+	assert.False(t, skip)
+	assert.NotNil(t, cond)
+
+	_, _, err = waitPolicy(info(map[string]string{WaitAnnotation: "nope"}))
+	assert.EqualError(t, err, `invalid helm.sh/wait annotation "nope" on /foo: must be a boolean`)
+
+	_, _, err = waitPolicy(info(map[string]string{ReadyWhenAnnotation: "condition="}))
+	assert.EqualError(t, err, `invalid helm.sh/ready-when annotation "condition=" on /foo: missing condition type`)
+}
+
+func TestReadyCheckerWaitPolicy(t *testing.T) {
+	c := NewReadyChecker(fake.NewClientset())
+
+	// The pod does not exist, it must not be checked.
+	ready, err := c.IsReady(t.Context(), &resource.Info{
+		Name:      "foo",
+		Namespace: defaultNamespace,
+		Object: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Annotations: map[string]string{WaitAnnotation: "false"},
+		}},
+	})
+	require.NoError(t, err)
+	assert.True(t, ready)
+
+	_, err = c.IsReady(t.Context(), &resource.Info{
+		Name:      "foo",
+		Namespace: defaultNamespace,
+		Object: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Annotations: map[string]string{ReadyWhenAnnotation: "status.phase"},
+		}},
+	})
+	assert.ErrorContains(t, err, "invalid helm.sh/ready-when annotation")
+}
+
+var podSkippedManifest = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: skipped-pod
+  namespace: ns
+  annotations:
+    helm.sh/wait: "false"
+`
+
+var jobReadyWhenManifest = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: ready-when
+  namespace: ns
+  generation: 1
+  annotations:
+    helm.sh/ready-when: "{.status.active}=1"
+status:
+  active: 1
+`
+
+var podReadyWhenPendingManifest = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: ready-when-pod
+  namespace: ns
+  annotations:
+    helm.sh/ready-when: "condition=Initialized"
+status:
+  conditions:
+  - type: Ready
+    status: "True"
+  phase: Running
+`
+
+func TestStatusWaitReadyWhen(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		objManifests []string
+		expectErr    string
+	}{
+		{
+			name:         "resource excluded from waiting",
+			objManifests: []string{podSkippedManifest, podCurrentManifest},
+		},
+		{
+			name:         "condition replaces the readiness of the kind",
+			objManifests: []string{jobReadyWhenManifest},
+		},
+		{
+			name:         "condition is not satisfied",
+			objManifests: []string{podReadyWhenPendingManifest},
+			expectErr:    "resource not ready, name: ready-when-pod, kind: Pod, status: InProgress",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newTestClient(t)
+			fakeClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme)
+			fakeMapper := testutil.NewFakeRESTMapper(
+				corev1.SchemeGroupVersion.WithKind("Pod"),
+				batchv1.SchemeGroupVersion.WithKind("Job"),
+			)
+			statusWaiter := statusWaiter{
+				client:     fakeClient,
+				restMapper: fakeMapper,
+			}
+			objs := getRuntimeObjFromManifests(t, tt.objManifests)
+			for _, obj := range objs {
+				u := obj.(*unstructured.Unstructured)
+				require.NoError(t, fakeClient.Tracker().Create(getGVR(t, fakeMapper, u), u, u.GetNamespace()))
+			}
+			resourceList := getResourceListFromRuntimeObjs(t, c, objs)
+			err := statusWaiter.WaitWithJobs(resourceList, time.Second)
+			if tt.expectErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.expectErr)
+		})
+	}
+}
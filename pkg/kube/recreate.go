@@ -0,0 +1,137 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// immutableFieldMessage is the message of the API server for a change of an
+// immutable field.
+const immutableFieldMessage = "field is immutable"
+
+var (
+	// recreatePollInterval is the interval at which a resource being
+	// recreated is checked for its deletion.
+	recreatePollInterval = time.Second
+	// recreateDeletionTimeout is the maximum time to wait for the deletion of
+	// a resource being recreated.
+	recreateDeletionTimeout = 2 * time.Minute
+)
+
+// isImmutableFieldError returns whether an error is the rejection of a change
+// of an immutable field by the API server.
+func isImmutableFieldError(err error) bool {
+	if !apierrors.IsInvalid(err) {
+		return false
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return false
+	}
+	s := status.Status()
+	if s.Details != nil {
+		for _, cause := range s.Details.Causes {
+			if strings.Contains(cause.Message, immutableFieldMessage) {
+				return true
+			}
+		}
+	}
+	return strings.Contains(s.Message, immutableFieldMessage)
+}
+
+// hasRecreateOnImmutablePolicy returns whether the manifest of a resource
+// has the recreate-on-immutable resource policy.
+func hasRecreateOnImmutablePolicy(info *resource.Info) bool {
+	annotations, err := metadataAccessor.Annotations(info.Object)
+	if err != nil {
+		slog.Debug("unable to get annotations", "namespace", info.Namespace, "name", info.Name, "kind", info.Mapping.GroupVersionKind.Kind, slog.Any("error", err))
+		return false
+	}
+	return annotations[ResourcePolicyAnno] == RecreateOnImmutablePolicy
+}
+
+// recreateOnImmutable wraps an UpdateApplyFunc to delete and create again the
+// resources whose update fails because an immutable field changed, if
+// enabled for all resources or by their resource policy. The recreated
+// resources are appended to recreated.
+func recreateOnImmutable(updateApplyFunc UpdateApplyFunc, updateOptions *clientUpdateOptions, recreated *ResourceList) UpdateApplyFunc {
+	return func(original, target *resource.Info) error {
+		err := updateApplyFunc(original, target)
+		if err == nil || !isImmutableFieldError(err) {
+			return err
+		}
+		if !updateOptions.recreateOnImmutable && !hasRecreateOnImmutablePolicy(target) {
+			return err
+		}
+
+		kind := target.Mapping.GroupVersionKind.Kind
+		if updateOptions.dryRun {
+			slog.Debug("skipping recreate of resource in dry run", "namespace", target.Namespace, "name", target.Name, "kind", kind, slog.Any("error", err))
+			*recreated = append(*recreated, target)
+			return nil
+		}
+
+		slog.Debug("recreating resource since an immutable field changed", "namespace", target.Namespace, "name", target.Name, "kind", kind, slog.Any("error", err))
+		create := createResource
+		if updateOptions.serverSideApply {
+			create = func(info *resource.Info) error {
+				return patchResourceServerSide(info, false, updateOptions.forceConflicts, updateOptions.fieldValidationDirective)
+			}
+		}
+		if err := recreateResource(target, create); err != nil {
+			return fmt.Errorf("failed to recreate %s %q after an immutable field changed: %w", kind, target.Name, err)
+		}
+		*recreated = append(*recreated, target)
+		return nil
+	}
+}
+
+// recreateResource deletes a resource, waits until it is gone, and creates it
+// again from the target.
+func recreateResource(target *resource.Info, create func(*resource.Info) error) error {
+	if err := deleteResource(target, metav1.DeletePropagationBackground); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete resource: %w", err)
+	}
+
+	helper := resource.NewHelper(target.Client, target.Mapping)
+	err := wait.PollUntilContextTimeout(context.Background(), recreatePollInterval, recreateDeletionTimeout, true, func(_ context.Context) (bool, error) {
+		_, err := helper.Get(target.Namespace, target.Name)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wait for the deletion of the resource: %w", err)
+	}
+
+	if err := create(target); err != nil {
+		return fmt.Errorf("failed to create resource: %w", err)
+	}
+	return nil
+}
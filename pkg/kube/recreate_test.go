@@ -0,0 +1,221 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func newImmutableFieldError(name string) *apierrors.StatusError {
+	return apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, name, field.ErrorList{
+		field.Invalid(field.NewPath("spec", "containers"), "app:v5", "field is immutable"),
+	})
+}
+
+func TestIsImmutableFieldError(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"immutable field": {
+			err:      newImmutableFieldError("starfish"),
+			expected: true,
+		},
+		"wrapped immutable field": {
+			err:      fmt.Errorf("cannot patch %q with kind Pod: %w", "starfish", newImmutableFieldError("starfish")),
+			expected: true,
+		},
+		"other invalid field": {
+			err: apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "starfish", field.ErrorList{
+				field.Required(field.NewPath("spec", "containers"), ""),
+			}),
+			expected: false,
+		},
+		"conflict": {
+			err:      apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "starfish", errors.New("field is immutable")),
+			expected: false,
+		},
+		"plain error": {
+			err:      errors.New("field is immutable"),
+			expected: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isImmutableFieldError(tt.err))
+		})
+	}
+}
+
+func TestUpdateRecreateOnImmutable(t *testing.T) {
+	recreatePollInterval = time.Millisecond
+	t.Cleanup(func() { recreatePollInterval = time.Second })
+
+	tests := map[string]struct {
+		option          bool
+		policy          string
+		serverSideApply bool
+		dryRun          bool
+		expectedActions []string
+		expectedError   string
+	}{
+		"recreated with option": {
+			option: true,
+			expectedActions: []string{
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:PATCH",
+				"/namespaces/default/pods/starfish:DELETE",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods:POST",
+			},
+		},
+		"recreated with resource policy": {
+			policy: RecreateOnImmutablePolicy,
+			expectedActions: []string{
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:PATCH",
+				"/namespaces/default/pods/starfish:DELETE",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods:POST",
+			},
+		},
+		"recreated with server-side apply": {
+			option:          true,
+			serverSideApply: true,
+			expectedActions: []string{
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:PATCH",
+				"/namespaces/default/pods/starfish:DELETE",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:PATCH",
+			},
+		},
+		"not recreated in dry run": {
+			option:          true,
+			serverSideApply: true,
+			dryRun:          true,
+			expectedActions: []string{
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:PATCH",
+			},
+		},
+		"not recreated without option or resource policy": {
+			policy: KeepPolicy,
+			expectedActions: []string{
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:GET",
+				"/namespaces/default/pods/starfish:PATCH",
+			},
+			expectedError: "field is immutable",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			original := newPodList("starfish")
+			target := newPodList("starfish")
+			target.Items[0].Spec.Containers[0].Image = "app:v5"
+			if tt.policy != "" {
+				target.Items[0].Annotations = map[string]string{ResourcePolicyAnno: tt.policy}
+			}
+
+			deleted := false
+			gets := 0
+			patches := 0
+			cb := func(_ []RequestResponseAction, req *http.Request) (*http.Response, error) {
+				p, m := req.URL.Path, req.Method
+				switch {
+				case p == "/namespaces/default/pods/starfish" && m == http.MethodGet:
+					if !deleted {
+						return newResponse(http.StatusOK, &original.Items[0])
+					}
+					// The pod is gone on the second check after its deletion.
+					gets++
+					if gets > 1 {
+						return newResponse(http.StatusNotFound, notFoundBody())
+					}
+					return newResponse(http.StatusOK, &original.Items[0])
+				case p == "/namespaces/default/pods/starfish" && m == http.MethodPatch:
+					patches++
+					if patches > 1 {
+						return newResponse(http.StatusCreated, &target.Items[0])
+					}
+					return newResponse(http.StatusUnprocessableEntity, &newImmutableFieldError("starfish").ErrStatus)
+				case p == "/namespaces/default/pods/starfish" && m == http.MethodDelete:
+					deleted = true
+					return newResponse(http.StatusOK, &original.Items[0])
+				case p == "/namespaces/default/pods" && m == http.MethodPost:
+					return newResponse(http.StatusCreated, &target.Items[0])
+				}
+				t.Errorf("unexpected request: %s %s", m, p)
+				return newResponse(http.StatusInternalServerError, &v1.Pod{})
+			}
+
+			client := NewRequestResponseLogClient(t, cb)
+			c := newTestClient(t)
+			c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+				NegotiatedSerializer: unstructuredSerializer,
+				Client:               fake.CreateHTTPClient(client.Do),
+			}
+
+			originals, err := c.Build(objBody(&original), false)
+			require.NoError(t, err)
+			targets, err := c.Build(objBody(&target), false)
+			require.NoError(t, err)
+
+			result, err := c.Update(
+				originals,
+				targets,
+				ClientUpdateOptionServerSideApply(tt.serverSideApply, false),
+				ClientUpdateOptionDryRun(tt.dryRun),
+				ClientUpdateOptionRecreateOnImmutable(tt.option))
+
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				assert.Empty(t, result.Recreated)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, result.Recreated, 1)
+				assert.Equal(t, "starfish", result.Recreated[0].Name)
+			}
+
+			actions := []string{}
+			for _, action := range client.Actions {
+				actions = append(actions, action.Request.URL.Path+":"+action.Request.Method)
+			}
+			assert.Equal(t, tt.expectedActions, actions)
+		})
+	}
+}
@@ -25,3 +25,11 @@ const ResourcePolicyAnno = "helm.sh/resource-policy"
 //
 //	during an uninstallRelease action.
 const KeepPolicy = "keep"
+
+// RecreateOnImmutablePolicy is the resource policy type for recreating a
+// resource when an update fails because an immutable field changed.
+//
+// This resource policy type makes Helm delete the resource and create it
+// again from the new manifest, e.g. when the selector of a Deployment or the
+// template of a Job changes.
+const RecreateOnImmutablePolicy = "recreate-on-immutable"
@@ -23,6 +23,11 @@ type Result struct {
 	Created ResourceList
 	Updated ResourceList
 	Deleted ResourceList
+	// Recreated are the updated resources which were deleted and created
+	// again, since an immutable field changed.
+	Recreated ResourceList
+	// Warnings are the warnings returned by the API server for the resources.
+	Warnings []Warning
 }
 
 // If needed, we can add methods to the Result type for things like diffing
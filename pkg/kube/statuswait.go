@@ -32,7 +32,6 @@ import (
 	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
 	"github.com/fluxcd/cli-utils/pkg/kstatus/watcher"
 	"github.com/fluxcd/cli-utils/pkg/object"
-	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/dynamic"
@@ -43,6 +42,17 @@ import (
 type statusWaiter struct {
 	client     dynamic.Interface
 	restMapper meta.RESTMapper
+	observer   StatusObserverFunc
+	// diagnoser explains why resources are not ready when waiting times out.
+	diagnoser *diagnoser
+}
+
+var _ ObservableWaiter = (*statusWaiter)(nil)
+
+// SetStatusObserver sets a function called with every status update of the
+// resources being waited on.
+func (w *statusWaiter) SetStatusObserver(fn StatusObserverFunc) {
+	w.observer = fn
 }
 
 func alwaysReady(_ *unstructured.Unstructured) (*status.Result, error) {
@@ -113,7 +123,7 @@ func (w *statusWaiter) waitForDelete(ctx context.Context, resourceList ResourceL
 	}
 	eventCh := sw.Watch(cancelCtx, resources, watcher.Options{})
 	statusCollector := collector.NewResourceStatusCollector(resources)
-	done := statusCollector.ListenWithObserver(eventCh, statusObserver(cancel, status.NotFoundStatus))
+	done := statusCollector.ListenWithObserver(eventCh, statusObserver(cancel, status.NotFoundStatus, w.observer))
 	<-done
 
 	if statusCollector.Error != nil {
@@ -136,27 +146,17 @@ func (w *statusWaiter) waitForDelete(ctx context.Context, resourceList ResourceL
 	return nil
 }
 
-func (w *statusWaiter) wait(ctx context.Context, resourceList ResourceList, sw watcher.StatusWatcher) error {
+func (w *statusWaiter) wait(ctx context.Context, resourceList ResourceList, sw *watcher.DefaultStatusWatcher) error {
 	cancelCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	resources := []object.ObjMetadata{}
-	for _, resource := range resourceList {
-		switch value := AsVersioned(resource).(type) {
-		case *appsv1.Deployment:
-			if value.Spec.Paused {
-				continue
-			}
-		}
-		obj, err := object.RuntimeToObjMeta(resource.Object)
-		if err != nil {
-			return err
-		}
-		resources = append(resources, obj)
+	resources, infos, err := watchedResources(resourceList, sw, w.restMapper)
+	if err != nil {
+		return err
 	}
 
 	eventCh := sw.Watch(cancelCtx, resources, watcher.Options{})
 	statusCollector := collector.NewResourceStatusCollector(resources)
-	done := statusCollector.ListenWithObserver(eventCh, statusObserver(cancel, status.CurrentStatus))
+	done := statusCollector.ListenWithObserver(eventCh, statusObserver(cancel, status.CurrentStatus, w.observer))
 	<-done
 
 	if statusCollector.Error != nil {
@@ -165,22 +165,37 @@ func (w *statusWaiter) wait(ctx context.Context, resourceList ResourceList, sw w
 
 	// Only check parent context error, otherwise we would error when desired status is achieved.
 	if ctx.Err() != nil {
+		diagCtx, cancelDiag := context.WithTimeout(context.Background(), diagnosticsTimeout)
+		defer cancelDiag()
 		errs := []error{}
+		var diagnostics []ResourceDiagnostics
 		for _, id := range resources {
 			rs := statusCollector.ResourceStatuses[id]
 			if rs.Status == status.CurrentStatus {
 				continue
 			}
 			errs = append(errs, fmt.Errorf("resource not ready, name: %s, kind: %s, status: %s", rs.Identifier.Name, rs.Identifier.GroupKind.Kind, rs.Status))
+			diagnostics = append(diagnostics, w.diagnoser.diagnose(diagCtx, infos[id], rs.Status.String(), rs.Message))
 		}
 		errs = append(errs, ctx.Err())
-		return errors.Join(errs...)
+		return &WaitTimeoutError{Err: errors.Join(errs...), Resources: diagnostics}
 	}
 	return nil
 }
 
-func statusObserver(cancel context.CancelFunc, desired status.Status) collector.ObserverFunc {
-	return func(statusCollector *collector.ResourceStatusCollector, _ event.Event) {
+func statusObserver(cancel context.CancelFunc, desired status.Status, observer StatusObserverFunc) collector.ObserverFunc {
+	return func(statusCollector *collector.ResourceStatusCollector, e event.Event) {
+		if observer != nil && e.Type == event.ResourceUpdateEvent && e.Resource != nil {
+			observer(StatusUpdate{
+				Name:      e.Resource.Identifier.Name,
+				Namespace: e.Resource.Identifier.Namespace,
+				Kind:      e.Resource.Identifier.GroupKind.Kind,
+				Status:    e.Resource.Status.String(),
+				Message:   e.Resource.Message,
+				Desired:   desired.String(),
+			})
+		}
+
 		var rss []*event.ResourceStatus
 		var nonDesiredResources []*event.ResourceStatus
 		for _, rs := range statusCollector.ResourceStatuses {
@@ -218,6 +233,14 @@ type hookOnlyWaiter struct {
 	sw *statusWaiter
 }
 
+var _ ObservableWaiter = (*hookOnlyWaiter)(nil)
+
+// SetStatusObserver sets a function called with every status update of the
+// hook resources being waited on.
+func (w *hookOnlyWaiter) SetStatusObserver(fn StatusObserverFunc) {
+	w.sw.SetStatusObserver(fn)
+}
+
 func (w *hookOnlyWaiter) WatchUntilReady(resourceList ResourceList, timeout time.Duration) error {
 	return w.sw.WatchUntilReady(resourceList, timeout)
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package kube // import "helm.sh/helm/v3/pkg/kube"
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -330,6 +331,11 @@ func TestStatusWait(t *testing.T) {
 			err := statusWaiter.Wait(resourceList, time.Second*3)
 			if tt.expectErrs != nil {
 				assert.EqualError(t, err, errors.Join(tt.expectErrs...).Error())
+				var timeoutErr *WaitTimeoutError
+				if assert.ErrorAs(t, err, &timeoutErr) {
+					assert.Len(t, timeoutErr.Resources, len(tt.expectErrs)-1)
+				}
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
 				return
 			}
 			assert.NoError(t, err)
@@ -448,3 +454,35 @@ func TestWatchForReady(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusWaitObserver(t *testing.T) {
+	t.Parallel()
+	c := newTestClient(t)
+	fakeClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme)
+	fakeMapper := testutil.NewFakeRESTMapper(
+		v1.SchemeGroupVersion.WithKind("Pod"),
+	)
+	var updates []StatusUpdate
+	statusWaiter := statusWaiter{
+		restMapper: fakeMapper,
+		client:     fakeClient,
+	}
+	statusWaiter.SetStatusObserver(func(u StatusUpdate) {
+		updates = append(updates, u)
+	})
+	objs := getRuntimeObjFromManifests(t, []string{podCurrentManifest})
+	for _, obj := range objs {
+		u := obj.(*unstructured.Unstructured)
+		gvr := getGVR(t, fakeMapper, u)
+		assert.NoError(t, fakeClient.Tracker().Create(gvr, u, u.GetNamespace()))
+	}
+	resourceList := getResourceListFromRuntimeObjs(t, c, objs)
+	assert.NoError(t, statusWaiter.Wait(resourceList, time.Second*3))
+
+	assert.NotEmpty(t, updates)
+	last := updates[len(updates)-1]
+	assert.Equal(t, "current-pod", last.Name)
+	assert.Equal(t, "Pod", last.Kind)
+	assert.Equal(t, "Current", last.Status)
+	assert.Equal(t, "Current", last.Desired)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/event"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/watcher"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// verifyPollInterval is the interval in which the restart counts of pods are
+// checked during verification.
+var verifyPollInterval = time.Second
+
+var (
+	_ VerifyingWaiter = (*statusWaiter)(nil)
+	_ VerifyingWaiter = (*hookOnlyWaiter)(nil)
+)
+
+// VerificationError is returned by Verify when a resource fails during the
+// verification window.
+type VerificationError struct {
+	// Kind, Namespace and Name identify the failed resource.
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string
+}
+
+func (e *VerificationError) Error() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("%s %s %s", e.Kind, e.Name, e.Reason)
+	}
+	return fmt.Sprintf("%s %s/%s %s", e.Kind, e.Namespace, e.Name, e.Reason)
+}
+
+// Verify watches ready resources until the context is done. It returns a
+// *VerificationError as soon as one of them is no longer ready, or a
+// container of a pod of one of them restarts. It returns nil when the
+// context is done.
+func (w *statusWaiter) Verify(ctx context.Context, resourceList ResourceList) error {
+	cancelCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	slog.Debug("verifying resources", "count", len(resourceList))
+
+	sw := watcher.NewDefaultStatusWatcher(w.client, w.restMapper)
+	resources, _, err := watchedResources(resourceList, sw, w.restMapper)
+	if err != nil {
+		return err
+	}
+
+	baseline, err := w.podRestarts(cancelCtx, resourceList)
+	if err != nil {
+		return err
+	}
+
+	eventCh := sw.Watch(cancelCtx, resources, watcher.Options{})
+	ticker := time.NewTicker(verifyPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-eventCh:
+			if !ok {
+				return ctx.Err()
+			}
+			switch e.Type {
+			case event.ErrorEvent:
+				return e.Error
+			case event.ResourceUpdateEvent:
+				if e.Resource == nil {
+					continue
+				}
+				if w.observer != nil {
+					w.observer(StatusUpdate{
+						Name:      e.Resource.Identifier.Name,
+						Namespace: e.Resource.Identifier.Namespace,
+						Kind:      e.Resource.Identifier.GroupKind.Kind,
+						Status:    e.Resource.Status.String(),
+						Message:   e.Resource.Message,
+						Desired:   status.CurrentStatus.String(),
+					})
+				}
+				if e.Resource.Status != status.CurrentStatus {
+					reason := fmt.Sprintf("is no longer ready: %s", e.Resource.Status)
+					if e.Resource.Message != "" {
+						reason += ": " + e.Resource.Message
+					}
+					return &VerificationError{
+						Kind:      e.Resource.Identifier.GroupKind.Kind,
+						Namespace: e.Resource.Identifier.Namespace,
+						Name:      e.Resource.Identifier.Name,
+						Reason:    reason,
+					}
+				}
+			}
+		case <-ticker.C:
+			restarts, err := w.podRestarts(cancelCtx, resourceList)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			for key, count := range restarts {
+				if count > baseline[key] {
+					return &VerificationError{
+						Kind:      "Pod",
+						Namespace: key.namespace,
+						Name:      key.pod,
+						Reason:    fmt.Sprintf("container %s restarted %d time(s)", key.container, count-baseline[key]),
+					}
+				}
+			}
+		}
+	}
+}
+
+type containerKey struct {
+	namespace, pod, container string
+}
+
+// podRestarts returns the restart counts of the containers of the pods in the
+// list and of the pods selected by workloads in the list.
+func (w *statusWaiter) podRestarts(ctx context.Context, resourceList ResourceList) (map[containerKey]int32, error) {
+	restarts := map[containerKey]int32{}
+	add := func(pod *v1.Pod) {
+		for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+			for _, cs := range statuses {
+				restarts[containerKey{pod.Namespace, pod.Name, cs.Name}] = cs.RestartCount
+			}
+		}
+	}
+	podsGVR := v1.SchemeGroupVersion.WithResource("pods")
+	for _, info := range resourceList {
+		var selector *metav1.LabelSelector
+		switch value := AsVersioned(info).(type) {
+		case *v1.Pod:
+			u, err := w.client.Resource(podsGVR).Namespace(info.Namespace).Get(ctx, info.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("unable to get pod %s/%s: %w", info.Namespace, info.Name, err)
+			}
+			pod := &v1.Pod{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pod); err != nil {
+				return nil, err
+			}
+			add(pod)
+			continue
+		case *appsv1.Deployment:
+			selector = value.Spec.Selector
+		case *appsv1.StatefulSet:
+			selector = value.Spec.Selector
+		case *appsv1.DaemonSet:
+			selector = value.Spec.Selector
+		case *appsv1.ReplicaSet:
+			selector = value.Spec.Selector
+		case *batchv1.Job:
+			selector = value.Spec.Selector
+		}
+		if selector == nil {
+			continue
+		}
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		if sel.Empty() {
+			continue
+		}
+		list, err := w.client.Resource(podsGVR).Namespace(info.Namespace).List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list pods of %s: %w", info.ObjectName(), err)
+		}
+		for _, u := range list.Items {
+			pod := &v1.Pod{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pod); err != nil {
+				return nil, err
+			}
+			add(pod)
+		}
+	}
+	return restarts, nil
+}
+
+// Verify forwards to the status watcher; verification is not limited to hooks.
+func (w *hookOnlyWaiter) Verify(ctx context.Context, resourceList ResourceList) error {
+	return w.sw.Verify(ctx, resourceList)
+}
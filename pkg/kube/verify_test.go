@@ -0,0 +1,122 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/cli-utils/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+var podRunningManifest = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: ns
+spec:
+  containers:
+  - name: app
+    image: nginx
+status:
+  conditions:
+  - type: Ready
+    status: "True"
+  phase: Running
+  containerStatuses:
+  - name: app
+    restartCount: 2
+`
+
+func TestStatusWaitVerify(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		update    func(u *unstructured.Unstructured)
+		expectErr string
+	}{
+		{
+			name: "pod stays ready",
+		},
+		{
+			name: "container restarts",
+			update: func(u *unstructured.Unstructured) {
+				statuses, _, _ := unstructured.NestedSlice(u.Object, "status", "containerStatuses")
+				statuses[0].(map[string]interface{})["restartCount"] = int64(3)
+				assert.NoError(t, unstructured.SetNestedSlice(u.Object, statuses, "status", "containerStatuses"))
+			},
+			expectErr: "Pod ns/web container app restarted 1 time(s)",
+		},
+		{
+			name: "pod becomes unready",
+			update: func(u *unstructured.Unstructured) {
+				assert.NoError(t, unstructured.SetNestedSlice(u.Object, []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "False"},
+				}, "status", "conditions"))
+			},
+			expectErr: "Pod ns/web is no longer ready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newTestClient(t)
+			fakeClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme)
+			fakeMapper := testutil.NewFakeRESTMapper(
+				v1.SchemeGroupVersion.WithKind("Pod"),
+			)
+			statusWaiter := statusWaiter{
+				restMapper: fakeMapper,
+				client:     fakeClient,
+			}
+			objs := getRuntimeObjFromManifests(t, []string{podRunningManifest})
+			u := objs[0].(*unstructured.Unstructured)
+			gvr := getGVR(t, fakeMapper, u)
+			require.NoError(t, fakeClient.Tracker().Create(gvr, u, u.GetNamespace()))
+			resourceList := getResourceListFromRuntimeObjs(t, c, objs)
+
+			if tt.update != nil {
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					updated := u.DeepCopy()
+					tt.update(updated)
+					assert.NoError(t, fakeClient.Tracker().Update(gvr, updated, updated.GetNamespace()))
+				}()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			err := statusWaiter.Verify(ctx, resourceList)
+			if tt.expectErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var verr *VerificationError
+			require.ErrorAs(t, err, &verr)
+			assert.Contains(t, err.Error(), tt.expectErr)
+		})
+	}
+}
@@ -69,12 +69,19 @@ func (hw *legacyWaiter) waitForResources(created ResourceList, timeout time.Dura
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	// Invalid wait annotations are not worth retrying.
+	for _, v := range created {
+		if _, _, err := waitPolicy(v); err != nil {
+			return err
+		}
+	}
+
 	numberOfErrors := make([]int, len(created))
 	for i := range numberOfErrors {
 		numberOfErrors[i] = 0
 	}
 
-	return wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
 		waitRetries := 30
 		for i, v := range created {
 			ready, err := hw.c.IsReady(ctx, v)
@@ -95,6 +102,28 @@ func (hw *legacyWaiter) waitForResources(created ResourceList, timeout time.Dura
 		}
 		return true, nil
 	})
+	if err != nil && ctx.Err() != nil {
+		return &WaitTimeoutError{Err: err, Resources: hw.diagnose(created)}
+	}
+	return err
+}
+
+// diagnose returns the diagnostics of the resources which are not ready.
+func (hw *legacyWaiter) diagnose(resources ResourceList) []ResourceDiagnostics {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+	d := &diagnoser{}
+	if hw.kubeClient != nil {
+		d.client = hw.kubeClient
+	}
+	var diagnostics []ResourceDiagnostics
+	for _, v := range resources {
+		if ready, _ := hw.c.IsReady(ctx, v); ready {
+			continue
+		}
+		diagnostics = append(diagnostics, d.diagnose(ctx, v, "NotReady", ""))
+	}
+	return diagnostics
 }
 
 func (hw *legacyWaiter) isRetryableError(err error, resource *resource.Info) bool {
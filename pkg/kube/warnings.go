@@ -0,0 +1,159 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v4/pkg/kube"
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+)
+
+// Warning is a warning returned by the API server for a request about a
+// resource, such as the use of a deprecated API or a warning of an admission
+// webhook.
+type Warning struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Message   string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Namespace == "" {
+		return fmt.Sprintf("%s %s: %s", w.Kind, w.Name, w.Message)
+	}
+	return fmt.Sprintf("%s %s/%s: %s", w.Kind, w.Namespace, w.Name, w.Message)
+}
+
+// warningCollector collects the warnings returned by the API server for
+// requests about resources.
+type warningCollector struct {
+	mu       sync.Mutex
+	warnings []Warning
+	seen     map[Warning]bool
+}
+
+// collect installs a rest.WarningHandler collecting the warnings of the
+// requests about the resources in the lists, until the returned function is
+// called.
+func (w *warningCollector) collect(lists ...ResourceList) (restore func()) {
+	var wrapped []*resource.Info
+	for _, list := range lists {
+		for _, info := range list {
+			if info.Client == nil {
+				continue
+			}
+			if _, ok := info.Client.(*warningRESTClient); ok {
+				continue
+			}
+			info.Client = &warningRESTClient{
+				RESTClient: info.Client,
+				handler:    &resourceWarningHandler{collector: w, info: info},
+			}
+			wrapped = append(wrapped, info)
+		}
+	}
+	return func() {
+		for _, info := range wrapped {
+			if c, ok := info.Client.(*warningRESTClient); ok {
+				info.Client = c.RESTClient
+			}
+		}
+	}
+}
+
+func (w *warningCollector) add(warning Warning) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.seen == nil {
+		w.seen = map[Warning]bool{}
+	}
+	if w.seen[warning] {
+		return
+	}
+	w.seen[warning] = true
+	w.warnings = append(w.warnings, warning)
+}
+
+// list returns the collected warnings in the order they were received.
+func (w *warningCollector) list() []Warning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Warning(nil), w.warnings...)
+}
+
+// warningRESTClient sets a warning handler on all requests.
+type warningRESTClient struct {
+	resource.RESTClient
+	handler rest.WarningHandler
+}
+
+func (c *warningRESTClient) Get() *rest.Request {
+	return c.RESTClient.Get().WarningHandler(c.handler)
+}
+
+func (c *warningRESTClient) Post() *rest.Request {
+	return c.RESTClient.Post().WarningHandler(c.handler)
+}
+
+func (c *warningRESTClient) Patch(pt types.PatchType) *rest.Request {
+	return c.RESTClient.Patch(pt).WarningHandler(c.handler)
+}
+
+func (c *warningRESTClient) Delete() *rest.Request {
+	return c.RESTClient.Delete().WarningHandler(c.handler)
+}
+
+func (c *warningRESTClient) Put() *rest.Request {
+	return c.RESTClient.Put().WarningHandler(c.handler)
+}
+
+// resourceWarningHandler records warnings for a resource.
+type resourceWarningHandler struct {
+	collector *warningCollector
+	info      *resource.Info
+}
+
+func (h *resourceWarningHandler) HandleWarningHeader(code int, _ string, message string) {
+	// Warnings of the API server use the code 299, see
+	// https://kubernetes.io/blog/2020/09/03/warnings/
+	if code != 299 || message == "" {
+		return
+	}
+	warning := Warning{
+		Kind:      resourceKind(h.info),
+		Namespace: h.info.Namespace,
+		Name:      h.info.Name,
+		Message:   message,
+	}
+	slog.Debug("warning from the API server", "kind", warning.Kind, "namespace", warning.Namespace, "name", warning.Name, "warning", message)
+	h.collector.add(warning)
+}
+
+func resourceKind(info *resource.Info) string {
+	if info.Mapping != nil {
+		return info.Mapping.GroupVersionKind.Kind
+	}
+	if info.Object != nil {
+		return info.Object.GetObjectKind().GroupVersionKind().Kind
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestWarnings(t *testing.T) {
+	pods := newPodList("starfish")
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			resp, err := newResponse(http.StatusOK, &pods.Items[0])
+			resp.Header.Add("Warning", `299 - "spec.privileged is deprecated"`)
+			resp.Header.Add("Warning", `299 - "spec.privileged is deprecated"`)
+			resp.Header.Add("Warning", `299 - "admission webhook says hello"`)
+			resp.Request = req
+			return resp, err
+		}),
+	}
+
+	list, err := c.Build(objBody(&pods), false)
+	require.NoError(t, err)
+	client := list[0].Client
+
+	expected := []Warning{
+		{Kind: "Pod", Namespace: "default", Name: "starfish", Message: "spec.privileged is deprecated"},
+		{Kind: "Pod", Namespace: "default", Name: "starfish", Message: "admission webhook says hello"},
+	}
+
+	result, err := c.Create(list)
+	require.NoError(t, err)
+	assert.Equal(t, expected, result.Warnings)
+	assert.Equal(t, "Pod default/starfish: spec.privileged is deprecated", result.Warnings[0].String())
+	// The warning handler is only installed for the operation.
+	assert.Same(t, client, list[0].Client)
+
+	result, errs := c.Delete(list, metav1.DeletePropagationBackground)
+	require.Empty(t, errs)
+	assert.Equal(t, expected, result.Warnings)
+}
+
+func TestWarningCollectorSkipsInfosWithoutClient(t *testing.T) {
+	w := &warningCollector{}
+	info := &resource.Info{Name: "foo"}
+	restore := w.collect(ResourceList{info})
+	assert.Nil(t, info.Client)
+	restore()
+	assert.Empty(t, w.list())
+}
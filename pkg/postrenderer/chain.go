@@ -0,0 +1,184 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrenderer
+
+import (
+	"bytes"
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// FilenameAnnotation is the annotation Helm sets on each rendered document to
+// record the template it was rendered from, so that post-rendered documents
+// can be attributed to their templates.
+const FilenameAnnotation = "postrenderer.helm.sh/postrender-filename"
+
+// StageError is returned by a chain of post-renderers when one of them fails.
+type StageError struct {
+	// Stage is the 1-based position of the failed post-renderer in the chain.
+	Stage int
+	// Stages is the number of post-renderers in the chain.
+	Stages int
+	// Name describes the failed post-renderer, if it implements fmt.Stringer.
+	Name string
+	Err  error
+}
+
+func (e *StageError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("post-renderer %d of %d (%s) failed: %s", e.Stage, e.Stages, e.Name, e.Err)
+	}
+	return fmt.Sprintf("post-renderer %d of %d failed: %s", e.Stage, e.Stages, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// NewChain returns a PostRenderer which runs the given post-renderers in
+// order, each on the output of the previous one.
+//
+// Post-renderers may drop the FilenameAnnotation of documents they rewrite.
+// The chain restores it on documents of the same kind and name as a document
+// of the input of the stage, so later stages and Helm itself can still
+// attribute them to their templates. Documents whose namespace was changed by
+// the stage are matched by kind and name alone, as long as that is unambiguous.
+func NewChain(renderers ...PostRenderer) PostRenderer {
+	if len(renderers) == 1 {
+		return renderers[0]
+	}
+	return chain(renderers)
+}
+
+type chain []PostRenderer
+
+func (c chain) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	current := renderedManifests
+	for i, r := range c {
+		filenames, err := newDocumentFilenames(current)
+		if err != nil {
+			return nil, c.stageError(i, err)
+		}
+		out, err := r.Run(current)
+		if err != nil {
+			return nil, c.stageError(i, err)
+		}
+		if current, err = restoreFilenames(out, filenames); err != nil {
+			return nil, c.stageError(i, err)
+		}
+	}
+	return current, nil
+}
+
+func (c chain) stageError(i int, err error) error {
+	e := &StageError{Stage: i + 1, Stages: len(c), Err: err}
+	if s, ok := c[i].(fmt.Stringer); ok {
+		e.Name = s.String()
+	}
+	return e
+}
+
+// documentID identifies a document across post-rendering stages.
+type documentID struct {
+	apiVersion, kind, namespace, name string
+}
+
+func newDocumentID(node *kyaml.RNode) documentID {
+	return documentID{
+		apiVersion: node.GetApiVersion(),
+		kind:       node.GetKind(),
+		namespace:  node.GetNamespace(),
+		name:       node.GetName(),
+	}
+}
+
+// documentFilenames maps documents to the templates they were rendered from.
+type documentFilenames struct {
+	byID map[documentID]string
+	// byName ignores namespaces. Names shared by several documents map to
+	// an empty filename.
+	byName map[documentID]string
+}
+
+func newDocumentFilenames(manifests *bytes.Buffer) (*documentFilenames, error) {
+	nodes, err := kio.FromBytes(manifests.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse manifests: %w", err)
+	}
+	f := &documentFilenames{
+		byID:   make(map[documentID]string, len(nodes)),
+		byName: make(map[documentID]string, len(nodes)),
+	}
+	for _, node := range nodes {
+		fname := node.GetAnnotations(FilenameAnnotation)[FilenameAnnotation]
+		if fname == "" {
+			continue
+		}
+		id := newDocumentID(node)
+		f.byID[id] = fname
+		id.namespace = ""
+		if _, ok := f.byName[id]; ok {
+			f.byName[id] = ""
+		} else {
+			f.byName[id] = fname
+		}
+	}
+	return f, nil
+}
+
+// lookup returns the template filename of a document.
+func (f *documentFilenames) lookup(node *kyaml.RNode) string {
+	id := newDocumentID(node)
+	if fname, ok := f.byID[id]; ok {
+		return fname
+	}
+	id.namespace = ""
+	return f.byName[id]
+}
+
+// restoreFilenames sets the FilenameAnnotation on output documents which lost
+// it. Documents which do not match an input document are left as is.
+func restoreFilenames(manifests *bytes.Buffer, filenames *documentFilenames) (*bytes.Buffer, error) {
+	nodes, err := kio.FromBytes(manifests.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse output: %w", err)
+	}
+	restored := false
+	for _, node := range nodes {
+		if node.GetAnnotations(FilenameAnnotation)[FilenameAnnotation] != "" {
+			continue
+		}
+		fname := filenames.lookup(node)
+		if fname == "" {
+			continue
+		}
+		if err := node.PipeE(kyaml.SetAnnotation(FilenameAnnotation, fname)); err != nil {
+			return nil, err
+		}
+		restored = true
+	}
+	if !restored {
+		return manifests, nil
+	}
+	out, err := kio.StringAll(nodes)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBufferString(out), nil
+}
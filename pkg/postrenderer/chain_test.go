@@ -0,0 +1,108 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrenderer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type postRendererFunc func(*bytes.Buffer) (*bytes.Buffer, error)
+
+func (f postRendererFunc) Run(in *bytes.Buffer) (*bytes.Buffer, error) {
+	return f(in)
+}
+
+// replacer is a post-renderer replacing old with new in the manifests.
+type replacer struct {
+	old, new string
+}
+
+func replacing(old, new string) PostRenderer {
+	return &replacer{old: old, new: new}
+}
+
+func (r *replacer) Run(in *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(strings.ReplaceAll(in.String(), r.old, r.new)), nil
+}
+
+func (r *replacer) String() string {
+	return "replace " + r.old
+}
+
+const chainInput = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: default
+  annotations:
+    postrenderer.helm.sh/postrender-filename: templates/configmap.yaml
+data:
+  image: nginx
+`
+
+func TestChainRunsInOrder(t *testing.T) {
+	c := NewChain(
+		replacing("image: nginx", "image: nginx:1.27"),
+		replacing("nginx:1.27", "registry.example.com/nginx:1.27"),
+	)
+	out, err := c.Run(bytes.NewBufferString(chainInput))
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "image: registry.example.com/nginx:1.27")
+}
+
+func TestChainRestoresFilenameAnnotation(t *testing.T) {
+	dropAnnotation := replacing("  annotations:\n    postrenderer.helm.sh/postrender-filename: templates/configmap.yaml\n", "")
+	moveNamespace := replacing("namespace: default", "namespace: production")
+
+	c := NewChain(dropAnnotation, moveNamespace, dropAnnotation)
+	out, err := c.Run(bytes.NewBufferString(chainInput))
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "namespace: production")
+	assert.Contains(t, out.String(), "postrenderer.helm.sh/postrender-filename: 'templates/configmap.yaml'")
+}
+
+func TestChainReportsFailedStage(t *testing.T) {
+	failing := postRendererFunc(func(*bytes.Buffer) (*bytes.Buffer, error) {
+		return nil, errors.New("policy violation")
+	})
+	c := NewChain(replacing("nginx", "httpd"), failing, replacing("httpd", "caddy"))
+
+	_, err := c.Run(bytes.NewBufferString(chainInput))
+	var stageErr *StageError
+	require.ErrorAs(t, err, &stageErr)
+	assert.Equal(t, 2, stageErr.Stage)
+	assert.Equal(t, 3, stageErr.Stages)
+	assert.EqualError(t, err, "post-renderer 2 of 3 failed: policy violation")
+}
+
+func TestChainReportsFailedStageName(t *testing.T) {
+	c := NewChain(replacing("nginx", "httpd"), replacing("kind: ConfigMap", "kind: [unterminated"), replacing("httpd", "caddy"))
+
+	_, err := c.Run(bytes.NewBufferString(chainInput))
+	assert.ErrorContains(t, err, "post-renderer 2 of 3 (replace kind: ConfigMap) failed: unable to parse output")
+}
+
+func TestNewChainSingle(t *testing.T) {
+	r := replacing("a", "b")
+	assert.Equal(t, r, NewChain(r))
+}
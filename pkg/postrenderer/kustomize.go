@@ -0,0 +1,563 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrenderer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+	"sigs.k8s.io/yaml"
+)
+
+// kustomizationFileNames are the names of a kustomization file, in the order
+// they are looked up in a kustomization directory.
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// kustomization is the subset of a kustomize Kustomization which is applied
+// by the kustomize post-renderer. Other fields, such as resources and
+// generators, are rejected as the rendered manifests are the only resources of
+// the kustomization.
+type kustomization struct {
+	APIVersion            string            `json:"apiVersion,omitempty"`
+	Kind                  string            `json:"kind,omitempty"`
+	Namespace             string            `json:"namespace,omitempty"`
+	CommonLabels          map[string]string `json:"commonLabels,omitempty"`
+	Labels                []kustomizeLabel  `json:"labels,omitempty"`
+	CommonAnnotations     map[string]string `json:"commonAnnotations,omitempty"`
+	Images                []kustomizeImage  `json:"images,omitempty"`
+	Patches               []kustomizePatch  `json:"patches,omitempty"`
+	PatchesStrategicMerge []string          `json:"patchesStrategicMerge,omitempty"`
+	PatchesJSON6902       []kustomizePatch  `json:"patchesJson6902,omitempty"`
+}
+
+type kustomizeLabel struct {
+	Pairs            map[string]string `json:"pairs,omitempty"`
+	IncludeSelectors bool              `json:"includeSelectors,omitempty"`
+	IncludeTemplates bool              `json:"includeTemplates,omitempty"`
+}
+
+type kustomizeImage struct {
+	Name    string `json:"name"`
+	NewName string `json:"newName,omitempty"`
+	NewTag  string `json:"newTag,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+type kustomizePatch struct {
+	Path   string             `json:"path,omitempty"`
+	Patch  string             `json:"patch,omitempty"`
+	Target *kustomizeSelector `json:"target,omitempty"`
+}
+
+type kustomizeSelector struct {
+	Group              string `json:"group,omitempty"`
+	Version            string `json:"version,omitempty"`
+	Kind               string `json:"kind,omitempty"`
+	Name               string `json:"name,omitempty"`
+	Namespace          string `json:"namespace,omitempty"`
+	LabelSelector      string `json:"labelSelector,omitempty"`
+	AnnotationSelector string `json:"annotationSelector,omitempty"`
+}
+
+// NewPostRendererKustomize returns a PostRenderer which applies the
+// kustomization in dir to the rendered manifests.
+//
+// The rendered manifests take the place of the resources of the
+// kustomization. Namespace, labels, annotations, images and patches, both
+// strategic merge and JSON 6902, are applied in the order kustomize applies
+// them. Patch files must be located within dir.
+func NewPostRendererKustomize(dir string) (PostRenderer, error) {
+	var data []byte
+	var fname string
+	for _, name := range kustomizationFileNames {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, fname = b, name
+		break
+	}
+	if fname == "" {
+		return nil, fmt.Errorf("no kustomization file found in %s, expected one of %s", dir, strings.Join(kustomizationFileNames, ", "))
+	}
+
+	k := &kustomization{}
+	if err := yaml.UnmarshalStrict(data, k); err != nil {
+		return nil, fmt.Errorf("invalid or unsupported kustomization %s: %w", filepath.Join(dir, fname), err)
+	}
+
+	r := &kustomizeRenderer{dir: dir, kustomization: k}
+	for _, img := range k.Images {
+		if img.Name == "" {
+			return nil, fmt.Errorf("kustomization %s: image without a name", filepath.Join(dir, fname))
+		}
+	}
+
+	patches := make([]kustomizePatch, 0, len(k.PatchesStrategicMerge)+len(k.Patches)+len(k.PatchesJSON6902))
+	for _, p := range k.PatchesStrategicMerge {
+		// Entries of patchesStrategicMerge are either file names or inline patches.
+		if strings.Contains(p, "\n") {
+			patches = append(patches, kustomizePatch{Patch: p})
+		} else {
+			patches = append(patches, kustomizePatch{Path: p})
+		}
+	}
+	patches = append(patches, k.Patches...)
+	for _, p := range k.PatchesJSON6902 {
+		if p.Target == nil {
+			return nil, fmt.Errorf("kustomization %s: patchesJson6902 entry without a target", filepath.Join(dir, fname))
+		}
+		patches = append(patches, p)
+	}
+	for _, p := range patches {
+		loaded, err := r.loadPatch(p)
+		if err != nil {
+			return nil, err
+		}
+		r.patches = append(r.patches, loaded...)
+	}
+	return r, nil
+}
+
+type kustomizeRenderer struct {
+	dir           string
+	kustomization *kustomization
+	patches       []*loadedPatch
+}
+
+// loadedPatch is a single patch document and the resources it applies to.
+type loadedPatch struct {
+	source string
+	target *resourceSelector
+	// Exactly one of strategicMerge and json6902 is set.
+	strategicMerge *kyaml.RNode
+	json6902       jsonpatch.Patch
+}
+
+func (r *kustomizeRenderer) loadPatch(p kustomizePatch) ([]*loadedPatch, error) {
+	if (p.Path == "") == (p.Patch == "") {
+		return nil, errors.New("kustomization patches must set exactly one of path and patch")
+	}
+	source, content := "inline patch", p.Patch
+	if p.Path != "" {
+		path := filepath.Join(r.dir, p.Path)
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("patch %s is not located within the kustomization directory %s", p.Path, r.dir)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		source, content = p.Path, string(b)
+	}
+
+	var target *resourceSelector
+	if p.Target != nil {
+		var err error
+		if target, err = newResourceSelector(p.Target); err != nil {
+			return nil, fmt.Errorf("invalid target of %s: %w", source, err)
+		}
+	}
+
+	// A patch which is a list of operations is a JSON 6902 patch, anything
+	// else is a strategic merge patch.
+	if node, err := kyaml.Parse(content); err == nil && node.YNode().Kind == kyaml.SequenceNode {
+		if target == nil {
+			return nil, fmt.Errorf("JSON 6902 patch %s requires a target", source)
+		}
+		j, err := yaml.YAMLToJSON([]byte(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON 6902 patch %s: %w", source, err)
+		}
+		ops, err := jsonpatch.DecodePatch(j)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON 6902 patch %s: %w", source, err)
+		}
+		return []*loadedPatch{{source: source, target: target, json6902: ops}}, nil
+	}
+
+	docs, err := kio.FromBytes([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid strategic merge patch %s: %w", source, err)
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("strategic merge patch %s is empty", source)
+	}
+	patches := make([]*loadedPatch, 0, len(docs))
+	for _, doc := range docs {
+		t := target
+		if t == nil {
+			t = selectorForPatch(doc)
+		}
+		patches = append(patches, &loadedPatch{source: source, target: t, strategicMerge: doc})
+	}
+	return patches, nil
+}
+
+func (r *kustomizeRenderer) String() string {
+	return fmt.Sprintf("kustomize %q", r.dir)
+}
+
+// Run applies the kustomization to the rendered manifests.
+func (r *kustomizeRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	nodes, err := kio.FromBytes(renderedManifests.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("kustomize post-renderer: unable to parse rendered manifests: %w", err)
+	}
+
+	for _, p := range r.patches {
+		if nodes, err = p.apply(nodes); err != nil {
+			return nil, fmt.Errorf("kustomize post-renderer: unable to apply patch %s: %w", p.source, err)
+		}
+	}
+
+	k := r.kustomization
+	for _, node := range nodes {
+		if k.Namespace != "" && isNamespaced(node) {
+			if err := node.SetNamespace(k.Namespace); err != nil {
+				return nil, err
+			}
+		}
+		if len(k.CommonLabels) > 0 {
+			if err := setLabels(node, k.CommonLabels, true, true); err != nil {
+				return nil, err
+			}
+		}
+		for _, l := range k.Labels {
+			if err := setLabels(node, l.Pairs, l.IncludeSelectors, l.IncludeSelectors || l.IncludeTemplates); err != nil {
+				return nil, err
+			}
+		}
+		if len(k.CommonAnnotations) > 0 {
+			if err := setAnnotations(node, k.CommonAnnotations); err != nil {
+				return nil, err
+			}
+		}
+		for _, img := range k.Images {
+			setImage(node.YNode(), img)
+		}
+	}
+
+	out, err := kio.StringAll(nodes)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize post-renderer: %w", err)
+	}
+	return bytes.NewBufferString(out), nil
+}
+
+// apply applies the patch to the matching resources. Strategic merge patches
+// which do not name a target must match at least one resource.
+func (p *loadedPatch) apply(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
+	result := make([]*kyaml.RNode, 0, len(nodes))
+	matched := false
+	for _, node := range nodes {
+		ok, err := p.target.matches(node)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			result = append(result, node)
+			continue
+		}
+		matched = true
+
+		if p.json6902 != nil {
+			j, err := node.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			if j, err = p.json6902.Apply(j); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", node.GetKind(), node.GetName(), err)
+			}
+			patched, err := kyaml.ConvertJSONToYamlNode(string(j))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, patched)
+			continue
+		}
+
+		if isDeletePatch(p.strategicMerge) {
+			continue
+		}
+		patch := p.strategicMerge.Copy()
+		// A patch applied through a target applies to resources of any name.
+		if err := patch.SetName(node.GetName()); err != nil {
+			return nil, err
+		}
+		merged, err := merge2.Merge(patch, node, kyaml.MergeOptions{ListIncreaseDirection: kyaml.MergeOptionsListAppend})
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", node.GetKind(), node.GetName(), err)
+		}
+		if merged != nil {
+			result = append(result, merged)
+		}
+	}
+	if !matched && p.strategicMerge != nil && p.target.fromPatch {
+		return nil, fmt.Errorf("no resource matches %s %s", p.strategicMerge.GetKind(), p.strategicMerge.GetName())
+	}
+	return result, nil
+}
+
+// isDeletePatch reports whether a strategic merge patch deletes the resource.
+func isDeletePatch(patch *kyaml.RNode) bool {
+	f := patch.Field("$patch")
+	return f != nil && f.Value.YNode().Value == "delete"
+}
+
+// resourceSelector selects the resources a patch applies to.
+type resourceSelector struct {
+	group, version, kind, name, namespace *regexp.Regexp
+	labelSelector, annotationSelector     string
+	// fromPatch is set if the selector was derived from the patch itself.
+	fromPatch bool
+}
+
+func newResourceSelector(s *kustomizeSelector) (*resourceSelector, error) {
+	sel := &resourceSelector{
+		labelSelector:      s.LabelSelector,
+		annotationSelector: s.AnnotationSelector,
+	}
+	for _, f := range []struct {
+		pattern string
+		re      **regexp.Regexp
+	}{
+		{s.Group, &sel.group},
+		{s.Version, &sel.version},
+		{s.Kind, &sel.kind},
+		{s.Name, &sel.name},
+		{s.Namespace, &sel.namespace},
+	} {
+		if f.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + f.pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		*f.re = re
+	}
+	return sel, nil
+}
+
+// selectorForPatch returns the selector matching the resource a strategic
+// merge patch without a target is written for.
+func selectorForPatch(patch *kyaml.RNode) *resourceSelector {
+	group, version := splitAPIVersion(patch.GetApiVersion())
+	exact := func(s string) *regexp.Regexp {
+		if s == "" {
+			return nil
+		}
+		return regexp.MustCompile("^" + regexp.QuoteMeta(s) + "$")
+	}
+	return &resourceSelector{
+		group:     exact(group),
+		version:   exact(version),
+		kind:      exact(patch.GetKind()),
+		name:      exact(patch.GetName()),
+		namespace: exact(patch.GetNamespace()),
+		fromPatch: true,
+	}
+}
+
+func (s *resourceSelector) matches(node *kyaml.RNode) (bool, error) {
+	group, version := splitAPIVersion(node.GetApiVersion())
+	for _, f := range []struct {
+		re    *regexp.Regexp
+		value string
+	}{
+		{s.group, group},
+		{s.version, version},
+		{s.kind, node.GetKind()},
+		{s.name, node.GetName()},
+		{s.namespace, node.GetNamespace()},
+	} {
+		if f.re != nil && !f.re.MatchString(f.value) {
+			return false, nil
+		}
+	}
+	if s.labelSelector != "" {
+		if ok, err := node.MatchesLabelSelector(s.labelSelector); err != nil || !ok {
+			return false, err
+		}
+	}
+	if s.annotationSelector != "" {
+		if ok, err := node.MatchesAnnotationSelector(s.annotationSelector); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func splitAPIVersion(apiVersion string) (group, version string) {
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i], apiVersion[i+1:]
+	}
+	return "", apiVersion
+}
+
+// isNamespaced reports whether a resource is namespaced. Resources of unknown
+// kinds, such as custom resources, are assumed to be namespaced.
+func isNamespaced(node *kyaml.RNode) bool {
+	return !openapi.IsCertainlyClusterScoped(kyaml.TypeMeta{APIVersion: node.GetApiVersion(), Kind: node.GetKind()})
+}
+
+// templateLabelPaths are the paths of the pod and job template labels of
+// workload resources, by kind.
+var templateLabelPaths = map[string][][]string{
+	"Deployment":            {{"spec", "template", "metadata", "labels"}},
+	"ReplicaSet":            {{"spec", "template", "metadata", "labels"}},
+	"DaemonSet":             {{"spec", "template", "metadata", "labels"}},
+	"StatefulSet":           {{"spec", "template", "metadata", "labels"}},
+	"Job":                   {{"spec", "template", "metadata", "labels"}},
+	"ReplicationController": {{"spec", "template", "metadata", "labels"}},
+	"CronJob": {
+		{"spec", "jobTemplate", "metadata", "labels"},
+		{"spec", "jobTemplate", "spec", "template", "metadata", "labels"},
+	},
+}
+
+// selectorLabelPaths are the paths of the label selectors of resources, by
+// kind. Selectors of Jobs are generated by the API server and left as is.
+var selectorLabelPaths = map[string][]string{
+	"Deployment":            {"spec", "selector", "matchLabels"},
+	"ReplicaSet":            {"spec", "selector", "matchLabels"},
+	"DaemonSet":             {"spec", "selector", "matchLabels"},
+	"StatefulSet":           {"spec", "selector", "matchLabels"},
+	"Service":               {"spec", "selector"},
+	"ReplicationController": {"spec", "selector"},
+}
+
+// setLabels adds labels to the metadata of a resource and optionally to its
+// selectors and templates.
+func setLabels(node *kyaml.RNode, labels map[string]string, selectors, templates bool) error {
+	paths := [][]string{{"metadata", "labels"}}
+	if templates {
+		paths = append(paths, templateLabelPaths[node.GetKind()]...)
+	}
+	if p, ok := selectorLabelPaths[node.GetKind()]; ok && selectors {
+		paths = append(paths, p)
+	}
+	return setFields(node, paths, labels)
+}
+
+// setAnnotations adds annotations to the metadata of a resource and to its
+// pod templates.
+func setAnnotations(node *kyaml.RNode, annotations map[string]string) error {
+	paths := [][]string{{"metadata", "annotations"}}
+	for _, p := range templateLabelPaths[node.GetKind()] {
+		paths = append(paths, append(p[:len(p)-1:len(p)-1], "annotations"))
+	}
+	return setFields(node, paths, annotations)
+}
+
+func setFields(node *kyaml.RNode, paths [][]string, values map[string]string) error {
+	for _, path := range paths {
+		m, err := node.Pipe(kyaml.LookupCreate(kyaml.MappingNode, path...))
+		if err != nil {
+			return err
+		}
+		for _, k := range slices.Sorted(maps.Keys(values)) {
+			if err := m.PipeE(kyaml.SetField(k, kyaml.NewStringRNode(values[k]))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// containerFields are the fields holding lists of containers in pod specs.
+var containerFields = map[string]bool{
+	"containers":          true,
+	"initContainers":      true,
+	"ephemeralContainers": true,
+}
+
+// setImage replaces the image of all containers of a resource which use the
+// named image, wherever their pod spec is located.
+func setImage(node *kyaml.Node, img kustomizeImage) {
+	if node.Kind != kyaml.MappingNode {
+		for _, n := range node.Content {
+			setImage(n, img)
+		}
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if containerFields[key.Value] && value.Kind == kyaml.SequenceNode {
+			for _, c := range value.Content {
+				updateContainerImage(c, img)
+			}
+		}
+		setImage(value, img)
+	}
+}
+
+func updateContainerImage(container *kyaml.Node, img kustomizeImage) {
+	if container.Kind != kyaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(container.Content); i += 2 {
+		if container.Content[i].Value != "image" {
+			continue
+		}
+		value := container.Content[i+1]
+		name, suffix := splitImage(value.Value)
+		if name != img.Name {
+			return
+		}
+		if img.NewName != "" {
+			name = img.NewName
+		}
+		switch {
+		case img.Digest != "":
+			suffix = "@" + img.Digest
+		case img.NewTag != "":
+			suffix = ":" + img.NewTag
+		}
+		value.Value = name + suffix
+		return
+	}
+}
+
+// splitImage splits an image reference into its name and its tag or digest
+// suffix, including the separator.
+func splitImage(image string) (name, suffix string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i:]
+	}
+	return image, ""
+}
@@ -0,0 +1,200 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrenderer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const kustomizeInput = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    postrenderer.helm.sh/postrender-filename: templates/deployment.yaml
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.36
+      containers:
+      - name: web
+        image: nginx:1.25
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  annotations:
+    postrenderer.helm.sh/postrender-filename: templates/service.yaml
+spec:
+  type: LoadBalancer
+  selector:
+    app: web
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web
+  annotations:
+    postrenderer.helm.sh/postrender-filename: templates/clusterrole.yaml
+rules: []
+`
+
+const kustomizeOutput = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    postrenderer.helm.sh/postrender-filename: templates/deployment.yaml
+    owner: sre
+  namespace: production
+  labels:
+    team: payments
+    env: prod
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: web
+      team: payments
+  template:
+    metadata:
+      labels:
+        app: web
+        team: payments
+      annotations:
+        owner: sre
+    spec:
+      initContainers:
+      - name: init
+        image: busybox@sha256:0000000000000000000000000000000000000000000000000000000000000000
+      containers:
+      - name: web
+        image: registry.example.com/nginx:1.27
+---
+apiVersion: v1
+kind: Service
+metadata:
+  annotations:
+    postrenderer.helm.sh/postrender-filename: templates/service.yaml
+    owner: sre
+  name: web
+  namespace: production
+  labels:
+    team: payments
+    env: prod
+spec:
+  selector:
+    app: web
+    team: payments
+  type: ClusterIP
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web
+  annotations:
+    postrenderer.helm.sh/postrender-filename: templates/clusterrole.yaml
+    owner: sre
+  labels:
+    team: payments
+    env: prod
+rules: []
+`
+
+func TestKustomizeRun(t *testing.T) {
+	renderer, err := NewPostRendererKustomize("testdata/kustomize/overlay")
+	require.NoError(t, err)
+
+	out, err := renderer.Run(bytes.NewBufferString(kustomizeInput))
+	require.NoError(t, err)
+	assert.Equal(t, kustomizeOutput, out.String())
+}
+
+func TestKustomizeUnsupportedField(t *testing.T) {
+	_, err := NewPostRendererKustomize("testdata/kustomize/unsupported")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown field "resources"`)
+}
+
+func TestKustomizeMissingKustomization(t *testing.T) {
+	_, err := NewPostRendererKustomize(t.TempDir())
+	assert.ErrorContains(t, err, "no kustomization file found")
+}
+
+func TestKustomizePatchOutsideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	kustomization := "patches:\n- path: ../patch.yaml\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644))
+
+	_, err := NewPostRendererKustomize(dir)
+	assert.ErrorContains(t, err, "is not located within the kustomization directory")
+}
+
+func TestKustomizePatchWithoutMatch(t *testing.T) {
+	dir := t.TempDir()
+	kustomization := `patches:
+- patch: |-
+    apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: missing
+    spec:
+      replicas: 3
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644))
+
+	renderer, err := NewPostRendererKustomize(dir)
+	require.NoError(t, err)
+	_, err = renderer.Run(bytes.NewBufferString(kustomizeInput))
+	assert.ErrorContains(t, err, "no resource matches Deployment missing")
+}
+
+func TestKustomizeDeletePatch(t *testing.T) {
+	dir := t.TempDir()
+	kustomization := `patches:
+- patch: |-
+    $patch: delete
+    apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644))
+
+	renderer, err := NewPostRendererKustomize(dir)
+	require.NoError(t, err)
+	out, err := renderer.Run(bytes.NewBufferString(kustomizeInput))
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), "kind: Service")
+	assert.Contains(t, out.String(), "kind: Deployment")
+}
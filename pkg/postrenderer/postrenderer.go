@@ -59,6 +59,11 @@ type postRendererPlugin struct {
 	settings *cli.EnvSettings
 }
 
+// String implements fmt.Stringer, naming the plugin in errors of a Chain.
+func (r *postRendererPlugin) String() string {
+	return fmt.Sprintf("plugin %q", r.plugin.Metadata().Name)
+}
+
 // Run implements PostRenderer by using the plugin's Runtime
 func (r *postRendererPlugin) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
 	input := &plugin.Input{
@@ -35,6 +35,10 @@ const (
 	HookPreRollback  HookEvent = "pre-rollback"
 	HookPostRollback HookEvent = "post-rollback"
 	HookTest         HookEvent = "test"
+
+	HookPostInstallFailure  HookEvent = "post-install-failure"
+	HookPostUpgradeFailure  HookEvent = "post-upgrade-failure"
+	HookPostRollbackFailure HookEvent = "post-rollback-failure"
 )
 
 func (x HookEvent) String() string { return string(x) }
@@ -62,6 +66,19 @@ const (
 
 func (x HookOutputLogPolicy) String() string { return string(x) }
 
+// HookFailurePolicy specifies what happens to the operation when a hook fails
+type HookFailurePolicy string
+
+// Hook failure policy types
+const (
+	// HookFailureAbort fails the operation when the hook fails. This is the default.
+	HookFailureAbort HookFailurePolicy = "abort"
+	// HookFailureContinue records the failure of the hook and carries on with the operation.
+	HookFailureContinue HookFailurePolicy = "continue"
+)
+
+func (x HookFailurePolicy) String() string { return string(x) }
+
 // HookAnnotation is the label name for a hook
 const HookAnnotation = "helm.sh/hook"
 
@@ -74,6 +91,15 @@ const HookDeleteAnnotation = "helm.sh/hook-delete-policy"
 // HookOutputLogAnnotation is the label name for the output log policy for a hook
 const HookOutputLogAnnotation = "helm.sh/hook-output-log-policy"
 
+// HookTimeoutAnnotation is the label name for the time to wait for a hook to complete
+const HookTimeoutAnnotation = "helm.sh/hook-timeout"
+
+// HookRetriesAnnotation is the label name for the number of times a failed hook is retried
+const HookRetriesAnnotation = "helm.sh/hook-retries"
+
+// HookFailurePolicyAnnotation is the label name for the failure policy for a hook
+const HookFailurePolicyAnnotation = "helm.sh/hook-failure-policy"
+
 // Hook defines a hook object.
 type Hook struct {
 	Name string `json:"name,omitempty"`
@@ -93,6 +119,14 @@ type Hook struct {
 	DeletePolicies []HookDeletePolicy `json:"delete_policies,omitempty"`
 	// OutputLogPolicies defines whether we should copy hook logs back to main process
 	OutputLogPolicies []HookOutputLogPolicy `json:"output_log_policies,omitempty"`
+	// Timeout is the time to wait for each attempt of the hook. If zero, the timeout of the operation is used.
+	// An attempt never waits longer than the timeout of the operation leaves.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Retries is the number of times the hook is retried after it failed. All hooks of an event share the
+	// timeout of the operation, including their retries, so no retry is started once it expired.
+	Retries int `json:"retries,omitempty"`
+	// FailurePolicy defines whether a failure of the hook fails the operation. If empty, HookFailureAbort is used.
+	FailurePolicy HookFailurePolicy `json:"failure_policy,omitempty"`
 }
 
 // A HookExecution records the result for the last execution of a hook for a given release.
@@ -103,6 +137,10 @@ type HookExecution struct {
 	CompletedAt time.Time `json:"completed_at,omitzero"`
 	// Phase indicates whether the hook completed successfully
 	Phase HookPhase `json:"phase"`
+	// Attempts is the number of times the hook was run
+	Attempts int `json:"attempts,omitempty"`
+	// Error is the error of the last attempt, if it failed
+	Error string `json:"error,omitempty"`
 }
 
 // A HookPhase indicates the state of a hook execution
@@ -127,6 +165,8 @@ type hookExecutionJSON struct {
 	StartedAt   *time.Time `json:"started_at,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 	Phase       HookPhase  `json:"phase"`
+	Attempts    int        `json:"attempts,omitempty"`
+	Error       string     `json:"error,omitempty"`
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
@@ -167,6 +207,8 @@ func (h *HookExecution) UnmarshalJSON(data []byte) error {
 		h.CompletedAt = *tmp.CompletedAt
 	}
 	h.Phase = tmp.Phase
+	h.Attempts = tmp.Attempts
+	h.Error = tmp.Error
 
 	return nil
 }
@@ -175,7 +217,9 @@ func (h *HookExecution) UnmarshalJSON(data []byte) error {
 // It omits zero-value time fields from the JSON output.
 func (h HookExecution) MarshalJSON() ([]byte, error) {
 	tmp := hookExecutionJSON{
-		Phase: h.Phase,
+		Phase:    h.Phase,
+		Attempts: h.Attempts,
+		Error:    h.Error,
 	}
 
 	if !h.StartedAt.IsZero() {
@@ -74,6 +74,17 @@ func TestHookExecutionMarshalJSON(t *testing.T) {
 			},
 			expected: `{"phase":"Unknown"}`,
 		},
+		{
+			name: "retried failure",
+			exec: HookExecution{
+				StartedAt:   started,
+				CompletedAt: completed,
+				Phase:       HookPhaseFailed,
+				Attempts:    3,
+				Error:       "job failed: BackoffLimitExceeded",
+			},
+			expected: `{"started_at":"2025-10-08T12:00:00Z","completed_at":"2025-10-08T12:05:00Z","phase":"Failed","attempts":3,"error":"job failed: BackoffLimitExceeded"}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -188,6 +199,7 @@ func TestHookExecutionRoundTrip(t *testing.T) {
 		StartedAt:   started,
 		CompletedAt: completed,
 		Phase:       HookPhaseSucceeded,
+		Attempts:    2,
 	}
 
 	data, err := json.Marshal(&original)
@@ -200,6 +212,7 @@ func TestHookExecutionRoundTrip(t *testing.T) {
 	assert.Equal(t, original.StartedAt.Unix(), decoded.StartedAt.Unix())
 	assert.Equal(t, original.CompletedAt.Unix(), decoded.CompletedAt.Unix())
 	assert.Equal(t, original.Phase, decoded.Phase)
+	assert.Equal(t, original.Attempts, decoded.Attempts)
 }
 
 func TestHookExecutionEmptyStringRoundTrip(t *testing.T) {
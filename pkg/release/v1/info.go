@@ -40,6 +40,9 @@ type Info struct {
 	Notes string `json:"notes,omitempty"`
 	// Contains the deployed resources information
 	Resources map[string][]runtime.Object `json:"resources,omitempty"`
+	// Warnings are the warnings returned by the Kubernetes API server while
+	// the resources of the release were applied.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // infoJSON is used for custom JSON marshaling/unmarshaling
@@ -51,6 +54,7 @@ type infoJSON struct {
 	Status        common.Status               `json:"status,omitempty"`
 	Notes         string                      `json:"notes,omitempty"`
 	Resources     map[string][]runtime.Object `json:"resources,omitempty"`
+	Warnings      []string                    `json:"warnings,omitempty"`
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
@@ -97,6 +101,7 @@ func (i *Info) UnmarshalJSON(data []byte) error {
 	i.Status = tmp.Status
 	i.Notes = tmp.Notes
 	i.Resources = tmp.Resources
+	i.Warnings = tmp.Warnings
 
 	return nil
 }
@@ -109,6 +114,7 @@ func (i Info) MarshalJSON() ([]byte, error) {
 		Status:      i.Status,
 		Notes:       i.Notes,
 		Resources:   i.Resources,
+		Warnings:    i.Warnings,
 	}
 
 	if !i.FirstDeployed.IsZero() {
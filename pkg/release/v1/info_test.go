@@ -235,6 +235,7 @@ func TestInfoRoundTrip(t *testing.T) {
 		Description:   "Test release",
 		Status:        common.StatusDeployed,
 		Notes:         "Release notes",
+		Warnings:      []string{"Pod default/web: spec.privileged is deprecated"},
 	}
 
 	data, err := json.Marshal(&original)
@@ -250,6 +251,7 @@ func TestInfoRoundTrip(t *testing.T) {
 	assert.Equal(t, original.Description, decoded.Description)
 	assert.Equal(t, original.Status, decoded.Status)
 	assert.Equal(t, original.Notes, decoded.Notes)
+	assert.Equal(t, original.Warnings, decoded.Warnings)
 }
 
 func TestInfoEmptyStringRoundTrip(t *testing.T) {
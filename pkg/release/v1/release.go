@@ -51,6 +51,13 @@ type Release struct {
 	// ApplyMethod stores whether server-side or client-side apply was used for the release
 	// Unset (empty string) should be treated as the default of client-side apply
 	ApplyMethod string `json:"apply_method,omitempty"` // "ssa" | "csa"
+	// ApplySetID is the ID of the KEP-3659 ApplySet the resources of the
+	// release are members of. Unset if the release does not use an ApplySet.
+	ApplySetID string `json:"apply_set_id,omitempty"`
+	// ServiceAccount is the service account, as "namespace/name", the
+	// resources of the release are managed as. Unset if they are managed with
+	// the credentials of the user.
+	ServiceAccount string `json:"service_account,omitempty"`
 }
 
 // SetStatus is a helper for setting the status on a release.
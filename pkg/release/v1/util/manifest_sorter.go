@@ -23,6 +23,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"sigs.k8s.io/yaml"
 
@@ -61,6 +62,10 @@ var events = map[string]release.HookEvent{
 	release.HookPreRollback.String():  release.HookPreRollback,
 	release.HookPostRollback.String(): release.HookPostRollback,
 	release.HookTest.String():         release.HookTest,
+
+	release.HookPostInstallFailure.String():  release.HookPostInstallFailure,
+	release.HookPostUpgradeFailure.String():  release.HookPostUpgradeFailure,
+	release.HookPostRollbackFailure.String(): release.HookPostRollbackFailure,
 	// Support test-success for backward compatibility with Helm 2 tests
 	"test-success": release.HookTest,
 }
@@ -136,6 +141,16 @@ func SortManifests(files map[string]string, _ common.VersionSet, ordering KindSo
 //	 metadata:
 //			annotations:
 //				helm.sh/hook-output-log-policy: hook-succeeded,hook-failed
+//
+// To determine the timeout, retries and failure policy of the hook, it looks for a YAML structure like this:
+//
+//	 kind: Job
+//	 apiVersion: batch/v1
+//	 metadata:
+//			annotations:
+//				helm.sh/hook-timeout: 10m
+//				helm.sh/hook-retries: "3"
+//				helm.sh/hook-failure-policy: continue
 func (file *manifestFile) sort(result *result) error {
 	// Go through manifests in order found in file (function `SplitManifests` creates integer-sortable keys)
 	var sortedEntryKeys []string
@@ -209,6 +224,10 @@ func (file *manifestFile) sort(result *result) error {
 		operateAnnotationValues(entry, release.HookOutputLogAnnotation, func(value string) {
 			h.OutputLogPolicies = append(h.OutputLogPolicies, release.HookOutputLogPolicy(value))
 		})
+
+		if err := setHookExecutionPolicy(h, entry); err != nil {
+			return fmt.Errorf("%s: hook %s: %w", file.path, h.Name, err)
+		}
 	}
 
 	return nil
@@ -233,6 +252,35 @@ func calculateHookWeight(entry SimpleHead) int {
 	return hw
 }
 
+// setHookExecutionPolicy sets the timeout, retries and failure policy of a hook
+// from its annotations.
+func setHookExecutionPolicy(h *release.Hook, entry SimpleHead) error {
+	annotations := entry.Metadata.Annotations
+	if value, ok := annotations[release.HookTimeoutAnnotation]; ok {
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid %s annotation %q: must be a positive duration", release.HookTimeoutAnnotation, value)
+		}
+		h.Timeout = timeout
+	}
+	if value, ok := annotations[release.HookRetriesAnnotation]; ok {
+		retries, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || retries < 0 {
+			return fmt.Errorf("invalid %s annotation %q: must be a non-negative integer", release.HookRetriesAnnotation, value)
+		}
+		h.Retries = retries
+	}
+	if value, ok := annotations[release.HookFailurePolicyAnnotation]; ok {
+		switch policy := release.HookFailurePolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+		case release.HookFailureAbort, release.HookFailureContinue:
+			h.FailurePolicy = policy
+		default:
+			return fmt.Errorf("invalid %s annotation %q: must be %q or %q", release.HookFailurePolicyAnnotation, value, release.HookFailureAbort, release.HookFailureContinue)
+		}
+	}
+	return nil
+}
+
 // operateAnnotationValues finds the given annotation and runs the operate function with the value of that annotation
 func operateAnnotationValues(entry SimpleHead, annotation string, operate func(p string)) {
 	if dps, ok := entry.Metadata.Annotations[annotation]; ok {
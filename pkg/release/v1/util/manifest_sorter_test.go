@@ -17,8 +17,11 @@ limitations under the License.
 package util
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"sigs.k8s.io/yaml"
 
@@ -225,3 +228,63 @@ metadata:
 		}
 	}
 }
+
+func TestSortManifestsHookExecutionPolicy(t *testing.T) {
+	manifests := map[string]string{
+		"templates/migrate.yaml": `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    "helm.sh/hook": pre-upgrade
+    "helm.sh/hook-timeout": 10m
+    "helm.sh/hook-retries": "3"
+    "helm.sh/hook-failure-policy": Continue
+`,
+		"templates/notify.yaml": `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: notify
+  annotations:
+    "helm.sh/hook": post-upgrade
+`,
+	}
+
+	hs, _, err := SortManifests(manifests, nil, InstallOrder)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, h := range hs {
+		switch h.Name {
+		case "migrate":
+			if h.Timeout != 10*time.Minute || h.Retries != 3 || h.FailurePolicy != release.HookFailureContinue {
+				t.Errorf("unexpected execution policy of hook migrate: timeout %s, retries %d, failure policy %q", h.Timeout, h.Retries, h.FailurePolicy)
+			}
+		case "notify":
+			if h.Timeout != 0 || h.Retries != 0 || h.FailurePolicy != "" {
+				t.Errorf("unexpected execution policy of hook notify: timeout %s, retries %d, failure policy %q", h.Timeout, h.Retries, h.FailurePolicy)
+			}
+		}
+	}
+
+	for annotation, value := range map[string]string{
+		release.HookTimeoutAnnotation:       "forever",
+		release.HookRetriesAnnotation:       "-1",
+		release.HookFailurePolicyAnnotation: "ignore",
+	} {
+		manifests := map[string]string{
+			"templates/migrate.yaml": fmt.Sprintf(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    "helm.sh/hook": pre-upgrade
+    %q: %q
+`, annotation, value),
+		}
+		_, _, err := SortManifests(manifests, nil, InstallOrder)
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("invalid %s annotation", annotation)) {
+			t.Errorf("expected invalid %s annotation error, got %v", annotation, err)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package driver // import "helm.sh/helm/v4/pkg/storage/driver"
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -36,6 +37,7 @@ import (
 )
 
 var _ Driver = (*ConfigMaps)(nil)
+var _ Rekeyer = (*ConfigMaps)(nil)
 
 // ConfigMapsDriverName is the string name of the driver.
 const ConfigMapsDriverName = "ConfigMap"
@@ -43,6 +45,10 @@ const ConfigMapsDriverName = "ConfigMap"
 // ConfigMaps is a wrapper around an implementation of a kubernetes
 // ConfigMapsInterface.
 type ConfigMaps struct {
+	// Encryption, if set, encrypts release records before they are stored.
+	// Unencrypted records are still read.
+	Encryption *Encryption
+
 	impl corev1.ConfigMapInterface
 }
 
@@ -73,7 +79,7 @@ func (cfgmaps *ConfigMaps) Get(key string) (release.Releaser, error) {
 		return nil, err
 	}
 	// found the configmap, decode the base64 data string
-	r, err := decodeRelease(obj.Data["release"])
+	r, err := decodeReleaseEncrypted(obj.Data["release"], cfgmaps.Encryption, key)
 	if err != nil {
 		slog.Debug("failed to decode data", "key", key, slog.Any("error", err))
 		return nil, err
@@ -85,7 +91,8 @@ func (cfgmaps *ConfigMaps) Get(key string) (release.Releaser, error) {
 
 // List fetches all releases and returns the list releases such
 // that filter(release) == true. An error is returned if the
-// configmap fails to retrieve the releases.
+// configmap fails to retrieve the releases. Records which fail to decode are
+// skipped, unless they are encrypted and cannot be decrypted.
 func (cfgmaps *ConfigMaps) List(filter func(release.Releaser) bool) ([]release.Releaser, error) {
 	lsel := kblabels.Set{"owner": "helm"}.AsSelector()
 	opts := metav1.ListOptions{LabelSelector: lsel.String()}
@@ -101,8 +108,11 @@ func (cfgmaps *ConfigMaps) List(filter func(release.Releaser) bool) ([]release.R
 	// iterate over the configmaps object list
 	// and decode each release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+		rls, err := decodeReleaseEncrypted(item.Data["release"], cfgmaps.Encryption, item.Name)
 		if err != nil {
+			if isDecryptionError(err) {
+				return nil, fmt.Errorf("list: failed to decode release %q: %w", item.Name, err)
+			}
 			slog.Debug("failed to decode release", "item", item, slog.Any("error", err))
 			continue
 		}
@@ -117,7 +127,9 @@ func (cfgmaps *ConfigMaps) List(filter func(release.Releaser) bool) ([]release.R
 }
 
 // Query fetches all releases that match the provided map of labels.
-// An error is returned if the configmap fails to retrieve the releases.
+// An error is returned if the configmap fails to retrieve the releases. Records
+// which fail to decode are skipped, unless they are encrypted and cannot be
+// decrypted.
 func (cfgmaps *ConfigMaps) Query(labels map[string]string) ([]release.Releaser, error) {
 	ls := kblabels.Set{}
 	for k, v := range labels {
@@ -141,8 +153,11 @@ func (cfgmaps *ConfigMaps) Query(labels map[string]string) ([]release.Releaser,
 
 	var results []release.Releaser
 	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+		rls, err := decodeReleaseEncrypted(item.Data["release"], cfgmaps.Encryption, item.Name)
 		if err != nil {
+			if isDecryptionError(err) {
+				return nil, fmt.Errorf("query: failed to decode release %q: %w", item.Name, err)
+			}
 			slog.Debug("failed to decode release", slog.Any("error", err))
 			continue
 		}
@@ -173,7 +188,7 @@ func (cfgmaps *ConfigMaps) Create(key string, rls release.Releaser) error {
 	}
 
 	// create a new configmap to hold the release
-	obj, err := newConfigMapsObject(key, rel, lbs)
+	obj, err := newConfigMapsObject(key, rel, lbs, cfgmaps.Encryption)
 	if err != nil {
 		slog.Debug("failed to encode release", "name", rac.Name(), slog.Any("error", err))
 		return err
@@ -206,7 +221,7 @@ func (cfgmaps *ConfigMaps) Update(key string, rel release.Releaser) error {
 	lbs.set("modifiedAt", fmt.Sprintf("%v", time.Now().Unix()))
 
 	// create a new configmap object to hold the release
-	obj, err := newConfigMapsObject(key, rls, lbs)
+	obj, err := newConfigMapsObject(key, rls, lbs, cfgmaps.Encryption)
 	if err != nil {
 		slog.Debug("failed to encode release", "name", rls.Name, slog.Any("error", err))
 		return err
@@ -233,6 +248,48 @@ func (cfgmaps *ConfigMaps) Delete(key string) (rls release.Releaser, err error)
 	return rls, nil
 }
 
+// Rekey rewrites every release record with the current Encryption settings,
+// e.g. after a new key has been added. It returns the number of rewritten
+// records. Records that cannot be decrypted are reported and left untouched.
+// Without a key to encrypt records, Rekey fails rather than rewriting them
+// unencrypted.
+func (cfgmaps *ConfigMaps) Rekey() (int, error) {
+	if !cfgmaps.Encryption.canSeal() {
+		return 0, fmt.Errorf("rekey: %w", ErrNoEncryptionKey)
+	}
+
+	lsel := kblabels.Set{"owner": "helm"}.AsSelector()
+	opts := metav1.ListOptions{LabelSelector: lsel.String()}
+
+	list, err := cfgmaps.impl.List(context.Background(), opts)
+	if err != nil {
+		return 0, fmt.Errorf("rekey: failed to list: %w", err)
+	}
+
+	var errs []error
+	rekeyed := 0
+	for i := range list.Items {
+		item := &list.Items[i]
+		rls, err := decodeReleaseEncrypted(item.Data["release"], cfgmaps.Encryption, item.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rekey: failed to decode %q: %w", item.Name, err))
+			continue
+		}
+		s, err := encodeReleaseEncrypted(rls, cfgmaps.Encryption, item.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rekey: failed to encode %q: %w", item.Name, err))
+			continue
+		}
+		item.Data["release"] = s
+		if _, err := cfgmaps.impl.Update(context.Background(), item, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("rekey: failed to update %q: %w", item.Name, err))
+			continue
+		}
+		rekeyed++
+	}
+	return rekeyed, errors.Join(errs...)
+}
+
 // newConfigMapsObject constructs a kubernetes ConfigMap object
 // to store a release. Each configmap data entry is the base64
 // encoded gzipped string of a release.
@@ -245,11 +302,11 @@ func (cfgmaps *ConfigMaps) Delete(key string) (rls release.Releaser, err error)
 //	"status"         - status of the release (see pkg/release/status.go for variants)
 //	"owner"          - owner of the configmap, currently "helm".
 //	"name"           - name of the release.
-func newConfigMapsObject(key string, rls *rspb.Release, lbs labels) (*v1.ConfigMap, error) {
+func newConfigMapsObject(key string, rls *rspb.Release, lbs labels, enc *Encryption) (*v1.ConfigMap, error) {
 	const owner = "helm"
 
 	// encode the release
-	s, err := encodeRelease(rls)
+	s, err := encodeReleaseEncrypted(rls, enc, key)
 	if err != nil {
 		return nil, err
 	}
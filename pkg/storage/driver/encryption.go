@@ -0,0 +1,391 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v4/pkg/storage/driver"
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// magicEncrypted prefixes encrypted release records. It is followed by a
+// single byte identifying the encryption scheme.
+var magicEncrypted = []byte("HELMENC\x00")
+
+const (
+	encryptionSchemeAESGCM byte = 0x01
+	encryptionSchemeAge    byte = 0x02
+)
+
+const (
+	aesKeySize   = 32
+	aesKeyIDSize = 8
+)
+
+var (
+	// ErrEncryptedRelease indicates that a release record is encrypted but no
+	// key able to decrypt it is configured.
+	ErrEncryptedRelease = errors.New("release: encrypted record cannot be decrypted with the configured keys")
+	// ErrNoEncryptionKey indicates that keys to decrypt release records are
+	// configured, but none to encrypt them.
+	ErrNoEncryptionKey = errors.New("release: no key to encrypt records is configured")
+)
+
+// decryptionError is returned when a record carries the encryption header but
+// cannot be decrypted. Unlike corrupt legacy records, such records are not
+// skipped when listing releases, as that would hide releases from operations.
+type decryptionError struct {
+	err error
+}
+
+func (e *decryptionError) Error() string { return e.err.Error() }
+
+func (e *decryptionError) Unwrap() error { return e.err }
+
+// isDecryptionError reports whether err was returned for an encrypted record
+// which cannot be decrypted.
+func isDecryptionError(err error) bool {
+	var decErr *decryptionError
+	return errors.As(err, &decErr)
+}
+
+// Rekeyer is implemented by drivers that support encrypting release records.
+//
+// Rekey rewrites every stored release record with the driver's current
+// encryption settings and returns the number of rewritten records.
+type Rekeyer interface {
+	Rekey() (int, error)
+}
+
+// EncryptionOptions configures the encryption of release records at rest.
+type EncryptionOptions struct {
+	// KeyFile contains base64 encoded 256-bit AES keys, one per line. The
+	// first key encrypts new records, all keys are tried when decrypting.
+	KeyFile string
+	// AgeRecipientsFile contains the age recipients new records are
+	// encrypted to. It takes precedence over KeyFile for encryption.
+	AgeRecipientsFile string
+	// AgeIdentityFile contains the age identities used to decrypt records.
+	// Without recipients and AES keys, records are encrypted to the
+	// recipients of its X25519 identities.
+	AgeIdentityFile string
+}
+
+// Encryption is an envelope encryption layer for encoded release records.
+//
+// With AES keys, every record is encrypted with a random data key using
+// AES-256-GCM, and the data key is in turn encrypted with the first
+// configured key. With age, records are encrypted to all recipients.
+//
+// A nil *Encryption leaves records unencrypted.
+type Encryption struct {
+	aesKeys       [][]byte
+	ageRecipients []age.Recipient
+	ageIdentities []age.Identity
+}
+
+// LoadEncryption reads the key material referenced by opts. It returns nil
+// if no key material is configured.
+func LoadEncryption(opts EncryptionOptions) (*Encryption, error) {
+	if opts.KeyFile == "" && opts.AgeRecipientsFile == "" && opts.AgeIdentityFile == "" {
+		return nil, nil
+	}
+
+	e := &Encryption{}
+	if opts.KeyFile != "" {
+		keys, err := readAESKeyFile(opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		e.aesKeys = keys
+	}
+	if opts.AgeRecipientsFile != "" {
+		f, err := os.Open(opts.AgeRecipientsFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read age recipients: %w", err)
+		}
+		defer f.Close()
+		if e.ageRecipients, err = age.ParseRecipients(f); err != nil {
+			return nil, fmt.Errorf("unable to parse age recipients file %s: %w", opts.AgeRecipientsFile, err)
+		}
+	}
+	if opts.AgeIdentityFile != "" {
+		f, err := os.Open(opts.AgeIdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read age identities: %w", err)
+		}
+		defer f.Close()
+		if e.ageIdentities, err = age.ParseIdentities(f); err != nil {
+			return nil, fmt.Errorf("unable to parse age identity file %s: %w", opts.AgeIdentityFile, err)
+		}
+	}
+	if len(e.ageRecipients) == 0 && len(e.aesKeys) == 0 {
+		for _, id := range e.ageIdentities {
+			if x, ok := id.(*age.X25519Identity); ok {
+				e.ageRecipients = append(e.ageRecipients, x.Recipient())
+			}
+		}
+	}
+	return e, nil
+}
+
+// NewAESEncryption returns an Encryption using the given 256-bit AES keys. The
+// first key encrypts new records, all keys are tried when decrypting.
+func NewAESEncryption(keys ...[]byte) (*Encryption, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+	for i, k := range keys {
+		if len(k) != aesKeySize {
+			return nil, fmt.Errorf("key %d is %d bytes long, expected %d", i, len(k), aesKeySize)
+		}
+	}
+	return &Encryption{aesKeys: keys}, nil
+}
+
+// NewAgeEncryption returns an Encryption that encrypts records to the given
+// age recipients and decrypts them with the given identities.
+func NewAgeEncryption(recipients []age.Recipient, identities []age.Identity) *Encryption {
+	return &Encryption{ageRecipients: recipients, ageIdentities: identities}
+}
+
+func readAESKeyFile(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read encryption key file: %w", err)
+	}
+
+	var keys [][]byte
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, err := b64.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key file %s, line %d: key is not base64 encoded: %w", path, n, err)
+		}
+		if len(k) != aesKeySize {
+			return nil, fmt.Errorf("encryption key file %s, line %d: key is %d bytes long, expected %d", path, n, len(k), aesKeySize)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("encryption key file %s contains no keys", path)
+	}
+	return keys, nil
+}
+
+// isEncrypted reports whether b is an encrypted record.
+func isEncrypted(b []byte) bool {
+	return len(b) > len(magicEncrypted) && bytes.Equal(b[:len(magicEncrypted)], magicEncrypted)
+}
+
+// canSeal reports whether e has a key to encrypt records with.
+func (e *Encryption) canSeal() bool {
+	return e != nil && (len(e.ageRecipients) > 0 || len(e.aesKeys) > 0)
+}
+
+// seal encrypts an encoded record stored under key. It returns b unchanged if
+// e is nil, and ErrNoEncryptionKey if e only has keys to decrypt records.
+//
+// The record is bound to its key, so it cannot be opened when copied to
+// another key, e.g. to pass off an old revision as a newer one.
+func (e *Encryption) seal(b []byte, key string) ([]byte, error) {
+	switch {
+	case e == nil:
+		return b, nil
+	case len(e.ageRecipients) > 0:
+		return e.sealAge(b, key)
+	case len(e.aesKeys) > 0:
+		return e.sealAES(b, key)
+	default:
+		return nil, ErrNoEncryptionKey
+	}
+}
+
+// open decrypts a record produced by seal for the same key. Records without
+// the encryption header are returned unchanged.
+func (e *Encryption) open(b []byte, key string) ([]byte, error) {
+	if !isEncrypted(b) {
+		return b, nil
+	}
+	if e == nil {
+		return nil, ErrEncryptedRelease
+	}
+
+	payload := b[len(magicEncrypted)+1:]
+	switch scheme := b[len(magicEncrypted)]; scheme {
+	case encryptionSchemeAESGCM:
+		return e.openAES(payload, key)
+	case encryptionSchemeAge:
+		return e.openAge(payload, key)
+	default:
+		return nil, fmt.Errorf("release: unknown encryption scheme %d", scheme)
+	}
+}
+
+// sealAES produces: key ID | wrapped data key nonce | wrapped data key | nonce | ciphertext
+//
+// The record key is the additional data of both the data key and the record.
+func (e *Encryption) sealAES(b []byte, key string) ([]byte, error) {
+	kek := e.aesKeys[0]
+	dek := make([]byte, aesKeySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, err
+	}
+
+	kekAEAD, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	dekAEAD, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append(bytes.Clone(magicEncrypted), encryptionSchemeAESGCM)
+	out = append(out, aesKeyID(kek)...)
+
+	wrapNonce := make([]byte, kekAEAD.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, wrapNonce); err != nil {
+		return nil, err
+	}
+	out = append(out, wrapNonce...)
+	out = kekAEAD.Seal(out, wrapNonce, dek, []byte(key))
+
+	nonce := make([]byte, dekAEAD.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return dekAEAD.Seal(out, nonce, b, []byte(key)), nil
+}
+
+func (e *Encryption) openAES(b []byte, key string) ([]byte, error) {
+	if len(b) < aesKeyIDSize {
+		return nil, errors.New("release: encrypted record is truncated")
+	}
+	id, b := b[:aesKeyIDSize], b[aesKeyIDSize:]
+
+	var kek []byte
+	for _, k := range e.aesKeys {
+		if bytes.Equal(aesKeyID(k), id) {
+			kek = k
+			break
+		}
+	}
+	if kek == nil {
+		return nil, ErrEncryptedRelease
+	}
+
+	kekAEAD, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	wrappedLen := kekAEAD.NonceSize() + aesKeySize + kekAEAD.Overhead()
+	if len(b) < wrappedLen {
+		return nil, errors.New("release: encrypted record is truncated")
+	}
+	dek, err := kekAEAD.Open(nil, b[:kekAEAD.NonceSize()], b[kekAEAD.NonceSize():wrappedLen], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("release: unable to decrypt data key: %w", err)
+	}
+	b = b[wrappedLen:]
+
+	dekAEAD, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < dekAEAD.NonceSize() {
+		return nil, errors.New("release: encrypted record is truncated")
+	}
+	plain, err := dekAEAD.Open(nil, b[:dekAEAD.NonceSize()], b[dekAEAD.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("release: unable to decrypt record: %w", err)
+	}
+	return plain, nil
+}
+
+// sealAge encrypts the record key, followed by a NUL byte and the record, as
+// age has no additional data.
+func (e *Encryption) sealAge(b []byte, key string) ([]byte, error) {
+	buf := bytes.NewBuffer(append(bytes.Clone(magicEncrypted), encryptionSchemeAge))
+	w, err := age.Encrypt(buf, e.ageRecipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(key), 0)); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *Encryption) openAge(b []byte, key string) ([]byte, error) {
+	if len(e.ageIdentities) == 0 {
+		return nil, ErrEncryptedRelease
+	}
+	r, err := age.Decrypt(bytes.NewReader(b), e.ageIdentities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, ErrEncryptedRelease
+		}
+		return nil, fmt.Errorf("release: unable to decrypt record: %w", err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("release: unable to decrypt record: %w", err)
+	}
+	recordKey, plain, ok := bytes.Cut(plain, []byte{0})
+	if !ok || string(recordKey) != key {
+		return nil, fmt.Errorf("release: encrypted record does not belong to %q", key)
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// aesKeyID identifies a key without revealing it, so the matching key can be
+// picked when several are configured.
+func aesKeyID(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:aesKeyIDSize]
+}
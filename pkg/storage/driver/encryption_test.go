@@ -0,0 +1,316 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"filippo.io/age"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"helm.sh/helm/v4/pkg/release"
+	"helm.sh/helm/v4/pkg/release/common"
+	rspb "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// recordKey is the storage key encrypted test records are bound to.
+const recordKey = "sh.helm.release.v1.smug-pigeon.v1"
+
+func testAESKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, aesKeySize)
+}
+
+// unlabeledReleaseStub returns a release stub without labels, which are stored
+// on the storage object rather than in the encoded release.
+func unlabeledReleaseStub() *rspb.Release {
+	rel := releaseStub("smug-pigeon", 1, "default", common.StatusDeployed)
+	rel.Labels = nil
+	return rel
+}
+
+func TestEncryptionAESRoundTrip(t *testing.T) {
+	rel := unlabeledReleaseStub()
+	enc, err := NewAESEncryption(testAESKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := encodeReleaseEncrypted(rel, enc, recordKey)
+	if err != nil {
+		t.Fatalf("Failed to encode release: %s", err)
+	}
+	raw, _ := b64.DecodeString(data)
+	if !isEncrypted(raw) {
+		t.Fatal("Expected encoded release to be encrypted")
+	}
+
+	got, err := decodeReleaseEncrypted(data, enc, recordKey)
+	if err != nil {
+		t.Fatalf("Failed to decode release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	if _, err := decodeRelease(data); !errors.Is(err, ErrEncryptedRelease) {
+		t.Errorf("Expected ErrEncryptedRelease without keys, got %v", err)
+	}
+
+	other, _ := NewAESEncryption(testAESKey(2))
+	if _, err := decodeReleaseEncrypted(data, other, recordKey); !errors.Is(err, ErrEncryptedRelease) {
+		t.Errorf("Expected ErrEncryptedRelease with the wrong key, got %v", err)
+	}
+
+	rotated, _ := NewAESEncryption(testAESKey(2), testAESKey(1))
+	if _, err := decodeReleaseEncrypted(data, rotated, recordKey); err != nil {
+		t.Errorf("Expected the old key to still decrypt, got %v", err)
+	}
+
+	if _, err := decodeReleaseEncrypted(data, enc, "sh.helm.release.v1.smug-pigeon.v2"); err == nil {
+		t.Error("Expected an error for a record stored under another key")
+	}
+}
+
+func TestEncryptionReadsUnencrypted(t *testing.T) {
+	rel := unlabeledReleaseStub()
+	data, err := encodeRelease(rel)
+	if err != nil {
+		t.Fatalf("Failed to encode release: %s", err)
+	}
+
+	enc, _ := NewAESEncryption(testAESKey(1))
+	got, err := decodeReleaseEncrypted(data, enc, recordKey)
+	if err != nil {
+		t.Fatalf("Failed to decode release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+}
+
+func TestEncryptionAgeRoundTrip(t *testing.T) {
+	rel := unlabeledReleaseStub()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := NewAgeEncryption([]age.Recipient{id.Recipient()}, []age.Identity{id})
+
+	data, err := encodeReleaseEncrypted(rel, enc, recordKey)
+	if err != nil {
+		t.Fatalf("Failed to encode release: %s", err)
+	}
+	got, err := decodeReleaseEncrypted(data, enc, recordKey)
+	if err != nil {
+		t.Fatalf("Failed to decode release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	if _, err := decodeReleaseEncrypted(data, NewAgeEncryption(nil, []age.Identity{other}), recordKey); !errors.Is(err, ErrEncryptedRelease) {
+		t.Errorf("Expected ErrEncryptedRelease with the wrong identity, got %v", err)
+	}
+
+	if _, err := decodeReleaseEncrypted(data, enc, "sh.helm.release.v1.smug-pigeon.v2"); err == nil {
+		t.Error("Expected an error for a record stored under another key")
+	}
+}
+
+func TestLoadEncryption(t *testing.T) {
+	enc, err := LoadEncryption(EncryptionOptions{})
+	if err != nil || enc != nil {
+		t.Fatalf("Expected no encryption, got %v, %v", enc, err)
+	}
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys")
+	content := "# new key\n" + b64.EncodeToString(testAESKey(2)) + "\n\n" + b64.EncodeToString(testAESKey(1)) + "\n"
+	if err := os.WriteFile(keyFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	enc, err = LoadEncryption(EncryptionOptions{KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Failed to load encryption: %s", err)
+	}
+	if len(enc.aesKeys) != 2 || !bytes.Equal(enc.aesKeys[0], testAESKey(2)) {
+		t.Errorf("Expected 2 keys with the new key first, got %d", len(enc.aesKeys))
+	}
+
+	badFile := filepath.Join(dir, "bad")
+	if err := os.WriteFile(badFile, []byte(b64.EncodeToString([]byte("short"))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadEncryption(EncryptionOptions{KeyFile: badFile}); err == nil {
+		t.Error("Expected an error for a key of the wrong size")
+	}
+
+	id, _ := age.GenerateX25519Identity()
+	recipientsFile := filepath.Join(dir, "recipients")
+	identityFile := filepath.Join(dir, "identity")
+	os.WriteFile(recipientsFile, []byte(id.Recipient().String()+"\n"), 0600)
+	os.WriteFile(identityFile, []byte(id.String()+"\n"), 0600)
+	enc, err = LoadEncryption(EncryptionOptions{AgeRecipientsFile: recipientsFile, AgeIdentityFile: identityFile})
+	if err != nil {
+		t.Fatalf("Failed to load age encryption: %s", err)
+	}
+	if len(enc.ageRecipients) != 1 || len(enc.ageIdentities) != 1 {
+		t.Errorf("Expected one recipient and identity, got %d and %d", len(enc.ageRecipients), len(enc.ageIdentities))
+	}
+}
+
+func TestLoadEncryptionIdentitiesOnly(t *testing.T) {
+	id, _ := age.GenerateX25519Identity()
+	identityFile := filepath.Join(t.TempDir(), "identity")
+	if err := os.WriteFile(identityFile, []byte(id.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := LoadEncryption(EncryptionOptions{AgeIdentityFile: identityFile})
+	if err != nil {
+		t.Fatalf("Failed to load age encryption: %s", err)
+	}
+
+	secrets := newTestFixtureSecrets(t, releaseStub("smug-pigeon", 1, "default", common.StatusDeployed))
+	secrets.Encryption = enc
+	if n, err := secrets.Rekey(); err != nil || n != 1 {
+		t.Fatalf("Expected 1 rekeyed record, got %d, %v", n, err)
+	}
+	obj := secrets.impl.(*MockSecretsInterface).objects[testKey("smug-pigeon", 1)]
+	raw, _ := b64.DecodeString(string(obj.Data["release"]))
+	if !isEncrypted(raw) {
+		t.Error("Expected the record to be encrypted to the identity")
+	}
+	if _, err := secrets.Get(testKey("smug-pigeon", 1)); err != nil {
+		t.Errorf("Failed to get rekeyed release: %s", err)
+	}
+
+	// Identities without a recipient only decrypt
+	secrets.Encryption = NewAgeEncryption(nil, []age.Identity{id})
+	if _, err := secrets.Rekey(); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("Expected ErrNoEncryptionKey, got %v", err)
+	}
+	if err := secrets.Update(testKey("smug-pigeon", 1), releaseStub("smug-pigeon", 1, "default", common.StatusSuperseded)); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("Expected ErrNoEncryptionKey, got %v", err)
+	}
+	if _, err := secrets.Get(testKey("smug-pigeon", 1)); err != nil {
+		t.Errorf("Expected the record to be left encrypted, got %s", err)
+	}
+}
+
+func TestSecretsRekey(t *testing.T) {
+	rels := []*rspb.Release{
+		releaseStub("smug-pigeon", 1, "default", common.StatusSuperseded),
+		releaseStub("smug-pigeon", 2, "default", common.StatusDeployed),
+	}
+	secrets := newTestFixtureSecrets(t, rels...)
+	secrets.Encryption, _ = NewAESEncryption(testAESKey(1))
+
+	n, err := secrets.Rekey()
+	if err != nil {
+		t.Fatalf("Failed to rekey: %s", err)
+	}
+	if n != len(rels) {
+		t.Errorf("Expected %d rekeyed records, got %d", len(rels), n)
+	}
+
+	mock := secrets.impl.(*MockSecretsInterface)
+	for key, obj := range mock.objects {
+		raw, _ := b64.DecodeString(string(obj.Data["release"]))
+		if !isEncrypted(raw) {
+			t.Errorf("Expected %s to be encrypted", key)
+		}
+	}
+	if _, err := secrets.Get(testKey("smug-pigeon", 2)); err != nil {
+		t.Errorf("Failed to get rekeyed release: %s", err)
+	}
+
+	// Without the key the records can no longer be rekeyed
+	secrets.Encryption, _ = NewAESEncryption(testAESKey(2))
+	if _, err := secrets.Rekey(); !errors.Is(err, ErrEncryptedRelease) {
+		t.Errorf("Expected ErrEncryptedRelease, got %v", err)
+	}
+}
+
+func TestConfigMapsRekey(t *testing.T) {
+	cfgmaps := newTestFixtureCfgMaps(t, releaseStub("smug-pigeon", 1, "default", common.StatusDeployed))
+	cfgmaps.Encryption, _ = NewAESEncryption(testAESKey(1))
+
+	if n, err := cfgmaps.Rekey(); err != nil || n != 1 {
+		t.Fatalf("Expected 1 rekeyed record, got %d, %v", n, err)
+	}
+	obj := cfgmaps.impl.(*MockConfigMapsInterface).objects[testKey("smug-pigeon", 1)]
+	raw, _ := b64.DecodeString(obj.Data["release"])
+	if !isEncrypted(raw) {
+		t.Error("Expected the record to be encrypted")
+	}
+}
+
+func TestSecretsListEncrypted(t *testing.T) {
+	secrets := newTestFixtureSecrets(t, releaseStub("smug-pigeon", 1, "default", common.StatusDeployed))
+	secrets.Encryption, _ = NewAESEncryption(testAESKey(1))
+	if _, err := secrets.Rekey(); err != nil {
+		t.Fatalf("Failed to rekey: %s", err)
+	}
+	// Corrupt legacy records are skipped
+	mock := secrets.impl.(*MockSecretsInterface)
+	mock.objects["corrupt"] = &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "corrupt", Labels: map[string]string{"owner": "helm", "name": "smug-pigeon"}},
+		Data:       map[string][]byte{"release": []byte("corrupt")},
+	}
+
+	all := func(release.Releaser) bool { return true }
+	if rels, err := secrets.List(all); err != nil || len(rels) != 1 {
+		t.Fatalf("Expected 1 release, got %d, %v", len(rels), err)
+	}
+	if rels, err := secrets.Query(map[string]string{"name": "smug-pigeon"}); err != nil || len(rels) != 1 {
+		t.Fatalf("Expected 1 release, got %d, %v", len(rels), err)
+	}
+
+	// Encrypted records which cannot be decrypted fail instead
+	secrets.Encryption, _ = NewAESEncryption(testAESKey(2))
+	if _, err := secrets.List(all); !errors.Is(err, ErrEncryptedRelease) {
+		t.Errorf("Expected ErrEncryptedRelease, got %v", err)
+	}
+	if _, err := secrets.Query(map[string]string{"name": "smug-pigeon"}); !errors.Is(err, ErrEncryptedRelease) {
+		t.Errorf("Expected ErrEncryptedRelease, got %v", err)
+	}
+}
+
+func TestConfigMapsListEncrypted(t *testing.T) {
+	cfgmaps := newTestFixtureCfgMaps(t, releaseStub("smug-pigeon", 1, "default", common.StatusDeployed))
+	cfgmaps.Encryption, _ = NewAESEncryption(testAESKey(1))
+	if _, err := cfgmaps.Rekey(); err != nil {
+		t.Fatalf("Failed to rekey: %s", err)
+	}
+
+	all := func(release.Releaser) bool { return true }
+	if rels, err := cfgmaps.List(all); err != nil || len(rels) != 1 {
+		t.Fatalf("Expected 1 release, got %d, %v", len(rels), err)
+	}
+
+	cfgmaps.Encryption = nil
+	if _, err := cfgmaps.List(all); !errors.Is(err, ErrEncryptedRelease) {
+		t.Errorf("Expected ErrEncryptedRelease, got %v", err)
+	}
+	if _, err := cfgmaps.Query(map[string]string{"name": "smug-pigeon"}); !errors.Is(err, ErrEncryptedRelease) {
+		t.Errorf("Expected ErrEncryptedRelease, got %v", err)
+	}
+}
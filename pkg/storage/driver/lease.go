@@ -0,0 +1,201 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v4/pkg/storage/driver"
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcoordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+var _ Locker = (*Leases)(nil)
+
+const (
+	// leasePrefix is prepended to the release name to form the Lease name.
+	leasePrefix = "sh.helm.release.lock."
+	// leaseOperationAnnotation records the operation a Lease was acquired for.
+	leaseOperationAnnotation = "helm.sh/lock-operation"
+)
+
+// Leases is a Locker backed by coordination.k8s.io/v1 Leases, one per
+// release, in the namespace of the release.
+type Leases struct {
+	impl typedcoordinationv1.LeaseInterface
+}
+
+// NewLeases initializes a new Leases wrapping an implementation of the
+// kubernetes LeaseInterface.
+func NewLeases(impl typedcoordinationv1.LeaseInterface) *Leases {
+	return &Leases{impl: impl}
+}
+
+// Lock acquires the Lease of a release, taking it over if it has expired.
+func (l *Leases) Lock(lock *LockInfo) error {
+	now := time.Now()
+	lock.Acquired = now
+	lock.Renewed = now
+
+	obj, err := l.impl.Get(context.Background(), leasePrefix+lock.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		obj = newLeaseObject(lock)
+		if _, err := l.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return l.lockedError(lock.Name)
+			}
+			return fmt.Errorf("lock: failed to create lease: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("lock: failed to get lease: %w", err)
+	}
+
+	if current := leaseToLockInfo(lock.Name, obj); current.Holder != lock.Holder && current.Holder != "" && !current.Expired(now) {
+		return &LockedError{Lock: current}
+	}
+	updated := newLeaseObject(lock)
+	updated.ResourceVersion = obj.ResourceVersion
+	if _, err := l.impl.Update(context.Background(), updated, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return l.lockedError(lock.Name)
+		}
+		return fmt.Errorf("lock: failed to update lease: %w", err)
+	}
+	return nil
+}
+
+// Renew extends the Lease of a release held by holder.
+func (l *Leases) Renew(name, holder string) error {
+	obj, err := l.impl.Get(context.Background(), leasePrefix+name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("renew: failed to get lease: %w", err)
+	}
+	if current := leaseToLockInfo(name, obj); current.Holder != holder {
+		return &LockedError{Lock: current}
+	}
+	obj.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+	if _, err := l.impl.Update(context.Background(), obj, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return l.lockedError(name)
+		}
+		return fmt.Errorf("renew: failed to update lease: %w", err)
+	}
+	return nil
+}
+
+// Unlock deletes the Lease of a release if it is held by holder.
+func (l *Leases) Unlock(name, holder string) error {
+	obj, err := l.impl.Get(context.Background(), leasePrefix+name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unlock: failed to get lease: %w", err)
+	}
+	if leaseToLockInfo(name, obj).Holder != holder {
+		return nil
+	}
+	err = l.impl.Delete(context.Background(), obj.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &obj.ResourceVersion},
+	})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return fmt.Errorf("unlock: failed to delete lease: %w", err)
+	}
+	return nil
+}
+
+// GetLock returns the lock described by the Lease of a release.
+func (l *Leases) GetLock(name string) (*LockInfo, error) {
+	obj, err := l.impl.Get(context.Background(), leasePrefix+name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrNotLocked
+		}
+		return nil, fmt.Errorf("get lock: failed to get lease: %w", err)
+	}
+	return leaseToLockInfo(name, obj), nil
+}
+
+// ForceUnlock deletes the Lease of a release regardless of its holder.
+func (l *Leases) ForceUnlock(name string) error {
+	err := l.impl.Delete(context.Background(), leasePrefix+name, metav1.DeleteOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ErrNotLocked
+		}
+		return fmt.Errorf("force unlock: failed to delete lease: %w", err)
+	}
+	return nil
+}
+
+// lockedError returns the error for a Lease that was acquired concurrently.
+func (l *Leases) lockedError(name string) error {
+	current, err := l.GetLock(name)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLocked, err)
+	}
+	return &LockedError{Lock: current}
+}
+
+// newLeaseObject constructs the Lease object of a release lock.
+func newLeaseObject(lock *LockInfo) *coordinationv1.Lease {
+	seconds := int32(lock.Duration / time.Second)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: leasePrefix + lock.Name,
+			Labels: map[string]string{
+				"name":  lock.Name,
+				"owner": "helm",
+			},
+			Annotations: map[string]string{
+				leaseOperationAnnotation: lock.Operation,
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &lock.Holder,
+			LeaseDurationSeconds: &seconds,
+			AcquireTime:          &metav1.MicroTime{Time: lock.Acquired},
+			RenewTime:            &metav1.MicroTime{Time: lock.Renewed},
+		},
+	}
+}
+
+// leaseToLockInfo converts a Lease object into the lock it describes.
+func leaseToLockInfo(name string, obj *coordinationv1.Lease) *LockInfo {
+	info := &LockInfo{
+		Name:      name,
+		Operation: obj.Annotations[leaseOperationAnnotation],
+	}
+	if obj.Spec.HolderIdentity != nil {
+		info.Holder = *obj.Spec.HolderIdentity
+	}
+	if obj.Spec.LeaseDurationSeconds != nil {
+		info.Duration = time.Duration(*obj.Spec.LeaseDurationSeconds) * time.Second
+	}
+	if obj.Spec.AcquireTime != nil {
+		info.Acquired = obj.Spec.AcquireTime.Time
+	}
+	if obj.Spec.RenewTime != nil {
+		info.Renewed = obj.Spec.RenewTime.Time
+	}
+	return info
+}
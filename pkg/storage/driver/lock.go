@@ -0,0 +1,88 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v4/pkg/storage/driver"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrLocked indicates that the lock of a release is held by another holder.
+	ErrLocked = errors.New("release is locked")
+	// ErrNotLocked indicates that no lock is held for a release.
+	ErrNotLocked = errors.New("release is not locked")
+)
+
+// LockInfo describes the lock of a release.
+type LockInfo struct {
+	// Name is the name of the locked release.
+	Name string
+	// Holder identifies the process holding the lock.
+	Holder string
+	// Operation is the operation the lock was acquired for, e.g. "upgrade".
+	Operation string
+	// Acquired is the time the lock was acquired.
+	Acquired time.Time
+	// Renewed is the time the lock was last renewed.
+	Renewed time.Time
+	// Duration is the time after the last renewal at which the lock expires.
+	Duration time.Duration
+}
+
+// Expired reports whether the lock has not been renewed within its duration.
+func (l *LockInfo) Expired(now time.Time) bool {
+	return now.After(l.Renewed.Add(l.Duration))
+}
+
+// LockedError is returned when a release lock is held by another holder.
+type LockedError struct {
+	Lock *LockInfo
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("release %q is locked by %s for %s since %s", e.Lock.Name, e.Lock.Holder, e.Lock.Operation, e.Lock.Acquired.Format(time.RFC3339))
+}
+
+// Is makes LockedError match ErrLocked.
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Locker is implemented by backends which provide mutual exclusion between
+// operations on the same release.
+//
+// A lock is held until it is unlocked or until it has not been renewed for its
+// duration, after which another holder may take it over.
+type Locker interface {
+	// Lock acquires the lock described by lock. Acquired and Renewed are set
+	// by the Locker. It returns a *LockedError if the lock is held by another
+	// holder and has not expired.
+	Lock(lock *LockInfo) error
+	// Renew extends a lock held by holder. It returns a *LockedError if the
+	// lock has been taken over by another holder.
+	Renew(name, holder string) error
+	// Unlock releases a lock held by holder. Locks held by other holders are
+	// left in place.
+	Unlock(name, holder string) error
+	// GetLock returns the lock of the named release, or ErrNotLocked.
+	GetLock(name string) (*LockInfo, error)
+	// ForceUnlock removes the lock of the named release regardless of its
+	// holder. It returns ErrNotLocked if the release is not locked.
+	ForceUnlock(name string) error
+}
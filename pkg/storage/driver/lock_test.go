@@ -0,0 +1,101 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeasesLocker(t *testing.T) {
+	testLocker(t, NewLeases(fake.NewClientset().CoordinationV1().Leases("default")))
+}
+
+func TestSQLiteLocker(t *testing.T) {
+	d, _ := newTestFixtureSQLite(t)
+	testLocker(t, d)
+}
+
+func testLocker(t *testing.T, l Locker) {
+	t.Helper()
+
+	if _, err := l.GetLock("smug-pigeon"); !errors.Is(err, ErrNotLocked) {
+		t.Fatalf("Expected ErrNotLocked, got %v", err)
+	}
+
+	lock := &LockInfo{Name: "smug-pigeon", Holder: "a", Operation: "upgrade", Duration: time.Minute}
+	if err := l.Lock(lock); err != nil {
+		t.Fatalf("Failed to lock: %s", err)
+	}
+	// Locking again as the same holder succeeds
+	if err := l.Lock(lock); err != nil {
+		t.Fatalf("Failed to lock again: %s", err)
+	}
+
+	err := l.Lock(&LockInfo{Name: "smug-pigeon", Holder: "b", Operation: "rollback", Duration: time.Minute})
+	var lerr *LockedError
+	if !errors.As(err, &lerr) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected a LockedError, got %v", err)
+	}
+	if lerr.Lock.Holder != "a" || lerr.Lock.Operation != "upgrade" || lerr.Lock.Duration != time.Minute {
+		t.Errorf("Unexpected lock info %+v", lerr.Lock)
+	}
+
+	if err := l.Renew("smug-pigeon", "a"); err != nil {
+		t.Errorf("Failed to renew: %s", err)
+	}
+	if err := l.Renew("smug-pigeon", "b"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked renewing as another holder, got %v", err)
+	}
+
+	// Unlocking as another holder leaves the lock in place
+	if err := l.Unlock("smug-pigeon", "b"); err != nil {
+		t.Errorf("Failed to unlock: %s", err)
+	}
+	if _, err := l.GetLock("smug-pigeon"); err != nil {
+		t.Errorf("Expected the lock to be held, got %v", err)
+	}
+	if err := l.Unlock("smug-pigeon", "a"); err != nil {
+		t.Errorf("Failed to unlock: %s", err)
+	}
+	if _, err := l.GetLock("smug-pigeon"); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("Expected ErrNotLocked after unlock, got %v", err)
+	}
+
+	// An expired lock is taken over
+	if err := l.Lock(&LockInfo{Name: "smug-pigeon", Holder: "a", Operation: "install"}); err != nil {
+		t.Fatalf("Failed to lock: %s", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := l.Lock(&LockInfo{Name: "smug-pigeon", Holder: "b", Operation: "uninstall", Duration: time.Minute}); err != nil {
+		t.Fatalf("Failed to take over an expired lock: %s", err)
+	}
+	info, err := l.GetLock("smug-pigeon")
+	if err != nil || info.Holder != "b" {
+		t.Fatalf("Expected the lock to be held by b, got %+v, %v", info, err)
+	}
+
+	if err := l.ForceUnlock("smug-pigeon"); err != nil {
+		t.Errorf("Failed to force unlock: %s", err)
+	}
+	if err := l.ForceUnlock("smug-pigeon"); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("Expected ErrNotLocked, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v4/pkg/storage/driver"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"helm.sh/helm/v4/internal/plugin"
+	"helm.sh/helm/v4/internal/plugin/schema"
+	"helm.sh/helm/v4/pkg/release"
+	rspb "helm.sh/helm/v4/pkg/release/v1"
+)
+
+var _ Driver = (*Plugin)(nil)
+
+// PluginDriverName is the string name of this driver.
+const PluginDriverName = "Plugin"
+
+// Plugin is a storage driver that delegates to a storage/v1 plugin, allowing
+// release records to be kept in stores Helm has no built-in support for.
+type Plugin struct {
+	plugin    plugin.Plugin
+	namespace string
+}
+
+// NewPlugin initializes a new driver backed by the storage/v1 plugin with the
+// given name, found in one of pluginsDirs.
+func NewPlugin(pluginsDirs []string, name string, namespace string) (*Plugin, error) {
+	descriptor := plugin.Descriptor{
+		Name: name,
+		Type: "storage/v1",
+	}
+	p, err := plugin.FindPlugin(pluginsDirs, descriptor)
+	if err != nil {
+		return nil, err
+	}
+	return newPlugin(p, namespace), nil
+}
+
+func newPlugin(p plugin.Plugin, namespace string) *Plugin {
+	return &Plugin{
+		plugin:    p,
+		namespace: namespace,
+	}
+}
+
+// Name returns the name of the driver.
+func (p *Plugin) Name() string {
+	return PluginDriverName
+}
+
+// Get returns the release named by key or ErrReleaseNotFound.
+func (p *Plugin) Get(key string) (release.Releaser, error) {
+	out, err := p.invoke(schema.InputMessageStorageV1{
+		Operation: schema.StorageOperationGetV1,
+		Namespace: p.namespace,
+		Key:       key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Records) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+	r, err := decodeRelease(out.Records[0].Body)
+	if err != nil {
+		return nil, fmt.Errorf("get: failed to decode data %q: %w", key, err)
+	}
+	r.Labels = filterSystemLabels(out.Records[0].Labels)
+	return r, nil
+}
+
+// List returns the list of all releases such that filter(release) == true.
+func (p *Plugin) List(filter func(release.Releaser) bool) ([]release.Releaser, error) {
+	out, err := p.invoke(schema.InputMessageStorageV1{
+		Operation: schema.StorageOperationListV1,
+		Namespace: p.namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []release.Releaser
+	for _, rec := range p.decodeRecords(out.Records) {
+		if filter(rec) {
+			results = append(results, rec)
+		}
+	}
+	return results, nil
+}
+
+// Query returns the set of releases that match the provided set of labels.
+func (p *Plugin) Query(labels map[string]string) ([]release.Releaser, error) {
+	out, err := p.invoke(schema.InputMessageStorageV1{
+		Operation: schema.StorageOperationQueryV1,
+		Namespace: p.namespace,
+		Labels:    labels,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := p.decodeRecords(out.Records)
+	if len(results) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+	return results, nil
+}
+
+// Create creates a new release or returns ErrReleaseExists.
+func (p *Plugin) Create(key string, rel release.Releaser) error {
+	rls, err := releaserToV1Release(rel)
+	if err != nil {
+		return err
+	}
+	rec, err := newPluginRecord(key, rls, "createdAt")
+	if err != nil {
+		return fmt.Errorf("create: failed to encode release %q: %w", rls.Name, err)
+	}
+	_, err = p.invoke(schema.InputMessageStorageV1{
+		Operation: schema.StorageOperationCreateV1,
+		Namespace: rec.Namespace,
+		Key:       key,
+		Record:    rec,
+	})
+	return err
+}
+
+// Update updates a release or returns ErrReleaseNotFound.
+func (p *Plugin) Update(key string, rel release.Releaser) error {
+	rls, err := releaserToV1Release(rel)
+	if err != nil {
+		return err
+	}
+	rec, err := newPluginRecord(key, rls, "modifiedAt")
+	if err != nil {
+		return fmt.Errorf("update: failed to encode release %q: %w", rls.Name, err)
+	}
+	_, err = p.invoke(schema.InputMessageStorageV1{
+		Operation: schema.StorageOperationUpdateV1,
+		Namespace: rec.Namespace,
+		Key:       key,
+		Record:    rec,
+	})
+	return err
+}
+
+// Delete deletes a release or returns ErrReleaseNotFound.
+func (p *Plugin) Delete(key string) (release.Releaser, error) {
+	out, err := p.invoke(schema.InputMessageStorageV1{
+		Operation: schema.StorageOperationDeleteV1,
+		Namespace: p.namespace,
+		Key:       key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Records) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+	r, err := decodeRelease(out.Records[0].Body)
+	if err != nil {
+		return nil, fmt.Errorf("delete: failed to decode data %q: %w", key, err)
+	}
+	r.Labels = filterSystemLabels(out.Records[0].Labels)
+	return r, nil
+}
+
+func (p *Plugin) invoke(msg schema.InputMessageStorageV1) (*schema.OutputMessageStorageV1, error) {
+	output, err := p.plugin.Invoke(context.Background(), &plugin.Input{Message: msg})
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to invoke storage plugin %q: %w", msg.Operation, p.plugin.Metadata().Name, err)
+	}
+	out, ok := output.Message.(schema.OutputMessageStorageV1)
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected output message type %T from storage plugin %q", msg.Operation, output.Message, p.plugin.Metadata().Name)
+	}
+
+	switch out.Error {
+	case "":
+		return &out, nil
+	case schema.StorageErrorNotFoundV1:
+		return nil, ErrReleaseNotFound
+	case schema.StorageErrorAlreadyExistsV1:
+		return nil, ErrReleaseExists
+	default:
+		return nil, fmt.Errorf("%s: storage plugin %q: %w", msg.Operation, p.plugin.Metadata().Name, errors.New(out.Error))
+	}
+}
+
+func (p *Plugin) decodeRecords(records []schema.StorageRecordV1) []release.Releaser {
+	var results []release.Releaser
+	for _, rec := range records {
+		rls, err := decodeRelease(rec.Body)
+		if err != nil {
+			slog.Debug("failed to decode release", "key", rec.Key, slog.Any("error", err))
+			continue
+		}
+		rls.Labels = rec.Labels
+		results = append(results, rls)
+	}
+	return results
+}
+
+// newPluginRecord encodes a release for a storage plugin. The record carries
+// the same labels as the Secret and ConfigMap drivers, with timestampLabel set
+// to the current time.
+func newPluginRecord(key string, rls *rspb.Release, timestampLabel string) (*schema.StorageRecordV1, error) {
+	body, err := encodeRelease(rls)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := rls.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	var lbs labels
+	lbs.init()
+	lbs.fromMap(rls.Labels)
+	lbs.set(timestampLabel, strconv.FormatInt(time.Now().Unix(), 10))
+	lbs.set("name", rls.Name)
+	lbs.set("owner", "helm")
+	lbs.set("status", rls.Info.Status.String())
+	lbs.set("version", strconv.Itoa(rls.Version))
+
+	return &schema.StorageRecordV1{
+		Key:       key,
+		Namespace: namespace,
+		Labels:    lbs.toMap(),
+		Body:      body,
+	}, nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"helm.sh/helm/v4/internal/plugin"
+	"helm.sh/helm/v4/internal/plugin/schema"
+	"helm.sh/helm/v4/pkg/release"
+	"helm.sh/helm/v4/pkg/release/common"
+	rspb "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// mockStoragePlugin is an in-memory storage/v1 plugin
+type mockStoragePlugin struct {
+	records map[string]schema.StorageRecordV1
+}
+
+func (m *mockStoragePlugin) Dir() string { return "" }
+
+func (m *mockStoragePlugin) Metadata() plugin.Metadata {
+	return plugin.Metadata{Name: "mock", Type: "storage/v1"}
+}
+
+func (m *mockStoragePlugin) Invoke(_ context.Context, input *plugin.Input) (*plugin.Output, error) {
+	msg := input.Message.(schema.InputMessageStorageV1)
+	id := msg.Namespace + "/" + msg.Key
+	var out schema.OutputMessageStorageV1
+
+	switch msg.Operation {
+	case schema.StorageOperationCreateV1:
+		if _, ok := m.records[id]; ok {
+			out.Error = schema.StorageErrorAlreadyExistsV1
+			break
+		}
+		m.records[id] = *msg.Record
+	case schema.StorageOperationUpdateV1:
+		if _, ok := m.records[id]; !ok {
+			out.Error = schema.StorageErrorNotFoundV1
+			break
+		}
+		m.records[id] = *msg.Record
+	case schema.StorageOperationGetV1, schema.StorageOperationDeleteV1:
+		rec, ok := m.records[id]
+		if !ok {
+			out.Error = schema.StorageErrorNotFoundV1
+			break
+		}
+		if msg.Operation == schema.StorageOperationDeleteV1 {
+			delete(m.records, id)
+		}
+		out.Records = []schema.StorageRecordV1{rec}
+	case schema.StorageOperationListV1, schema.StorageOperationQueryV1:
+		for _, rec := range m.records {
+			if msg.Namespace != "" && rec.Namespace != msg.Namespace {
+				continue
+			}
+			if labels(rec.Labels).match(msg.Labels) {
+				out.Records = append(out.Records, rec)
+			}
+		}
+	default:
+		out.Error = "unsupported operation"
+	}
+	return &plugin.Output{Message: out}, nil
+}
+
+func newTestFixturePlugin(t *testing.T, releases ...*rspb.Release) *Plugin {
+	t.Helper()
+	p := newPlugin(&mockStoragePlugin{records: map[string]schema.StorageRecordV1{}}, "default")
+	for _, rls := range releases {
+		if err := p.Create(testKey(rls.Name, rls.Version), rls); err != nil {
+			t.Fatalf("failed to create release: %v", err)
+		}
+	}
+	return p
+}
+
+func TestPluginName(t *testing.T) {
+	p := newTestFixturePlugin(t)
+	if p.Name() != PluginDriverName {
+		t.Errorf("Expected name to be %s, got %s", PluginDriverName, p.Name())
+	}
+}
+
+func TestPluginCreateGet(t *testing.T) {
+	rel := releaseStub("smug-pigeon", 1, "default", common.StatusDeployed)
+	key := testKey(rel.Name, rel.Version)
+	p := newTestFixturePlugin(t, rel)
+
+	if err := p.Create(key, rel); !errors.Is(err, ErrReleaseExists) {
+		t.Errorf("Expected ErrReleaseExists, got %v", err)
+	}
+
+	got, err := p.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %v", err)
+	}
+	gotRel := got.(*rspb.Release)
+	if gotRel.Name != rel.Name || gotRel.Version != rel.Version {
+		t.Errorf("Expected release %s.v%d, got %s.v%d", rel.Name, rel.Version, gotRel.Name, gotRel.Version)
+	}
+	if gotRel.Labels["key1"] != "val1" || ContainsSystemLabels(gotRel.Labels) {
+		t.Errorf("Expected only custom labels, got %v", gotRel.Labels)
+	}
+
+	if _, err := p.Get(testKey("missing", 1)); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("Expected ErrReleaseNotFound, got %v", err)
+	}
+}
+
+func TestPluginListQuery(t *testing.T) {
+	p := newTestFixturePlugin(t,
+		releaseStub("key-1", 1, "default", common.StatusSuperseded),
+		releaseStub("key-1", 2, "default", common.StatusDeployed),
+		releaseStub("key-2", 1, "default", common.StatusDeployed),
+	)
+
+	deployed, err := p.List(func(rls release.Releaser) bool {
+		return rls.(*rspb.Release).Info.Status == common.StatusDeployed
+	})
+	if err != nil {
+		t.Fatalf("Failed to list releases: %v", err)
+	}
+	if len(deployed) != 2 {
+		t.Errorf("Expected 2 deployed releases, got %d", len(deployed))
+	}
+
+	rels, err := p.Query(map[string]string{"name": "key-1", "owner": "helm"})
+	if err != nil {
+		t.Fatalf("Failed to query releases: %v", err)
+	}
+	if len(rels) != 2 {
+		t.Errorf("Expected 2 releases, got %d", len(rels))
+	}
+
+	if _, err := p.Query(map[string]string{"name": "missing"}); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("Expected ErrReleaseNotFound, got %v", err)
+	}
+}
+
+func TestPluginUpdateDelete(t *testing.T) {
+	rel := releaseStub("smug-pigeon", 1, "default", common.StatusDeployed)
+	key := testKey(rel.Name, rel.Version)
+	p := newTestFixturePlugin(t, rel)
+
+	rel.Info.Status = common.StatusSuperseded
+	if err := p.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %v", err)
+	}
+	if err := p.Update(testKey("missing", 1), rel); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("Expected ErrReleaseNotFound, got %v", err)
+	}
+
+	deleted, err := p.Delete(key)
+	if err != nil {
+		t.Fatalf("Failed to delete release: %v", err)
+	}
+	if deleted.(*rspb.Release).Info.Status != common.StatusSuperseded {
+		t.Errorf("Expected deleted release to be superseded, got %s", deleted.(*rspb.Release).Info.Status)
+	}
+	if _, err := p.Get(key); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("Expected ErrReleaseNotFound, got %v", err)
+	}
+}
@@ -18,6 +18,7 @@ package driver // import "helm.sh/helm/v4/pkg/storage/driver"
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -36,6 +37,7 @@ import (
 )
 
 var _ Driver = (*Secrets)(nil)
+var _ Rekeyer = (*Secrets)(nil)
 
 // SecretsDriverName is the string name of the driver.
 const SecretsDriverName = "Secret"
@@ -43,6 +45,10 @@ const SecretsDriverName = "Secret"
 // Secrets is a wrapper around an implementation of a kubernetes
 // SecretsInterface.
 type Secrets struct {
+	// Encryption, if set, encrypts release records before they are stored.
+	// Unencrypted records are still read.
+	Encryption *Encryption
+
 	impl corev1.SecretInterface
 }
 
@@ -71,7 +77,7 @@ func (secrets *Secrets) Get(key string) (release.Releaser, error) {
 		return nil, fmt.Errorf("get: failed to get %q: %w", key, err)
 	}
 	// found the secret, decode the base64 data string
-	r, err := decodeRelease(string(obj.Data["release"]))
+	r, err := decodeReleaseEncrypted(string(obj.Data["release"]), secrets.Encryption, key)
 	if err != nil {
 		return r, fmt.Errorf("get: failed to decode data %q: %w", key, err)
 	}
@@ -81,7 +87,8 @@ func (secrets *Secrets) Get(key string) (release.Releaser, error) {
 
 // List fetches all releases and returns the list releases such
 // that filter(release) == true. An error is returned if the
-// secret fails to retrieve the releases.
+// secret fails to retrieve the releases. Records which fail to decode are
+// skipped, unless they are encrypted and cannot be decrypted.
 func (secrets *Secrets) List(filter func(release.Releaser) bool) ([]release.Releaser, error) {
 	lsel := kblabels.Set{"owner": "helm"}.AsSelector()
 	opts := metav1.ListOptions{LabelSelector: lsel.String()}
@@ -96,8 +103,11 @@ func (secrets *Secrets) List(filter func(release.Releaser) bool) ([]release.Rele
 	// iterate over the secrets object list
 	// and decode each release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+		rls, err := decodeReleaseEncrypted(string(item.Data["release"]), secrets.Encryption, item.Name)
 		if err != nil {
+			if isDecryptionError(err) {
+				return nil, fmt.Errorf("list: failed to decode release %q: %w", item.Name, err)
+			}
 			slog.Debug("list failed to decode release", "key", item.Name, slog.Any("error", err))
 			continue
 		}
@@ -112,7 +122,9 @@ func (secrets *Secrets) List(filter func(release.Releaser) bool) ([]release.Rele
 }
 
 // Query fetches all releases that match the provided map of labels.
-// An error is returned if the secret fails to retrieve the releases.
+// An error is returned if the secret fails to retrieve the releases. Records
+// which fail to decode are skipped, unless they are encrypted and cannot be
+// decrypted.
 func (secrets *Secrets) Query(labels map[string]string) ([]release.Releaser, error) {
 	ls := kblabels.Set{}
 	for k, v := range labels {
@@ -135,8 +147,11 @@ func (secrets *Secrets) Query(labels map[string]string) ([]release.Releaser, err
 
 	var results []release.Releaser
 	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+		rls, err := decodeReleaseEncrypted(string(item.Data["release"]), secrets.Encryption, item.Name)
 		if err != nil {
+			if isDecryptionError(err) {
+				return nil, fmt.Errorf("query: failed to decode release %q: %w", item.Name, err)
+			}
 			slog.Debug("failed to decode release", "key", item.Name, slog.Any("error", err))
 			continue
 		}
@@ -162,7 +177,7 @@ func (secrets *Secrets) Create(key string, rel release.Releaser) error {
 	lbs.set("createdAt", fmt.Sprintf("%v", time.Now().Unix()))
 
 	// create a new secret to hold the release
-	obj, err := newSecretsObject(key, rls, lbs)
+	obj, err := newSecretsObject(key, rls, lbs, secrets.Encryption)
 	if err != nil {
 		return fmt.Errorf("create: failed to encode release %q: %w", rls.Name, err)
 	}
@@ -193,7 +208,7 @@ func (secrets *Secrets) Update(key string, rel release.Releaser) error {
 	lbs.set("modifiedAt", fmt.Sprintf("%v", time.Now().Unix()))
 
 	// create a new secret object to hold the release
-	obj, err := newSecretsObject(key, rls, lbs)
+	obj, err := newSecretsObject(key, rls, lbs, secrets.Encryption)
 	if err != nil {
 		return fmt.Errorf("update: failed to encode release %q: %w", rls.Name, err)
 	}
@@ -219,6 +234,48 @@ func (secrets *Secrets) Delete(key string) (rls release.Releaser, err error) {
 	return rls, nil
 }
 
+// Rekey rewrites every release record with the current Encryption settings,
+// e.g. after a new key has been added. It returns the number of rewritten
+// records. Records that cannot be decrypted are reported and left untouched.
+// Without a key to encrypt records, Rekey fails rather than rewriting them
+// unencrypted.
+func (secrets *Secrets) Rekey() (int, error) {
+	if !secrets.Encryption.canSeal() {
+		return 0, fmt.Errorf("rekey: %w", ErrNoEncryptionKey)
+	}
+
+	lsel := kblabels.Set{"owner": "helm"}.AsSelector()
+	opts := metav1.ListOptions{LabelSelector: lsel.String()}
+
+	list, err := secrets.impl.List(context.Background(), opts)
+	if err != nil {
+		return 0, fmt.Errorf("rekey: failed to list: %w", err)
+	}
+
+	var errs []error
+	rekeyed := 0
+	for i := range list.Items {
+		item := &list.Items[i]
+		rls, err := decodeReleaseEncrypted(string(item.Data["release"]), secrets.Encryption, item.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rekey: failed to decode %q: %w", item.Name, err))
+			continue
+		}
+		s, err := encodeReleaseEncrypted(rls, secrets.Encryption, item.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rekey: failed to encode %q: %w", item.Name, err))
+			continue
+		}
+		item.Data["release"] = []byte(s)
+		if _, err := secrets.impl.Update(context.Background(), item, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("rekey: failed to update %q: %w", item.Name, err))
+			continue
+		}
+		rekeyed++
+	}
+	return rekeyed, errors.Join(errs...)
+}
+
 // newSecretsObject constructs a kubernetes Secret object
 // to store a release. Each secret data entry is the base64
 // encoded gzipped string of a release.
@@ -231,11 +288,11 @@ func (secrets *Secrets) Delete(key string) (rls release.Releaser, err error) {
 //	"status"         - status of the release (see pkg/release/status.go for variants)
 //	"owner"          - owner of the secret, currently "helm".
 //	"name"           - name of the release.
-func newSecretsObject(key string, rls *rspb.Release, lbs labels) (*v1.Secret, error) {
+func newSecretsObject(key string, rls *rspb.Release, lbs labels, enc *Encryption) (*v1.Secret, error) {
 	const owner = "helm"
 
 	// encode the release
-	s, err := encodeRelease(rls)
+	s, err := encodeReleaseEncrypted(rls, enc, key)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +62,7 @@ func TestUNcompressedSecretGet(t *testing.T) {
 	rel := releaseStub(name, vers, namespace, common.StatusDeployed)
 
 	// Create a test fixture which contains an uncompressed release
-	secret, err := newSecretsObject(key, rel, nil)
+	secret, err := newSecretsObject(key, rel, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create secret: %s", err)
 	}
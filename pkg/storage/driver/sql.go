@@ -49,6 +49,25 @@ var labelMap = map[string]struct{}{
 
 const postgreSQLDialect = "postgres"
 
+// sqlDialect captures the database specific parts of the SQL driver: how to
+// connect, how statements are parameterized and how the schema is created.
+type sqlDialect interface {
+	// driverName is the database/sql driver used to open connections.
+	driverName() string
+	// migrationDialect is the sql-migrate dialect used to track the schema.
+	migrationDialect() string
+	// placeholderFormat is the bind variable syntax used in statements.
+	placeholderFormat() sq.PlaceholderFormat
+	// dataSourceName turns the user supplied connection string into the one
+	// handed to the database/sql driver.
+	dataSourceName(connectionString string) string
+	// migrations creates the releases, custom labels and release locks tables.
+	migrations() []*migrate.Migration
+	// lockRowSuffix is appended to a select statement to lock the selected
+	// rows until the end of the transaction.
+	lockRowSuffix() string
+}
+
 // SQLDriverName is the string name of this driver.
 const SQLDriverName = "SQL"
 
@@ -87,6 +106,7 @@ const (
 // SQL is the sql storage driver implementation.
 type SQL struct {
 	db               *sqlx.DB
+	dialect          sqlDialect
 	namespace        string
 	statementBuilder sq.StatementBuilderType
 }
@@ -106,7 +126,7 @@ func (s *SQL) checkAlreadyApplied(migrations []*migrate.Migration) bool {
 
 	// get list of applied migrations
 	migrate.SetDisableCreateTable(true)
-	records, err := migrate.GetMigrationRecords(s.db.DB, postgreSQLDialect)
+	records, err := migrate.GetMigrationRecords(s.db.DB, s.dialect.migrationDialect())
 	migrate.SetDisableCreateTable(false)
 	if err != nil {
 		slog.Debug("failed to get migration records", slog.Any("error", err))
@@ -130,111 +150,165 @@ func (s *SQL) checkAlreadyApplied(migrations []*migrate.Migration) bool {
 	return true
 }
 
-func (s *SQL) ensureDBSetup() error {
-
-	migrations := &migrate.MemoryMigrationSource{
-		Migrations: []*migrate.Migration{
-			{
-				Id: "init",
-				Up: []string{
-					fmt.Sprintf(`
-						CREATE TABLE %s (
-							%s VARCHAR(90),
-							%s VARCHAR(64) NOT NULL,
-							%s TEXT NOT NULL,
-							%s VARCHAR(64) NOT NULL,
-							%s VARCHAR(64) NOT NULL,
-							%s INTEGER NOT NULL,
-							%s TEXT NOT NULL,
-							%s TEXT NOT NULL,
-							%s INTEGER NOT NULL,
-							%s INTEGER NOT NULL DEFAULT 0,
-							PRIMARY KEY(%s, %s)
-						);
-						CREATE INDEX ON %s (%s, %s);
-						CREATE INDEX ON %s (%s);
-						CREATE INDEX ON %s (%s);
-						CREATE INDEX ON %s (%s);
-						CREATE INDEX ON %s (%s);
-						CREATE INDEX ON %s (%s);
-	
-						GRANT ALL ON %s TO PUBLIC;
-	
-						ALTER TABLE %s ENABLE ROW LEVEL SECURITY;
-					`,
-						sqlReleaseTableName,
-						sqlReleaseTableKeyColumn,
-						sqlReleaseTableTypeColumn,
-						sqlReleaseTableBodyColumn,
-						sqlReleaseTableNameColumn,
-						sqlReleaseTableNamespaceColumn,
-						sqlReleaseTableVersionColumn,
-						sqlReleaseTableStatusColumn,
-						sqlReleaseTableOwnerColumn,
-						sqlReleaseTableCreatedAtColumn,
-						sqlReleaseTableModifiedAtColumn,
-						sqlReleaseTableKeyColumn,
-						sqlReleaseTableNamespaceColumn,
-						sqlReleaseTableName,
-						sqlReleaseTableKeyColumn,
-						sqlReleaseTableNamespaceColumn,
-						sqlReleaseTableName,
-						sqlReleaseTableVersionColumn,
-						sqlReleaseTableName,
-						sqlReleaseTableStatusColumn,
-						sqlReleaseTableName,
-						sqlReleaseTableOwnerColumn,
-						sqlReleaseTableName,
-						sqlReleaseTableCreatedAtColumn,
-						sqlReleaseTableName,
-						sqlReleaseTableModifiedAtColumn,
-						sqlReleaseTableName,
-						sqlReleaseTableName,
-					),
-				},
-				Down: []string{
-					fmt.Sprintf(`
-						DROP TABLE %s;
-					`, sqlReleaseTableName),
-				},
+// postgresDialect stores releases in PostgreSQL using lib/pq.
+type postgresDialect struct{}
+
+func (postgresDialect) driverName() string { return postgreSQLDialect }
+
+func (postgresDialect) migrationDialect() string { return postgreSQLDialect }
+
+func (postgresDialect) placeholderFormat() sq.PlaceholderFormat { return sq.Dollar }
+
+func (postgresDialect) dataSourceName(connectionString string) string { return connectionString }
+
+func (postgresDialect) lockRowSuffix() string { return "FOR UPDATE" }
+
+func (postgresDialect) migrations() []*migrate.Migration {
+	return []*migrate.Migration{
+		{
+			Id: "init",
+			Up: []string{
+				fmt.Sprintf(`
+					CREATE TABLE %s (
+						%s VARCHAR(90),
+						%s VARCHAR(64) NOT NULL,
+						%s TEXT NOT NULL,
+						%s VARCHAR(64) NOT NULL,
+						%s VARCHAR(64) NOT NULL,
+						%s INTEGER NOT NULL,
+						%s TEXT NOT NULL,
+						%s TEXT NOT NULL,
+						%s INTEGER NOT NULL,
+						%s INTEGER NOT NULL DEFAULT 0,
+						PRIMARY KEY(%s, %s)
+					);
+					CREATE INDEX ON %s (%s, %s);
+					CREATE INDEX ON %s (%s);
+					CREATE INDEX ON %s (%s);
+					CREATE INDEX ON %s (%s);
+					CREATE INDEX ON %s (%s);
+					CREATE INDEX ON %s (%s);
+
+					GRANT ALL ON %s TO PUBLIC;
+
+					ALTER TABLE %s ENABLE ROW LEVEL SECURITY;
+				`,
+					sqlReleaseTableName,
+					sqlReleaseTableKeyColumn,
+					sqlReleaseTableTypeColumn,
+					sqlReleaseTableBodyColumn,
+					sqlReleaseTableNameColumn,
+					sqlReleaseTableNamespaceColumn,
+					sqlReleaseTableVersionColumn,
+					sqlReleaseTableStatusColumn,
+					sqlReleaseTableOwnerColumn,
+					sqlReleaseTableCreatedAtColumn,
+					sqlReleaseTableModifiedAtColumn,
+					sqlReleaseTableKeyColumn,
+					sqlReleaseTableNamespaceColumn,
+					sqlReleaseTableName,
+					sqlReleaseTableKeyColumn,
+					sqlReleaseTableNamespaceColumn,
+					sqlReleaseTableName,
+					sqlReleaseTableVersionColumn,
+					sqlReleaseTableName,
+					sqlReleaseTableStatusColumn,
+					sqlReleaseTableName,
+					sqlReleaseTableOwnerColumn,
+					sqlReleaseTableName,
+					sqlReleaseTableCreatedAtColumn,
+					sqlReleaseTableName,
+					sqlReleaseTableModifiedAtColumn,
+					sqlReleaseTableName,
+					sqlReleaseTableName,
+				),
+			},
+			Down: []string{
+				fmt.Sprintf(`
+					DROP TABLE %s;
+				`, sqlReleaseTableName),
+			},
+		},
+		{
+			Id: "custom_labels",
+			Up: []string{
+				fmt.Sprintf(`
+					CREATE TABLE %s (
+						%s VARCHAR(64),
+						%s VARCHAR(67),
+						%s VARCHAR(%d),
+						%s VARCHAR(%d)
+					);
+					CREATE INDEX ON %s (%s, %s);
+					
+					GRANT ALL ON %s TO PUBLIC;
+					ALTER TABLE %s ENABLE ROW LEVEL SECURITY;
+				`,
+					sqlCustomLabelsTableName,
+					sqlCustomLabelsTableReleaseKeyColumn,
+					sqlCustomLabelsTableReleaseNamespaceColumn,
+					sqlCustomLabelsTableKeyColumn,
+					sqlCustomLabelsTableKeyMaxLength,
+					sqlCustomLabelsTableValueColumn,
+					sqlCustomLabelsTableValueMaxLength,
+					sqlCustomLabelsTableName,
+					sqlCustomLabelsTableReleaseKeyColumn,
+					sqlCustomLabelsTableReleaseNamespaceColumn,
+					sqlCustomLabelsTableName,
+					sqlCustomLabelsTableName,
+				),
+			},
+			Down: []string{
+				fmt.Sprintf(`
+					DELETE TABLE %s;
+				`, sqlCustomLabelsTableName),
 			},
-			{
-				Id: "custom_labels",
-				Up: []string{
-					fmt.Sprintf(`
-						CREATE TABLE %s (
-							%s VARCHAR(64),
-							%s VARCHAR(67),
-							%s VARCHAR(%d),
-							%s VARCHAR(%d)
-						);
-						CREATE INDEX ON %s (%s, %s);
-						
-						GRANT ALL ON %s TO PUBLIC;
-						ALTER TABLE %s ENABLE ROW LEVEL SECURITY;
-					`,
-						sqlCustomLabelsTableName,
-						sqlCustomLabelsTableReleaseKeyColumn,
-						sqlCustomLabelsTableReleaseNamespaceColumn,
-						sqlCustomLabelsTableKeyColumn,
-						sqlCustomLabelsTableKeyMaxLength,
-						sqlCustomLabelsTableValueColumn,
-						sqlCustomLabelsTableValueMaxLength,
-						sqlCustomLabelsTableName,
-						sqlCustomLabelsTableReleaseKeyColumn,
-						sqlCustomLabelsTableReleaseNamespaceColumn,
-						sqlCustomLabelsTableName,
-						sqlCustomLabelsTableName,
-					),
-				},
-				Down: []string{
-					fmt.Sprintf(`
-						DELETE TABLE %s;
-					`, sqlCustomLabelsTableName),
-				},
+		},
+		{
+			Id: "release_locks",
+			Up: []string{
+				fmt.Sprintf(`
+					CREATE TABLE %s (
+						%s VARCHAR(64) NOT NULL,
+						%s VARCHAR(67) NOT NULL,
+						%s VARCHAR(256) NOT NULL,
+						%s VARCHAR(64) NOT NULL,
+						%s INTEGER NOT NULL,
+						%s INTEGER NOT NULL,
+						%s INTEGER NOT NULL,
+						PRIMARY KEY(%s, %s)
+					);
+
+					GRANT ALL ON %s TO PUBLIC;
+					ALTER TABLE %s ENABLE ROW LEVEL SECURITY;
+				`,
+					sqlLockTableName,
+					sqlLockTableNameColumn,
+					sqlLockTableNamespaceColumn,
+					sqlLockTableHolderColumn,
+					sqlLockTableOperationColumn,
+					sqlLockTableAcquiredAtColumn,
+					sqlLockTableRenewedAtColumn,
+					sqlLockTableDurationColumn,
+					sqlLockTableNameColumn,
+					sqlLockTableNamespaceColumn,
+					sqlLockTableName,
+					sqlLockTableName,
+				),
+			},
+			Down: []string{
+				fmt.Sprintf(`
+					DROP TABLE %s;
+				`, sqlLockTableName),
 			},
 		},
 	}
+}
+
+func (s *SQL) ensureDBSetup() error {
+	migrations := &migrate.MemoryMigrationSource{
+		Migrations: s.dialect.migrations(),
+	}
 
 	// Check that init migration already applied
 	if s.checkAlreadyApplied(migrations.Migrations) {
@@ -242,7 +316,7 @@ func (s *SQL) ensureDBSetup() error {
 	}
 
 	// Populate the database with the relations we need if they don't exist yet
-	_, err := migrate.Exec(s.db.DB, postgreSQLDialect, migrations, migrate.Up)
+	_, err := migrate.Exec(s.db.DB, s.dialect.migrationDialect(), migrations, migrate.Up)
 	return err
 }
 
@@ -276,16 +350,21 @@ type SQLReleaseCustomLabelWrapper struct {
 	Value            string `db:"value"`
 }
 
-// NewSQL initializes a new sql driver.
+// NewSQL initializes a new sql driver backed by PostgreSQL.
 func NewSQL(connectionString string, namespace string) (*SQL, error) {
-	db, err := sqlx.Connect(postgreSQLDialect, connectionString)
+	return newSQL(postgresDialect{}, connectionString, namespace)
+}
+
+func newSQL(dialect sqlDialect, connectionString string, namespace string) (*SQL, error) {
+	db, err := sqlx.Connect(dialect.driverName(), dialect.dataSourceName(connectionString))
 	if err != nil {
 		return nil, err
 	}
 
 	driver := &SQL{
 		db:               db,
-		statementBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		dialect:          dialect,
+		statementBuilder: sq.StatementBuilder.PlaceholderFormat(dialect.placeholderFormat()),
 	}
 
 	if err := driver.ensureDBSetup(); err != nil {
@@ -0,0 +1,247 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v4/pkg/storage/driver"
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var _ Locker = (*SQL)(nil)
+
+const sqlLockTableName = "release_locks_v1"
+
+const (
+	sqlLockTableNameColumn       = "name"
+	sqlLockTableNamespaceColumn  = "namespace"
+	sqlLockTableHolderColumn     = "holder"
+	sqlLockTableOperationColumn  = "operation"
+	sqlLockTableAcquiredAtColumn = "acquiredAt"
+	sqlLockTableRenewedAtColumn  = "renewedAt"
+	sqlLockTableDurationColumn   = "durationSeconds"
+)
+
+// sqlLockRecord describes how release locks are stored in an SQL database.
+type sqlLockRecord struct {
+	Name            string `db:"name"`
+	Namespace       string `db:"namespace"`
+	Holder          string `db:"holder"`
+	Operation       string `db:"operation"`
+	AcquiredAt      int64  `db:"acquiredAt"`
+	RenewedAt       int64  `db:"renewedAt"`
+	DurationSeconds int64  `db:"durationSeconds"`
+}
+
+func (r *sqlLockRecord) lockInfo() *LockInfo {
+	return &LockInfo{
+		Name:      r.Name,
+		Holder:    r.Holder,
+		Operation: r.Operation,
+		Acquired:  time.Unix(r.AcquiredAt, 0),
+		Renewed:   time.Unix(r.RenewedAt, 0),
+		Duration:  time.Duration(r.DurationSeconds) * time.Second,
+	}
+}
+
+// lockNamespace returns the namespace release locks are stored under.
+func (s *SQL) lockNamespace() string {
+	if s.namespace == "" {
+		return defaultNamespace
+	}
+	return s.namespace
+}
+
+// Lock acquires the lock of a release by inserting or taking over its row in
+// the locks table. The row is locked for the duration of the transaction.
+func (s *SQL) Lock(lock *LockInfo) error {
+	now := time.Now()
+	lock.Acquired = now
+	lock.Renewed = now
+
+	transaction, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = transaction.Rollback()
+		}
+	}()
+
+	selectQuery, args, err := s.statementBuilder.
+		Select(sqlLockTableNameColumn, sqlLockTableNamespaceColumn, sqlLockTableHolderColumn, sqlLockTableOperationColumn,
+			sqlLockTableAcquiredAtColumn, sqlLockTableRenewedAtColumn, sqlLockTableDurationColumn).
+		From(sqlLockTableName).
+		Where(sq.Eq{sqlLockTableNameColumn: lock.Name}).
+		Where(sq.Eq{sqlLockTableNamespaceColumn: s.lockNamespace()}).
+		Suffix(s.dialect.lockRowSuffix()).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	var record sqlLockRecord
+	switch err = transaction.Get(&record, selectQuery, args...); {
+	case errors.Is(err, sql.ErrNoRows):
+		var insertQuery string
+		insertQuery, args, err = s.statementBuilder.
+			Insert(sqlLockTableName).
+			Columns(sqlLockTableNameColumn, sqlLockTableNamespaceColumn, sqlLockTableHolderColumn, sqlLockTableOperationColumn,
+				sqlLockTableAcquiredAtColumn, sqlLockTableRenewedAtColumn, sqlLockTableDurationColumn).
+			Values(lock.Name, s.lockNamespace(), lock.Holder, lock.Operation, now.Unix(), now.Unix(), int64(lock.Duration/time.Second)).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = transaction.Exec(insertQuery, args...); err != nil {
+			// Another holder inserted the row concurrently
+			slog.Debug("failed to insert release lock", "name", lock.Name, slog.Any("error", err))
+			_ = transaction.Rollback()
+			if current, gerr := s.GetLock(lock.Name); gerr == nil && current.Holder != lock.Holder {
+				return &LockedError{Lock: current}
+			}
+			return fmt.Errorf("lock: failed to insert lock of %q: %w", lock.Name, err)
+		}
+	case err != nil:
+		return fmt.Errorf("lock: failed to get lock of %q: %w", lock.Name, err)
+	default:
+		if current := record.lockInfo(); current.Holder != lock.Holder && !current.Expired(now) {
+			err = &LockedError{Lock: current}
+			return err
+		}
+		var updateQuery string
+		updateQuery, args, err = s.statementBuilder.
+			Update(sqlLockTableName).
+			Set(sqlLockTableHolderColumn, lock.Holder).
+			Set(sqlLockTableOperationColumn, lock.Operation).
+			Set(sqlLockTableAcquiredAtColumn, now.Unix()).
+			Set(sqlLockTableRenewedAtColumn, now.Unix()).
+			Set(sqlLockTableDurationColumn, int64(lock.Duration/time.Second)).
+			Where(sq.Eq{sqlLockTableNameColumn: lock.Name}).
+			Where(sq.Eq{sqlLockTableNamespaceColumn: s.lockNamespace()}).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = transaction.Exec(updateQuery, args...); err != nil {
+			return fmt.Errorf("lock: failed to take over lock of %q: %w", lock.Name, err)
+		}
+	}
+
+	if err = transaction.Commit(); err != nil {
+		return fmt.Errorf("lock: failed to commit lock of %q: %w", lock.Name, err)
+	}
+	return nil
+}
+
+// Renew extends the lock of a release held by holder.
+func (s *SQL) Renew(name, holder string) error {
+	query, args, err := s.statementBuilder.
+		Update(sqlLockTableName).
+		Set(sqlLockTableRenewedAtColumn, time.Now().Unix()).
+		Where(sq.Eq{sqlLockTableNameColumn: name}).
+		Where(sq.Eq{sqlLockTableNamespaceColumn: s.lockNamespace()}).
+		Where(sq.Eq{sqlLockTableHolderColumn: holder}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	n, err := s.execRowsAffected(query, args...)
+	if err != nil {
+		return fmt.Errorf("renew: failed to renew lock of %q: %w", name, err)
+	}
+	if n == 0 {
+		current, err := s.GetLock(name)
+		if err != nil {
+			return fmt.Errorf("renew: lock of %q was lost: %w", name, err)
+		}
+		return &LockedError{Lock: current}
+	}
+	return nil
+}
+
+// Unlock deletes the lock of a release if it is held by holder.
+func (s *SQL) Unlock(name, holder string) error {
+	query, args, err := s.statementBuilder.
+		Delete(sqlLockTableName).
+		Where(sq.Eq{sqlLockTableNameColumn: name}).
+		Where(sq.Eq{sqlLockTableNamespaceColumn: s.lockNamespace()}).
+		Where(sq.Eq{sqlLockTableHolderColumn: holder}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := s.execRowsAffected(query, args...); err != nil {
+		return fmt.Errorf("unlock: failed to delete lock of %q: %w", name, err)
+	}
+	return nil
+}
+
+// GetLock returns the lock of a release.
+func (s *SQL) GetLock(name string) (*LockInfo, error) {
+	query, args, err := s.statementBuilder.
+		Select(sqlLockTableNameColumn, sqlLockTableNamespaceColumn, sqlLockTableHolderColumn, sqlLockTableOperationColumn,
+			sqlLockTableAcquiredAtColumn, sqlLockTableRenewedAtColumn, sqlLockTableDurationColumn).
+		From(sqlLockTableName).
+		Where(sq.Eq{sqlLockTableNameColumn: name}).
+		Where(sq.Eq{sqlLockTableNamespaceColumn: s.lockNamespace()}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var record sqlLockRecord
+	if err := s.db.Get(&record, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotLocked
+		}
+		return nil, fmt.Errorf("get lock: failed to get lock of %q: %w", name, err)
+	}
+	return record.lockInfo(), nil
+}
+
+// ForceUnlock deletes the lock of a release regardless of its holder.
+func (s *SQL) ForceUnlock(name string) error {
+	query, args, err := s.statementBuilder.
+		Delete(sqlLockTableName).
+		Where(sq.Eq{sqlLockTableNameColumn: name}).
+		Where(sq.Eq{sqlLockTableNamespaceColumn: s.lockNamespace()}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	n, err := s.execRowsAffected(query, args...)
+	if err != nil {
+		return fmt.Errorf("force unlock: failed to delete lock of %q: %w", name, err)
+	}
+	if n == 0 {
+		return ErrNotLocked
+	}
+	return nil
+}
+
+func (s *SQL) execRowsAffected(query string, args ...interface{}) (int64, error) {
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,190 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v4/pkg/storage/driver"
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	migrate "github.com/rubenv/sql-migrate"
+
+	// Import the pure Go sqlite driver so helm can be built without cgo
+	_ "modernc.org/sqlite"
+)
+
+const (
+	sqliteDriverName       = "sqlite"
+	sqliteMigrationDialect = "sqlite3"
+)
+
+// sqliteDefaultPragmas are applied to connections unless the connection
+// string already sets pragmas. Several helm processes may share a database
+// file, so writers wait for locks instead of failing immediately.
+var sqliteDefaultPragmas = []string{
+	"_pragma=busy_timeout(5000)",
+	"_pragma=journal_mode(WAL)",
+	"_pragma=foreign_keys(1)",
+}
+
+// NewSQLite initializes a new sql driver backed by an embedded SQLite
+// database. The connection string is the path of the database file, optionally
+// followed by driver parameters, e.g. "/var/lib/helm/releases.db?_pragma=...".
+func NewSQLite(connectionString string, namespace string) (*SQL, error) {
+	return newSQL(sqliteDialect{}, connectionString, namespace)
+}
+
+// sqliteDialect stores releases in an embedded SQLite database.
+type sqliteDialect struct{}
+
+func (sqliteDialect) driverName() string { return sqliteDriverName }
+
+func (sqliteDialect) migrationDialect() string { return sqliteMigrationDialect }
+
+func (sqliteDialect) placeholderFormat() sq.PlaceholderFormat { return sq.Question }
+
+// lockRowSuffix is empty as SQLite serializes write transactions on the
+// whole database.
+func (sqliteDialect) lockRowSuffix() string { return "" }
+
+func (sqliteDialect) dataSourceName(connectionString string) string {
+	if strings.Contains(connectionString, "_pragma=") {
+		return connectionString
+	}
+	sep := "?"
+	if strings.Contains(connectionString, "?") {
+		sep = "&"
+	}
+	return connectionString + sep + strings.Join(sqliteDefaultPragmas, "&")
+}
+
+// migrations mirrors the PostgreSQL schema. SQLite has no GRANT or row level
+// security, and requires every index to be named.
+func (sqliteDialect) migrations() []*migrate.Migration {
+	return []*migrate.Migration{
+		{
+			Id: "init",
+			Up: []string{
+				fmt.Sprintf(`
+					CREATE TABLE %s (
+						%s VARCHAR(90),
+						%s VARCHAR(64) NOT NULL,
+						%s TEXT NOT NULL,
+						%s VARCHAR(64) NOT NULL,
+						%s VARCHAR(64) NOT NULL,
+						%s INTEGER NOT NULL,
+						%s TEXT NOT NULL,
+						%s TEXT NOT NULL,
+						%s INTEGER NOT NULL,
+						%s INTEGER NOT NULL DEFAULT 0,
+						PRIMARY KEY(%s, %s)
+					);
+				`,
+					sqlReleaseTableName,
+					sqlReleaseTableKeyColumn,
+					sqlReleaseTableTypeColumn,
+					sqlReleaseTableBodyColumn,
+					sqlReleaseTableNameColumn,
+					sqlReleaseTableNamespaceColumn,
+					sqlReleaseTableVersionColumn,
+					sqlReleaseTableStatusColumn,
+					sqlReleaseTableOwnerColumn,
+					sqlReleaseTableCreatedAtColumn,
+					sqlReleaseTableModifiedAtColumn,
+					sqlReleaseTableKeyColumn,
+					sqlReleaseTableNamespaceColumn,
+				),
+				sqliteCreateIndex(sqlReleaseTableName, sqlReleaseTableKeyColumn, sqlReleaseTableNamespaceColumn),
+				sqliteCreateIndex(sqlReleaseTableName, sqlReleaseTableVersionColumn),
+				sqliteCreateIndex(sqlReleaseTableName, sqlReleaseTableStatusColumn),
+				sqliteCreateIndex(sqlReleaseTableName, sqlReleaseTableOwnerColumn),
+				sqliteCreateIndex(sqlReleaseTableName, sqlReleaseTableCreatedAtColumn),
+				sqliteCreateIndex(sqlReleaseTableName, sqlReleaseTableModifiedAtColumn),
+			},
+			Down: []string{
+				fmt.Sprintf(`
+					DROP TABLE %s;
+				`, sqlReleaseTableName),
+			},
+		},
+		{
+			Id: "custom_labels",
+			Up: []string{
+				fmt.Sprintf(`
+					CREATE TABLE %s (
+						%s VARCHAR(64),
+						%s VARCHAR(67),
+						%s VARCHAR(%d),
+						%s VARCHAR(%d)
+					);
+				`,
+					sqlCustomLabelsTableName,
+					sqlCustomLabelsTableReleaseKeyColumn,
+					sqlCustomLabelsTableReleaseNamespaceColumn,
+					sqlCustomLabelsTableKeyColumn,
+					sqlCustomLabelsTableKeyMaxLength,
+					sqlCustomLabelsTableValueColumn,
+					sqlCustomLabelsTableValueMaxLength,
+				),
+				sqliteCreateIndex(sqlCustomLabelsTableName, sqlCustomLabelsTableReleaseKeyColumn, sqlCustomLabelsTableReleaseNamespaceColumn),
+			},
+			Down: []string{
+				fmt.Sprintf(`
+					DROP TABLE %s;
+				`, sqlCustomLabelsTableName),
+			},
+		},
+		{
+			Id: "release_locks",
+			Up: []string{
+				fmt.Sprintf(`
+					CREATE TABLE %s (
+						%s VARCHAR(64) NOT NULL,
+						%s VARCHAR(67) NOT NULL,
+						%s VARCHAR(256) NOT NULL,
+						%s VARCHAR(64) NOT NULL,
+						%s INTEGER NOT NULL,
+						%s INTEGER NOT NULL,
+						%s INTEGER NOT NULL,
+						PRIMARY KEY(%s, %s)
+					);
+				`,
+					sqlLockTableName,
+					sqlLockTableNameColumn,
+					sqlLockTableNamespaceColumn,
+					sqlLockTableHolderColumn,
+					sqlLockTableOperationColumn,
+					sqlLockTableAcquiredAtColumn,
+					sqlLockTableRenewedAtColumn,
+					sqlLockTableDurationColumn,
+					sqlLockTableNameColumn,
+					sqlLockTableNamespaceColumn,
+				),
+			},
+			Down: []string{
+				fmt.Sprintf(`
+					DROP TABLE %s;
+				`, sqlLockTableName),
+			},
+		},
+	}
+}
+
+func sqliteCreateIndex(table string, columns ...string) string {
+	return fmt.Sprintf("CREATE INDEX %s_%s_idx ON %s (%s);",
+		table, strings.Join(columns, "_"), table, strings.Join(columns, ", "))
+}
@@ -0,0 +1,131 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"helm.sh/helm/v4/pkg/release"
+	"helm.sh/helm/v4/pkg/release/common"
+	rspb "helm.sh/helm/v4/pkg/release/v1"
+)
+
+func newTestFixtureSQLite(t *testing.T) (*SQL, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "releases.db")
+	d, err := NewSQLite(path, "default")
+	if err != nil {
+		t.Fatalf("failed to open sqlite driver: %v", err)
+	}
+	t.Cleanup(func() { d.db.Close() })
+	return d, path
+}
+
+func TestSQLiteDataSourceName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/tmp/r.db", "/tmp/r.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"},
+		{"/tmp/r.db?mode=rw", "/tmp/r.db?mode=rw&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"},
+		{"/tmp/r.db?_pragma=busy_timeout(100)", "/tmp/r.db?_pragma=busy_timeout(100)"},
+	}
+	for _, tt := range tests {
+		if got := (sqliteDialect{}).dataSourceName(tt.in); got != tt.want {
+			t.Errorf("dataSourceName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteCRUD(t *testing.T) {
+	sqlDriver, _ := newTestFixtureSQLite(t)
+
+	rel := releaseStub("smug-pigeon", 1, "default", common.StatusDeployed)
+	key := testKey(rel.Name, rel.Version)
+
+	if err := sqlDriver.Create(key, rel); err != nil {
+		t.Fatalf("failed to create release: %v", err)
+	}
+	if err := sqlDriver.Create(key, rel); !errors.Is(err, ErrReleaseExists) {
+		t.Fatalf("expected ErrReleaseExists, got %v", err)
+	}
+
+	got, err := sqlDriver.Get(key)
+	if err != nil {
+		t.Fatalf("failed to get release: %v", err)
+	}
+	gotRel := got.(*rspb.Release)
+	if gotRel.Name != rel.Name || gotRel.Labels["key1"] != "val1" {
+		t.Errorf("unexpected release %s with labels %v", gotRel.Name, gotRel.Labels)
+	}
+
+	rel.Info.Status = common.StatusSuperseded
+	if err := sqlDriver.Update(key, rel); err != nil {
+		t.Fatalf("failed to update release: %v", err)
+	}
+
+	rel2 := releaseStub("smug-pigeon", 2, "default", common.StatusDeployed)
+	if err := sqlDriver.Create(testKey(rel2.Name, rel2.Version), rel2); err != nil {
+		t.Fatalf("failed to create release: %v", err)
+	}
+
+	rels, err := sqlDriver.Query(map[string]string{"name": "smug-pigeon", "status": "superseded"})
+	if err != nil {
+		t.Fatalf("failed to query releases: %v", err)
+	}
+	if len(rels) != 1 || rels[0].(*rspb.Release).Version != 1 {
+		t.Errorf("expected revision 1 to be superseded, got %v", rels)
+	}
+
+	all, err := sqlDriver.List(func(_ release.Releaser) bool { return true })
+	if err != nil {
+		t.Fatalf("failed to list releases: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 releases, got %d", len(all))
+	}
+
+	deleted, err := sqlDriver.Delete(key)
+	if err != nil {
+		t.Fatalf("failed to delete release: %v", err)
+	}
+	if deleted.(*rspb.Release).Labels["key1"] != "val1" {
+		t.Errorf("expected deleted release to carry its custom labels, got %v", deleted.(*rspb.Release).Labels)
+	}
+	if _, err := sqlDriver.Get(key); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("expected ErrReleaseNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteReopen(t *testing.T) {
+	sqlDriver, path := newTestFixtureSQLite(t)
+
+	rel := releaseStub("smug-pigeon", 1, "default", common.StatusDeployed)
+	if err := sqlDriver.Create(testKey(rel.Name, rel.Version), rel); err != nil {
+		t.Fatalf("failed to create release: %v", err)
+	}
+	sqlDriver.db.Close()
+
+	// Opening an existing database must not apply the migrations again
+	reopened, err := NewSQLite(path, "default")
+	if err != nil {
+		t.Fatalf("failed to reopen sqlite driver: %v", err)
+	}
+	defer reopened.db.Close()
+
+	if _, err := reopened.Get(testKey(rel.Name, rel.Version)); err != nil {
+		t.Errorf("failed to get release after reopening: %v", err)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"slices"
 
@@ -36,6 +37,13 @@ var systemLabels = []string{"name", "owner", "status", "version", "createdAt", "
 // encodeRelease encodes a release returning a base64 encoded
 // gzipped string representation, or error.
 func encodeRelease(rls *rspb.Release) (string, error) {
+	return encodeReleaseEncrypted(rls, nil, "")
+}
+
+// encodeReleaseEncrypted is like encodeRelease, but encrypts the gzipped
+// release stored under key before base64 encoding it. A nil enc disables
+// encryption.
+func encodeReleaseEncrypted(rls *rspb.Release, enc *Encryption, key string) (string, error) {
 	b, err := json.Marshal(rls)
 	if err != nil {
 		return "", err
@@ -50,19 +58,33 @@ func encodeRelease(rls *rspb.Release) (string, error) {
 	}
 	w.Close()
 
-	return b64.EncodeToString(buf.Bytes()), nil
+	sealed, err := enc.seal(buf.Bytes(), key)
+	if err != nil {
+		return "", fmt.Errorf("unable to encrypt release: %w", err)
+	}
+	return b64.EncodeToString(sealed), nil
 }
 
 // decodeRelease decodes the bytes of data into a release
 // type. Data must contain a base64 encoded gzipped string of a
 // valid release, otherwise an error is returned.
 func decodeRelease(data string) (*rspb.Release, error) {
+	return decodeReleaseEncrypted(data, nil, "")
+}
+
+// decodeReleaseEncrypted is like decodeRelease, but also reads records
+// encrypted by encodeReleaseEncrypted for the same key using the keys of enc.
+func decodeReleaseEncrypted(data string, enc *Encryption, key string) (*rspb.Release, error) {
 	// base64 decode string
 	b, err := b64.DecodeString(data)
 	if err != nil {
 		return nil, err
 	}
 
+	if b, err = enc.open(b, key); err != nil {
+		return nil, &decryptionError{err: err}
+	}
+
 	// For backwards compatibility with releases that were stored before
 	// compression was introduced we skip decompression if the
 	// gzip magic header is not found